@@ -1,17 +1,26 @@
 package handler
 
 import (
+	"context"
 	"net/http"
-	
+	"strconv"
+	"time"
+
 	"github.com/budget-buddy/api/lib"
 )
 
-// Handler handles analytics requests
-func Handler(w http.ResponseWriter, r *http.Request) {
+func init() {
+	lib.RegisterAnomalyHook(lib.LogAnomalyAlerts)
+}
+
+// Analytics handles analytics requests
+func Analytics(w http.ResponseWriter, r *http.Request) {
 	config := lib.Config{
-		RequireAuth:    true,
-		AllowedMethods: []string{"GET"},
-		EnableCORS:     true,
+		RequireAuth:     true,
+		AllowedMethods:  []string{"GET"},
+		CORS:            lib.DefaultCORS(),
+		WorkspaceScoped: true,
+		RateLimit:       &lib.RateLimitPolicy{Name: "analytics", Limit: 30, Window: time.Minute, Algorithm: lib.TokenBucket},
 	}
 
 	handler := lib.CreateHandler(analyticsHandler, config)
@@ -21,33 +30,43 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 func analyticsHandler(w http.ResponseWriter, r *http.Request) {
 	user, ok := lib.GetUserFromContext(r)
 	if !ok {
-		lib.ErrorResponse(w, "Unauthorized", http.StatusUnauthorized, nil)
+		lib.WriteError(w, lib.UnauthorizedError())
 		return
 	}
 
 	analyticsType := lib.GetQueryParam(r, "type", "summary")
-	startDate := lib.GetQueryParam(r, "start_date", "")
-	endDate := lib.GetQueryParam(r, "end_date", "")
-
-	_ = startDate
-	_ = endDate
 
 	switch analyticsType {
-	case "summary":
-		handleSummaryAnalytics(w, user)
-	case "category":
-		handleCategoryAnalytics(w, user)
-	case "trend":
-		handleTrendAnalytics(w, user)
+	case "summary", "category", "trend":
+		var params lib.AnalyticsRangeParams
+		if !lib.BindQuery(w, r, &params) {
+			return
+		}
+		if errs := lib.ValidateDateRange("start_date", params.StartDate, "end_date", params.EndDate); len(errs) > 0 {
+			lib.WriteError(w, lib.NewError(http.StatusBadRequest, lib.CodeValidation, "Invalid query parameters", errs))
+			return
+		}
+		switch analyticsType {
+		case "summary":
+			handleSummaryAnalytics(w, user, params)
+		case "category":
+			handleCategoryAnalytics(w, user, params)
+		default:
+			handleTrendAnalytics(w, user, params)
+		}
+	case "forecast":
+		handleForecastAnalytics(w, r, user)
+	case "anomalies":
+		handleAnomalyAnalytics(w, r, user)
 	default:
-		lib.ErrorResponse(w, "Invalid analytics type", http.StatusBadRequest, map[string]interface{}{
-			"allowed": []string{"summary", "category", "trend"},
-		})
+		lib.WriteError(w, lib.BadRequestError("Invalid analytics type", map[string]interface{}{
+			"allowed": []string{"summary", "category", "trend", "forecast", "anomalies"},
+		}))
 	}
 }
 
-func handleSummaryAnalytics(w http.ResponseWriter, user *lib.User) {
-	// TODO: Query database and calculate
+func handleSummaryAnalytics(w http.ResponseWriter, user *lib.User, params lib.AnalyticsRangeParams) {
+	// TODO: Query database between params.StartDate and params.EndDate and calculate
 	summary := lib.AnalyticsSummary{
 		TotalIncome:      5000.0,
 		TotalExpenses:    3000.0,
@@ -56,13 +75,13 @@ func handleSummaryAnalytics(w http.ResponseWriter, user *lib.User) {
 		TransactionCount: 150,
 	}
 
-	lib.SuccessResponse(w, map[string]interface{}{
-		"summary": summary,
+	lib.SuccessResponse(w, lib.SummaryAnalyticsResponse{
+		Summary: summary,
 	}, http.StatusOK)
 }
 
-func handleCategoryAnalytics(w http.ResponseWriter, user *lib.User) {
-	// TODO: Query database and aggregate
+func handleCategoryAnalytics(w http.ResponseWriter, user *lib.User, params lib.AnalyticsRangeParams) {
+	// TODO: Query database between params.StartDate and params.EndDate and aggregate
 	categories := []lib.CategoryAnalytics{
 		{
 			Category:     "Groceries",
@@ -78,13 +97,13 @@ func handleCategoryAnalytics(w http.ResponseWriter, user *lib.User) {
 		},
 	}
 
-	lib.SuccessResponse(w, map[string]interface{}{
-		"categories": categories,
+	lib.SuccessResponse(w, lib.CategoryAnalyticsResponse{
+		Categories: categories,
 	}, http.StatusOK)
 }
 
-func handleTrendAnalytics(w http.ResponseWriter, user *lib.User) {
-	// TODO: Query database and aggregate by month
+func handleTrendAnalytics(w http.ResponseWriter, user *lib.User, params lib.AnalyticsRangeParams) {
+	// TODO: Query database between params.StartDate and params.EndDate and aggregate by month
 	trend := []lib.TrendData{
 		{
 			Month:    "2024-01",
@@ -100,8 +119,123 @@ func handleTrendAnalytics(w http.ResponseWriter, user *lib.User) {
 		},
 	}
 
-	lib.SuccessResponse(w, map[string]interface{}{
-		"trend": trend,
+	lib.SuccessResponse(w, lib.TrendAnalyticsResponse{
+		Trend: trend,
+	}, http.StatusOK)
+}
+
+func handleForecastAnalytics(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	// TODO: Query database for the current account balance instead of starting_balance
+	var params lib.ForecastParams
+	if !lib.BindQuery(w, r, &params) {
+		return
+	}
+
+	now := time.Now().UTC()
+	history, err := getTransactionHistory(r.Context(), user, now.AddDate(0, -6, 0), now)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to load transactions", err))
+		return
+	}
+	_, span := lib.StartSpan(r.Context(), "analytics.forecast", lib.SpanKindInternal)
+	span.SetAttribute("transactions", len(history))
+	forecast, err := lib.ForecastCashFlow(
+		history,
+		getRecurringTransactions(user),
+		lib.ForecastOptions{
+			Start:           now,
+			Days:            params.Days,
+			StartingBalance: params.StartingBalance,
+			Threshold:       params.Threshold,
+			ConfidenceLevel: params.Confidence,
+		},
+	)
+	span.End()
+	if err != nil {
+		lib.WriteError(w, lib.BadRequestError("Unable to compute forecast", map[string]string{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.ForecastAnalyticsResponse{
+		Forecast: forecast,
+	}, http.StatusOK)
+}
+
+func handleAnomalyAnalytics(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var params lib.AnomalyParams
+	if !lib.BindQuery(w, r, &params) {
+		return
+	}
+
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -params.Days)
+	history, err := getTransactionHistory(r.Context(), user, now.AddDate(0, -12, 0), now)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to load transactions", err))
+		return
+	}
+	_, span := lib.StartSpan(r.Context(), "analytics.anomalies", lib.SpanKindInternal)
+	span.SetAttribute("transactions", len(history))
+	anomalies := lib.DetectAnomalies(history, lib.AnomalyOptions{
+		Since:      since,
+		AsOf:       now,
+		ZThreshold: params.ZThreshold,
+	})
+	span.SetAttribute("anomalies", len(anomalies))
+	span.End()
+
+	lib.NotifyAnomalyHooks(user, anomalies)
+
+	lib.SuccessResponse(w, lib.AnomalyAnalyticsResponse{
+		Anomalies: anomalies,
+		Window: lib.DateRange{
+			Start: since.Format("2006-01-02"),
+			End:   now.Format("2006-01-02"),
+		},
 	}, http.StatusOK)
 }
 
+func getTransactionHistory(ctx context.Context, user *lib.User, from, to time.Time) ([]lib.Transaction, error) {
+	// TODO: Query database
+	// For now, generate weekly groceries, a monthly subscription and dining out
+	var transactions []lib.Transaction
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		day := d.YearDay()
+		if d.Weekday() == time.Saturday {
+			transactions = append(transactions, lib.Transaction{
+				ID: "trans-g" + strconv.Itoa(day), UserID: user.ID, Amount: 80 + float64(day%5)*10,
+				Category: "Groceries", Type: "expense", Merchant: "Fresh Market", Date: d,
+			})
+		}
+		if d.Day() == 12 {
+			transactions = append(transactions, lib.Transaction{
+				ID: "trans-s" + strconv.Itoa(day), UserID: user.ID, Amount: 15.49,
+				Category: "Entertainment", Type: "expense", Merchant: "Netflix", Date: d,
+			})
+		}
+		if day%4 == 0 {
+			transactions = append(transactions, lib.Transaction{
+				ID: "trans-d" + strconv.Itoa(day), UserID: user.ID, Amount: 12 + float64(day%7)*4,
+				Category: "Dining", Type: "expense", Date: d,
+			})
+		}
+	}
+	return lib.ExcludeTrashedTransactions(ctx, transactions, false)
+}
+
+func getRecurringTransactions(user *lib.User) []lib.RecurringTransaction {
+	// TODO: Query database
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []lib.RecurringTransaction{
+		{
+			ID: "recurring-1", UserID: user.ID, Amount: 5000.0, Category: "Salary",
+			Type: "income", Frequency: lib.FrequencyMonthly, StartDate: start, Active: true,
+		},
+		{
+			ID: "recurring-2", UserID: user.ID, Amount: 1500.0, Category: "Rent",
+			Type: "expense", Frequency: lib.FrequencyMonthly, StartDate: start, Active: true,
+		},
+	}
+}
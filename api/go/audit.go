@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// Audit handles querying the audit log of the caller's own changes
+func Audit(w http.ResponseWriter, r *http.Request) {
+	config := lib.Config{
+		RequireAuth:    true,
+		AllowedMethods: []string{"GET"},
+		CORS:           lib.DefaultCORS(),
+		RateLimit:      lib.APIRateLimit(),
+	}
+
+	handler := lib.CreateHandler(auditHandler, config)
+	handler(w, r)
+}
+
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := lib.GetUserFromContext(r)
+	if !ok {
+		lib.WriteError(w, lib.UnauthorizedError())
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		handleGetAuditLog(w, r, user)
+	default:
+		lib.WriteError(w, lib.MethodNotAllowedError(nil))
+	}
+}
+
+func handleGetAuditLog(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var params lib.AuditLogParams
+	if !lib.BindQuery(w, r, &params) {
+		return
+	}
+
+	// Users only ever see the changes they made themselves
+	records, total, err := lib.AuditLog.List(r.Context(), lib.AuditQuery{
+		ActorID:    user.ID,
+		Resource:   params.Resource,
+		ResourceID: params.ResourceID,
+		Action:     params.Action,
+		Since:      parseAuditTime(params.Since, false),
+		Until:      parseAuditTime(params.Until, true),
+		Limit:      params.Limit,
+		Offset:     params.Offset,
+	})
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to load audit log", err))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.AuditLogResponse{
+		Records: records,
+		Pagination: lib.Pagination{
+			Total:   total,
+			Limit:   params.Limit,
+			Offset:  params.Offset,
+			HasMore: params.Offset+len(records) < total,
+		},
+	}, http.StatusOK)
+}
+
+// parseAuditTime parses an already validated YYYY-MM-DD date or RFC 3339
+// timestamp. A bare date used as an upper bound covers the whole day.
+func parseAuditTime(value string, endOfDay bool) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			return date.Add(24*time.Hour - time.Nanosecond)
+		}
+		return date
+	}
+	t, _ := time.Parse(time.RFC3339, value)
+	return t
+}
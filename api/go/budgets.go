@@ -1,17 +1,25 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"net/http"
-	
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/budget-buddy/api/lib"
 )
 
-// Handler handles budget CRUD operations
-func Handler(w http.ResponseWriter, r *http.Request) {
+// Budgets handles budget CRUD operations
+func Budgets(w http.ResponseWriter, r *http.Request) {
 	config := lib.Config{
-		RequireAuth:    true,
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
-		EnableCORS:     true,
+		RequireAuth:     true,
+		AllowedMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		CORS:            lib.DefaultCORS().AllowHeaders("If-Match", "Idempotency-Key").ExposeHeaders("ETag", "Idempotent-Replayed"),
+		WorkspaceScoped: true,
+		Idempotent:      true,
+		RateLimit:       lib.APIRateLimit(),
 	}
 
 	handler := lib.CreateHandler(budgetHandler, config)
@@ -21,7 +29,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 func budgetHandler(w http.ResponseWriter, r *http.Request) {
 	user, ok := lib.GetUserFromContext(r)
 	if !ok {
-		lib.ErrorResponse(w, "Unauthorized", http.StatusUnauthorized, nil)
+		lib.WriteError(w, lib.UnauthorizedError())
 		return
 	}
 
@@ -29,113 +37,486 @@ func budgetHandler(w http.ResponseWriter, r *http.Request) {
 	case "GET":
 		handleGetBudgets(w, r, user)
 	case "POST":
+		if lib.GetQueryParam(r, "action", "") == "accept" {
+			handleCreateBudgets(w, r, user)
+			return
+		}
 		handleCreateBudget(w, r, user)
 	case "PUT":
 		handleUpdateBudget(w, r, user)
+	case "PATCH":
+		handlePatchBudget(w, r, user)
 	case "DELETE":
 		handleDeleteBudget(w, r, user)
 	default:
-		lib.ErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed, nil)
+		lib.WriteError(w, lib.MethodNotAllowedError(nil))
 	}
 }
 
 func handleGetBudgets(w http.ResponseWriter, r *http.Request, user *lib.User) {
-	period := lib.GetQueryParam(r, "period", "monthly")
+	switch lib.GetQueryParam(r, "view", "") {
+	case "history":
+		handleGetBudgetHistory(w, r, user)
+		return
+	case "proposal":
+		handleProposeBudgets(w, r, user)
+		return
+	}
 
-	// TODO: Query database
-	budgets := []map[string]interface{}{
-		{
-			"id":              "budget-1",
-			"user_id":         user.ID,
-			"category":        "Groceries",
-			"amount":          500.0,
-			"period":          period,
-			"alert_threshold": 80,
-			"created_at":      "2024-01-01T00:00:00Z",
-		},
+	if id := lib.GetQueryParam(r, "id", ""); id != "" {
+		handleGetBudget(w, r, user, id)
+		return
+	}
+
+	var params lib.BudgetListParams
+	if !lib.BindQuery(w, r, &params) {
+		return
+	}
+
+	now := time.Now().UTC()
+	transactions, err := getBudgetTransactions(r.Context(), user)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to load transactions", err))
+		return
+	}
+	kept, err := lib.ExcludeTrashedBudgets(r.Context(), getBudgets(user), params.IncludeDeleted)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to load trash", err))
+		return
+	}
+
+	budgets := []lib.BudgetStatus{}
+	for _, budget := range kept {
+		if budget.Period != params.Period {
+			continue
+		}
+		status, err := lib.CurrentBudgetStatus(budget, transactions, now)
+		if err != nil {
+			lib.WriteError(w, lib.InternalError("Unable to compute budget utilization", err))
+			return
+		}
+		budgets = append(budgets, status)
+	}
+
+	lib.SuccessResponse(w, lib.BudgetListResponse{
+		Budgets: budgets,
+	}, http.StatusOK)
+}
+
+func handleGetBudget(w http.ResponseWriter, r *http.Request, user *lib.User, id string) {
+	budget, err := findBudget(r.Context(), user, id)
+	if err != nil {
+		lib.WriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", lib.ETag(budget.UpdatedAt))
+	lib.SuccessResponse(w, lib.BudgetResponse{
+		Budget: *budget,
+	}, http.StatusOK)
+}
+
+func handleGetBudgetHistory(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var params lib.BudgetHistoryParams
+	if !lib.BindQuery(w, r, &params) {
+		return
+	}
+
+	budget, err := findBudget(r.Context(), user, params.ID)
+	if err != nil {
+		lib.WriteError(w, err)
+		return
+	}
+
+	transactions, err := getBudgetTransactions(r.Context(), user)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to load transactions", err))
+		return
+	}
+	_, span := lib.StartSpan(r.Context(), "budgets.history", lib.SpanKindInternal)
+	history, err := lib.ComputeBudgetHistory(*budget, transactions, time.Now().UTC())
+	span.End()
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Unable to compute budget history", err))
+		return
+	}
+
+	// Most recent periods first
+	if len(history) > params.Periods {
+		history = history[len(history)-params.Periods:]
+	}
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+
+	lib.SuccessResponse(w, lib.BudgetHistoryResponse{
+		Budget:  *budget,
+		History: history,
+	}, http.StatusOK)
+}
+
+func handleProposeBudgets(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var params lib.BudgetProposalParams
+	if !lib.BindQuery(w, r, &params) {
+		return
 	}
 
-	lib.SuccessResponse(w, map[string]interface{}{
-		"budgets": budgets,
+	transactions, err := getBudgetTransactions(r.Context(), user)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to load transactions", err))
+		return
+	}
+	_, span := lib.StartSpan(r.Context(), "budgets.proposal", lib.SpanKindInternal)
+	proposal, err := lib.ProposeBudgets(transactions, lib.BudgetProposalOptions{
+		AsOf:            time.Now().UTC(),
+		Months:          params.Months,
+		BufferPercent:   params.Buffer,
+		CategoryBuffers: params.CategoryBuffers,
+		Template:        params.Template,
+		MonthlyIncome:   params.Income,
+	})
+	span.End()
+	if err != nil {
+		lib.WriteError(w, lib.BadRequestError("Unable to propose budgets", map[string]string{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.BudgetProposalResponse{
+		Proposal: proposal,
 	}, http.StatusOK)
 }
 
 func handleCreateBudget(w http.ResponseWriter, r *http.Request, user *lib.User) {
 	var input lib.CreateBudgetInput
-	if err := lib.ParseJSONBody(r, &input); err != nil {
-		lib.ErrorResponse(w, "Invalid JSON body", http.StatusBadRequest, nil)
+	if !lib.BindJSON(w, r, &input) {
 		return
 	}
 
-	// Validate input
-	if input.Category == "" {
-		lib.ErrorResponse(w, "Category is required", http.StatusBadRequest, nil)
+	// TODO: Insert into database
+	budget := newBudget(r, user, input, "budget-new")
+	if errs := budgetDateErrors("", budget, true); len(errs) > 0 {
+		lib.WriteError(w, lib.ValidationError(errs))
 		return
 	}
 
-	if input.Amount <= 0 {
-		lib.ErrorResponse(w, "Amount must be positive", http.StatusBadRequest, nil)
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditCreate, Resource: "budget", ResourceID: budget.ID, After: budget}) {
 		return
 	}
 
-	if input.Period != "weekly" && input.Period != "monthly" && input.Period != "yearly" {
-		lib.ErrorResponse(w, "Period must be 'weekly', 'monthly', or 'yearly'", http.StatusBadRequest, nil)
+	w.Header().Set("ETag", lib.ETag(budget.UpdatedAt))
+	lib.SuccessResponse(w, lib.BudgetResponse{
+		Budget: budget,
+	}, http.StatusCreated)
+}
+
+func handleCreateBudgets(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var input lib.CreateBudgetsInput
+	if !lib.BindJSON(w, r, &input) {
 		return
 	}
 
-	// TODO: Insert into database
-	budget := map[string]interface{}{
-		"id":              "budget-new",
-		"user_id":         user.ID,
-		"category":        input.Category,
-		"amount":          input.Amount,
-		"period":          input.Period,
-		"alert_threshold": input.AlertThreshold,
-		"created_at":      "2024-01-15T10:00:00Z",
-	}
-
-	lib.SuccessResponse(w, map[string]interface{}{
-		"budget": budget,
+	// The whole set is validated first so nothing is created if any entry is invalid
+	var errs lib.ValidationErrors
+	seen := make(map[string]bool)
+	budgets := make([]lib.Budget, 0, len(input.Budgets))
+	for i, b := range input.Budgets {
+		prefix := "budgets[" + strconv.Itoa(i) + "]."
+		key := strings.ToLower(b.Category) + "/" + b.Period
+		if seen[key] {
+			errs = append(errs, lib.FieldError{
+				Field:   prefix + "category",
+				Message: "duplicates another budget with the same period",
+			})
+		}
+		seen[key] = true
+		budget := newBudget(r, user, b, "budget-new-"+strconv.Itoa(i+1))
+		errs = append(errs, budgetDateErrors(prefix, budget, true)...)
+		budgets = append(budgets, budget)
+	}
+	if len(errs) > 0 {
+		lib.WriteError(w, lib.ValidationError(errs))
+		return
+	}
+
+	// TODO: Insert into database in a single transaction
+	events := make([]lib.AuditEvent, 0, len(budgets))
+	for _, budget := range budgets {
+		events = append(events, lib.AuditEvent{Action: lib.AuditCreate, Resource: "budget", ResourceID: budget.ID, After: budget})
+	}
+	// One append, so a failure leaves no entries for budgets that were never created
+	if !lib.Audit(w, r, events...) {
+		return
+	}
+
+	lib.SuccessResponse(w, lib.BudgetBatchResponse{
+		Budgets: budgets,
+		Count:   len(budgets),
 	}, http.StatusCreated)
 }
 
+func newBudget(r *http.Request, user *lib.User, input lib.CreateBudgetInput, id string) lib.Budget {
+	now := time.Now().UTC()
+	budget := lib.Budget{
+		ID:             id,
+		UserID:         user.ID,
+		WorkspaceID:    lib.WorkspaceIDFromContext(r),
+		Category:       input.Category,
+		Amount:         input.Amount,
+		Period:         input.Period,
+		StartDate:      now,
+		AlertThreshold: input.AlertThreshold,
+		RolloverPolicy: input.RolloverPolicy,
+		RolloverCap:    input.RolloverCap,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if budget.RolloverPolicy == "" {
+		budget.RolloverPolicy = lib.RolloverNone
+	}
+	if start, err := time.Parse("2006-01-02", input.StartDate); err == nil {
+		budget.StartDate = start
+	}
+	if end, err := time.Parse("2006-01-02", input.EndDate); err == nil {
+		budget.EndDate = end
+	}
+	return budget
+}
+
+// budgetDateErrors rejects a start date set by the request that is not within
+// lib.MaxMonthOffsetYears of today, which bounds the periods
+// ComputeBudgetHistory replays, and an end date before the start date. Fields
+// are named after prefix, such as "budgets[0]."
+func budgetDateErrors(prefix string, budget lib.Budget, startChanged bool) lib.ValidationErrors {
+	var errs lib.ValidationErrors
+	if startChanged && !lib.DateInRange(budget.StartDate, time.Now()) {
+		errs = append(errs, lib.FieldError{
+			Field:   prefix + "start_date",
+			Message: fmt.Sprintf("must be within %d years of today", lib.MaxMonthOffsetYears),
+		})
+	}
+	if !budget.EndDate.IsZero() {
+		errs = append(errs, lib.ValidateDateRange(prefix+"start_date", budget.StartDate.Format("2006-01-02"),
+			prefix+"end_date", budget.EndDate.Format("2006-01-02"))...)
+	}
+	return errs
+}
+
 func handleUpdateBudget(w http.ResponseWriter, r *http.Request, user *lib.User) {
 	id := lib.GetQueryParam(r, "id", "")
 	if id == "" {
-		lib.ErrorResponse(w, "Budget ID required", http.StatusBadRequest, nil)
+		lib.WriteError(w, lib.BadRequestError("Budget ID required", nil))
+		return
+	}
+
+	budget, err := findBudget(r.Context(), user, id)
+	if err != nil {
+		lib.WriteError(w, err)
+		return
+	}
+
+	if !lib.CheckIfMatch(w, r, lib.ETag(budget.UpdatedAt)) {
+		return
+	}
+
+	var input lib.UpdateBudgetInput
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	before := *budget
+	if input.Category != nil {
+		budget.Category = *input.Category
+	}
+	if input.Amount != nil {
+		budget.Amount = *input.Amount
+	}
+	if input.Period != nil {
+		budget.Period = *input.Period
+	}
+	if input.StartDate != nil {
+		budget.StartDate, _ = time.Parse("2006-01-02", *input.StartDate)
+	}
+	if input.EndDate != nil {
+		budget.EndDate, _ = time.Parse("2006-01-02", *input.EndDate)
+	}
+	if input.AlertThreshold != nil {
+		budget.AlertThreshold = *input.AlertThreshold
+	}
+	if input.RolloverPolicy != nil {
+		budget.RolloverPolicy = *input.RolloverPolicy
+	}
+	if input.RolloverCap != nil {
+		budget.RolloverCap = *input.RolloverCap
+	}
+	if errs := budgetDateErrors("", *budget, input.StartDate != nil); len(errs) > 0 {
+		lib.WriteError(w, lib.ValidationError(errs))
+		return
+	}
+	budget.UpdatedAt = time.Now().UTC()
+
+	// TODO: Update in database, conditional on the UpdatedAt that was checked
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditUpdate, Resource: "budget", ResourceID: id, Before: before, After: *budget}) {
+		return
+	}
+
+	w.Header().Set("ETag", lib.ETag(budget.UpdatedAt))
+	lib.SuccessResponse(w, lib.BudgetResponse{
+		Budget: *budget,
+	}, http.StatusOK)
+}
+
+// handlePatchBudget applies a JSON merge patch (RFC 7396) to a budget
+func handlePatchBudget(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	id := lib.GetQueryParam(r, "id", "")
+	if id == "" {
+		lib.WriteError(w, lib.BadRequestError("Budget ID required", nil))
+		return
+	}
+
+	budget, err := findBudget(r.Context(), user, id)
+	if err != nil {
+		lib.WriteError(w, err)
+		return
+	}
+
+	if !lib.CheckIfMatch(w, r, lib.ETag(budget.UpdatedAt)) {
+		return
+	}
+
+	// The patch is applied to the editable fields and validated like a create
+	input := lib.CreateBudgetInput{
+		Category:       budget.Category,
+		Amount:         budget.Amount,
+		Period:         budget.Period,
+		StartDate:      budget.StartDate.Format("2006-01-02"),
+		AlertThreshold: budget.AlertThreshold,
+		RolloverPolicy: budget.RolloverPolicy,
+		RolloverCap:    budget.RolloverCap,
+	}
+	if !budget.EndDate.IsZero() {
+		input.EndDate = budget.EndDate.Format("2006-01-02")
+	}
+	if !lib.BindMergePatch(w, r, &input) {
+		return
+	}
+	// A budget always has a start date; only the end date can be cleared
+	if input.StartDate == "" {
+		lib.WriteError(w, lib.ValidationError(lib.ValidationErrors{{Field: "start_date", Message: "cannot be cleared"}}))
 		return
 	}
 
-	var input map[string]interface{}
-	if err := lib.ParseJSONBody(r, &input); err != nil {
-		lib.ErrorResponse(w, "Invalid JSON body", http.StatusBadRequest, nil)
+	before := *budget
+	budget.Category = input.Category
+	budget.Amount = input.Amount
+	budget.Period = input.Period
+	budget.StartDate, _ = time.Parse("2006-01-02", input.StartDate)
+	budget.EndDate, _ = time.Parse("2006-01-02", input.EndDate)
+	budget.AlertThreshold = input.AlertThreshold
+	budget.RolloverPolicy = input.RolloverPolicy
+	if budget.RolloverPolicy == "" {
+		budget.RolloverPolicy = lib.RolloverNone
+	}
+	budget.RolloverCap = input.RolloverCap
+	if errs := budgetDateErrors("", *budget, input.StartDate != before.StartDate.Format("2006-01-02")); len(errs) > 0 {
+		lib.WriteError(w, lib.ValidationError(errs))
 		return
 	}
+	budget.UpdatedAt = time.Now().UTC()
 
-	// TODO: Update in database
-	budget := map[string]interface{}{
-		"id":      id,
-		"user_id": user.ID,
-		"updated": true,
+	// TODO: Update in database, conditional on the UpdatedAt that was checked
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditUpdate, Resource: "budget", ResourceID: id, Before: before, After: *budget}) {
+		return
 	}
 
-	lib.SuccessResponse(w, map[string]interface{}{
-		"budget": budget,
+	w.Header().Set("ETag", lib.ETag(budget.UpdatedAt))
+	lib.SuccessResponse(w, lib.BudgetResponse{
+		Budget: *budget,
 	}, http.StatusOK)
 }
 
 func handleDeleteBudget(w http.ResponseWriter, r *http.Request, user *lib.User) {
 	id := lib.GetQueryParam(r, "id", "")
 	if id == "" {
-		lib.ErrorResponse(w, "Budget ID required", http.StatusBadRequest, nil)
+		lib.WriteError(w, lib.BadRequestError("Budget ID required", nil))
 		return
 	}
 
-	// TODO: Delete from database
+	budget, err := findBudget(r.Context(), user, id)
+	if err != nil {
+		lib.WriteError(w, err)
+		return
+	}
 
-	lib.SuccessResponse(w, map[string]interface{}{
-		"message": "Budget deleted successfully",
-		"id":      id,
+	// Deleted budgets go to the trash and can be restored until purged
+	item := lib.TrashBudgetItem(*budget, time.Now().UTC())
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditDelete, Resource: "budget", ResourceID: id, Before: *budget, After: *item.Budget}) {
+		return
+	}
+	if err := lib.Trash.Put(r.Context(), item); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to delete budget", err))
+		return
+	}
+	// TODO: Set deleted_at in database
+	lib.PurgeExpiredTrash(r.Context(), item.DeletedAt)
+
+	lib.SuccessResponse(w, lib.TrashedResponse{
+		Message: "Budget moved to trash",
+		Item:    item,
 	}, http.StatusOK)
 }
 
+func getBudgets(user *lib.User) []lib.Budget {
+	// TODO: Query database, scoped to the request workspace when set
+	return []lib.Budget{
+		{
+			ID:             "budget-1",
+			UserID:         user.ID,
+			Category:       "Groceries",
+			Amount:         500.0,
+			Period:         "monthly",
+			StartDate:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			AlertThreshold: 80,
+			RolloverPolicy: lib.RolloverSurplus,
+			RolloverCap:    250.0,
+			CreatedAt:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			UpdatedAt:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+// findBudget returns a budget, or a 404 APIError when it does not exist or is
+// in the trash
+func findBudget(ctx context.Context, user *lib.User, id string) (*lib.Budget, error) {
+	budgets, err := lib.ExcludeTrashedBudgets(ctx, getBudgets(user), false)
+	if err != nil {
+		return nil, lib.InternalError("Failed to load trash", err)
+	}
+	for _, b := range budgets {
+		if b.ID == id {
+			return &b, nil
+		}
+	}
+	return nil, lib.NotFoundError("Budget not found")
+}
+
+func getBudgetTransactions(ctx context.Context, user *lib.User) ([]lib.Transaction, error) {
+	// TODO: Query database
+	now := time.Now().UTC()
+	transactions := []lib.Transaction{
+		{ID: "trans-1", UserID: user.ID, Amount: 420.0, Category: "Groceries", Type: "expense", Date: now.AddDate(0, -2, 0)},
+		{ID: "trans-2", UserID: user.ID, Amount: 610.0, Category: "Groceries", Type: "expense", Date: now.AddDate(0, -1, 0)},
+		{ID: "trans-3", UserID: user.ID, Amount: 100.5, Category: "Groceries", Type: "expense", Date: now},
+		{ID: "trans-4", UserID: user.ID, Amount: 380.0, Category: "Groceries", Type: "expense", Date: now.AddDate(0, -3, 0)},
+		{ID: "trans-5", UserID: user.ID, Amount: 150.0, Category: "Dining", Type: "expense", Date: now.AddDate(0, -1, 0)},
+		{ID: "trans-6", UserID: user.ID, Amount: 170.0, Category: "Dining", Type: "expense", Date: now.AddDate(0, -2, 0)},
+		{ID: "trans-7", UserID: user.ID, Amount: 1500.0, Category: "Rent", Type: "expense", Date: now.AddDate(0, -1, 0)},
+		{ID: "trans-8", UserID: user.ID, Amount: 1500.0, Category: "Rent", Type: "expense", Date: now.AddDate(0, -2, 0)},
+		{ID: "trans-9", UserID: user.ID, Amount: 1500.0, Category: "Rent", Type: "expense", Date: now.AddDate(0, -3, 0)},
+		{ID: "trans-10", UserID: user.ID, Amount: 4000.0, Category: "Salary", Type: "income", Date: now.AddDate(0, -1, 0)},
+		{ID: "trans-11", UserID: user.ID, Amount: 4000.0, Category: "Salary", Type: "income", Date: now.AddDate(0, -2, 0)},
+		{ID: "trans-12", UserID: user.ID, Amount: 4000.0, Category: "Salary", Type: "income", Date: now.AddDate(0, -3, 0)},
+	}
+	return lib.ExcludeTrashedTransactions(ctx, transactions, false)
+}
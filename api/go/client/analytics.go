@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// analytics fetches one analytics type into out
+func (c *Client) analytics(ctx context.Context, analyticsType string, query url.Values, out interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("type", analyticsType)
+	return c.do(ctx, http.MethodGet, "analytics", query, nil, out)
+}
+
+// Summary returns income, expenses and savings totals
+func (c *Client) Summary(ctx context.Context) (*lib.AnalyticsSummary, error) {
+	var result lib.SummaryAnalyticsResponse
+	if err := c.analytics(ctx, "summary", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result.Summary, nil
+}
+
+// Categories returns income and expenses by category
+func (c *Client) Categories(ctx context.Context) ([]lib.CategoryAnalytics, error) {
+	var result lib.CategoryAnalyticsResponse
+	if err := c.analytics(ctx, "category", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Categories, nil
+}
+
+// Trend returns income and expenses by month
+func (c *Client) Trend(ctx context.Context) ([]lib.TrendData, error) {
+	var result lib.TrendAnalyticsResponse
+	if err := c.analytics(ctx, "trend", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Trend, nil
+}
+
+// Forecast returns a daily cash-flow forecast
+func (c *Client) Forecast(ctx context.Context, params lib.ForecastParams) (*lib.CashFlowForecast, error) {
+	var result lib.ForecastAnalyticsResponse
+	if err := c.analytics(ctx, "forecast", encodeQuery(params), &result); err != nil {
+		return nil, err
+	}
+	return result.Forecast, nil
+}
+
+// Anomalies returns unusual spending within a window
+func (c *Client) Anomalies(ctx context.Context, params lib.AnomalyParams) (*lib.AnomalyAnalyticsResponse, error) {
+	var result lib.AnomalyAnalyticsResponse
+	if err := c.analytics(ctx, "anomalies", encodeQuery(params), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
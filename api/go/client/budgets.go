@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// ListBudgets returns budgets with their current period
+func (c *Client) ListBudgets(ctx context.Context, params lib.BudgetListParams) ([]lib.BudgetStatus, error) {
+	var result lib.BudgetListResponse
+	if err := c.do(ctx, http.MethodGet, "budgets", encodeQuery(params), nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Budgets, nil
+}
+
+// GetBudget returns a budget
+func (c *Client) GetBudget(ctx context.Context, id string) (*lib.Budget, error) {
+	var result lib.BudgetResponse
+	if err := c.do(ctx, http.MethodGet, "budgets", url.Values{"id": {id}}, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result.Budget, nil
+}
+
+// BudgetHistory returns a budget's periods with rollover, most recent first
+func (c *Client) BudgetHistory(ctx context.Context, params lib.BudgetHistoryParams) (*lib.BudgetHistoryResponse, error) {
+	query := encodeQuery(params)
+	query.Set("view", "history")
+	var result lib.BudgetHistoryResponse
+	if err := c.do(ctx, http.MethodGet, "budgets", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateBudget creates a budget
+func (c *Client) CreateBudget(ctx context.Context, input lib.CreateBudgetInput) (*lib.Budget, error) {
+	var result lib.BudgetResponse
+	if err := c.do(ctx, http.MethodPost, "budgets", nil, input, &result); err != nil {
+		return nil, err
+	}
+	return &result.Budget, nil
+}
+
+// UpdateBudget changes the fields set in input. ctx must carry the
+// version the update is conditional on, from WithIfMatch, or else WithForce;
+// the update fails with ErrPreconditionFailed if the budget changed since.
+func (c *Client) UpdateBudget(ctx context.Context, id string, input lib.UpdateBudgetInput) (*lib.Budget, error) {
+	if err := requireIfMatch(ctx); err != nil {
+		return nil, err
+	}
+	var result lib.BudgetResponse
+	if err := c.do(ctx, http.MethodPut, "budgets", url.Values{"id": {id}}, input, &result); err != nil {
+		return nil, err
+	}
+	return &result.Budget, nil
+}
+
+// DeleteBudget moves a budget to the trash
+func (c *Client) DeleteBudget(ctx context.Context, id string) (*lib.TrashedResponse, error) {
+	var result lib.TrashedResponse
+	if err := c.do(ctx, http.MethodDelete, "budgets", url.Values{"id": {id}}, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,376 @@
+// Package client is a typed client for the Budget Buddy Go API. Requests and
+// responses use the lib DTOs the handlers are built on, so the client stays in
+// sync with the API:
+//
+//	c := client.New("http://localhost:8080", client.WithToken(token))
+//	page, err := c.ListTransactions(ctx, lib.TransactionListParams{Type: "expense"})
+//
+// Error responses are *Error values matching the Err variables with
+// errors.Is. Rate limited and failed requests are retried following a
+// RetryPolicy, and AllTransactions iterates over every page.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// UserAgent is sent with every request
+var UserAgent = "budget-buddy-go-client/" + lib.APIVersion
+
+// RetryPolicy controls how requests that were rate limited or failed on the
+// server or network are retried. Waits double from MinBackoff up to
+// MaxBackoff with jitter, and are at least the response's Retry-After.
+type RetryPolicy struct {
+	MaxAttempts int // attempts including the first; 1 disables retries
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration // a longer Retry-After gives up instead of waiting
+}
+
+// DefaultRetryPolicy is used unless WithRetry is given
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, MinBackoff: 250 * time.Millisecond, MaxBackoff: 30 * time.Second}
+
+// Client calls the API. It is safe for concurrent use.
+type Client struct {
+	baseURL     string
+	token       string
+	workspaceID string
+	userAgent   string
+	httpClient  *http.Client
+	retry       RetryPolicy
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithToken authenticates requests with a bearer token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithWorkspace acts in a shared workspace on the endpoints that are
+// workspace-scoped
+func WithWorkspace(workspaceID string) Option {
+	return func(c *Client) {
+		c.workspaceID = workspaceID
+	}
+}
+
+// WithUserAgent replaces UserAgent, such as with the name of a tool
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithHTTPClient sends requests with httpClient instead of
+// http.DefaultClient, such as to set timeouts, proxies or TLS configuration
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetry replaces DefaultRetryPolicy
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// New creates a client for the API at baseURL, such as
+// https://your-app.vercel.app or http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		userAgent:  UserAgent,
+		httpClient: http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context that sends key as the Idempotency-Key
+// of POST requests, so repeating a create returns the first response instead
+// of creating a duplicate
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+type ifMatch struct{}
+
+// WithIfMatch returns a context that sends etag as the If-Match of PUT, PATCH
+// and DELETE requests, so a write fails with ErrPreconditionFailed if the
+// resource changed since the version the caller read. A fetched resource's
+// ETag is lib.ETag(resource.UpdatedAt).
+func WithIfMatch(ctx context.Context, etag string) context.Context {
+	return context.WithValue(ctx, ifMatch{}, etag)
+}
+
+// WithForce returns a context whose writes apply to any version of the
+// resource, overwriting changes the caller has not seen
+func WithForce(ctx context.Context) context.Context {
+	return WithIfMatch(ctx, "*")
+}
+
+// requireIfMatch fails unless ctx carries the version an update is
+// conditional on, as the API refuses blind overwrites
+func requireIfMatch(ctx context.Context) error {
+	if etag, _ := ctx.Value(ifMatch{}).(string); etag == "" {
+		return fmt.Errorf("%w: pass the version read with WithIfMatch, or WithForce to overwrite any version", ErrPreconditionRequired)
+	}
+	return nil
+}
+
+// endpoint returns the registered endpoint with name
+func endpoint(name string) lib.Endpoint {
+	for _, e := range lib.Endpoints {
+		if e.Name == name {
+			return e
+		}
+	}
+	panic("client: unknown endpoint " + name)
+}
+
+// do sends a request to the named endpoint and decodes the data of the
+// response envelope into out, unless out is nil
+func (c *Client) do(ctx context.Context, method, name string, query url.Values, body, out interface{}) error {
+	return c.send(ctx, method, name, query, body, out, c.retry)
+}
+
+// send is do with a retry policy. Only requests that are safe to repeat are
+// retried: GET, PUT and DELETE, and POST to endpoints accepting an
+// Idempotency-Key, which is generated when the context does not carry one. A
+// retried DELETE answered with 404 after an attempt whose outcome is unknown
+// succeeds, as that attempt most likely deleted the resource; out is then
+// left empty. A retried write answered with 412 after such an attempt fails
+// with ErrOutcomeUnknown.
+func (c *Client) send(ctx context.Context, method, name string, query url.Values, body, out interface{}, policy RetryPolicy) error {
+	e := endpoint(name)
+	if query == nil {
+		query = url.Values{}
+	}
+	if e.WorkspaceScoped && c.workspaceID != "" {
+		query.Set("workspace_id", c.workspaceID)
+	}
+	target := c.baseURL + e.Path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	retryable := method != http.MethodPost
+	if method == http.MethodPost && e.Idempotent {
+		if key == "" && policy.MaxAttempts > 1 {
+			key = lib.NewID("client")
+		}
+		retryable = key != ""
+	}
+
+	// applied is set once an attempt may have taken effect without its
+	// response reaching us: a network error or a server error
+	applied := false
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if payload != nil {
+			reader = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, reader)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", c.userAgent)
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		if key != "" && method == http.MethodPost {
+			req.Header.Set(lib.IdempotencyKeyHeader, key)
+		}
+		if etag, _ := ctx.Value(ifMatch{}).(string); etag != "" && method != http.MethodGet && method != http.MethodPost {
+			req.Header.Set("If-Match", etag)
+		}
+
+		var retryAfter time.Duration
+		resp, err := c.httpClient.Do(req)
+		if err == nil {
+			err = decodeResponse(resp, out)
+			resp.Body.Close()
+		}
+		var apiErr *Error
+		switch {
+		case err == nil:
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.As(err, &apiErr):
+			switch {
+			case method == http.MethodDelete && applied && apiErr.Status == http.StatusNotFound:
+				return nil
+			case applied && apiErr.Status == http.StatusPreconditionFailed:
+				// Not ErrPreconditionFailed: the write may be what changed the version
+				return fmt.Errorf("%w: an earlier attempt may have applied the request: %v", ErrOutcomeUnknown, err)
+			}
+			if !apiErr.Temporary() {
+				return err
+			}
+			retryAfter = apiErr.RetryAfter
+			applied = applied || apiErr.Status >= http.StatusInternalServerError
+		case resp != nil:
+			// The response could not be decoded, so retrying will not help
+			return err
+		default:
+			applied = true
+		}
+		if !retryable || attempt >= policy.MaxAttempts || retryAfter > policy.MaxBackoff {
+			return err
+		}
+
+		timer := time.NewTimer(max(retryAfter, policy.backoff(attempt)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the wait after a failed attempt: MinBackoff doubled for
+// each earlier attempt, capped at MaxBackoff, less up to half as jitter
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.MinBackoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, p.MaxBackoff)
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// envelope is lib.Response with the data left undecoded
+type envelope struct {
+	Success   bool            `json:"success"`
+	Data      json.RawMessage `json:"data"`
+	Error     string          `json:"error"`
+	Code      string          `json:"code"`
+	Details   json.RawMessage `json:"details"`
+	RequestID string          `json:"request_id"`
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		if resp.StatusCode >= 400 {
+			env = envelope{Error: http.StatusText(resp.StatusCode)}
+		} else {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+	}
+	if !env.Success || resp.StatusCode >= 400 {
+		apiErr := &Error{
+			Status:     resp.StatusCode,
+			Code:       env.Code,
+			Message:    env.Error,
+			Details:    env.Details,
+			RequestID:  env.RequestID,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+		if apiErr.Code == "" {
+			apiErr.Code = lib.CodeForStatus(resp.StatusCode)
+		}
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = resp.Header.Get(lib.RequestIDHeader)
+		}
+		return apiErr
+	}
+	if out == nil || len(env.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(env.Data, out)
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// encodeQuery encodes the query-tagged fields of a params DTO such as
+// lib.TransactionListParams. Zero values are left out so the API applies its
+// defaults.
+func encodeQuery(params interface{}) url.Values {
+	query := url.Values{}
+	v := reflect.ValueOf(params)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("query")
+		field := v.Field(i)
+		if name == "" || field.IsZero() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			query.Set(name, field.String())
+		case reflect.Int, reflect.Int64:
+			query.Set(name, strconv.FormatInt(field.Int(), 10))
+		case reflect.Float64:
+			query.Set(name, strconv.FormatFloat(field.Float(), 'f', -1, 64))
+		case reflect.Bool:
+			query.Set(name, strconv.FormatBool(field.Bool()))
+		case reflect.Map:
+			// Prefixed parameters such as buffer_*, one per map entry
+			prefix := strings.TrimSuffix(name, "*")
+			for iter := field.MapRange(); iter.Next(); {
+				query.Set(prefix+iter.Key().String(), fmt.Sprint(iter.Value().Interface()))
+			}
+		}
+	}
+	return query
+}
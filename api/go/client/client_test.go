@@ -0,0 +1,240 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// request is what a test server saw of one request
+type request struct {
+	Method  string
+	Query   string
+	IfMatch string
+}
+
+// newTestServer serves handler and records each request it receives
+func newTestServer(t *testing.T, handler http.HandlerFunc) (*Client, func() []request) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, request{Method: r.Method, Query: r.URL.RawQuery, IfMatch: r.Header.Get("If-Match")})
+		mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	c := New(server.URL, WithToken("token"), WithRetry(RetryPolicy{MaxAttempts: 3}))
+	return c, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), requests...)
+	}
+}
+
+func writeData(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(lib.Response{Success: status < 400, Data: data})
+}
+
+func writeError(w http.ResponseWriter, err *lib.APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(lib.Response{Error: err.Message, Code: err.Code})
+}
+
+func TestUpdateTransactionIsConditional(t *testing.T) {
+	const etag = `"v1"`
+	c, requests := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		switch r.Header.Get("If-Match") {
+		case "":
+			writeError(w, lib.NewError(http.StatusPreconditionRequired, lib.CodePreconditionRequired, "Precondition required", nil))
+		case etag, "*":
+			writeData(w, http.StatusOK, lib.TransactionResponse{Transaction: lib.Transaction{ID: "tx-1", Amount: 12}})
+		default:
+			writeError(w, lib.NewError(http.StatusPreconditionFailed, lib.CodePreconditionFailed, "Precondition failed", nil))
+		}
+	})
+	amount := 12.0
+	input := lib.UpdateTransactionInput{Amount: &amount}
+
+	// Without a version the update is refused before anything is sent
+	if _, err := c.UpdateTransaction(context.Background(), "tx-1", input); !errors.Is(err, ErrPreconditionRequired) {
+		t.Errorf("UpdateTransaction without a version: %v, want ErrPreconditionRequired", err)
+	}
+	if got := requests(); len(got) != 0 {
+		t.Errorf("requests %+v, want none", got)
+	}
+
+	// A stale version from WithIfMatch is sent as is
+	_, err := c.UpdateTransaction(WithIfMatch(context.Background(), `"v0"`), "tx-1", input)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("UpdateTransaction with a stale version: %v, want ErrPreconditionFailed", err)
+	}
+	if got := requests(); len(got) != 1 || got[0].Method != http.MethodPut || got[0].IfMatch != `"v0"` {
+		t.Errorf("requests %+v, want one PUT with If-Match \"v0\"", got)
+	}
+
+	// WithForce overwrites any version
+	tx, err := c.UpdateTransaction(WithForce(context.Background()), "tx-1", input)
+	if err != nil || tx.Amount != 12 {
+		t.Fatalf("UpdateTransaction with WithForce = %+v, %v", tx, err)
+	}
+	if got := requests()[1:]; len(got) != 1 || got[0].IfMatch != "*" {
+		t.Errorf("requests %+v, want one PUT with If-Match *", got)
+	}
+}
+
+// failFirst answers the first attempts with the given errors and later ones
+// with the handler
+func failFirst(handler http.HandlerFunc, errs ...*lib.APIError) http.HandlerFunc {
+	var attempts atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		if n := int(attempts.Add(1)); n <= len(errs) {
+			writeError(w, errs[n-1])
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func apiError(status int) *lib.APIError {
+	return lib.NewError(status, lib.CodeForStatus(status), http.StatusText(status), nil)
+}
+
+func TestRetriesTemporaryErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantErr  error
+		attempts int
+	}{
+		{"server error", http.StatusServiceUnavailable, nil, 2},
+		{"rate limited", http.StatusTooManyRequests, nil, 2},
+		{"conflict without Retry-After", http.StatusConflict, ErrConflict, 1},
+		{"not found", http.StatusNotFound, ErrNotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, requests := newTestServer(t, failFirst(func(w http.ResponseWriter, r *http.Request) {
+				writeData(w, http.StatusOK, lib.TransactionResponse{Transaction: lib.Transaction{ID: "tx-1"}})
+			}, apiError(tt.status)))
+			if _, err := c.GetTransaction(context.Background(), "tx-1"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetTransaction: %v, want %v", err, tt.wantErr)
+			}
+			if got := len(requests()); got != tt.attempts {
+				t.Errorf("%d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetriesInProgressConflict(t *testing.T) {
+	var keys []string
+	var mu sync.Mutex
+	c, requests := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(lib.IdempotencyKeyHeader))
+		first := len(keys) == 1
+		mu.Unlock()
+		if first {
+			// The first attempt is still being processed under the same key
+			w.Header().Set("Retry-After", "1")
+			writeError(w, lib.NewError(http.StatusConflict, lib.CodeConflict, "Request in progress", nil))
+			return
+		}
+		writeData(w, http.StatusCreated, lib.TransactionResponse{Transaction: lib.Transaction{ID: "tx-1"}})
+	})
+	c.retry.MaxBackoff = 2 * time.Second
+
+	tx, err := c.CreateTransaction(context.Background(), lib.CreateTransactionInput{Amount: 10})
+	if err != nil || tx.ID != "tx-1" {
+		t.Fatalf("CreateTransaction = %+v, %v", tx, err)
+	}
+	if got := len(requests()); got != 2 {
+		t.Errorf("%d attempts, want 2", got)
+	}
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("idempotency keys %q, want the same key on each attempt", keys)
+	}
+}
+
+func TestDeleteRetriedAfterLostResponse(t *testing.T) {
+	notFound := func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, apiError(http.StatusNotFound))
+	}
+	tests := []struct {
+		name    string
+		first   []*lib.APIError
+		wantErr error
+	}{
+		{"not found on the first attempt", nil, ErrNotFound},
+		{"not found after a server error", []*lib.APIError{apiError(http.StatusBadGateway)}, nil},
+		{"not found after rate limiting", []*lib.APIError{apiError(http.StatusTooManyRequests)}, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestServer(t, failFirst(notFound, tt.first...))
+			if _, err := c.DeleteTransaction(context.Background(), "tx-1"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("DeleteTransaction: %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("not found after a dropped connection", func(t *testing.T) {
+		var attempts atomic.Int32
+		c, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) == 1 {
+				conn, _, err := http.NewResponseController(w).Hijack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				conn.Close()
+				return
+			}
+			notFound(w, r)
+		})
+		if _, err := c.DeleteTransaction(context.Background(), "tx-1"); err != nil {
+			t.Errorf("DeleteTransaction: %v, want nil", err)
+		}
+	})
+}
+
+func TestUpdateRetriedAfterLostResponse(t *testing.T) {
+	amount := 12.0
+	input := lib.UpdateTransactionInput{Amount: &amount}
+	preconditionFailed := func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, lib.NewError(http.StatusPreconditionFailed, lib.CodePreconditionFailed, "Precondition failed", nil))
+	}
+	tests := []struct {
+		name      string
+		first     []*lib.APIError
+		wantErr   error
+		unwantErr error
+	}{
+		{"stale on the first attempt", nil, ErrPreconditionFailed, ErrOutcomeUnknown},
+		{"stale after a server error", []*lib.APIError{apiError(http.StatusBadGateway)}, ErrOutcomeUnknown, ErrPreconditionFailed},
+		{"stale after rate limiting", []*lib.APIError{apiError(http.StatusTooManyRequests)}, ErrPreconditionFailed, ErrOutcomeUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestServer(t, failFirst(preconditionFailed, tt.first...))
+			_, err := c.UpdateTransaction(WithIfMatch(context.Background(), `"v1"`), "tx-1", input)
+			if !errors.Is(err, tt.wantErr) || errors.Is(err, tt.unwantErr) {
+				t.Errorf("UpdateTransaction: %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// Errors matching each API error code with errors.Is:
+//
+//	if errors.Is(err, client.ErrNotFound) { ... }
+var (
+	ErrBadRequest           = errors.New("bad request")
+	ErrValidation           = errors.New("validation failed")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrForbidden            = errors.New("forbidden")
+	ErrNotFound             = errors.New("not found")
+	ErrMethodNotAllowed     = errors.New("method not allowed")
+	ErrConflict             = errors.New("conflict")
+	ErrGone                 = errors.New("gone")
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrPreconditionRequired = errors.New("precondition required")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrUnprocessable        = errors.New("unprocessable")
+	ErrRateLimited          = errors.New("rate limited")
+	ErrInternal             = errors.New("internal error")
+	ErrUpstream             = errors.New("upstream error")
+)
+
+// ErrOutcomeUnknown is returned when a retried write fails its If-Match
+// precondition after an attempt whose response was lost. That attempt may
+// have applied the write and changed the version, so read the resource to
+// find out.
+var ErrOutcomeUnknown = errors.New("outcome unknown")
+
+var codeErrors = map[string]error{
+	lib.CodeBadRequest:           ErrBadRequest,
+	lib.CodeValidation:           ErrValidation,
+	lib.CodeUnauthorized:         ErrUnauthorized,
+	lib.CodeForbidden:            ErrForbidden,
+	lib.CodeNotFound:             ErrNotFound,
+	lib.CodeMethodNotAllowed:     ErrMethodNotAllowed,
+	lib.CodeConflict:             ErrConflict,
+	lib.CodeGone:                 ErrGone,
+	lib.CodePreconditionFailed:   ErrPreconditionFailed,
+	lib.CodePreconditionRequired: ErrPreconditionRequired,
+	lib.CodeUnsupportedMediaType: ErrUnsupportedMediaType,
+	lib.CodeUnprocessable:        ErrUnprocessable,
+	lib.CodeRateLimited:          ErrRateLimited,
+	lib.CodeInternal:             ErrInternal,
+	lib.CodeUpstream:             ErrUpstream,
+}
+
+// Error is an error response from the API. Match it against the Err
+// variables with errors.Is, or use errors.As for the details.
+type Error struct {
+	Status     int
+	Code       string // stable error code, such as lib.CodeNotFound
+	Message    string
+	Details    json.RawMessage
+	RequestID  string
+	RetryAfter time.Duration // from the Retry-After header, when sent
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%s, HTTP %d)", e.Message, e.Code, e.Status)
+}
+
+// Is reports whether target is the Err variable for the error's code
+func (e *Error) Is(target error) bool {
+	return codeErrors[e.Code] == target
+}
+
+// ValidationErrors returns the rejected fields of a validation error
+func (e *Error) ValidationErrors() lib.ValidationErrors {
+	var errs lib.ValidationErrors
+	if e.Code == lib.CodeValidation {
+		json.Unmarshal(e.Details, &errs)
+	}
+	return errs
+}
+
+// Temporary reports whether the request may succeed if retried: it was rate
+// limited, failed on the server, or conflicted with a request still in
+// progress, which the API signals with a 409 carrying Retry-After
+func (e *Error) Temporary() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= http.StatusInternalServerError ||
+		(e.Status == http.StatusConflict && e.RetryAfter > 0)
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// ListTransactions returns one page of transactions matching params
+func (c *Client) ListTransactions(ctx context.Context, params lib.TransactionListParams) (*lib.TransactionListResponse, error) {
+	var result lib.TransactionListResponse
+	if err := c.do(ctx, http.MethodGet, "transactions", encodeQuery(params), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AllTransactions iterates over every transaction matching params, fetching
+// pages of params.Limit from params.Offset as it goes. Iteration stops after
+// the first error.
+//
+//	for tx, err := range c.AllTransactions(ctx, lib.TransactionListParams{}) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (c *Client) AllTransactions(ctx context.Context, params lib.TransactionListParams) iter.Seq2[lib.Transaction, error] {
+	return func(yield func(lib.Transaction, error) bool) {
+		for {
+			page, err := c.ListTransactions(ctx, params)
+			if err != nil {
+				yield(lib.Transaction{}, err)
+				return
+			}
+			for _, tx := range page.Transactions {
+				if !yield(tx, nil) {
+					return
+				}
+			}
+			if !page.Pagination.HasMore || len(page.Transactions) == 0 {
+				return
+			}
+			params.Offset += len(page.Transactions)
+		}
+	}
+}
+
+// GetTransaction returns a transaction
+func (c *Client) GetTransaction(ctx context.Context, id string) (*lib.Transaction, error) {
+	var result lib.TransactionResponse
+	if err := c.do(ctx, http.MethodGet, "transactions", url.Values{"id": {id}}, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result.Transaction, nil
+}
+
+// CreateTransaction creates a transaction
+func (c *Client) CreateTransaction(ctx context.Context, input lib.CreateTransactionInput) (*lib.Transaction, error) {
+	var result lib.TransactionResponse
+	if err := c.do(ctx, http.MethodPost, "transactions", nil, input, &result); err != nil {
+		return nil, err
+	}
+	return &result.Transaction, nil
+}
+
+// UpdateTransaction changes the fields set in input. ctx must carry the
+// version the update is conditional on, from WithIfMatch, or else WithForce;
+// the update fails with ErrPreconditionFailed if the transaction changed since.
+func (c *Client) UpdateTransaction(ctx context.Context, id string, input lib.UpdateTransactionInput) (*lib.Transaction, error) {
+	if err := requireIfMatch(ctx); err != nil {
+		return nil, err
+	}
+	var result lib.TransactionResponse
+	if err := c.do(ctx, http.MethodPut, "transactions", url.Values{"id": {id}}, input, &result); err != nil {
+		return nil, err
+	}
+	return &result.Transaction, nil
+}
+
+// DeleteTransaction moves a transaction to the trash
+func (c *Client) DeleteTransaction(ctx context.Context, id string) (*lib.TrashedResponse, error) {
+	var result lib.TrashedResponse
+	if err := c.do(ctx, http.MethodDelete, "transactions", url.Values{"id": {id}}, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
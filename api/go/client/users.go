@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// Profile returns the current user's profile
+func (c *Client) Profile(ctx context.Context) (*lib.UserProfile, error) {
+	var result lib.ProfileResponse
+	if err := c.do(ctx, http.MethodGet, "users", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result.Profile, nil
+}
+
+// UpdateProfile changes the fields set in input
+func (c *Client) UpdateProfile(ctx context.Context, input lib.UpdateProfileInput) (*lib.UserProfile, error) {
+	var result lib.ProfileResponse
+	if err := c.do(ctx, http.MethodPut, "users", nil, input, &result); err != nil {
+		return nil, err
+	}
+	return &result.Profile, nil
+}
+
+// Health runs the liveness probe, or the readiness probe when ready is set.
+// A service that is not ready returns its status along with an *Error.
+// Probes are not retried, so they report the service as it is now.
+func (c *Client) Health(ctx context.Context, ready bool) (*lib.HealthStatus, error) {
+	query := url.Values{"probe": {"live"}}
+	if ready {
+		query.Set("probe", "ready")
+	}
+	var result lib.HealthStatus
+	err := c.send(ctx, http.MethodGet, "health", query, nil, &result, RetryPolicy{MaxAttempts: 1})
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusServiceUnavailable {
+		if json.Unmarshal(apiErr.Details, &result) == nil {
+			return &result, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
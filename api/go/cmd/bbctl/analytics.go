@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+func runAnalytics(ctx context.Context, args []string, out io.Writer) error {
+	return subcommand(ctx, "analytics", map[string]command{
+		"summary":   runSummary,
+		"category":  runCategories,
+		"trend":     runTrend,
+		"forecast":  runForecast,
+		"anomalies": runAnomalies,
+	}, args, out)
+}
+
+func runSummary(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("analytics summary")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	summary, err := c.Summary(ctx)
+	if err != nil {
+		return err
+	}
+	return g.print(out, summary, keyValues(
+		"total_income", formatAmount(summary.TotalIncome),
+		"total_expenses", formatAmount(summary.TotalExpenses),
+		"net_savings", formatAmount(summary.NetSavings),
+		"savings_rate", formatFloat(summary.SavingsRate),
+		"transaction_count", strconv.Itoa(summary.TransactionCount),
+	))
+}
+
+func runCategories(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("analytics category")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	categories, err := c.Categories(ctx)
+	if err != nil {
+		return err
+	}
+	t := table{headers: []string{"CATEGORY", "INCOME", "EXPENSES", "TRANSACTIONS"}}
+	for _, category := range categories {
+		t.add(category.Category, formatAmount(category.Income), formatAmount(category.Expenses), strconv.Itoa(category.Transactions))
+	}
+	return g.print(out, categories, t)
+}
+
+func runTrend(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("analytics trend")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	trend, err := c.Trend(ctx)
+	if err != nil {
+		return err
+	}
+	t := table{headers: []string{"MONTH", "INCOME", "EXPENSES", "NET"}}
+	for _, month := range trend {
+		t.add(month.Month, formatAmount(month.Income), formatAmount(month.Expenses), formatAmount(month.Net))
+	}
+	return g.print(out, trend, t)
+}
+
+func runForecast(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("analytics forecast")
+	var params lib.ForecastParams
+	fs.IntVar(&params.Days, "days", 0, "days to forecast, up to 365 (default 30)")
+	fs.Float64Var(&params.Threshold, "threshold", 0, "low balance warning threshold")
+	fs.Float64Var(&params.Confidence, "confidence", 0, "confidence band: 0.8, 0.9 or 0.95 (default 0.8)")
+	fs.Float64Var(&params.StartingBalance, "starting-balance", 0, "balance to start from (default 2500)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	forecast, err := c.Forecast(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"DATE", "INCOME", "EXPENSES", "NET", "BALANCE", "LOWER", "UPPER"}}
+	for _, day := range forecast.Daily {
+		t.add(day.Date, formatAmount(day.Income), formatAmount(day.Expenses), formatAmount(day.Net),
+			formatAmount(day.Balance), formatAmount(day.BalanceLower), formatAmount(day.BalanceUpper))
+	}
+	if err := g.print(out, forecast, t); err != nil {
+		return err
+	}
+	if g.output == "table" {
+		fmt.Fprintf(out, "\nprojected balance %s on %s", formatAmount(forecast.ProjectedBalance), forecast.EndDate)
+		if forecast.BelowThresholdDate != "" {
+			fmt.Fprintf(out, "; below %s on %s", formatAmount(forecast.Threshold), forecast.BelowThresholdDate)
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+func runAnomalies(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("analytics anomalies")
+	var params lib.AnomalyParams
+	fs.IntVar(&params.Days, "days", 0, "days to look back, up to 90 (default 30)")
+	fs.Float64Var(&params.ZThreshold, "z-threshold", 0, "z-score above which spending is unusual (default 3)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	result, err := c.Anomalies(ctx, params)
+	if err != nil {
+		return err
+	}
+	t := table{headers: []string{"DATE", "SEVERITY", "TYPE", "CATEGORY", "AMOUNT", "EXPECTED", "DESCRIPTION"}}
+	for _, a := range result.Anomalies {
+		t.add(a.Date, a.Severity, a.Type, a.Category, formatAmount(a.CurrentAmount),
+			formatAmount(a.ExpectedRange.Min)+"-"+formatAmount(a.ExpectedRange.Max), a.Description)
+	}
+	return g.print(out, result, t)
+}
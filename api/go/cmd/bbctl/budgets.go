@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"strconv"
+
+	"github.com/budget-buddy/api/client"
+	"github.com/budget-buddy/api/lib"
+)
+
+func runBudgets(ctx context.Context, args []string, out io.Writer) error {
+	return subcommand(ctx, "budgets", map[string]command{
+		"list":    runBudgetList,
+		"get":     runBudgetGet,
+		"add":     runBudgetAdd,
+		"edit":    runBudgetEdit,
+		"delete":  runBudgetDelete,
+		"history": runBudgetHistory,
+	}, args, out)
+}
+
+func budgetTable(budgets ...lib.Budget) table {
+	t := table{headers: []string{"ID", "CATEGORY", "AMOUNT", "PERIOD", "START", "ALERT_%", "ROLLOVER"}}
+	for _, b := range budgets {
+		t.add(b.ID, b.Category, formatAmount(b.Amount), b.Period, b.StartDate.Format("2006-01-02"),
+			strconv.Itoa(b.AlertThreshold), firstNonEmpty(b.RolloverPolicy, lib.RolloverNone))
+	}
+	return t
+}
+
+func runBudgetList(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("budgets list")
+	var params lib.BudgetListParams
+	fs.StringVar(&params.Period, "period", "", "weekly, monthly or yearly (default monthly)")
+	fs.BoolVar(&params.IncludeDeleted, "include-deleted", false, "also list budgets in the trash")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	budgets, err := c.ListBudgets(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"ID", "CATEGORY", "AMOUNT", "PERIOD", "ALLOWANCE", "SPENT", "REMAINING", "USED_%", "ALERT"}}
+	for _, b := range budgets {
+		p := b.CurrentPeriod
+		t.add(b.ID, b.Category, formatAmount(b.Amount), b.Period, formatAmount(p.Allowance), formatAmount(p.Spent),
+			formatAmount(p.Remaining), strconv.FormatFloat(p.Utilization, 'f', 1, 64), formatBool(p.AlertActive))
+	}
+	return g.print(out, budgets, t)
+}
+
+func runBudgetGet(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("budgets get")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(positional, 1, "a budget ID"); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	budget, err := c.GetBudget(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return g.print(out, budget, withETag(budgetTable(*budget), budget.UpdatedAt))
+}
+
+func runBudgetAdd(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("budgets add")
+	var input lib.CreateBudgetInput
+	fs.StringVar(&input.Category, "category", "", "category (required)")
+	fs.Float64Var(&input.Amount, "amount", 0, "amount per period, greater than 0 (required)")
+	fs.StringVar(&input.Period, "period", "monthly", "weekly, monthly or yearly")
+	fs.StringVar(&input.StartDate, "start", "", "YYYY-MM-DD start date (default today)")
+	fs.StringVar(&input.EndDate, "end", "", "YYYY-MM-DD end date")
+	fs.IntVar(&input.AlertThreshold, "alert", 0, "percent of the allowance spent that raises an alert")
+	fs.StringVar(&input.RolloverPolicy, "rollover", "", "none, surplus, deficit or both")
+	fs.Float64Var(&input.RolloverCap, "rollover-cap", 0, "largest amount carried between periods, 0 for no cap")
+	key := fs.String("idempotency-key", "", "repeat a create safely: the same key returns the first result")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if errs := lib.Validate(input); len(errs) > 0 {
+		return errUsage(errs.Error())
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	if *key != "" {
+		ctx = client.WithIdempotencyKey(ctx, *key)
+	}
+	budget, err := c.CreateBudget(ctx, input)
+	if err != nil {
+		return err
+	}
+	return g.print(out, budget, budgetTable(*budget))
+}
+
+func runBudgetEdit(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("budgets edit")
+	version := newVersionFlags(fs)
+	fs.String("category", "", "new category")
+	fs.String("amount", "", "new amount per period")
+	fs.String("period", "", "weekly, monthly or yearly")
+	fs.String("start", "", "new YYYY-MM-DD start date")
+	fs.String("end", "", "new YYYY-MM-DD end date")
+	fs.String("alert", "", "new alert threshold percent")
+	fs.String("rollover", "", "none, surplus, deficit or both")
+	fs.String("rollover-cap", "", "new rollover cap")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(positional, 1, "a budget ID"); err != nil {
+		return err
+	}
+
+	// Only the flags given are changed
+	var input lib.UpdateBudgetInput
+	var parseErr error
+	changed := 0
+	fs.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "category":
+			input.Category = &value
+		case "amount", "rollover-cap":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				parseErr = errUsage("--" + f.Name + " must be a number")
+			}
+			if f.Name == "amount" {
+				input.Amount = &number
+			} else {
+				input.RolloverCap = &number
+			}
+		case "period":
+			input.Period = &value
+		case "start":
+			input.StartDate = &value
+		case "end":
+			input.EndDate = &value
+		case "alert":
+			percent, err := strconv.Atoi(value)
+			if err != nil {
+				parseErr = errUsage("--alert must be a whole number")
+			}
+			input.AlertThreshold = &percent
+		case "rollover":
+			input.RolloverPolicy = &value
+		default:
+			return
+		}
+		changed++
+	})
+	if parseErr != nil {
+		return parseErr
+	}
+	if changed == 0 {
+		return errUsage("nothing to change; pass --amount, --category, ...")
+	}
+	if errs := lib.Validate(input); len(errs) > 0 {
+		return errUsage(errs.Error())
+	}
+
+	ctx, err = version.context(ctx)
+	if err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	budget, err := c.UpdateBudget(ctx, positional[0], input)
+	if err != nil {
+		return err
+	}
+	return g.print(out, budget, budgetTable(*budget))
+}
+
+func runBudgetDelete(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("budgets delete")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(positional, 1, "a budget ID"); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	result, err := c.DeleteBudget(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return g.print(out, result, trashedTable(result))
+}
+
+func runBudgetHistory(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("budgets history")
+	var params lib.BudgetHistoryParams
+	fs.IntVar(&params.Periods, "periods", 0, "periods to show, most recent first (default 12)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(positional, 1, "a budget ID"); err != nil {
+		return err
+	}
+	params.ID = positional[0]
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	result, err := c.BudgetHistory(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"START", "END", "ALLOWANCE", "CARRIED_IN", "SPENT", "REMAINING", "CARRIED_OUT", "USED_%"}}
+	for _, p := range result.History {
+		t.add(p.StartDate, p.EndDate, formatAmount(p.Allowance), formatAmount(p.CarriedIn), formatAmount(p.Spent),
+			formatAmount(p.Remaining), formatAmount(p.CarriedOut), strconv.FormatFloat(p.Utilization, 'f', 1, 64))
+	}
+	return g.print(out, result, t)
+}
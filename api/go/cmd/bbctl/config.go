@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/budget-buddy/api/client"
+	"github.com/budget-buddy/api/lib"
+)
+
+const defaultAPIURL = "http://localhost:8080"
+
+// Config is the login stored between runs
+type Config struct {
+	APIURL      string    `json:"api_url"`
+	Token       string    `json:"token,omitempty"`
+	WorkspaceID string    `json:"workspace_id,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at,omitzero"`
+}
+
+// configPath is BBCTL_CONFIG, or bbctl/config.json in the user's config
+// directory
+func configPath() (string, error) {
+	if path := os.Getenv("BBCTL_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bbctl", "config.json"), nil
+}
+
+// loadConfig reads the stored login, returning an empty one when there is none
+func loadConfig() (Config, error) {
+	var config Config
+	path, err := configPath()
+	if err != nil {
+		return config, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if config.Token != "" && !config.ExpiresAt.IsZero() && time.Now().After(config.ExpiresAt) {
+		fmt.Fprintln(os.Stderr, "bbctl: the stored token has expired; run bbctl login")
+	}
+	return config, nil
+}
+
+// saveConfig stores the login, readable only by the user since it holds a token
+func saveConfig(config Config) (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// runLogin stores a token, given with --token, read from stdin, or obtained
+// from Supabase Auth with --email and a password read from BBCTL_PASSWORD or
+// stdin. The token is checked against the API before it is stored.
+func runLogin(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("login")
+	email := fs.String("email", "", "sign in to Supabase Auth with this email")
+	supabaseURL := fs.String("supabase-url", firstNonEmpty(os.Getenv("SUPABASE_URL"), os.Getenv("NEXT_PUBLIC_SUPABASE_URL")), "Supabase project URL, for --email")
+	anonKey := fs.String("anon-key", firstNonEmpty(os.Getenv("SUPABASE_ANON_KEY"), os.Getenv("NEXT_PUBLIC_SUPABASE_ANON_KEY")), "Supabase anon key, for --email")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	config.APIURL = firstNonEmpty(g.apiURL, config.APIURL, defaultAPIURL)
+	config.WorkspaceID = firstNonEmpty(g.workspace, config.WorkspaceID)
+	config.ExpiresAt = time.Time{}
+
+	stdin := bufio.NewReader(os.Stdin)
+	switch {
+	case g.token != "":
+		config.Token = g.token
+	case *email != "":
+		if *supabaseURL == "" || *anonKey == "" {
+			return errUsage("--email needs --supabase-url and --anon-key, or SUPABASE_URL and SUPABASE_ANON_KEY")
+		}
+		password := os.Getenv("BBCTL_PASSWORD")
+		if password == "" {
+			fmt.Fprint(os.Stderr, "Password: ")
+			if password, err = readLine(stdin); err != nil {
+				return err
+			}
+		}
+		token, expiresIn, err := signIn(ctx, *supabaseURL, *anonKey, *email, password)
+		if err != nil {
+			return err
+		}
+		config.Token = token
+		config.ExpiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	default:
+		fmt.Fprint(os.Stderr, "Access token: ")
+		if config.Token, err = readLine(stdin); err != nil {
+			return err
+		}
+	}
+	if config.Token == "" {
+		return errUsage("no token given")
+	}
+
+	c := client.New(config.APIURL, client.WithToken(config.Token), client.WithUserAgent("bbctl/"+lib.APIVersion))
+	profile, err := c.Profile(ctx)
+	if err != nil {
+		return fmt.Errorf("checking the token: %w", err)
+	}
+	path, err := saveConfig(config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Logged in to %s as %s; saved to %s\n", config.APIURL, firstNonEmpty(profile.Email, profile.ID), path)
+	return nil
+}
+
+func runLogout(ctx context.Context, args []string, out io.Writer) error {
+	fs, _ := newFlagSet("logout")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	config.Token = ""
+	config.ExpiresAt = time.Time{}
+	if _, err := saveConfig(config); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Logged out")
+	return nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// signIn exchanges an email and password for an access token with Supabase
+// Auth's password grant
+func signIn(ctx context.Context, supabaseURL, anonKey, email, password string) (string, int, error) {
+	payload, err := json.Marshal(map[string]string{"email": email, "password": password})
+	if err != nil {
+		return "", 0, err
+	}
+	endpoint := strings.TrimSuffix(supabaseURL, "/") + "/auth/v1/token?grant_type=password"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("apikey", anonKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		ErrorDescription string `json:"error_description"`
+		Message          string `json:"msg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("signing in: HTTP %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return "", 0, fmt.Errorf("signing in: %s", firstNonEmpty(result.ErrorDescription, result.Message, http.StatusText(resp.StatusCode)))
+	}
+	return result.AccessToken, result.ExpiresIn, nil
+}
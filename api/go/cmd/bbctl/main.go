@@ -0,0 +1,289 @@
+// Command bbctl is a command-line client for the Budget Buddy API:
+//
+//	bbctl login --api-url https://your-app.vercel.app
+//	bbctl transactions list --type expense -o csv
+//	bbctl analytics forecast --days 60
+//
+// Run bbctl help for every command.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/budget-buddy/api/client"
+	"github.com/budget-buddy/api/lib"
+)
+
+const usage = `bbctl is a command-line client for the Budget Buddy API.
+
+Usage:
+  bbctl <command> [subcommand] [flags] [args]
+
+Commands:
+  login                       Store the API URL and an access token
+  logout                      Forget the stored access token
+  transactions (tx) list      List transactions (--type, --category, --all, ...)
+  transactions get ID         Show a transaction
+  transactions add            Create a transaction (--amount, --category, --type, ...)
+  transactions edit ID        Change the given fields of a transaction (--if-match or --force)
+  transactions delete ID      Move a transaction to the trash
+  budgets list|get|add|edit|delete|history
+                              Manage budgets
+  analytics TYPE              Run summary, category, trend, forecast or anomalies
+  import FILE                 Create transactions from a CSV or JSON file
+  export [FILE]               Write transactions to a CSV or JSON file, or stdout
+  health                      Show API health; --ready probes its dependencies
+  version                     Show the bbctl and API versions
+
+Flags accepted by every command:
+  --api-url URL     API base URL (BBCTL_API_URL, the stored login, or http://localhost:8080)
+  --token TOKEN     Access token (BBCTL_TOKEN or the stored login)
+  --workspace ID    Act in a shared workspace (BBCTL_WORKSPACE)
+  -o, --output FMT  table, json or csv (default table)
+
+Run bbctl <command> -h for the flags of a command.
+`
+
+// errUsage reports a mistake on the command line; its message is printed
+// with a hint to run help
+type errUsage string
+
+func (e errUsage) Error() string {
+	return string(e)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout)
+	var usageErr errUsage
+	var apiErr *client.Error
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(os.Stderr, "bbctl:", err)
+		fmt.Fprintln(os.Stderr, "Run bbctl help for usage.")
+		os.Exit(2)
+	case errors.As(err, &apiErr):
+		fmt.Fprintln(os.Stderr, "bbctl:", err)
+		if len(apiErr.Details) > 0 && string(apiErr.Details) != "null" {
+			fmt.Fprintln(os.Stderr, "details:", string(apiErr.Details))
+		}
+		os.Exit(1)
+	default:
+		fmt.Fprintln(os.Stderr, "bbctl:", err)
+		os.Exit(1)
+	}
+}
+
+// command runs one command with its arguments
+type command func(ctx context.Context, args []string, out io.Writer) error
+
+var commands = map[string]command{
+	"login":        runLogin,
+	"logout":       runLogout,
+	"transactions": runTransactions,
+	"tx":           runTransactions,
+	"budgets":      runBudgets,
+	"analytics":    runAnalytics,
+	"import":       runImport,
+	"export":       runExport,
+	"health":       runHealth,
+	"version":      runVersion,
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(out, usage)
+		return nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return errUsage(fmt.Sprintf("unknown command %q", args[0]))
+	}
+	return cmd(ctx, args[1:], out)
+}
+
+// subcommand dispatches to one of subcommands by the first argument
+func subcommand(ctx context.Context, name string, subcommands map[string]command, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(subcommands))
+		for sub := range subcommands {
+			names = append(names, sub)
+		}
+		return errUsage(name + " needs a subcommand: " + strings.Join(sortedStrings(names), ", "))
+	}
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		return errUsage(fmt.Sprintf("unknown %s subcommand %q", name, args[0]))
+	}
+	return cmd(ctx, args[1:], out)
+}
+
+// globalFlags are accepted by every command
+type globalFlags struct {
+	apiURL    string
+	token     string
+	workspace string
+	output    string
+}
+
+// newFlagSet creates a flag set for a command with the global flags
+func newFlagSet(name string) (*flag.FlagSet, *globalFlags) {
+	fs := flag.NewFlagSet("bbctl "+name, flag.ContinueOnError)
+	g := &globalFlags{}
+	fs.StringVar(&g.apiURL, "api-url", os.Getenv("BBCTL_API_URL"), "API base URL")
+	fs.StringVar(&g.token, "token", os.Getenv("BBCTL_TOKEN"), "access token")
+	fs.StringVar(&g.workspace, "workspace", os.Getenv("BBCTL_WORKSPACE"), "shared workspace ID")
+	fs.StringVar(&g.output, "output", "table", "output format: table, json or csv")
+	fs.StringVar(&g.output, "o", "table", "shorthand for --output")
+	return fs, g
+}
+
+// parseArgs parses flags wherever they appear among the positional
+// arguments, which it returns
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errUsage(err.Error())
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// expectArgs checks the number of positional arguments
+func expectArgs(args []string, n int, names string) error {
+	if len(args) != n {
+		return errUsage("expected " + names)
+	}
+	return nil
+}
+
+// versionFlags adds the --if-match and --force flags of an edit command
+type versionFlags struct {
+	ifMatch string
+	force   bool
+}
+
+func newVersionFlags(fs *flag.FlagSet) *versionFlags {
+	v := &versionFlags{}
+	fs.StringVar(&v.ifMatch, "if-match", "", "ETag of the version being changed, as shown by get")
+	fs.BoolVar(&v.force, "force", false, "change whatever the current version is")
+	return v
+}
+
+// context returns ctx carrying the version an edit is conditional on, which
+// the API requires so an edit can't overwrite a change it never saw
+func (v *versionFlags) context(ctx context.Context) (context.Context, error) {
+	switch {
+	case v.ifMatch != "" && v.force:
+		return nil, errUsage("pass either --if-match or --force, not both")
+	case v.ifMatch != "":
+		// The shell strips the quotes of an ETag pasted unquoted
+		etag := v.ifMatch
+		if !strings.HasPrefix(etag, `"`) {
+			etag = `"` + etag + `"`
+		}
+		return client.WithIfMatch(ctx, etag), nil
+	case v.force:
+		return client.WithForce(ctx), nil
+	}
+	return nil, errUsage("pass --if-match with the ETag shown by get, or --force to overwrite any version")
+}
+
+// client creates an API client from the flags, environment and stored login
+func (g *globalFlags) client() (*client.Client, error) {
+	switch g.output {
+	case "table", "json", "csv":
+	default:
+		return nil, errUsage(fmt.Sprintf("unknown output format %q", g.output))
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	apiURL := firstNonEmpty(g.apiURL, config.APIURL, defaultAPIURL)
+	token := firstNonEmpty(g.token, config.Token)
+	workspace := firstNonEmpty(g.workspace, config.WorkspaceID)
+	return client.New(apiURL,
+		client.WithToken(token),
+		client.WithWorkspace(workspace),
+		client.WithUserAgent("bbctl/"+lib.APIVersion),
+	), nil
+}
+
+func runHealth(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("health")
+	ready := fs.Bool("ready", false, "also probe the API's dependencies")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	status, healthErr := c.Health(ctx, *ready)
+	if status == nil {
+		return healthErr
+	}
+
+	t := table{headers: []string{"CHECK", "STATUS", "CRITICAL", "LATENCY_MS", "ERROR"}}
+	t.add("api", status.Status, "", "", "")
+	for _, check := range status.Checks {
+		t.add(check.Name, check.Status, formatBool(check.Critical), formatFloat(check.LatencyMS), check.Error)
+	}
+	if err := g.print(out, status, t); err != nil {
+		return err
+	}
+	if g.output == "table" {
+		fmt.Fprintf(out, "\nversion %s, commit %s, built %s\n",
+			status.Build.Version, firstNonEmpty(status.Build.GitSHA, "unknown"), firstNonEmpty(status.Build.BuildTime, "unknown"))
+	}
+	return healthErr
+}
+
+func runVersion(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("version")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	versions := map[string]string{"bbctl": lib.CurrentBuildInfo().Version}
+	if c, err := g.client(); err == nil {
+		if status, err := c.Health(ctx, false); err == nil {
+			versions["api"] = status.Build.Version
+		}
+	}
+	if g.output == "json" {
+		return json.NewEncoder(out).Encode(versions)
+	}
+	fmt.Fprintln(out, "bbctl", versions["bbctl"])
+	fmt.Fprintln(out, "api  ", firstNonEmpty(versions["api"], "unreachable"))
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
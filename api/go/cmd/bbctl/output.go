@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// table is the tabular form of a result, printed as aligned columns or CSV
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// print writes a result in the chosen output format: value as indented JSON,
+// or t as a table or CSV
+func (g *globalFlags) print(out io.Writer, value interface{}, t table) error {
+	switch g.output {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case "csv":
+		w := csv.NewWriter(out)
+		headers := make([]string, len(t.headers))
+		for i, header := range t.headers {
+			headers[i] = strings.ToLower(header)
+		}
+		w.Write(headers)
+		w.WriteAll(t.rows)
+		return w.Error()
+	default:
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		io.WriteString(w, strings.Join(t.headers, "\t")+"\n")
+		for _, row := range t.rows {
+			io.WriteString(w, strings.Join(row, "\t")+"\n")
+		}
+		return w.Flush()
+	}
+}
+
+// withETag adds the ETag of a resource last modified at updatedAt to the one
+// row of t, for edit --if-match
+func withETag(t table, updatedAt time.Time) table {
+	t.headers = append(t.headers, "ETAG")
+	t.rows[0] = append(t.rows[0], lib.ETag(updatedAt))
+	return t
+}
+
+// keyValues is a table of one object's fields
+func keyValues(pairs ...string) table {
+	t := table{headers: []string{"FIELD", "VALUE"}}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		t.add(pairs[i], pairs[i+1])
+	}
+	return t
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatBool(v bool) string {
+	return strconv.FormatBool(v)
+}
+
+func sortedStrings(values []string) []string {
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+func TestPrint(t *testing.T) {
+	value := []map[string]interface{}{
+		{"category": "Food", "amount": 12.5},
+		{"category": "Rent, utilities", "amount": 900},
+	}
+	tab := table{headers: []string{"CATEGORY", "AMOUNT"}}
+	tab.add("Food", formatAmount(12.5))
+	tab.add("Rent, utilities", formatAmount(900))
+
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"table", "CATEGORY         AMOUNT\n" +
+			"Food             12.50\n" +
+			"Rent, utilities  900.00\n"},
+		// CSV headers are lower case and cells are quoted as needed
+		{"csv", "category,amount\n" +
+			"Food,12.50\n" +
+			"\"Rent, utilities\",900.00\n"},
+		// JSON prints the value itself rather than the table
+		{"json", `[
+  {
+    "amount": 12.5,
+    "category": "Food"
+  },
+  {
+    "amount": 900,
+    "category": "Rent, utilities"
+  }
+]
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			g := &globalFlags{output: tt.output}
+			var out strings.Builder
+			if err := g.print(&out, value, tab); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("printed\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWithETag(t *testing.T) {
+	updatedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tab := table{headers: []string{"ID", "CATEGORY"}}
+	tab.add("tx-1", "Food")
+	tab = withETag(tab, updatedAt)
+
+	if got := strings.Join(tab.headers, ","); got != "ID,CATEGORY,ETAG" {
+		t.Errorf("headers = %s", got)
+	}
+	if got := tab.rows[0][2]; got != lib.ETag(updatedAt) {
+		t.Errorf("ETag = %s, want %s", got, lib.ETag(updatedAt))
+	}
+}
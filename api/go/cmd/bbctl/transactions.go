@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/budget-buddy/api/client"
+	"github.com/budget-buddy/api/lib"
+)
+
+func runTransactions(ctx context.Context, args []string, out io.Writer) error {
+	return subcommand(ctx, "transactions", map[string]command{
+		"list":   runTransactionList,
+		"get":    runTransactionGet,
+		"add":    runTransactionAdd,
+		"edit":   runTransactionEdit,
+		"delete": runTransactionDelete,
+	}, args, out)
+}
+
+// transactionFilterFlags registers the list filters shared by list and export
+func transactionFilterFlags(fs *flag.FlagSet) *lib.TransactionListParams {
+	params := &lib.TransactionListParams{}
+	fs.StringVar(&params.Type, "type", "", "only income or expense")
+	fs.StringVar(&params.Category, "category", "", "only this category")
+	fs.BoolVar(&params.IncludeDeleted, "include-deleted", false, "also list transactions in the trash")
+	return params
+}
+
+// listAllTransactions fetches every page matching params, summarizing the
+// transactions as the API does for one page
+func listAllTransactions(ctx context.Context, c *client.Client, params lib.TransactionListParams) (*lib.TransactionListResponse, error) {
+	params.Limit = 100
+	all := &lib.TransactionListResponse{Transactions: []lib.Transaction{}}
+	for tx, err := range c.AllTransactions(ctx, params) {
+		if err != nil {
+			return nil, err
+		}
+		all.Transactions = append(all.Transactions, tx)
+		if tx.DeletedAt != nil {
+			continue
+		}
+		if tx.Type == "income" {
+			all.Summary.TotalIncome += tx.Amount
+		} else {
+			all.Summary.TotalExpenses += tx.Amount
+		}
+	}
+	all.Summary.Count = len(all.Transactions)
+	all.Pagination = lib.Pagination{Total: len(all.Transactions), Limit: len(all.Transactions)}
+	return all, nil
+}
+
+func transactionTable(transactions ...lib.Transaction) table {
+	t := table{headers: []string{"ID", "DATE", "TYPE", "AMOUNT", "CATEGORY", "DESCRIPTION", "MERCHANT"}}
+	for _, tx := range transactions {
+		t.add(tx.ID, tx.Date.Format("2006-01-02"), tx.Type, formatAmount(tx.Amount), tx.Category, tx.Description, tx.Merchant)
+	}
+	return t
+}
+
+func runTransactionList(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("transactions list")
+	params := transactionFilterFlags(fs)
+	fs.IntVar(&params.Limit, "limit", 0, "page size, up to 100 (default 50)")
+	fs.IntVar(&params.Offset, "offset", 0, "transactions to skip")
+	all := fs.Bool("all", false, "fetch every page")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+
+	var result *lib.TransactionListResponse
+	if *all {
+		result, err = listAllTransactions(ctx, c, *params)
+	} else {
+		result, err = c.ListTransactions(ctx, *params)
+	}
+	if err != nil {
+		return err
+	}
+	if err := g.print(out, result, transactionTable(result.Transactions...)); err != nil {
+		return err
+	}
+	if g.output == "table" {
+		fmt.Fprintf(out, "\n%d transactions, income %s, expenses %s", result.Summary.Count,
+			formatAmount(result.Summary.TotalIncome), formatAmount(result.Summary.TotalExpenses))
+		if result.Pagination.HasMore {
+			fmt.Fprintf(out, "; more with --offset %d or --all", result.Pagination.Offset+len(result.Transactions))
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+func runTransactionGet(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("transactions get")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(positional, 1, "a transaction ID"); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	tx, err := c.GetTransaction(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return g.print(out, tx, withETag(transactionTable(*tx), tx.UpdatedAt))
+}
+
+func runTransactionAdd(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("transactions add")
+	var input lib.CreateTransactionInput
+	fs.Float64Var(&input.Amount, "amount", 0, "amount, greater than 0 (required)")
+	fs.StringVar(&input.Category, "category", "", "category (required)")
+	fs.StringVar(&input.Type, "type", "expense", "income or expense")
+	fs.StringVar(&input.Description, "description", "", "description")
+	fs.StringVar(&input.Date, "date", "", "YYYY-MM-DD date or RFC 3339 timestamp (default now)")
+	fs.StringVar(&input.Merchant, "merchant", "", "merchant")
+	fs.StringVar(&input.PaymentMethod, "payment-method", "", "payment method")
+	key := fs.String("idempotency-key", "", "repeat a create safely: the same key returns the first result")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if errs := lib.Validate(input); len(errs) > 0 {
+		return errUsage(errs.Error())
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	if *key != "" {
+		ctx = client.WithIdempotencyKey(ctx, *key)
+	}
+	tx, err := c.CreateTransaction(ctx, input)
+	if err != nil {
+		return err
+	}
+	return g.print(out, tx, transactionTable(*tx))
+}
+
+func runTransactionEdit(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("transactions edit")
+	version := newVersionFlags(fs)
+	fs.String("amount", "", "new amount")
+	fs.String("category", "", "new category")
+	fs.String("type", "", "income or expense")
+	fs.String("description", "", "new description")
+	fs.String("date", "", "new YYYY-MM-DD date or RFC 3339 timestamp")
+	fs.String("merchant", "", "new merchant")
+	fs.String("payment-method", "", "new payment method")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(positional, 1, "a transaction ID"); err != nil {
+		return err
+	}
+
+	// Only the flags given are changed
+	var input lib.UpdateTransactionInput
+	var parseErr error
+	changed := 0
+	fs.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "amount":
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				parseErr = errUsage("--amount must be a number")
+			}
+			input.Amount = &amount
+		case "category":
+			input.Category = &value
+		case "type":
+			input.Type = &value
+		case "description":
+			input.Description = &value
+		case "date":
+			input.Date = &value
+		case "merchant":
+			input.Merchant = &value
+		case "payment-method":
+			input.PaymentMethod = &value
+		default:
+			return
+		}
+		changed++
+	})
+	if parseErr != nil {
+		return parseErr
+	}
+	if changed == 0 {
+		return errUsage("nothing to change; pass --amount, --category, ...")
+	}
+	if errs := lib.Validate(input); len(errs) > 0 {
+		return errUsage(errs.Error())
+	}
+
+	ctx, err = version.context(ctx)
+	if err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	tx, err := c.UpdateTransaction(ctx, positional[0], input)
+	if err != nil {
+		return err
+	}
+	return g.print(out, tx, transactionTable(*tx))
+}
+
+func runTransactionDelete(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("transactions delete")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(positional, 1, "a transaction ID"); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	result, err := c.DeleteTransaction(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return g.print(out, result, trashedTable(result))
+}
+
+func trashedTable(result *lib.TrashedResponse) table {
+	return keyValues("message", result.Message, "id", result.Item.ID, "type", result.Item.Type, "purge_at", result.Item.PurgeAt.Format("2006-01-02"))
+}
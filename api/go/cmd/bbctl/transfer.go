@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/budget-buddy/api/client"
+	"github.com/budget-buddy/api/lib"
+)
+
+// transferColumns are the CSV columns written by export. Import reads the same
+// columns by header name, in any order, ignoring id and unknown columns.
+var transferColumns = []string{"id", "date", "type", "amount", "category", "description", "merchant", "payment_method"}
+
+// transferFormat picks csv or json from --format, or else the file extension
+func transferFormat(format, path string) (string, error) {
+	if format == "" {
+		format = "csv"
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			format = "json"
+		}
+	}
+	if format != "csv" && format != "json" {
+		return "", errUsage(fmt.Sprintf("unknown file format %q, expected csv or json", format))
+	}
+	return format, nil
+}
+
+func runExport(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("export")
+	params := transactionFilterFlags(fs)
+	format := fs.String("format", "", "csv or json (default from the file extension, else csv)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errUsage("expected at most one file")
+	}
+	path := "-"
+	if len(positional) == 1 {
+		path = positional[0]
+	}
+	if *format, err = transferFormat(*format, path); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	result, err := listAllTransactions(ctx, c, *params)
+	if err != nil {
+		return err
+	}
+
+	w := out
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeTransactions(w, *format, result.Transactions); err != nil {
+		return err
+	}
+	if path != "-" {
+		fmt.Fprintf(out, "Exported %d transactions to %s\n", len(result.Transactions), path)
+	}
+	return nil
+}
+
+func writeTransactions(w io.Writer, format string, transactions []lib.Transaction) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(transactions)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(transferColumns)
+	for _, tx := range transactions {
+		cw.Write([]string{tx.ID, tx.Date.Format(time.RFC3339), tx.Type, formatFloat(tx.Amount),
+			tx.Category, tx.Description, tx.Merchant, tx.PaymentMethod})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func runImport(ctx context.Context, args []string, out io.Writer) error {
+	fs, g := newFlagSet("import")
+	format := fs.String("format", "", "csv or json (default from the file extension, else csv)")
+	dryRun := fs.Bool("dry-run", false, "validate the file without creating anything")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(positional, 1, "a CSV or JSON file, or - for stdin"); err != nil {
+		return err
+	}
+	path := positional[0]
+	if *format, err = transferFormat(*format, path); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	inputs, err := readTransactions(r, *format)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	// Check every row before creating any
+	invalid := 0
+	for i, input := range inputs {
+		if errs := lib.Validate(input); len(errs) > 0 {
+			fmt.Fprintf(os.Stderr, "row %d: %s\n", i+1, errs.Error())
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d rows are invalid; nothing was imported", invalid, len(inputs))
+	}
+	if *dryRun {
+		fmt.Fprintf(out, "%d transactions are valid\n", len(inputs))
+		return nil
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for i, input := range inputs {
+		// Keys derived from each row make re-running an interrupted import
+		// replay the rows already created instead of duplicating them
+		if _, err := c.CreateTransaction(client.WithIdempotencyKey(ctx, importKey(i, input)), input); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "row %d: %s\n", i+1, err)
+			failed++
+		}
+	}
+	fmt.Fprintf(out, "Imported %d of %d transactions\n", len(inputs)-failed, len(inputs))
+	if failed > 0 {
+		return fmt.Errorf("%d rows failed", failed)
+	}
+	return nil
+}
+
+// importKey is the Idempotency-Key of row i
+func importKey(i int, input lib.CreateTransactionInput) string {
+	payload, _ := json.Marshal(input)
+	sum := sha256.Sum256(append([]byte(strconv.Itoa(i)+":"), payload...))
+	return "bbctl-import-" + hex.EncodeToString(sum[:16])
+}
+
+// readTransactions reads a JSON array of transactions, such as an export, or
+// a CSV file with a header row
+func readTransactions(r io.Reader, format string) ([]lib.CreateTransactionInput, error) {
+	var inputs []lib.CreateTransactionInput
+	if format == "json" {
+		err := json.NewDecoder(r).Decode(&inputs)
+		return inputs, err
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"amount", "category", "type"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return inputs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		amount, err := strconv.ParseFloat(field("amount"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: amount %q is not a number", line, field("amount"))
+		}
+		inputs = append(inputs, lib.CreateTransactionInput{
+			Amount:        amount,
+			Category:      field("category"),
+			Type:          field("type"),
+			Description:   field("description"),
+			Date:          field("date"),
+			Merchant:      field("merchant"),
+			PaymentMethod: field("payment_method"),
+		})
+	}
+}
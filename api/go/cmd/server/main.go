@@ -0,0 +1,141 @@
+// Command server runs every Go function in one long-lived HTTP server, for
+// local development and container deployments outside Vercel:
+//
+//	go run ./cmd/server -addr :8080
+//
+// Flags default to environment variables, see the README.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// Config is the server configuration
+type Config struct {
+	Addr            string
+	TLSCertFile     string
+	TLSKeyFile      string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+}
+
+// loadConfig reads the configuration from flags, defaulting to the
+// environment
+func loadConfig(args []string) (Config, error) {
+	var config Config
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.StringVar(&config.Addr, "addr", lib.GetEnv("SERVER_ADDR", ":"+lib.GetEnv("PORT", "8080")), "address to listen on")
+	flags.StringVar(&config.TLSCertFile, "tls-cert", lib.GetEnv("TLS_CERT_FILE", ""), "TLS certificate file; serves HTTPS with -tls-key")
+	flags.StringVar(&config.TLSKeyFile, "tls-key", lib.GetEnv("TLS_KEY_FILE", ""), "TLS private key file")
+	flags.DurationVar(&config.ReadTimeout, "read-timeout", envSeconds("SERVER_READ_TIMEOUT_SECONDS", 15), "maximum time to read a request")
+	flags.DurationVar(&config.WriteTimeout, "write-timeout", envSeconds("SERVER_WRITE_TIMEOUT_SECONDS", 30), "maximum time to write a response")
+	flags.DurationVar(&config.IdleTimeout, "idle-timeout", envSeconds("SERVER_IDLE_TIMEOUT_SECONDS", 60), "how long idle keep-alive connections stay open")
+	flags.DurationVar(&config.ShutdownTimeout, "shutdown-timeout", envSeconds("SERVER_SHUTDOWN_TIMEOUT_SECONDS", 20), "how long to wait for in-flight requests on shutdown")
+	if err := flags.Parse(args); err != nil {
+		return config, err
+	}
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return config, errors.New("-tls-cert and -tls-key must be set together")
+	}
+	// The stores would otherwise lose their state on restart without notice
+	if err := lib.RedisConfigError(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// envSeconds reads a duration in whole seconds from an environment variable
+func envSeconds(key string, fallback int) time.Duration {
+	seconds, err := strconv.Atoi(lib.GetEnv(key, ""))
+	if err != nil || seconds < 0 {
+		seconds = fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func main() {
+	config, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		lib.Logger.Error("invalid configuration", "error", err.Error())
+		os.Exit(2)
+	}
+	if err := run(config); err != nil {
+		lib.Logger.Error("server failed", "error", err.Error())
+		os.Exit(1)
+	}
+}
+
+// run serves until SIGINT or SIGTERM, then stops accepting connections and
+// waits up to ShutdownTimeout for in-flight requests
+func run(config Config) error {
+	router, err := NewRouter()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Push metrics for the life of the process rather than from the first request
+	lib.StartOTLPPush(ctx)
+
+	server := &http.Server{
+		Addr:              config.Addr,
+		Handler:           router,
+		ReadTimeout:       config.ReadTimeout,
+		ReadHeaderTimeout: min(config.ReadTimeout, 5*time.Second),
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(lib.Logger.Handler(), slog.LevelWarn),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		lib.Logger.Info("server listening", "addr", config.Addr, "tls", config.TLSCertFile != "", "version", lib.CurrentBuildInfo().Version)
+		if config.TLSCertFile != "" {
+			errs <- server.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	lib.Logger.Info("shutting down", "timeout", config.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	lib.WaitForAnomalyHooks()
+
+	if flushErr := lib.FlushSpans(shutdownCtx); flushErr != nil {
+		lib.Logger.Warn("OTLP trace flush failed", "error", flushErr.Error())
+	}
+
+	// Flush the final counts, which the next periodic push would have sent
+	if endpoint := lib.OTLPMetricsEndpoint(); endpoint != "" {
+		if pushErr := lib.Metrics.PushOTLP(shutdownCtx, endpoint); pushErr != nil {
+			lib.Logger.Warn("final OTLP metrics push failed", "error", pushErr.Error())
+		}
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	handler "github.com/budget-buddy/api"
+	"github.com/budget-buddy/api/lib"
+)
+
+// functions maps each endpoint name in lib.Endpoints to its Vercel function
+var functions = map[string]http.HandlerFunc{
+	"index":        handler.Index,
+	"health":       handler.Health,
+	"transactions": handler.Transactions,
+	"budgets":      handler.Budgets,
+	"analytics":    handler.Analytics,
+	"users":        handler.Users,
+	"debts":        handler.Debts,
+	"envelopes":    handler.Envelopes,
+	"workspaces":   handler.Workspaces,
+	"splits":       handler.Splits,
+	"trash":        handler.Trash,
+	"audit":        handler.Audit,
+	"metrics":      handler.Metrics,
+}
+
+// NewRouter mounts every function at its endpoint path, the same routes as
+// vercel-go.json. Paths match exactly, as on Vercel; anything else is a
+// JSON 404. It fails when an endpoint has no function, so new functions must
+// be added to both lib.Endpoints and functions.
+func NewRouter() (http.Handler, error) {
+	mux := http.NewServeMux()
+	for _, endpoint := range lib.Endpoints {
+		fn, ok := functions[endpoint.Name]
+		if !ok {
+			return nil, fmt.Errorf("no function for endpoint %q", endpoint.Name)
+		}
+		mux.HandleFunc(endpoint.Path, fn)
+	}
+	if len(functions) != len(lib.Endpoints) {
+		return nil, fmt.Errorf("%d functions for %d endpoints", len(functions), len(lib.Endpoints))
+	}
+	// Unknown paths are logged but not traced or counted, which would label
+	// metrics with arbitrary paths
+	mux.HandleFunc("/", lib.Chain(func(w http.ResponseWriter, r *http.Request) {
+		lib.WriteError(w, lib.NotFoundError("Route not found"))
+	}, lib.LogRequests(), lib.ProblemDetails()))
+	return mux, nil
+}
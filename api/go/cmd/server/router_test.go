@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// newTestRouter returns NewRouter with request logging silenced
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	previousLogger := lib.Logger
+	lib.Logger = lib.NewLogger(io.Discard, "error")
+	t.Cleanup(func() { lib.Logger = previousLogger })
+
+	router, err := NewRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestRouterServesEveryEndpoint(t *testing.T) {
+	router := newTestRouter(t)
+	// routed reports whether a request reached a function rather than the
+	// catch-all 404
+	routed := func(t *testing.T, path string) bool {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var resp lib.Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("GET %s: %v: %s", path, err, rec.Body)
+		}
+		return resp.Error != "Route not found"
+	}
+
+	for _, endpoint := range lib.Endpoints {
+		if !routed(t, endpoint.Path) {
+			t.Errorf("%s (%s) is not routed", endpoint.Path, endpoint.Name)
+		}
+	}
+	for _, path := range []string{"/", "/api/go/unknown", "/api/go/transactions/tx-1"} {
+		if routed(t, path) {
+			t.Errorf("%s is routed, want the catch-all 404", path)
+		}
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	router := newTestRouter(t)
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		accept        string
+		wantStatus    int
+		wantType      string
+	}{
+		{name: "disabled", authorization: "Bearer secret", wantStatus: http.StatusNotFound},
+		{name: "missing token", token: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", authorization: "Bearer secrets", wantStatus: http.StatusUnauthorized},
+		{name: "prometheus", token: "secret", authorization: "Bearer secret",
+			wantStatus: http.StatusOK, wantType: lib.PrometheusContentType},
+		{name: "openmetrics", token: "secret", authorization: "Bearer secret", accept: "application/openmetrics-text; version=1.0.0",
+			wantStatus: http.StatusOK, wantType: lib.OpenMetricsContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("METRICS_TOKEN", tt.token)
+			req := httptest.NewRequest(http.MethodGet, "/api/go/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if strings.Contains(rec.Body.String(), "# TYPE") {
+					t.Errorf("metrics served without the token: %s", rec.Body)
+				}
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", got)
+			}
+			if !strings.Contains(rec.Body.String(), "# TYPE "+lib.MetricsPrefix+"http_requests") {
+				t.Errorf("body has no request counter:\n%s", rec.Body)
+			}
+			if got := strings.HasSuffix(rec.Body.String(), "# EOF\n"); got != (tt.wantType == lib.OpenMetricsContentType) {
+				t.Errorf("body ends with # EOF = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// Debts handles debt payoff simulations
+func Debts(w http.ResponseWriter, r *http.Request) {
+	config := lib.Config{
+		RequireAuth:    true,
+		AllowedMethods: []string{"POST"},
+		CORS:           lib.DefaultCORS(),
+		RateLimit:      lib.APIRateLimit(),
+	}
+
+	handler := lib.CreateHandler(debtHandler, config)
+	handler(w, r)
+}
+
+func debtHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := lib.GetUserFromContext(r)
+	if !ok {
+		lib.WriteError(w, lib.UnauthorizedError())
+		return
+	}
+
+	var input lib.DebtPayoffInput
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	start := time.Now().UTC()
+	if input.StartDate != "" {
+		start, _ = time.Parse("2006-01-02", input.StartDate)
+	}
+
+	liabilities := input.Liabilities
+	if len(liabilities) == 0 {
+		liabilities = getUserLiabilities(user)
+	}
+
+	strategies := []string{input.Strategy}
+	if input.Strategy == "" {
+		strategies = []string{lib.StrategyAvalanche, lib.StrategySnowball}
+		if len(input.CustomOrder) > 0 {
+			strategies = append(strategies, lib.StrategyCustom)
+		}
+	}
+
+	var plans []*lib.PayoffPlan
+	for _, strategy := range strategies {
+		plan, err := lib.SimulatePayoff(liabilities, input.MonthlyBudget, strategy, input.CustomOrder, start)
+		if err != nil {
+			lib.WriteError(w, lib.BadRequestError("Unable to simulate payoff plan", map[string]string{
+				"strategy": strategy,
+				"error":    err.Error(),
+			}))
+			return
+		}
+		plans = append(plans, plan)
+	}
+
+	// Recommend the plan with the least interest, preferring the faster one on ties
+	recommended := plans[0]
+	for _, plan := range plans[1:] {
+		if plan.TotalInterest < recommended.TotalInterest ||
+			(plan.TotalInterest == recommended.TotalInterest && plan.Months < recommended.Months) {
+			recommended = plan
+		}
+	}
+
+	lib.SuccessResponse(w, lib.DebtPayoffResponse{
+		Plans:       plans,
+		Recommended: recommended.Strategy,
+	}, http.StatusOK)
+}
+
+func getUserLiabilities(user *lib.User) []lib.Liability {
+	// TODO: Query database
+	return []lib.Liability{
+		{
+			ID:             "liability-1",
+			UserID:         user.ID,
+			Name:           "Credit Card",
+			Balance:        2500.0,
+			APR:            22.9,
+			MinimumPayment: 75.0,
+		},
+		{
+			ID:             "liability-2",
+			UserID:         user.ID,
+			Name:           "Car Loan",
+			Balance:        8000.0,
+			APR:            6.5,
+			MinimumPayment: 250.0,
+		},
+	}
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// Envelopes handles zero-based envelope budgeting
+func Envelopes(w http.ResponseWriter, r *http.Request) {
+	config := lib.Config{
+		RequireAuth:     true,
+		AllowedMethods:  []string{"GET", "POST", "DELETE"},
+		CORS:            lib.DefaultCORS().AllowHeaders("Idempotency-Key").ExposeHeaders("Idempotent-Replayed"),
+		WorkspaceScoped: true,
+		Idempotent:      true,
+		RateLimit:       lib.APIRateLimit(),
+	}
+
+	handler := lib.CreateHandler(envelopeHandler, config)
+	handler(w, r)
+}
+
+func envelopeHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := lib.GetUserFromContext(r)
+	if !ok {
+		lib.WriteError(w, lib.UnauthorizedError())
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		handleGetEnvelopeMonth(w, r, user)
+	case "POST":
+		switch lib.GetQueryParam(r, "action", "create") {
+		case "create":
+			handleCreateEnvelope(w, r, user)
+		case "assign":
+			handleAssignToEnvelope(w, r, user)
+		case "move":
+			handleMoveBetweenEnvelopes(w, r, user)
+		default:
+			lib.WriteError(w, lib.BadRequestError("Invalid action", map[string]interface{}{
+				"allowed": []string{"create", "assign", "move"},
+			}))
+		}
+	case "DELETE":
+		handleDeleteEnvelope(w, r, user)
+	default:
+		lib.WriteError(w, lib.MethodNotAllowedError(nil))
+	}
+}
+
+func handleGetEnvelopeMonth(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var params lib.EnvelopeMonthParams
+	if !lib.BindQuery(w, r, &params) {
+		return
+	}
+
+	if params.Month == "" {
+		params.Month = time.Now().UTC().Format(lib.MonthFormat)
+	}
+
+	view, err := computeEnvelopeMonth(r.Context(), user, params.Month, nil, nil)
+	if err != nil {
+		lib.WriteError(w, err)
+		return
+	}
+
+	lib.SuccessResponse(w, lib.EnvelopeMonthResponse{
+		Budget: view,
+	}, http.StatusOK)
+}
+
+func handleCreateEnvelope(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var input lib.CreateEnvelopeInput
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	for _, e := range getEnvelopes(user) {
+		if e.Category == input.Category {
+			lib.WriteError(w, lib.ConflictError("An envelope already exists for this category", map[string]string{
+				"envelope_id": e.ID,
+			}))
+			return
+		}
+	}
+
+	rollover := true
+	if input.Rollover != nil {
+		rollover = *input.Rollover
+	}
+
+	// TODO: Insert into database
+	now := time.Now().UTC()
+	envelope := lib.Envelope{
+		ID:          "envelope-new",
+		UserID:      user.ID,
+		WorkspaceID: lib.WorkspaceIDFromContext(r),
+		Name:        input.Name,
+		Category:    input.Category,
+		Rollover:    rollover,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditCreate, Resource: "envelope", ResourceID: envelope.ID, After: envelope}) {
+		return
+	}
+
+	lib.SuccessResponse(w, lib.EnvelopeResponse{
+		Envelope: envelope,
+	}, http.StatusCreated)
+}
+
+func handleAssignToEnvelope(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var input lib.EnvelopeAssignment
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	if input.Month == "" {
+		input.Month = time.Now().UTC().Format(lib.MonthFormat)
+	}
+
+	if !hasEnvelope(user, input.EnvelopeID) {
+		lib.WriteError(w, lib.NotFoundError("Envelope not found"))
+		return
+	}
+
+	// TODO: Insert into database
+	view, err := computeEnvelopeMonth(r.Context(), user, input.Month, []lib.EnvelopeAssignment{input}, nil)
+	if err != nil {
+		lib.WriteError(w, err)
+		return
+	}
+
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditCreate, Resource: "envelope_assignment", ResourceID: input.EnvelopeID, After: input}) {
+		return
+	}
+
+	lib.SuccessResponse(w, lib.EnvelopeAssignmentResponse{
+		Assignment: input,
+		Budget:     view,
+	}, http.StatusCreated)
+}
+
+func handleMoveBetweenEnvelopes(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var input lib.EnvelopeTransfer
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	if input.FromEnvelopeID == input.ToEnvelopeID {
+		lib.WriteError(w, lib.ValidationError(lib.ValidationErrors{
+			{Field: "to_envelope_id", Message: "must differ from from_envelope_id"},
+		}))
+		return
+	}
+
+	if input.Month == "" {
+		input.Month = time.Now().UTC().Format(lib.MonthFormat)
+	}
+
+	if !hasEnvelope(user, input.FromEnvelopeID) || !hasEnvelope(user, input.ToEnvelopeID) {
+		lib.WriteError(w, lib.NotFoundError("Envelope not found"))
+		return
+	}
+
+	current, err := computeEnvelopeMonth(r.Context(), user, input.Month, nil, nil)
+	if err != nil {
+		lib.WriteError(w, err)
+		return
+	}
+
+	available, _ := current.EnvelopeAvailable(input.FromEnvelopeID)
+	if input.Amount > available {
+		lib.WriteError(w, lib.UnprocessableError("Insufficient funds in source envelope", map[string]float64{
+			"available": available,
+		}))
+		return
+	}
+
+	// TODO: Insert into database
+	view, err := computeEnvelopeMonth(r.Context(), user, input.Month, nil, []lib.EnvelopeTransfer{input})
+	if err != nil {
+		lib.WriteError(w, err)
+		return
+	}
+
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditCreate, Resource: "envelope_transfer", ResourceID: input.FromEnvelopeID, After: input}) {
+		return
+	}
+
+	lib.SuccessResponse(w, lib.EnvelopeTransferResponse{
+		Transfer: input,
+		Budget:   view,
+	}, http.StatusCreated)
+}
+
+func handleDeleteEnvelope(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	id := lib.GetQueryParam(r, "id", "")
+	if id == "" {
+		lib.WriteError(w, lib.BadRequestError("Envelope ID required", nil))
+		return
+	}
+
+	// TODO: Delete from database; remaining money returns to "to be assigned"
+	event := lib.AuditEvent{Action: lib.AuditDelete, Resource: "envelope", ResourceID: id}
+	if envelope := findEnvelope(user, id); envelope != nil {
+		event.Before = *envelope
+	}
+	if !lib.Audit(w, r, event) {
+		return
+	}
+
+	lib.SuccessResponse(w, lib.DeleteResponse{
+		Message: "Envelope deleted successfully",
+		ID:      id,
+	}, http.StatusOK)
+}
+
+// computeEnvelopeMonth computes a month view including pending, not yet
+// persisted assignments and transfers. Errors are APIErrors: 400 for an
+// invalid month or amounts, 500 when transactions cannot be loaded.
+func computeEnvelopeMonth(ctx context.Context, user *lib.User, month string, assignments []lib.EnvelopeAssignment, transfers []lib.EnvelopeTransfer) (*lib.EnvelopeMonth, error) {
+	transactions, err := getEnvelopeTransactions(ctx, user)
+	if err != nil {
+		return nil, lib.InternalError("Failed to load transactions", err)
+	}
+	_, span := lib.StartSpan(ctx, "envelopes.month", lib.SpanKindInternal)
+	defer span.End()
+	view, err := lib.ComputeEnvelopeMonth(
+		getEnvelopes(user),
+		append(getEnvelopeAssignments(user), assignments...),
+		append(getEnvelopeTransfers(user), transfers...),
+		transactions,
+		month,
+	)
+	if err != nil {
+		return nil, lib.BadRequestError(err.Error(), nil)
+	}
+	return view, nil
+}
+
+func hasEnvelope(user *lib.User, id string) bool {
+	return findEnvelope(user, id) != nil
+}
+
+func findEnvelope(user *lib.User, id string) *lib.Envelope {
+	for _, e := range getEnvelopes(user) {
+		if e.ID == id {
+			return &e
+		}
+	}
+	return nil
+}
+
+func getEnvelopes(user *lib.User) []lib.Envelope {
+	// TODO: Query database
+	return []lib.Envelope{
+		{ID: "envelope-1", UserID: user.ID, Name: "Groceries", Category: "Groceries", Rollover: true},
+		{ID: "envelope-2", UserID: user.ID, Name: "Dining Out", Category: "Dining", Rollover: false},
+		{ID: "envelope-3", UserID: user.ID, Name: "Rent", Category: "Rent", Rollover: true},
+	}
+}
+
+func getEnvelopeAssignments(user *lib.User) []lib.EnvelopeAssignment {
+	// TODO: Query database
+	month := time.Now().UTC().Format(lib.MonthFormat)
+	return []lib.EnvelopeAssignment{
+		{EnvelopeID: "envelope-1", Month: month, Amount: 500.0},
+		{EnvelopeID: "envelope-2", Month: month, Amount: 200.0},
+		{EnvelopeID: "envelope-3", Month: month, Amount: 1500.0},
+	}
+}
+
+func getEnvelopeTransfers(user *lib.User) []lib.EnvelopeTransfer {
+	// TODO: Query database
+	return []lib.EnvelopeTransfer{}
+}
+
+func getEnvelopeTransactions(ctx context.Context, user *lib.User) ([]lib.Transaction, error) {
+	// TODO: Query database
+	now := time.Now().UTC()
+	transactions := []lib.Transaction{
+		{ID: "trans-1", UserID: user.ID, Amount: 5000.0, Category: "Salary", Type: "income", Date: now},
+		{ID: "trans-2", UserID: user.ID, Amount: 1500.0, Category: "Rent", Type: "expense", Date: now},
+		{ID: "trans-3", UserID: user.ID, Amount: 100.5, Category: "Groceries", Type: "expense", Date: now},
+		{ID: "trans-4", UserID: user.ID, Amount: 240.0, Category: "Dining", Type: "expense", Date: now},
+	}
+	return lib.ExcludeTrashedTransactions(ctx, transactions, false)
+}
@@ -1,35 +1,30 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 	"runtime"
 	"time"
-)
-
-// HealthResponse represents the health check response
-type HealthResponse struct {
-	Success bool                   `json:"success"`
-	Data    map[string]interface{} `json:"data"`
-	Time    string                 `json:"timestamp"`
-}
 
-// Handler handles health check requests
-func Handler(w http.ResponseWriter, r *http.Request) {
-	// Enable CORS
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	"github.com/budget-buddy/api/lib"
+)
 
-	// Handle preflight
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
+// Health handles liveness and readiness probes
+func Health(w http.ResponseWriter, r *http.Request) {
+	config := lib.Config{
+		AllowedMethods: []string{"GET"},
+		CORS:           lib.DefaultCORS(),
 	}
 
-	// Only allow GET
-	if r.Method != "GET" {
-		errorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+	handler := lib.CreateHandler(healthHandler, config)
+	handler(w, r)
+}
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	probe := lib.GetQueryParam(r, "probe", "live")
+	if probe != "live" && probe != "ready" {
+		lib.WriteError(w, lib.BadRequestError("Invalid probe", map[string]interface{}{
+			"allowed": []string{"live", "ready"},
+		}))
 		return
 	}
 
@@ -37,43 +32,33 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	response := HealthResponse{
-		Success: true,
-		Data: map[string]interface{}{
-			"status":      "healthy",
-			"timestamp":   time.Now().UTC().Format(time.RFC3339),
-			"version":     "1.0.0",
-			"runtime":     "go",
-			"go_version":  runtime.Version(),
-			"environment": getEnv("NODE_ENV", "production"),
-			"memory": map[string]uint64{
-				"alloc":       m.Alloc,
-				"totalAlloc":  m.TotalAlloc,
-				"sys":         m.Sys,
-				"numGC":       uint64(m.NumGC),
-			},
+	status := lib.HealthStatus{
+		Status:      lib.HealthHealthy,
+		Probe:       probe,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Version:     lib.APIVersion,
+		Runtime:     "go",
+		GoVersion:   runtime.Version(),
+		Environment: lib.GetEnv("NODE_ENV", "production"),
+		Build:       lib.CurrentBuildInfo(),
+		Memory: lib.MemoryStats{
+			Alloc:      m.Alloc,
+			TotalAlloc: m.TotalAlloc,
+			Sys:        m.Sys,
+			NumGC:      uint64(m.NumGC),
 		},
-		Time: time.Now().UTC().Format(time.RFC3339),
 	}
+	w.Header().Set("Cache-Control", "no-store")
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
-}
-
-// Helper functions
-func errorResponse(w http.ResponseWriter, message string, status int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error":     message,
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-	})
-}
-
-func getEnv(key, fallback string) string {
-	if value := runtime.GOOS; value != "" {
-		return value
+	// Liveness only reports that the process can serve requests; readiness
+	// also probes the services requests depend on
+	if probe == "ready" {
+		status.Status, status.Checks = lib.RunHealthChecks(r.Context(), lib.HealthChecks)
+		if status.Status == lib.HealthUnhealthy {
+			lib.WriteError(w, lib.NewError(http.StatusServiceUnavailable, lib.CodeUpstream, "Service is not ready", status))
+			return
+		}
 	}
-	return fallback
+
+	lib.SuccessResponse(w, status, http.StatusOK)
 }
@@ -0,0 +1,574 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Anomaly types, aligned with the frontend SmartAlert anomaly types
+const (
+	AnomalyUnusualAmount   = "unusual_amount"   // transaction far above merchant/category history
+	AnomalySpendingSpike   = "spending_spike"   // category spend far above previous periods
+	AnomalyNewMerchant     = "new_merchant"     // large first-time charge
+	AnomalyFrequencyChange = "frequency_change" // unusually many transactions in a day
+)
+
+// AnomalyOptions configures anomaly detection
+type AnomalyOptions struct {
+	Since             time.Time // start of the window checked for anomalies
+	AsOf              time.Time // end of the window; history before Since is the baseline
+	ZThreshold        float64   // standard deviations above the mean, default 3
+	MinHistory        int       // baseline observations required for a z-score, default 5
+	NewMerchantAmount float64   // minimum first-time merchant charge to flag, default 100
+}
+
+// ExpectedRange represents the normal range for an observed value
+type ExpectedRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Anomaly represents unusual spending detected in a user's transactions
+type Anomaly struct {
+	ID                  string        `json:"id"` // stable across requests, see anomalyID
+	Type                string        `json:"type"`
+	Severity            string        `json:"severity"` // low, medium, high
+	TransactionID       string        `json:"transaction_id,omitempty"`
+	Merchant            string        `json:"merchant,omitempty"`
+	Category            string        `json:"category,omitempty"`
+	Date                string        `json:"date"`
+	CurrentAmount       float64       `json:"current_amount"`
+	ExpectedRange       ExpectedRange `json:"expected_range"`
+	DeviationPercentage float64       `json:"deviation_percentage"`
+	ZScore              float64       `json:"z_score,omitempty"`
+	Description         string        `json:"description"`
+}
+
+// DetectAnomalies flags unusual transactions and category spending in the
+// window [Since, AsOf) by comparing it with the history before Since.
+func DetectAnomalies(history []Transaction, opts AnomalyOptions) []Anomaly {
+	if opts.ZThreshold <= 0 {
+		opts.ZThreshold = 3
+	}
+	if opts.MinHistory <= 0 {
+		opts.MinHistory = 5
+	}
+	if opts.NewMerchantAmount <= 0 {
+		opts.NewMerchantAmount = 100
+	}
+
+	var baseline, recent []Transaction
+	for _, t := range history {
+		switch {
+		case t.Date.Before(opts.Since):
+			baseline = append(baseline, t)
+		case t.Date.Before(opts.AsOf):
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) == 0 {
+		return []Anomaly{}
+	}
+
+	anomalies := detectAmountAnomalies(baseline, recent, opts)
+	anomalies = append(anomalies, detectCategorySpikes(baseline, recent, opts)...)
+	anomalies = append(anomalies, detectFrequencyChanges(baseline, recent, opts)...)
+	if anomalies == nil {
+		return []Anomaly{}
+	}
+	for i := range anomalies {
+		anomalies[i].ID = anomalyID(anomalies[i], opts.AsOf)
+	}
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		if anomalies[i].Date != anomalies[j].Date {
+			return anomalies[i].Date > anomalies[j].Date
+		}
+		return severityRank(anomalies[i].Severity) > severityRank(anomalies[j].Severity)
+	})
+	return anomalies
+}
+
+// detectAmountAnomalies flags individual expenses that are outliers for their
+// merchant (or category when the merchant has too little history), and large
+// charges from merchants never seen before
+func detectAmountAnomalies(baseline, recent []Transaction, opts AnomalyOptions) []Anomaly {
+	byMerchant := make(map[string][]float64)
+	byCategory := make(map[string][]float64)
+	var allExpenses []float64
+	for _, t := range baseline {
+		if t.Type != "expense" {
+			continue
+		}
+		if key := merchantKey(t); key != "" {
+			byMerchant[key] = append(byMerchant[key], t.Amount)
+		}
+		byCategory[t.Category] = append(byCategory[t.Category], t.Amount)
+		allExpenses = append(allExpenses, t.Amount)
+	}
+	newMerchantMin := math.Max(opts.NewMerchantAmount, 3*median(allExpenses))
+
+	var anomalies []Anomaly
+	for _, t := range recent {
+		if t.Type != "expense" {
+			continue
+		}
+		key := merchantKey(t)
+		date := t.Date.Format("2006-01-02")
+
+		if key != "" && len(byMerchant[key]) == 0 && len(baseline) > 0 {
+			if t.Amount >= newMerchantMin {
+				anomalies = append(anomalies, Anomaly{
+					Type:                AnomalyNewMerchant,
+					Severity:            "medium",
+					TransactionID:       t.ID,
+					Merchant:            t.Merchant,
+					Category:            t.Category,
+					Date:                date,
+					CurrentAmount:       t.Amount,
+					ExpectedRange:       ExpectedRange{Min: 0, Max: roundCents(newMerchantMin)},
+					DeviationPercentage: deviation(t.Amount, newMerchantMin),
+					Description:         fmt.Sprintf("First charge of %.2f from a new merchant", t.Amount),
+				})
+			}
+			continue
+		}
+
+		values, scope := byMerchant[key], "merchant"
+		if key == "" || len(values) < opts.MinHistory {
+			values, scope = byCategory[t.Category], "category"
+		}
+		if len(values) < opts.MinHistory {
+			continue
+		}
+		mean, stdDev := meanStdDev(values)
+		z, ok := zScore(t.Amount, mean, stdDev)
+		if !ok || z < opts.ZThreshold {
+			continue
+		}
+		anomalies = append(anomalies, Anomaly{
+			Type:                AnomalyUnusualAmount,
+			Severity:            zSeverity(z, opts.ZThreshold),
+			TransactionID:       t.ID,
+			Merchant:            t.Merchant,
+			Category:            t.Category,
+			Date:                date,
+			CurrentAmount:       t.Amount,
+			ExpectedRange:       expectedRange(mean, stdDev, opts.ZThreshold),
+			DeviationPercentage: deviation(t.Amount, mean),
+			ZScore:              math.Round(z*100) / 100,
+			Description:         fmt.Sprintf("%.2f is unusually high for this %s (typically %.2f)", t.Amount, scope, roundCents(mean)),
+		})
+	}
+	return anomalies
+}
+
+// detectCategorySpikes compares each category's spend in the recent window
+// with its spend over preceding windows of the same length
+func detectCategorySpikes(baseline, recent []Transaction, opts AnomalyOptions) []Anomaly {
+	window := opts.AsOf.Sub(opts.Since)
+	if window <= 0 || len(baseline) == 0 {
+		return nil
+	}
+
+	first := opts.Since
+	for _, t := range baseline {
+		if t.Date.Before(first) {
+			first = t.Date
+		}
+	}
+	// Only whole windows are compared so a partial oldest window can't skew the mean
+	windows := int(opts.Since.Sub(first) / window)
+	if windows < 3 {
+		return nil
+	}
+
+	past := make(map[string][]float64)
+	for _, t := range baseline {
+		if t.Type != "expense" {
+			continue
+		}
+		idx := int(opts.Since.Sub(t.Date) / window)
+		if idx >= windows {
+			continue
+		}
+		if past[t.Category] == nil {
+			past[t.Category] = make([]float64, windows)
+		}
+		past[t.Category][idx] += t.Amount
+	}
+
+	current := make(map[string]float64)
+	for _, t := range recent {
+		if t.Type == "expense" {
+			current[t.Category] += t.Amount
+		}
+	}
+
+	var anomalies []Anomaly
+	for category, amount := range current {
+		totals, ok := past[category]
+		if !ok {
+			continue
+		}
+		mean, stdDev := meanStdDev(totals)
+		z, ok := zScore(amount, mean, stdDev)
+		if !ok || z < opts.ZThreshold {
+			continue
+		}
+		anomalies = append(anomalies, Anomaly{
+			Type:                AnomalySpendingSpike,
+			Severity:            zSeverity(z, opts.ZThreshold),
+			Category:            category,
+			Date:                opts.AsOf.AddDate(0, 0, -1).Format("2006-01-02"),
+			CurrentAmount:       roundCents(amount),
+			ExpectedRange:       expectedRange(mean, stdDev, opts.ZThreshold),
+			DeviationPercentage: deviation(amount, mean),
+			ZScore:              math.Round(z*100) / 100,
+			Description:         fmt.Sprintf("%s spending of %.2f is well above the usual %.2f", category, roundCents(amount), roundCents(mean)),
+		})
+	}
+	return anomalies
+}
+
+// detectFrequencyChanges flags days with far more transactions than usual
+func detectFrequencyChanges(baseline, recent []Transaction, opts AnomalyOptions) []Anomaly {
+	if len(baseline) == 0 {
+		return nil
+	}
+	first := opts.Since
+	for _, t := range baseline {
+		if t.Date.Before(first) {
+			first = t.Date
+		}
+	}
+	first = truncateDay(first)
+	days := int(truncateDay(opts.Since).Sub(first).Hours() / 24)
+	if days < opts.MinHistory {
+		return nil
+	}
+
+	counts := make([]float64, days)
+	for _, t := range baseline {
+		if idx := int(truncateDay(t.Date).Sub(first).Hours() / 24); idx >= 0 && idx < days {
+			counts[idx]++
+		}
+	}
+	mean, stdDev := meanStdDev(counts)
+
+	recentCounts := make(map[string]float64)
+	for _, t := range recent {
+		recentCounts[t.Date.UTC().Format("2006-01-02")]++
+	}
+
+	var anomalies []Anomaly
+	for date, count := range recentCounts {
+		// A handful of transactions is never a spike, whatever the baseline
+		if count < 3 {
+			continue
+		}
+		z, ok := zScore(count, mean, stdDev)
+		if !ok || z < opts.ZThreshold {
+			continue
+		}
+		anomalies = append(anomalies, Anomaly{
+			Type:                AnomalyFrequencyChange,
+			Severity:            zSeverity(z, opts.ZThreshold),
+			Date:                date,
+			CurrentAmount:       count,
+			ExpectedRange:       expectedRange(mean, stdDev, opts.ZThreshold),
+			DeviationPercentage: deviation(count, mean),
+			ZScore:              math.Round(z*100) / 100,
+			Description:         fmt.Sprintf("%d transactions in one day, compared with %.1f on a typical day", int(count), mean),
+		})
+	}
+	return anomalies
+}
+
+// zScore returns how many standard deviations value is above mean. The
+// deviation is floored at 5% of the mean so near-constant history does not
+// turn every small change into an extreme outlier.
+func zScore(value, mean, stdDev float64) (float64, bool) {
+	stdDev = math.Max(stdDev, mean*0.05)
+	if stdDev == 0 {
+		return 0, false
+	}
+	return (value - mean) / stdDev, true
+}
+
+// zSeverity grades a z-score relative to the detection threshold
+func zSeverity(z, threshold float64) string {
+	switch {
+	case z >= threshold+2:
+		return "high"
+	case z >= threshold+1:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case "high":
+		return 3
+	case "medium":
+		return 2
+	case "low":
+		return 1
+	default:
+		return 0
+	}
+}
+
+func expectedRange(mean, stdDev, threshold float64) ExpectedRange {
+	return ExpectedRange{
+		Min: roundCents(math.Max(0, mean-threshold*stdDev)),
+		Max: roundCents(mean + threshold*stdDev),
+	}
+}
+
+func deviation(value, expected float64) float64 {
+	if expected == 0 {
+		return 0
+	}
+	return math.Round((value-expected)/expected*1000) / 10
+}
+
+// anomalyID identifies an anomaly across requests so it is only notified once.
+// Transaction anomalies are keyed by transaction, frequency changes by day and
+// spending spikes by category and month, since a spike stays in the sliding
+// window for as long as it is detected.
+func anomalyID(a Anomaly, asOf time.Time) string {
+	subject := a.TransactionID
+	switch {
+	case a.Type == AnomalySpendingSpike:
+		subject = a.Category + "_" + asOf.AddDate(0, 0, -1).Format("2006-01")
+	case subject == "":
+		subject = a.Date
+	}
+	return fmt.Sprintf("anomaly_%s_%s", a.Type, subject)
+}
+
+// AnomalyHook receives anomalies detected for a user, e.g. to raise notifications.
+// Each anomaly is passed to hooks once, however often it is detected.
+type AnomalyHook func(user *User, anomalies []Anomaly)
+
+// AnomalyNotifiedTTL is how long a notified anomaly is remembered, longer
+// than the widest detection window
+const AnomalyNotifiedTTL = 90 * 24 * time.Hour
+
+// anomalyHookTimeout bounds the background work of notifying one request's anomalies
+const anomalyHookTimeout = 10 * time.Second
+
+var (
+	anomalyHooksMu sync.RWMutex
+	anomalyHooks   []AnomalyHook
+	anomalyHooksWG sync.WaitGroup
+)
+
+// RegisterAnomalyHook registers a hook called whenever new anomalies are detected
+func RegisterAnomalyHook(hook AnomalyHook) {
+	anomalyHooksMu.Lock()
+	defer anomalyHooksMu.Unlock()
+	anomalyHooks = append(anomalyHooks, hook)
+}
+
+// NotifyAnomalyHooks passes anomalies the user has not been notified about to
+// every registered hook. Deduplication and the hooks run in the background,
+// and a failing or panicking hook is logged without affecting the request or
+// the other hooks. On serverless platforms it waits for them before returning,
+// as a frozen instance could mark anomalies as notified without ever
+// delivering them.
+func NotifyAnomalyHooks(user *User, anomalies []Anomaly) {
+	if user == nil || len(anomalies) == 0 {
+		return
+	}
+	anomalyHooksMu.RLock()
+	hooks := make([]AnomalyHook, len(anomalyHooks))
+	copy(hooks, anomalyHooks)
+	anomalyHooksMu.RUnlock()
+	if len(hooks) == 0 {
+		return
+	}
+
+	if Serverless() {
+		notifyAnomalies(hooks, user, anomalies)
+		return
+	}
+	anomalyHooksWG.Add(1)
+	go func() {
+		defer anomalyHooksWG.Done()
+		notifyAnomalies(hooks, user, anomalies)
+	}()
+}
+
+// notifyAnomalies runs hooks with the anomalies not yet marked as notified
+func notifyAnomalies(hooks []AnomalyHook, user *User, anomalies []Anomaly) {
+	ctx, cancel := context.WithTimeout(context.Background(), anomalyHookTimeout)
+	defer cancel()
+
+	var fresh []Anomaly
+	for _, a := range anomalies {
+		first, err := NotifiedAnomalies.MarkNotified(ctx, user.ID, a.ID, AnomalyNotifiedTTL)
+		if err != nil {
+			Logger.Error("failed to deduplicate anomaly", "anomaly_id", a.ID, "user_id", user.ID, "error", err)
+			continue
+		}
+		if first {
+			fresh = append(fresh, a)
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+	for _, hook := range hooks {
+		runAnomalyHook(hook, user, fresh)
+	}
+}
+
+// WaitForAnomalyHooks blocks until background anomaly notifications finish,
+// for graceful shutdown
+func WaitForAnomalyHooks() {
+	anomalyHooksWG.Wait()
+}
+
+func runAnomalyHook(hook AnomalyHook, user *User, anomalies []Anomaly) {
+	defer func() {
+		if err := recover(); err != nil {
+			Logger.Error("anomaly hook panicked", "user_id", user.ID, "error", fmt.Sprint(err))
+		}
+	}()
+	hook(user, anomalies)
+}
+
+// LogAnomalyAlerts is an AnomalyHook logging each anomaly as the SmartAlert
+// the notification system would raise. CreateHandler deployments register it
+// from the analytics function; further hooks, such as a push notifier, are
+// registered the same way.
+func LogAnomalyAlerts(user *User, anomalies []Anomaly) {
+	for _, a := range anomalies {
+		alert := a.ToAlert(user.ID)
+		Logger.Info("spending anomaly detected",
+			"alert_id", alert.ID,
+			"user_id", user.ID,
+			"anomaly_type", a.Type,
+			"severity", alert.Severity,
+			"date", a.Date,
+		)
+	}
+}
+
+// AnomalyNotificationStore remembers which anomalies a user has been notified about
+type AnomalyNotificationStore interface {
+	// MarkNotified records an anomaly for ttl, reporting whether it was new
+	MarkNotified(ctx context.Context, userID, anomalyID string, ttl time.Duration) (bool, error)
+}
+
+// NotifiedAnomalies deduplicates anomaly notifications. It is kept in Redis
+// when REDIS_URL is set, so every instance shares it.
+var NotifiedAnomalies AnomalyNotificationStore = newAnomalyNotificationStore()
+
+func newAnomalyNotificationStore() AnomalyNotificationStore {
+	if client := newRedisClient("anomaly"); client != nil {
+		return &RedisAnomalyNotificationStore{client: client}
+	}
+	return NewMemoryAnomalyNotificationStore()
+}
+
+// MemoryAnomalyNotificationStore is an in-memory AnomalyNotificationStore
+type MemoryAnomalyNotificationStore struct {
+	mu       sync.Mutex
+	notified map[string]time.Time // user and anomaly ID to expiry
+}
+
+// NewMemoryAnomalyNotificationStore creates an empty in-memory notification store
+func NewMemoryAnomalyNotificationStore() *MemoryAnomalyNotificationStore {
+	return &MemoryAnomalyNotificationStore{notified: make(map[string]time.Time)}
+}
+
+// MarkNotified records an anomaly unless it is already recorded, also
+// dropping expired entries
+func (s *MemoryAnomalyNotificationStore) MarkNotified(ctx context.Context, userID, anomalyID string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, expires := range s.notified {
+		if now.After(expires) {
+			delete(s.notified, key)
+		}
+	}
+	key := userID + ":" + anomalyID
+	if _, ok := s.notified[key]; ok {
+		return false, nil
+	}
+	s.notified[key] = now.Add(ttl)
+	return true, nil
+}
+
+// RedisAnomalyNotificationStore is an AnomalyNotificationStore in Redis, one
+// expiring key per user and anomaly
+type RedisAnomalyNotificationStore struct {
+	client *RESPClient
+}
+
+// MarkNotified sets the anomaly's key unless it exists
+func (s *RedisAnomalyNotificationStore) MarkNotified(ctx context.Context, userID, anomalyID string, ttl time.Duration) (bool, error) {
+	reply, err := s.client.Do("SET", "anomaly:notified:"+userID+":"+anomalyID, "1", "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// SmartAlert mirrors the frontend SmartAlert shape used by the notification system
+type SmartAlert struct {
+	ID             string                 `json:"id"`
+	UserID         string                 `json:"userId"`
+	Type           string                 `json:"type"`
+	Severity       string                 `json:"severity"`
+	Title          string                 `json:"title"`
+	Message        string                 `json:"message"`
+	Category       string                 `json:"category,omitempty"`
+	Amount         float64                `json:"amount,omitempty"`
+	ActionRequired bool                   `json:"actionRequired"`
+	Dismissible    bool                   `json:"dismissible"`
+	Metadata       map[string]interface{} `json:"metadata"`
+	CreatedAt      string                 `json:"createdAt"`
+}
+
+// ToAlert converts an anomaly into a spending_anomaly SmartAlert
+func (a Anomaly) ToAlert(userID string) SmartAlert {
+	titles := map[string]string{
+		AnomalyUnusualAmount:   "Unusually large transaction",
+		AnomalySpendingSpike:   "Spending spike detected",
+		AnomalyNewMerchant:     "Large charge from a new merchant",
+		AnomalyFrequencyChange: "Unusual number of transactions",
+	}
+	return SmartAlert{
+		ID:             a.ID,
+		UserID:         userID,
+		Type:           "spending_anomaly",
+		Severity:       a.Severity,
+		Title:          titles[a.Type],
+		Message:        a.Description,
+		Category:       a.Category,
+		Amount:         a.CurrentAmount,
+		ActionRequired: a.Severity == "high",
+		Dismissible:    true,
+		Metadata: map[string]interface{}{
+			"anomalyType":         a.Type,
+			"transactionId":       a.TransactionID,
+			"merchant":            a.Merchant,
+			"date":                a.Date,
+			"expectedRange":       a.ExpectedRange,
+			"deviationPercentage": a.DeviationPercentage,
+		},
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+}
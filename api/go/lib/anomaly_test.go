@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAnomalyID(t *testing.T) {
+	asOf := date(2024, 3, 15)
+	tests := []struct {
+		name    string
+		anomaly Anomaly
+		want    string
+	}{
+		{"transaction", Anomaly{Type: AnomalyUnusualAmount, TransactionID: "tx-1", Date: "2024-03-10"}, "anomaly_unusual_amount_tx-1"},
+		{"new merchant", Anomaly{Type: AnomalyNewMerchant, TransactionID: "tx-2", Date: "2024-03-10"}, "anomaly_new_merchant_tx-2"},
+		{"spike by category and month", Anomaly{Type: AnomalySpendingSpike, Category: "Dining", Date: "2024-03-14"}, "anomaly_spending_spike_Dining_2024-03"},
+		{"frequency by day", Anomaly{Type: AnomalyFrequencyChange, Date: "2024-03-12"}, "anomaly_frequency_change_2024-03-12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := anomalyID(tt.anomaly, asOf); got != tt.want {
+				t.Errorf("anomalyID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectAnomaliesSetsIDs(t *testing.T) {
+	var history []Transaction
+	for i := 0; i < 10; i++ {
+		history = append(history, Transaction{ID: "base", Type: "expense", Merchant: "Cafe", Category: "Dining", Amount: 5, Date: date(2024, 1, 1+i)})
+	}
+	history = append(history, Transaction{ID: "big", Type: "expense", Merchant: "Cafe", Category: "Dining", Amount: 80, Date: date(2024, 2, 5)})
+
+	anomalies := DetectAnomalies(history, AnomalyOptions{Since: date(2024, 2, 1), AsOf: date(2024, 2, 10)})
+	if len(anomalies) == 0 {
+		t.Fatal("expected an anomaly")
+	}
+	for _, a := range anomalies {
+		if a.ID == "" || a.ToAlert("u1").ID != a.ID {
+			t.Errorf("anomaly %+v has no stable ID", a)
+		}
+	}
+}
+
+func TestAnomalyNotificationStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) AnomalyNotificationStore{
+		"memory": func(t *testing.T) AnomalyNotificationStore { return NewMemoryAnomalyNotificationStore() },
+		"redis": func(t *testing.T) AnomalyNotificationStore {
+			return &RedisAnomalyNotificationStore{client: newTestRESPClient(t)}
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			steps := []struct {
+				user, id string
+				ttl      time.Duration
+				want     bool
+			}{
+				{"u1", "a1", time.Hour, true},
+				{"u1", "a1", time.Hour, false},
+				{"u2", "a1", time.Hour, true},
+				{"u1", "short", time.Millisecond, true},
+			}
+			for _, step := range steps {
+				if got, err := store.MarkNotified(ctx, step.user, step.id, step.ttl); err != nil || got != step.want {
+					t.Errorf("MarkNotified(%s, %s) = %v, %v; want %v", step.user, step.id, got, err, step.want)
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			if got, _ := store.MarkNotified(ctx, "u1", "short", time.Hour); !got {
+				t.Error("an expired anomaly should be notified again")
+			}
+		})
+	}
+}
+
+func TestNotifyAnomalyHooks(t *testing.T) {
+	previousHooks, previousStore, previousLogger := anomalyHooks, NotifiedAnomalies, Logger
+	t.Cleanup(func() { anomalyHooks, NotifiedAnomalies, Logger = previousHooks, previousStore, previousLogger })
+	NotifiedAnomalies = NewMemoryAnomalyNotificationStore()
+	Logger = NewLogger(io.Discard, "error")
+
+	var mu sync.Mutex
+	var received []string
+	anomalyHooks = []AnomalyHook{
+		func(user *User, anomalies []Anomaly) { panic("hook failed") },
+		func(user *User, anomalies []Anomaly) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, a := range anomalies {
+				received = append(received, a.ID)
+			}
+		},
+	}
+
+	user := &User{ID: "u1"}
+	NotifyAnomalyHooks(user, []Anomaly{{ID: "a1"}, {ID: "a2"}})
+	WaitForAnomalyHooks()
+	NotifyAnomalyHooks(user, []Anomaly{{ID: "a2"}, {ID: "a3"}})
+	WaitForAnomalyHooks()
+
+	// The panicking hook does not stop the next one, and a2 is only sent once
+	want := []string{"a1", "a2", "a3"}
+	if len(received) != len(want) {
+		t.Fatalf("hook received %v, want %v", received, want)
+	}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Errorf("hook received %v, want %v", received, want)
+			break
+		}
+	}
+}
+
+func TestNotifyAnomalyHooksWaitsWhenServerless(t *testing.T) {
+	previousHooks, previousStore := anomalyHooks, NotifiedAnomalies
+	t.Cleanup(func() { anomalyHooks, NotifiedAnomalies = previousHooks, previousStore })
+	NotifiedAnomalies = NewMemoryAnomalyNotificationStore()
+	t.Setenv("VERCEL", "1")
+
+	delivered := false
+	anomalyHooks = []AnomalyHook{func(user *User, anomalies []Anomaly) { delivered = true }}
+	NotifyAnomalyHooks(&User{ID: "u1"}, []Anomaly{{ID: "a1"}})
+	// No WaitForAnomalyHooks: the instance may be frozen once the handler returns
+	if !delivered {
+		t.Error("NotifyAnomalyHooks returned before delivering the anomaly")
+	}
+}
@@ -0,0 +1,371 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// AuditRecord represents one mutation made through the API
+type AuditRecord struct {
+	ID          string        `json:"id"`
+	ActorID     string        `json:"actor_id"`
+	WorkspaceID string        `json:"workspace_id,omitempty"`
+	Action      string        `json:"action"`   // create, update, delete or restore
+	Resource    string        `json:"resource"` // e.g. transaction, budget, workspace
+	ResourceID  string        `json:"resource_id"`
+	Changes     []FieldChange `json:"changes"`
+	ClientIP    string        `json:"client_ip"`
+	UserAgent   string        `json:"user_agent,omitempty"`
+	RequestID   string        `json:"request_id,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// FieldChange represents a top-level field whose value changed. Before is
+// omitted for created fields and After for removed ones.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// AuditEvent describes a mutation to record. Before is nil for creates and
+// After is nil for deletes.
+type AuditEvent struct {
+	Action     string
+	Resource   string
+	ResourceID string
+	Before     interface{}
+	After      interface{}
+}
+
+// Audit records the mutations made by the authenticated user of r. Audit
+// records are a compliance requirement, so when they cannot be written a 500
+// response is written and false is returned. Handlers call it before
+// persisting the mutations, so a failed audit write never leaves an
+// unrecorded change behind. The events of a batch are stored together or not
+// at all.
+func Audit(w http.ResponseWriter, r *http.Request, events ...AuditEvent) bool {
+	if err := RecordAudit(r, events...); err != nil {
+		WriteError(w, InternalError("Failed to record audit log", err))
+		return false
+	}
+	return true
+}
+
+// RecordAudit builds an audit record for each event from the request and
+// stores them in one append
+func RecordAudit(r *http.Request, events ...AuditEvent) error {
+	records := make([]AuditRecord, 0, len(events))
+	for _, event := range events {
+		changes, err := Diff(event.Before, event.After)
+		if err != nil {
+			return err
+		}
+
+		record := AuditRecord{
+			ID:          NewID("audit"),
+			WorkspaceID: WorkspaceIDFromContext(r),
+			Action:      event.Action,
+			Resource:    event.Resource,
+			ResourceID:  event.ResourceID,
+			Changes:     changes,
+			ClientIP:    GetClientIP(r),
+			UserAgent:   r.UserAgent(),
+			RequestID:   RequestID(r),
+			CreatedAt:   time.Now().UTC(),
+		}
+		if user, ok := GetUserFromContext(r); ok {
+			record.ActorID = user.ID
+		}
+		records = append(records, record)
+	}
+	return AuditLog.Append(r.Context(), records...)
+}
+
+// Diff compares the JSON representations of two values field by field,
+// returning the changed top-level fields sorted by name
+func Diff(before, after interface{}) ([]FieldChange, error) {
+	old, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool)
+	for field := range old {
+		fields[field] = true
+	}
+	for field := range updated {
+		fields[field] = true
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, field := range names {
+		if !reflect.DeepEqual(old[field], updated[field]) {
+			changes = append(changes, FieldChange{Field: field, Before: old[field], After: updated[field]})
+		}
+	}
+	return changes, nil
+}
+
+// jsonFields returns the fields of a value's JSON object representation. Nil
+// values have no fields; values that are not objects are returned as "value".
+func jsonFields(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	if err := decodeNumbers(data, &decoded); err != nil {
+		return nil, err
+	}
+	switch fields := decoded.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return fields, nil
+	default:
+		return map[string]interface{}{"value": fields}, nil
+	}
+}
+
+// AuditQuery filters audit records. Zero fields match everything.
+type AuditQuery struct {
+	ActorID    string
+	Resource   string
+	ResourceID string
+	Action     string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
+// AuditStore persists audit records. Records are append-only.
+type AuditStore interface {
+	// Append stores records atomically: either all of them or none
+	Append(ctx context.Context, records ...AuditRecord) error
+	// List returns a page of matching records, newest first, and the total number of matches
+	List(ctx context.Context, query AuditQuery) ([]AuditRecord, int, error)
+}
+
+// AuditLog is the audit store used by handlers. Like Trash it is kept in the
+// Redis-protocol server at REDIS_URL when set, so the records written by every
+// function instance can be listed from any of them.
+// TODO: Replace with an append-only Supabase table
+var AuditLog AuditStore = InstrumentAuditStore(newAuditStore())
+
+func newAuditStore() AuditStore {
+	if client := newRedisClient("audit"); client != nil {
+		return &RedisAuditStore{client: client}
+	}
+	return NewMemoryAuditStore()
+}
+
+// matches reports whether a record passes the query's filters
+func (query AuditQuery) matches(record AuditRecord) bool {
+	switch {
+	case query.ActorID != "" && record.ActorID != query.ActorID,
+		query.Resource != "" && record.Resource != query.Resource,
+		query.ResourceID != "" && record.ResourceID != query.ResourceID,
+		query.Action != "" && record.Action != query.Action,
+		!query.Since.IsZero() && record.CreatedAt.Before(query.Since),
+		!query.Until.IsZero() && record.CreatedAt.After(query.Until):
+		return false
+	}
+	return true
+}
+
+// page returns the query's page of matches and the total number of matches
+func (query AuditQuery) page(matches []AuditRecord) ([]AuditRecord, int) {
+	total := len(matches)
+	if query.Offset >= total {
+		return []AuditRecord{}, total
+	}
+	matches = matches[query.Offset:]
+	if query.Limit > 0 && len(matches) > query.Limit {
+		matches = matches[:query.Limit]
+	}
+	return matches, total
+}
+
+// MemoryAuditStore is an in-memory AuditStore
+type MemoryAuditStore struct {
+	mu      sync.RWMutex
+	records []AuditRecord
+}
+
+// NewMemoryAuditStore creates an empty in-memory audit store
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{}
+}
+
+// Append stores audit records
+func (s *MemoryAuditStore) Append(ctx context.Context, records ...AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// List returns a page of matching records, newest first, and the total number of matches
+func (s *MemoryAuditStore) List(ctx context.Context, query AuditQuery) ([]AuditRecord, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := []AuditRecord{}
+	for i := len(s.records) - 1; i >= 0; i-- {
+		if query.matches(s.records[i]) {
+			matches = append(matches, s.records[i])
+		}
+	}
+	records, total := query.page(matches)
+	return records, total, nil
+}
+
+// RedisAuditStore is an AuditStore backed by a Redis-protocol server. Records
+// are appended as JSON to the list audit:log and to the actor's list
+// audit:actor:<user ID>, which List reads when the query names an actor.
+type RedisAuditStore struct {
+	client *RESPClient
+}
+
+// NewRedisAuditStore creates an audit store for a redis:// or rediss:// URL
+func NewRedisAuditStore(redisURL string) (*RedisAuditStore, error) {
+	client, err := NewRESPClient(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisAuditStore{client: client}, nil
+}
+
+const auditLogKey = "audit:log"
+
+func auditActorKey(actorID string) string { return "audit:actor:" + actorID }
+
+// Append stores audit records in one transaction
+func (s *RedisAuditStore) Append(ctx context.Context, records ...AuditRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	var commands [][]string
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		commands = append(commands, []string{"RPUSH", auditLogKey, string(data)})
+		if record.ActorID != "" {
+			commands = append(commands, []string{"RPUSH", auditActorKey(record.ActorID), string(data)})
+		}
+	}
+	_, err := s.client.Transaction(commands...)
+	return err
+}
+
+// auditScanBatch is how many records RedisAuditStore.List reads per LRANGE
+// when it filters
+var auditScanBatch = 500
+
+// List returns a page of matching records, newest first, and the total number
+// of matches. A query filtering only by actor reads just its page. Other
+// queries read the list newest first in batches, as the total counts every
+// match, stopping at the first record older than Since since records are
+// appended in time order.
+func (s *RedisAuditStore) List(ctx context.Context, query AuditQuery) ([]AuditRecord, int, error) {
+	key := auditLogKey
+	if query.ActorID != "" {
+		key = auditActorKey(query.ActorID)
+	}
+	reply, err := s.client.Do("LLEN", key)
+	if err != nil {
+		return nil, 0, err
+	}
+	length, ok := reply.(int64)
+	if !ok {
+		return nil, 0, RESPError("unexpected LLEN reply")
+	}
+	total := int(length)
+
+	unfiltered := query == AuditQuery{ActorID: query.ActorID, Limit: query.Limit, Offset: query.Offset}
+	if unfiltered {
+		if query.Offset >= total {
+			return []AuditRecord{}, total, nil
+		}
+		// Index 0 is the oldest record, so the page ends offset records before the last
+		stop := total - 1 - query.Offset
+		start := 0
+		if query.Limit > 0 {
+			start = max(stop-query.Limit+1, 0)
+		}
+		records, err := s.lrange(key, start, stop)
+		if err != nil {
+			return nil, 0, err
+		}
+		return records, total, nil
+	}
+
+	matches := []AuditRecord{}
+	for stop := total - 1; stop >= 0; stop -= auditScanBatch {
+		records, err := s.lrange(key, max(stop-auditScanBatch+1, 0), stop)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, record := range records {
+			if !query.Since.IsZero() && record.CreatedAt.Before(query.Since) {
+				page, total := query.page(matches)
+				return page, total, nil
+			}
+			if query.matches(record) {
+				matches = append(matches, record)
+			}
+		}
+	}
+	page, total := query.page(matches)
+	return page, total, nil
+}
+
+// lrange decodes the records from start to stop of a list, newest first
+func (s *RedisAuditStore) lrange(key string, start, stop int) ([]AuditRecord, error) {
+	reply, err := s.client.Do("LRANGE", key, strconv.Itoa(start), strconv.Itoa(stop))
+	if err != nil {
+		return nil, err
+	}
+	values, err := respStrings(reply)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]AuditRecord, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal([]byte(value), &records[len(values)-1-i]); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
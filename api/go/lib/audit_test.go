@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAuditStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) AuditStore{
+		"memory": func(t *testing.T) AuditStore { return NewMemoryAuditStore() },
+		"redis":  func(t *testing.T) AuditStore { return &RedisAuditStore{client: newTestRESPClient(t)} },
+	}
+	// Filtered Redis queries read more than one batch
+	previousBatch := auditScanBatch
+	t.Cleanup(func() { auditScanBatch = previousBatch })
+	auditScanBatch = 2
+	at := func(day int) time.Time { return date(2024, 1, day) }
+	records := []AuditRecord{
+		{ID: "a1", ActorID: "alice", Action: AuditCreate, Resource: "transaction", ResourceID: "tx-1", CreatedAt: at(1)},
+		{ID: "a2", ActorID: "bob", Action: AuditCreate, Resource: "budget", ResourceID: "b-1", CreatedAt: at(2)},
+		{ID: "a3", ActorID: "alice", Action: AuditUpdate, Resource: "transaction", ResourceID: "tx-1", CreatedAt: at(3)},
+		{ID: "a4", ActorID: "alice", Action: AuditDelete, Resource: "budget", ResourceID: "b-2", CreatedAt: at(4)},
+	}
+	tests := []struct {
+		name  string
+		query AuditQuery
+		want  []string
+		total int
+	}{
+		{"everything", AuditQuery{}, []string{"a4", "a3", "a2", "a1"}, 4},
+		{"by actor", AuditQuery{ActorID: "alice"}, []string{"a4", "a3", "a1"}, 3},
+		{"by resource", AuditQuery{ActorID: "alice", Resource: "transaction", ResourceID: "tx-1"}, []string{"a3", "a1"}, 2},
+		{"by action", AuditQuery{Action: AuditCreate}, []string{"a2", "a1"}, 2},
+		{"by time", AuditQuery{Since: at(2), Until: at(3)}, []string{"a3", "a2"}, 2},
+		{"since", AuditQuery{Since: at(3)}, []string{"a4", "a3"}, 2},
+		{"paged", AuditQuery{ActorID: "alice", Limit: 1, Offset: 1}, []string{"a3"}, 3},
+		{"last page", AuditQuery{Limit: 3, Offset: 2}, []string{"a2", "a1"}, 4},
+		{"filtered page", AuditQuery{Resource: "budget", Limit: 1, Offset: 1}, []string{"a2"}, 2},
+		{"past the end", AuditQuery{Offset: 10}, []string{}, 4},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			// One record alone, then the rest as a batch
+			if err := store.Append(ctx, records[0]); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Append(ctx, records[1:]...); err != nil {
+				t.Fatal(err)
+			}
+			for _, tt := range tests {
+				got, total, err := store.List(ctx, tt.query)
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				ids := []string{}
+				for _, record := range got {
+					ids = append(ids, record.ID)
+				}
+				if len(ids) != len(tt.want) || total != tt.total {
+					t.Errorf("%s: got %v of %d, want %v of %d", tt.name, ids, total, tt.want, tt.total)
+					continue
+				}
+				for i := range ids {
+					if ids[i] != tt.want[i] {
+						t.Errorf("%s: got %v, want %v", tt.name, ids, tt.want)
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package lib
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSMaxAge is how long browsers may cache a preflight response,
+// unless CORS_MAX_AGE is set in seconds
+const DefaultCORSMaxAge = 10 * time.Minute
+
+// CORSPolicy configures cross-origin access to a function
+type CORSPolicy struct {
+	// AllowedOrigins lists exact origins such as https://app.example.com and
+	// subdomain patterns such as https://*.example.com. "*" allows any origin,
+	// in which case credentials are never allowed.
+	AllowedOrigins   []string
+	AllowedMethods   []string // defaults to the handler's AllowedMethods
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// DefaultCORS returns the policy shared by every function. Origins come from
+// the comma-separated CORS_ALLOWED_ORIGINS; when it is unset any origin is
+// allowed without credentials, which suits bearer-token clients. Credentials
+// are allowed for an explicit allowlist.
+func DefaultCORS() *CORSPolicy {
+	policy := &CORSPolicy{
+		AllowedOrigins: []string{"*"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", "X-Requested-With", RequestIDHeader},
+		ExposedHeaders: []string{RequestIDHeader, "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+		MaxAge:         DefaultCORSMaxAge,
+	}
+
+	var origins []string
+	for _, origin := range strings.Split(GetEnv("CORS_ALLOWED_ORIGINS", ""), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) > 0 {
+		policy.AllowedOrigins = origins
+		policy.AllowCredentials = true
+	}
+
+	if seconds, err := strconv.Atoi(GetEnv("CORS_MAX_AGE", "")); err == nil && seconds >= 0 {
+		policy.MaxAge = time.Duration(seconds) * time.Second
+	}
+	return policy
+}
+
+// AllowHeaders returns a copy of the policy also accepting the given request headers
+func (p *CORSPolicy) AllowHeaders(headers ...string) *CORSPolicy {
+	copied := *p
+	copied.AllowedHeaders = append(append([]string{}, p.AllowedHeaders...), headers...)
+	return &copied
+}
+
+// ExposeHeaders returns a copy of the policy also exposing the given response headers
+func (p *CORSPolicy) ExposeHeaders(headers ...string) *CORSPolicy {
+	copied := *p
+	copied.ExposedHeaders = append(append([]string{}, p.ExposedHeaders...), headers...)
+	return &copied
+}
+
+// Handle applies the policy to the response. Preflight requests are answered
+// here, with 204 for allowed origins and 403 otherwise, and true is returned.
+// methods are used when the policy does not list its own.
+func (p *CORSPolicy) Handle(w http.ResponseWriter, r *http.Request, methods []string) bool {
+	origin := r.Header.Get("Origin")
+	allowed, wildcard := p.allowOrigin(origin)
+	if !wildcard {
+		w.Header().Add("Vary", "Origin")
+	}
+
+	preflight := r.Method == http.MethodOptions
+	if origin == "" || !allowed {
+		if preflight && origin != "" {
+			WriteError(w, ForbiddenError("Origin not allowed", nil))
+			return true
+		}
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return preflight
+	}
+
+	if wildcard {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if p.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !preflight {
+		if len(p.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+		}
+		return false
+	}
+
+	if len(p.AllowedMethods) > 0 {
+		methods = p.AllowedMethods
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(append(append([]string{}, methods...), http.MethodOptions), ", "))
+	if len(p.AllowedHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.AllowedHeaders, ", "))
+	}
+	if p.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// allowOrigin reports whether origin is allowed and whether that is because
+// the policy allows any origin
+func (p *CORSPolicy) allowOrigin(origin string) (allowed, wildcard bool) {
+	for _, pattern := range p.AllowedOrigins {
+		if pattern == "*" {
+			return true, true
+		}
+	}
+	for _, pattern := range p.AllowedOrigins {
+		if MatchOrigin(pattern, origin) {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// MatchOrigin reports whether origin matches an exact origin or a subdomain
+// pattern such as https://*.example.com, which matches any subdomain of
+// example.com over https but not example.com itself
+func MatchOrigin(pattern, origin string) bool {
+	if origin == "" {
+		return false
+	}
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "/"))
+	origin = strings.ToLower(origin)
+	if pattern == origin {
+		return true
+	}
+
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme != scheme || u.Path != "" {
+		return false
+	}
+	// The port, if any, must match the pattern's
+	return len(u.Host) > len(host)+1 && strings.HasSuffix(u.Host, "."+host)
+}
@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		pattern, origin string
+		want            bool
+	}{
+		{"https://app.example.com", "https://app.example.com", true},
+		{"https://app.example.com/", "https://app.example.com", true},
+		{"https://APP.example.com", "https://app.EXAMPLE.com", true},
+		{"https://app.example.com", "http://app.example.com", false},
+		{"https://app.example.com", "https://app.example.com.evil.com", false},
+		{"https://*.example.com", "https://app.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://.example.com", false},
+		{"https://*.example.com", "http://app.example.com", false},
+		{"https://*.example.com", "https://app.example.com.evil.com", false},
+		{"https://*.example.com", "https://evilexample.com", false},
+		{"https://*.example.com", "https://app.example.com/path", false},
+		{"https://*.example.com", "https://app.example.com:8443", false},
+		{"https://*.example.com:8443", "https://app.example.com:8443", true},
+		{"https://app.example.com", "", false},
+	}
+	for _, tt := range tests {
+		if got := MatchOrigin(tt.pattern, tt.origin); got != tt.want {
+			t.Errorf("MatchOrigin(%q, %q) = %v, want %v", tt.pattern, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCORSPolicyHandle(t *testing.T) {
+	allowlist := &CORSPolicy{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}
+	anyOrigin := &CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+	tests := []struct {
+		name        string
+		policy      *CORSPolicy
+		method      string
+		origin      string
+		handled     bool
+		status      int // of a handled request
+		allowOrigin string
+		credentials string
+	}{
+		{"listed origin", allowlist, http.MethodGet, "https://app.example.com", false, 0, "https://app.example.com", "true"},
+		{"unlisted origin", allowlist, http.MethodGet, "https://evil.com", false, 0, "", ""},
+		{"listed preflight", allowlist, http.MethodOptions, "https://app.example.com", true, http.StatusNoContent, "https://app.example.com", "true"},
+		{"unlisted preflight", allowlist, http.MethodOptions, "https://evil.com", true, http.StatusForbidden, "", ""},
+		{"any origin never allows credentials", anyOrigin, http.MethodGet, "https://evil.com", false, 0, "*", ""},
+		{"no origin", allowlist, http.MethodGet, "", false, 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/go/transactions", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			handled := tt.policy.Handle(w, r, []string{http.MethodGet})
+			if handled != tt.handled || (handled && w.Code != tt.status) {
+				t.Errorf("handled %v with status %d, want %v with %d", handled, w.Code, tt.handled, tt.status)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin %q, want %q", got, tt.allowOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Errorf("Access-Control-Allow-Credentials %q, want %q", got, tt.credentials)
+			}
+		})
+	}
+}
+
+func TestConfigEnableCORS(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com")
+	previousLogger := Logger
+	t.Cleanup(func() { Logger = previousLogger })
+	Logger = NewLogger(io.Discard, "error")
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"disabled", Config{}, ""},
+		{"shorthand for the default policy", Config{EnableCORS: true}, "https://app.example.com"},
+		{"an explicit policy wins", Config{EnableCORS: true, CORS: &CORSPolicy{AllowedOrigins: []string{"*"}}}, "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CreateHandler(func(w http.ResponseWriter, r *http.Request) {}, tt.config)
+			r := httptest.NewRequest(http.MethodGet, "/api/go/health", nil)
+			r.Header.Set("Origin", "https://app.example.com")
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Errorf("Access-Control-Allow-Origin %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
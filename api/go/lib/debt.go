@@ -0,0 +1,257 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Debt payoff strategies
+const (
+	StrategyAvalanche = "avalanche" // highest APR first
+	StrategySnowball  = "snowball"  // smallest balance first
+	StrategyCustom    = "custom"    // caller-supplied order
+)
+
+// MaxPayoffMonths caps the simulation length (50 years)
+const MaxPayoffMonths = 600
+
+// PayoffPayment represents a single liability's payment within a month
+type PayoffPayment struct {
+	LiabilityID string  `json:"liability_id"`
+	Name        string  `json:"name"`
+	Payment     float64 `json:"payment"`
+	Interest    float64 `json:"interest"`
+	Principal   float64 `json:"principal"`
+	Balance     float64 `json:"balance"`
+}
+
+// PayoffMonth represents one month of an amortization schedule
+type PayoffMonth struct {
+	Month            int             `json:"month"`
+	Date             string          `json:"date"`
+	Payments         []PayoffPayment `json:"payments"`
+	TotalPayment     float64         `json:"total_payment"`
+	TotalInterest    float64         `json:"total_interest"`
+	RemainingBalance float64         `json:"remaining_balance"`
+}
+
+// DebtPayoffSummary represents the outcome for a single liability
+type DebtPayoffSummary struct {
+	LiabilityID  string  `json:"liability_id"`
+	Name         string  `json:"name"`
+	PayoffDate   string  `json:"payoff_date"`
+	Months       int     `json:"months"`
+	InterestPaid float64 `json:"interest_paid"`
+	TotalPaid    float64 `json:"total_paid"`
+}
+
+// PayoffPlan represents a full simulated payoff plan
+type PayoffPlan struct {
+	Strategy      string              `json:"strategy"`
+	MonthlyBudget float64             `json:"monthly_budget"`
+	Order         []string            `json:"order"`
+	Months        int                 `json:"months"`
+	PayoffDate    string              `json:"payoff_date"`
+	TotalInterest float64             `json:"total_interest"`
+	TotalPaid     float64             `json:"total_paid"`
+	Debts         []DebtPayoffSummary `json:"debts"`
+	Schedule      []PayoffMonth       `json:"schedule"`
+}
+
+// SimulatePayoff simulates paying off liabilities with a fixed monthly budget.
+// Minimum payments are made on every open liability first; whatever is left
+// goes to liabilities in strategy order, so freed-up minimums roll forward.
+func SimulatePayoff(liabilities []Liability, monthlyBudget float64, strategy string, customOrder []string, start time.Time) (*PayoffPlan, error) {
+	if monthlyBudget <= 0 {
+		return nil, fmt.Errorf("monthly budget must be positive")
+	}
+
+	var debts []Liability
+	var minimums float64
+	for _, l := range liabilities {
+		if l.Balance < 0 || l.APR < 0 || l.MinimumPayment < 0 {
+			return nil, fmt.Errorf("liability %q has a negative balance, APR or minimum payment", l.ID)
+		}
+		if l.Balance == 0 {
+			continue
+		}
+		debts = append(debts, l)
+		minimums += l.MinimumPayment
+	}
+	if len(debts) == 0 {
+		return nil, fmt.Errorf("no liabilities with an outstanding balance")
+	}
+	if minimums > monthlyBudget {
+		return nil, fmt.Errorf("monthly budget %.2f does not cover minimum payments of %.2f", monthlyBudget, roundCents(minimums))
+	}
+
+	ordered, err := orderLiabilities(debts, strategy, customOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &PayoffPlan{
+		Strategy:      strategy,
+		MonthlyBudget: monthlyBudget,
+	}
+	balances := make([]float64, len(ordered))
+	summaries := make([]DebtPayoffSummary, len(ordered))
+	for i, l := range ordered {
+		balances[i] = l.Balance
+		summaries[i] = DebtPayoffSummary{LiabilityID: l.ID, Name: l.Name}
+		plan.Order = append(plan.Order, l.ID)
+	}
+
+	for month := 1; month <= MaxPayoffMonths; month++ {
+		date := addMonths(start, month-1).Format("2006-01-02")
+		payments := make([]PayoffPayment, len(ordered))
+		available := monthlyBudget
+		var owedBefore float64
+
+		// Accrue interest and make minimum payments
+		for i, l := range ordered {
+			if balances[i] <= 0 {
+				continue
+			}
+			owedBefore += balances[i]
+			interest := roundCents(balances[i] * l.APR / 100 / 12)
+			balances[i] = roundCents(balances[i] + interest)
+			pay := math.Min(l.MinimumPayment, balances[i])
+			balances[i] = roundCents(balances[i] - pay)
+			available -= pay
+			payments[i] = PayoffPayment{LiabilityID: l.ID, Name: l.Name, Payment: pay, Interest: interest}
+		}
+
+		// Direct the remainder at liabilities in priority order
+		for i := range ordered {
+			if available <= 0 {
+				break
+			}
+			if balances[i] <= 0 {
+				continue
+			}
+			extra := math.Min(available, balances[i])
+			balances[i] = roundCents(balances[i] - extra)
+			available -= extra
+			payments[i].Payment += extra
+		}
+
+		entry := PayoffMonth{Month: month, Date: date}
+		for i := range ordered {
+			p := payments[i]
+			if p.LiabilityID == "" {
+				continue
+			}
+			p.Payment = roundCents(p.Payment)
+			p.Principal = roundCents(p.Payment - p.Interest)
+			p.Balance = balances[i]
+			entry.Payments = append(entry.Payments, p)
+			entry.TotalPayment += p.Payment
+			entry.TotalInterest += p.Interest
+			entry.RemainingBalance += p.Balance
+
+			summaries[i].InterestPaid += p.Interest
+			summaries[i].TotalPaid += p.Payment
+			if p.Balance <= 0 && summaries[i].PayoffDate == "" {
+				summaries[i].PayoffDate = date
+				summaries[i].Months = month
+			}
+		}
+		entry.TotalPayment = roundCents(entry.TotalPayment)
+		entry.TotalInterest = roundCents(entry.TotalInterest)
+		entry.RemainingBalance = roundCents(entry.RemainingBalance)
+
+		plan.Schedule = append(plan.Schedule, entry)
+		plan.TotalInterest += entry.TotalInterest
+		plan.TotalPaid += entry.TotalPayment
+
+		if entry.RemainingBalance <= 0 {
+			plan.Months = month
+			plan.PayoffDate = date
+			break
+		}
+		if entry.RemainingBalance >= owedBefore {
+			return nil, fmt.Errorf("monthly budget %.2f does not cover accruing interest", monthlyBudget)
+		}
+	}
+
+	if plan.PayoffDate == "" {
+		return nil, fmt.Errorf("liabilities are not paid off within %d months", MaxPayoffMonths)
+	}
+
+	plan.TotalInterest = roundCents(plan.TotalInterest)
+	plan.TotalPaid = roundCents(plan.TotalPaid)
+	for i := range summaries {
+		summaries[i].InterestPaid = roundCents(summaries[i].InterestPaid)
+		summaries[i].TotalPaid = roundCents(summaries[i].TotalPaid)
+	}
+	plan.Debts = summaries
+
+	return plan, nil
+}
+
+// orderLiabilities sorts liabilities into payoff priority for a strategy
+func orderLiabilities(debts []Liability, strategy string, customOrder []string) ([]Liability, error) {
+	ordered := make([]Liability, len(debts))
+	copy(ordered, debts)
+
+	avalanche := func(a, b Liability) bool {
+		if a.APR != b.APR {
+			return a.APR > b.APR
+		}
+		return a.Balance < b.Balance
+	}
+
+	switch strategy {
+	case StrategyAvalanche:
+		sort.SliceStable(ordered, func(i, j int) bool { return avalanche(ordered[i], ordered[j]) })
+	case StrategySnowball:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if ordered[i].Balance != ordered[j].Balance {
+				return ordered[i].Balance < ordered[j].Balance
+			}
+			return ordered[i].APR > ordered[j].APR
+		})
+	case StrategyCustom:
+		if len(customOrder) == 0 {
+			return nil, fmt.Errorf("custom strategy requires custom_order")
+		}
+		rank := make(map[string]int, len(customOrder))
+		for i, id := range customOrder {
+			rank[id] = i
+		}
+		known := make(map[string]bool, len(debts))
+		for _, l := range debts {
+			known[l.ID] = true
+		}
+		for _, id := range customOrder {
+			if !known[id] {
+				return nil, fmt.Errorf("custom_order references unknown liability %q", id)
+			}
+		}
+		// Liabilities missing from the custom order follow in avalanche order
+		sort.SliceStable(ordered, func(i, j int) bool {
+			ri, iok := rank[ordered[i].ID]
+			rj, jok := rank[ordered[j].ID]
+			switch {
+			case iok && jok:
+				return ri < rj
+			case iok != jok:
+				return iok
+			default:
+				return avalanche(ordered[i], ordered[j])
+			}
+		})
+	default:
+		return nil, fmt.Errorf("invalid strategy %q", strategy)
+	}
+
+	return ordered, nil
+}
+
+// roundCents rounds an amount to two decimal places
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSimulatePayoffWithoutInterest(t *testing.T) {
+	liabilities := []Liability{{ID: "loan", Name: "Loan", Balance: 1000, MinimumPayment: 100}}
+	plan, err := SimulatePayoff(liabilities, 250, StrategyAvalanche, nil, date(2024, 1, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Months != 4 || plan.TotalInterest != 0 || plan.TotalPaid != 1000 {
+		t.Errorf("months %d, interest %v, paid %v; want 4, 0 and 1000", plan.Months, plan.TotalInterest, plan.TotalPaid)
+	}
+	// Schedule dates step from the start without skipping short months
+	var dates []string
+	for _, month := range plan.Schedule {
+		dates = append(dates, month.Date)
+	}
+	if want := []string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30"}; !reflect.DeepEqual(dates, want) {
+		t.Errorf("schedule dates %v, want %v", dates, want)
+	}
+	if plan.PayoffDate != "2024-04-30" || plan.Debts[0].PayoffDate != "2024-04-30" {
+		t.Errorf("payoff date %q, want 2024-04-30", plan.PayoffDate)
+	}
+}
+
+func TestSimulatePayoffInterest(t *testing.T) {
+	// 12% APR accrues 1% a month: 1000 -> 1010, then 1010 - 510 = 500 -> 505
+	liabilities := []Liability{{ID: "card", Balance: 1000, APR: 12, MinimumPayment: 20}}
+	plan, err := SimulatePayoff(liabilities, 510, StrategyAvalanche, nil, date(2024, 1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Months != 2 || plan.TotalInterest != 15 || plan.TotalPaid != 1015 {
+		t.Errorf("months %d, interest %v, paid %v; want 2, 15 and 1015", plan.Months, plan.TotalInterest, plan.TotalPaid)
+	}
+	first := plan.Schedule[0].Payments[0]
+	if first.Interest != 10 || first.Principal != 500 || first.Balance != 500 {
+		t.Errorf("first payment %+v", first)
+	}
+}
+
+func TestSimulatePayoffStrategies(t *testing.T) {
+	liabilities := []Liability{
+		{ID: "card", Balance: 3000, APR: 24, MinimumPayment: 60},
+		{ID: "car", Balance: 500, APR: 4, MinimumPayment: 50},
+		{ID: "store", Balance: 1500, APR: 24, MinimumPayment: 30},
+	}
+	tests := []struct {
+		strategy string
+		custom   []string
+		want     []string
+	}{
+		{StrategyAvalanche, nil, []string{"store", "card", "car"}},
+		{StrategySnowball, nil, []string{"car", "store", "card"}},
+		{StrategyCustom, []string{"card"}, []string{"card", "store", "car"}},
+	}
+	plans := map[string]*PayoffPlan{}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			plan, err := SimulatePayoff(liabilities, 400, tt.strategy, tt.custom, date(2024, 1, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(plan.Order, tt.want) {
+				t.Errorf("order %v, want %v", plan.Order, tt.want)
+			}
+			plans[tt.strategy] = plan
+		})
+	}
+	if plans[StrategyAvalanche].TotalInterest > plans[StrategySnowball].TotalInterest {
+		t.Errorf("avalanche interest %v exceeds snowball %v", plans[StrategyAvalanche].TotalInterest, plans[StrategySnowball].TotalInterest)
+	}
+}
+
+func TestSimulatePayoffErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		liabilities []Liability
+		budget      float64
+		strategy    string
+		custom      []string
+	}{
+		{"no budget", []Liability{{ID: "a", Balance: 100}}, 0, StrategyAvalanche, nil},
+		{"negative balance", []Liability{{ID: "a", Balance: -1}}, 100, StrategyAvalanche, nil},
+		{"nothing owed", []Liability{{ID: "a", Balance: 0}}, 100, StrategyAvalanche, nil},
+		{"minimums not covered", []Liability{{ID: "a", Balance: 1000, MinimumPayment: 150}}, 100, StrategyAvalanche, nil},
+		{"interest not covered", []Liability{{ID: "a", Balance: 10000, APR: 24, MinimumPayment: 100}}, 150, StrategyAvalanche, nil},
+		{"unknown strategy", []Liability{{ID: "a", Balance: 100}}, 100, "fastest", nil},
+		{"custom without order", []Liability{{ID: "a", Balance: 100}}, 100, StrategyCustom, nil},
+		{"custom with unknown liability", []Liability{{ID: "a", Balance: 100}}, 100, StrategyCustom, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SimulatePayoff(tt.liabilities, tt.budget, tt.strategy, tt.custom, date(2024, 1, 1)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
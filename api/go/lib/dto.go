@@ -0,0 +1,332 @@
+package lib
+
+// Response DTOs returned in the data field of the standard Response envelope.
+// Every endpoint responds with one of these so the OpenAPI document can be
+// generated from them.
+
+// MessageResponse represents a response that only carries a message
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// DeleteResponse represents the result of deleting a resource
+type DeleteResponse struct {
+	Message string `json:"message"`
+	ID      string `json:"id"`
+}
+
+// HealthStatus represents the health check response. Checks are only
+// reported by the readiness probe.
+type HealthStatus struct {
+	Status      string             `json:"status"` // healthy, degraded or unhealthy
+	Probe       string             `json:"probe"`  // live or ready
+	Timestamp   string             `json:"timestamp"`
+	Version     string             `json:"version"`
+	Runtime     string             `json:"runtime"`
+	GoVersion   string             `json:"go_version"`
+	Environment string             `json:"environment"`
+	Build       BuildInfo          `json:"build"`
+	Memory      MemoryStats        `json:"memory"`
+	Checks      []DependencyStatus `json:"checks,omitempty"`
+}
+
+// BuildInfo represents the version and commit the API was built from
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitSHA    string `json:"git_sha,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // built from a tree with uncommitted changes
+}
+
+// DependencyStatus represents the result of probing one dependency
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"` // ok, failed or skipped
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// MemoryStats represents Go runtime memory statistics
+type MemoryStats struct {
+	Alloc      uint64 `json:"alloc"`
+	TotalAlloc uint64 `json:"total_alloc"`
+	Sys        uint64 `json:"sys"`
+	NumGC      uint64 `json:"num_gc"`
+}
+
+// TransactionSummary represents totals for a list of transactions
+type TransactionSummary struct {
+	TotalIncome   float64 `json:"total_income"`
+	TotalExpenses float64 `json:"total_expenses"`
+	Count         int     `json:"count"`
+}
+
+// TransactionListResponse represents a page of transactions
+type TransactionListResponse struct {
+	Transactions []Transaction      `json:"transactions"`
+	Summary      TransactionSummary `json:"summary"`
+	Pagination   Pagination         `json:"pagination"`
+}
+
+// TransactionResponse represents a single transaction
+type TransactionResponse struct {
+	Transaction Transaction `json:"transaction"`
+}
+
+// BudgetListResponse represents budgets with their current period
+type BudgetListResponse struct {
+	Budgets []BudgetStatus `json:"budgets"`
+}
+
+// BudgetResponse represents a single budget
+type BudgetResponse struct {
+	Budget Budget `json:"budget"`
+}
+
+// BudgetBatchResponse represents budgets created together
+type BudgetBatchResponse struct {
+	Budgets []Budget `json:"budgets"`
+	Count   int      `json:"count"`
+}
+
+// BudgetHistoryResponse represents a budget's period history, most recent first
+type BudgetHistoryResponse struct {
+	Budget  Budget         `json:"budget"`
+	History []BudgetPeriod `json:"history"`
+}
+
+// BudgetProposalResponse represents budgets proposed from spending history
+type BudgetProposalResponse struct {
+	Proposal *BudgetProposal `json:"proposal"`
+}
+
+// ProfileResponse represents a user profile
+type ProfileResponse struct {
+	Profile UserProfile `json:"profile"`
+	Message string      `json:"message,omitempty"`
+}
+
+// SummaryAnalyticsResponse represents summary analytics
+type SummaryAnalyticsResponse struct {
+	Summary AnalyticsSummary `json:"summary"`
+}
+
+// CategoryAnalyticsResponse represents the per-category breakdown
+type CategoryAnalyticsResponse struct {
+	Categories []CategoryAnalytics `json:"categories"`
+}
+
+// TrendAnalyticsResponse represents monthly trend analytics
+type TrendAnalyticsResponse struct {
+	Trend []TrendData `json:"trend"`
+}
+
+// ForecastAnalyticsResponse represents a cash-flow forecast
+type ForecastAnalyticsResponse struct {
+	Forecast *CashFlowForecast `json:"forecast"`
+}
+
+// AnomalyAnalyticsResponse represents anomalies detected within a window
+type AnomalyAnalyticsResponse struct {
+	Anomalies []Anomaly `json:"anomalies"`
+	Window    DateRange `json:"window"`
+}
+
+// DateRange represents an inclusive range of YYYY-MM-DD dates
+type DateRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// DebtPayoffResponse represents simulated payoff plans and the recommended strategy
+type DebtPayoffResponse struct {
+	Plans       []*PayoffPlan `json:"plans"`
+	Recommended string        `json:"recommended"`
+}
+
+// EnvelopeMonthResponse represents the envelope budget for a month
+type EnvelopeMonthResponse struct {
+	Budget *EnvelopeMonth `json:"budget"`
+}
+
+// EnvelopeResponse represents a single envelope
+type EnvelopeResponse struct {
+	Envelope Envelope `json:"envelope"`
+}
+
+// EnvelopeAssignmentResponse represents an assignment and the resulting month
+type EnvelopeAssignmentResponse struct {
+	Assignment EnvelopeAssignment `json:"assignment"`
+	Budget     *EnvelopeMonth     `json:"budget"`
+}
+
+// EnvelopeTransferResponse represents a transfer and the resulting month
+type EnvelopeTransferResponse struct {
+	Transfer EnvelopeTransfer `json:"transfer"`
+	Budget   *EnvelopeMonth   `json:"budget"`
+}
+
+// WorkspaceListResponse represents the workspaces a user belongs to
+type WorkspaceListResponse struct {
+	Workspaces []Workspace `json:"workspaces"`
+}
+
+// WorkspaceDetailResponse represents a workspace with its members and the caller's role
+type WorkspaceDetailResponse struct {
+	Workspace *Workspace        `json:"workspace"`
+	Members   []WorkspaceMember `json:"members"`
+	Role      string            `json:"role"`
+}
+
+// WorkspaceResponse represents a single workspace
+type WorkspaceResponse struct {
+	Workspace Workspace `json:"workspace"`
+}
+
+// InvitationResponse represents a created invitation and its one-time token
+type InvitationResponse struct {
+	Invitation WorkspaceInvitation `json:"invitation"`
+	Token      string              `json:"token"`
+	AcceptURL  string              `json:"accept_url"`
+}
+
+// MemberResponse represents a single workspace member
+type MemberResponse struct {
+	Member WorkspaceMember `json:"member"`
+}
+
+// RemoveMemberResponse represents the result of removing a workspace member
+type RemoveMemberResponse struct {
+	Message string `json:"message"`
+	UserID  string `json:"user_id"`
+}
+
+// SharedExpenseListResponse represents a workspace's shared expenses and settlements
+type SharedExpenseListResponse struct {
+	Expenses    []SharedExpense `json:"expenses"`
+	Settlements []Settlement    `json:"settlements"`
+}
+
+// SplitBalancesResponse represents member balances and the transfers that settle them
+type SplitBalancesResponse struct {
+	Balances  []MemberBalance `json:"balances"`
+	Transfers []Transfer      `json:"transfers"`
+}
+
+// SharedExpenseResponse represents a single shared expense
+type SharedExpenseResponse struct {
+	Expense SharedExpense `json:"expense"`
+}
+
+// SettlementResponse represents a settlement and the transactions it records
+type SettlementResponse struct {
+	Settlement   Settlement    `json:"settlement"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// TrashedResponse represents a resource moved to the trash
+type TrashedResponse struct {
+	Message string      `json:"message"`
+	Item    TrashedItem `json:"item"`
+}
+
+// TrashListResponse represents the items in the trash
+type TrashListResponse struct {
+	Items         []TrashedItem `json:"items"`
+	RetentionDays int           `json:"retention_days"`
+}
+
+// RestoreResponse represents a resource restored from the trash
+type RestoreResponse struct {
+	Message     string       `json:"message"`
+	Type        string       `json:"type"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Budget      *Budget      `json:"budget,omitempty"`
+}
+
+// AuditLogResponse represents a page of the caller's audit records
+type AuditLogResponse struct {
+	Records    []AuditRecord `json:"records"`
+	Pagination Pagination    `json:"pagination"`
+}
+
+// Query parameter DTOs, bound with BindQuery
+
+// TransactionListParams represents the query for listing transactions
+type TransactionListParams struct {
+	Limit          int    `query:"limit" default:"50" validate:"min=1,max=100"`
+	Offset         int    `query:"offset" default:"0" validate:"min=0"`
+	Type           string `query:"type" validate:"oneof=income expense"`
+	Category       string `query:"category" validate:"max=100"`
+	IncludeDeleted bool   `query:"include_deleted" doc:"Also list transactions in the trash"`
+}
+
+// BudgetListParams represents the query for listing budgets
+type BudgetListParams struct {
+	Period         string `query:"period" default:"monthly" validate:"oneof=weekly monthly yearly"`
+	IncludeDeleted bool   `query:"include_deleted" doc:"Also list budgets in the trash"`
+}
+
+// BudgetHistoryParams represents the query for a budget's period history
+type BudgetHistoryParams struct {
+	ID      string `query:"id" validate:"required"`
+	Periods int    `query:"periods" default:"12" validate:"min=1,max=120"`
+}
+
+// BudgetProposalParams represents the query for proposing budgets
+type BudgetProposalParams struct {
+	Months          int                `query:"months" default:"3" validate:"min=1,max=24"`
+	Buffer          float64            `query:"buffer" default:"10" validate:"min=0,max=100" doc:"Buffer percent; override per category with buffer_<category>"`
+	Income          float64            `query:"income" validate:"min=0" doc:"Monthly income to fit the template to"`
+	Template        string             `query:"template" validate:"oneof=50/30/20 70/20/10 60/20/20"`
+	CategoryBuffers map[string]float64 `query:"buffer_*" validate:"min=0,max=100"` // buffer_<category>=<percent>
+}
+
+// AnalyticsRangeParams represents the query for the summary, category and
+// trend analytics
+type AnalyticsRangeParams struct {
+	StartDate string `query:"start_date" validate:"date" doc:"Only transactions on or after this date"`
+	EndDate   string `query:"end_date" validate:"date" doc:"Only transactions on or before this date"`
+}
+
+// ForecastParams represents the query for a cash-flow forecast
+type ForecastParams struct {
+	Days            int     `query:"days" default:"30" validate:"min=1,max=365"`
+	Threshold       float64 `query:"threshold" default:"0" doc:"Low balance warning threshold"`
+	Confidence      float64 `query:"confidence" default:"0.8" validate:"oneof=0.8 0.9 0.95"`
+	StartingBalance float64 `query:"starting_balance" default:"2500"`
+}
+
+// AnomalyParams represents the query for anomaly detection
+type AnomalyParams struct {
+	Days       int     `query:"days" default:"30" validate:"min=1,max=90"`
+	ZThreshold float64 `query:"z_threshold" default:"3" validate:"gt=0"`
+}
+
+// EnvelopeMonthParams represents the query for an envelope month
+type EnvelopeMonthParams struct {
+	Month string `query:"month" validate:"month" doc:"YYYY-MM within 5 years of the current month, defaults to the current month"`
+}
+
+// TrashListParams represents the query for listing the trash
+type TrashListParams struct {
+	Type string `query:"type" validate:"oneof=transaction budget"`
+}
+
+// TrashItemParams represents the query identifying an item in the trash
+type TrashItemParams struct {
+	Type string `query:"type" validate:"required,oneof=transaction budget"`
+	ID   string `query:"id" validate:"required"`
+}
+
+// AuditLogParams represents the query for listing audit records
+type AuditLogParams struct {
+	Resource   string `query:"resource" validate:"max=50" doc:"e.g. transaction, budget, workspace"`
+	ResourceID string `query:"resource_id" validate:"max=100"`
+	Action     string `query:"action" validate:"oneof=create update delete restore"`
+	Since      string `query:"since" validate:"datetime" doc:"Only records at or after this time"`
+	Until      string `query:"until" validate:"datetime" doc:"Only records at or before this time"`
+	Limit      int    `query:"limit" default:"50" validate:"min=1,max=200"`
+	Offset     int    `query:"offset" default:"0" validate:"min=0"`
+}
@@ -0,0 +1,279 @@
+package lib
+
+import "net/http"
+
+// API metadata served by the index endpoint
+const (
+	APITitle   = "Budget Buddy Serverless API (Go)"
+	APIVersion = "1.0.0"
+)
+
+// Endpoint describes a serverless function and the operations it serves
+type Endpoint struct {
+	Name            string
+	Path            string
+	Description     string
+	Auth            bool
+	WorkspaceScoped bool
+	Idempotent      bool // POST requests accept an Idempotency-Key header
+	RateLimited     bool // requests may be refused with 429 Too Many Requests
+	Raw             bool // responses are written as-is rather than in the Response envelope
+	Operations      []Operation
+}
+
+// Operation describes one request and response of an endpoint. Endpoints
+// that dispatch on a query parameter set Selector, either "key=value" such as
+// "action=settle" or just a parameter name when its presence selects the
+// operation.
+type Operation struct {
+	Method   string
+	Selector string
+	Default  bool // selected when the selector parameter is omitted
+	Summary  string
+	Params   []Param
+	Query    interface{} // query DTO whose query-tagged fields are parameters
+	Body     interface{} // request DTO, nil when there is no body
+	Response interface{} // response DTO wrapped in the Response envelope
+	Status   int         // success status, defaults to 200
+
+	ContentType  string // request body media type, defaults to application/json
+	ResponseType string // response media type of raw endpoints, defaults to application/json
+	ETag         bool   // the response carries an ETag; writes also honour If-Match
+}
+
+// Param describes a query parameter
+type Param struct {
+	Name        string
+	Type        string // string, integer, number or boolean; defaults to string
+	Description string
+	Default     string
+	Required    bool
+	Enum        []string
+	Rules       string // validate rules, see Validate
+}
+
+var (
+	idParam        = Param{Name: "id", Description: "Resource ID", Required: true}
+	workspaceParam = Param{Name: "workspace_id", Description: "Shared workspace to act in; the caller must be a member"}
+
+	requiredWorkspaceParam = Param{Name: workspaceParam.Name, Description: workspaceParam.Description, Required: true}
+)
+
+// Endpoints lists every function with its operations and DTOs, in the order
+// they appear in the generated OpenAPI document
+var Endpoints = []Endpoint{
+	{
+		Name:        "index",
+		Path:        "/api/go",
+		Description: "API index",
+		Raw:         true,
+		Operations: []Operation{
+			{Method: http.MethodGet, Summary: "OpenAPI 3.1 document describing this API", Response: OpenAPIDocument{}},
+		},
+	},
+	{
+		Name:        "health",
+		Path:        "/api/go/health",
+		Description: "Liveness and readiness probes",
+		Operations: []Operation{
+			{Method: http.MethodGet, Selector: "probe=live", Default: true, Summary: "Liveness: runtime statistics and build info", Response: HealthStatus{}},
+			{
+				Method:   http.MethodGet,
+				Selector: "probe=ready",
+				Summary:  "Readiness: status and latency of each dependency, 503 when a critical one is failing",
+				Response: HealthStatus{},
+			},
+		},
+	},
+	{
+		Name:            "transactions",
+		Path:            "/api/go/transactions",
+		Description:     "Transaction CRUD operations",
+		Auth:            true,
+		RateLimited:     true,
+		WorkspaceScoped: true,
+		Idempotent:      true,
+		Operations: []Operation{
+			{
+				Method:   http.MethodGet,
+				Summary:  "List transactions",
+				Query:    TransactionListParams{},
+				Response: TransactionListResponse{},
+			},
+			{Method: http.MethodGet, Selector: "id", Summary: "Get a transaction", Params: []Param{idParam}, Response: TransactionResponse{}, ETag: true},
+			{Method: http.MethodPost, Summary: "Create a transaction", Body: CreateTransactionInput{}, Response: TransactionResponse{}, Status: http.StatusCreated, ETag: true},
+			{Method: http.MethodPut, Summary: "Update the given fields of a transaction", Params: []Param{idParam}, Body: UpdateTransactionInput{}, Response: TransactionResponse{}, ETag: true},
+			{Method: http.MethodPatch, Summary: "Apply a JSON merge patch to a transaction; null clears a field", Params: []Param{idParam}, Body: UpdateTransactionInput{}, ContentType: MergePatchContentType, Response: TransactionResponse{}, ETag: true},
+			{Method: http.MethodDelete, Summary: "Move a transaction to the trash", Params: []Param{idParam}, Response: TrashedResponse{}},
+		},
+	},
+	{
+		Name:            "budgets",
+		Path:            "/api/go/budgets",
+		Description:     "Budget CRUD operations",
+		Auth:            true,
+		RateLimited:     true,
+		WorkspaceScoped: true,
+		Idempotent:      true,
+		Operations: []Operation{
+			{
+				Method:   http.MethodGet,
+				Summary:  "List budgets with their current period",
+				Query:    BudgetListParams{},
+				Response: BudgetListResponse{},
+			},
+			{Method: http.MethodGet, Selector: "id", Summary: "Get a budget", Params: []Param{idParam}, Response: BudgetResponse{}, ETag: true},
+			{
+				Method:   http.MethodGet,
+				Selector: "view=history",
+				Summary:  "Budget period history with rollover, most recent first",
+				Query:    BudgetHistoryParams{},
+				Response: BudgetHistoryResponse{},
+			},
+			{
+				Method:   http.MethodGet,
+				Selector: "view=proposal",
+				Summary:  "Propose budgets from spending history",
+				Query:    BudgetProposalParams{},
+				Response: BudgetProposalResponse{},
+			},
+			{Method: http.MethodPost, Summary: "Create a budget", Body: CreateBudgetInput{}, Response: BudgetResponse{}, Status: http.StatusCreated, ETag: true},
+			{Method: http.MethodPost, Selector: "action=accept", Summary: "Create a set of budgets, such as an accepted proposal", Body: CreateBudgetsInput{}, Response: BudgetBatchResponse{}, Status: http.StatusCreated},
+			{Method: http.MethodPut, Summary: "Update the given fields of a budget", Params: []Param{idParam}, Body: UpdateBudgetInput{}, Response: BudgetResponse{}, ETag: true},
+			{Method: http.MethodPatch, Summary: "Apply a JSON merge patch to a budget; null clears a field", Params: []Param{idParam}, Body: UpdateBudgetInput{}, ContentType: MergePatchContentType, Response: BudgetResponse{}, ETag: true},
+			{Method: http.MethodDelete, Summary: "Move a budget to the trash", Params: []Param{idParam}, Response: TrashedResponse{}},
+		},
+	},
+	{
+		Name:            "analytics",
+		Path:            "/api/go/analytics",
+		Description:     "Financial analytics",
+		Auth:            true,
+		RateLimited:     true,
+		WorkspaceScoped: true,
+		Operations: []Operation{
+			{Method: http.MethodGet, Selector: "type=summary", Default: true, Summary: "Income, expenses and savings summary", Query: AnalyticsRangeParams{}, Response: SummaryAnalyticsResponse{}},
+			{Method: http.MethodGet, Selector: "type=category", Summary: "Breakdown by category", Query: AnalyticsRangeParams{}, Response: CategoryAnalyticsResponse{}},
+			{Method: http.MethodGet, Selector: "type=trend", Summary: "Monthly trend", Query: AnalyticsRangeParams{}, Response: TrendAnalyticsResponse{}},
+			{
+				Method:   http.MethodGet,
+				Selector: "type=forecast",
+				Summary:  "Daily cash-flow forecast",
+				Query:    ForecastParams{},
+				Response: ForecastAnalyticsResponse{},
+			},
+			{
+				Method:   http.MethodGet,
+				Selector: "type=anomalies",
+				Summary:  "Spending anomalies",
+				Query:    AnomalyParams{},
+				Response: AnomalyAnalyticsResponse{},
+			},
+		},
+	},
+	{
+		Name:        "users",
+		Path:        "/api/go/users",
+		Description: "User profile operations",
+		Auth:        true,
+		RateLimited: true,
+		Operations: []Operation{
+			{Method: http.MethodGet, Summary: "Get the current user's profile", Response: ProfileResponse{}},
+			{Method: http.MethodPut, Summary: "Update the current user's profile", Body: UpdateProfileInput{}, Response: ProfileResponse{}},
+			{Method: http.MethodDelete, Summary: "Delete the current user's account", Body: DeleteAccountInput{}, Response: MessageResponse{}},
+		},
+	},
+	{
+		Name:        "debts",
+		Path:        "/api/go/debts",
+		Description: "Debt payoff simulations",
+		Auth:        true,
+		RateLimited: true,
+		Operations: []Operation{
+			{Method: http.MethodPost, Summary: "Simulate and compare debt payoff strategies", Body: DebtPayoffInput{}, Response: DebtPayoffResponse{}},
+		},
+	},
+	{
+		Name:            "envelopes",
+		Path:            "/api/go/envelopes",
+		Description:     "Zero-based envelope budgeting",
+		Auth:            true,
+		RateLimited:     true,
+		WorkspaceScoped: true,
+		Idempotent:      true,
+		Operations: []Operation{
+			{Method: http.MethodGet, Summary: "Envelope balances for a month", Query: EnvelopeMonthParams{}, Response: EnvelopeMonthResponse{}},
+			{Method: http.MethodPost, Selector: "action=create", Default: true, Summary: "Create an envelope", Body: CreateEnvelopeInput{}, Response: EnvelopeResponse{}, Status: http.StatusCreated},
+			{Method: http.MethodPost, Selector: "action=assign", Summary: "Assign money to an envelope", Body: EnvelopeAssignment{}, Response: EnvelopeAssignmentResponse{}, Status: http.StatusCreated},
+			{Method: http.MethodPost, Selector: "action=move", Summary: "Move money between envelopes", Body: EnvelopeTransfer{}, Response: EnvelopeTransferResponse{}, Status: http.StatusCreated},
+			{Method: http.MethodDelete, Summary: "Delete an envelope", Params: []Param{idParam}, Response: DeleteResponse{}},
+		},
+	},
+	{
+		Name:        "workspaces",
+		Path:        "/api/go/workspaces",
+		Description: "Shared household workspaces",
+		Auth:        true,
+		RateLimited: true,
+		Idempotent:  true,
+		Operations: []Operation{
+			{Method: http.MethodGet, Summary: "List the current user's workspaces", Response: WorkspaceListResponse{}},
+			{Method: http.MethodGet, Selector: "id", Summary: "Get a workspace with its members", Params: []Param{idParam}, Response: WorkspaceDetailResponse{}},
+			{Method: http.MethodPost, Selector: "action=create", Default: true, Summary: "Create a workspace", Body: CreateWorkspaceInput{}, Response: WorkspaceResponse{}, Status: http.StatusCreated},
+			{Method: http.MethodPost, Selector: "action=invite", Summary: "Invite a member", Params: []Param{idParam}, Body: InviteMemberInput{}, Response: InvitationResponse{}, Status: http.StatusCreated},
+			{Method: http.MethodPost, Selector: "action=accept", Summary: "Accept an invitation", Body: AcceptInvitationInput{}, Response: MemberResponse{}},
+			{Method: http.MethodPut, Summary: "Change a member's role", Params: []Param{idParam, {Name: "user_id", Required: true}}, Body: UpdateMemberInput{}, Response: MemberResponse{}},
+			{Method: http.MethodDelete, Selector: "user_id", Summary: "Remove a member or leave the workspace", Params: []Param{idParam, {Name: "user_id"}}, Response: RemoveMemberResponse{}},
+			{Method: http.MethodDelete, Summary: "Delete a workspace", Params: []Param{idParam}, Response: DeleteResponse{}},
+		},
+	},
+	{
+		Name:            "splits",
+		Path:            "/api/go/splits",
+		Description:     "Shared expense splitting and settle-up",
+		Auth:            true,
+		RateLimited:     true,
+		WorkspaceScoped: true,
+		Idempotent:      true,
+		Operations: []Operation{
+			{Method: http.MethodGet, Summary: "List shared expenses and settlements", Params: []Param{requiredWorkspaceParam}, Response: SharedExpenseListResponse{}},
+			{Method: http.MethodGet, Selector: "view=balances", Summary: "Member balances and suggested settle-up transfers", Params: []Param{requiredWorkspaceParam}, Response: SplitBalancesResponse{}},
+			{Method: http.MethodPost, Summary: "Record a shared expense", Params: []Param{requiredWorkspaceParam}, Body: CreateSharedExpenseInput{}, Response: SharedExpenseResponse{}, Status: http.StatusCreated},
+			{Method: http.MethodPost, Selector: "action=settle", Summary: "Record a settlement paid or received by the caller; the owner may record any", Params: []Param{requiredWorkspaceParam}, Body: CreateSettlementInput{}, Response: SettlementResponse{}, Status: http.StatusCreated},
+			{Method: http.MethodDelete, Summary: "Delete a shared expense added by the caller; the owner may delete any", Params: []Param{requiredWorkspaceParam, idParam}, Response: DeleteResponse{}},
+		},
+	},
+	{
+		Name:            "trash",
+		Path:            "/api/go/trash",
+		Description:     "Deleted transactions and budgets, restorable until purged",
+		Auth:            true,
+		RateLimited:     true,
+		WorkspaceScoped: true,
+		Idempotent:      true,
+		Operations: []Operation{
+			{Method: http.MethodGet, Summary: "List items in the trash, most recently deleted first", Query: TrashListParams{}, Response: TrashListResponse{}},
+			{Method: http.MethodPost, Selector: "action=restore", Summary: "Restore an item from the trash", Query: TrashItemParams{}, Response: RestoreResponse{}},
+			{Method: http.MethodDelete, Summary: "Permanently delete an item from the trash", Query: TrashItemParams{}, Response: DeleteResponse{}},
+		},
+	},
+	{
+		Name:        "audit",
+		Path:        "/api/go/audit",
+		Description: "Audit log of the caller's creates, updates and deletes",
+		Auth:        true,
+		RateLimited: true,
+		Operations: []Operation{
+			{Method: http.MethodGet, Summary: "List the caller's audit records, newest first", Query: AuditLogParams{}, Response: AuditLogResponse{}},
+		},
+	},
+	{
+		Name:        "metrics",
+		Path:        "/api/go/metrics",
+		Description: "Request, error, rate limit and storage metrics; send METRICS_TOKEN as a bearer token",
+		Raw:         true,
+		Operations: []Operation{
+			{Method: http.MethodGet, Summary: "Metrics in the OpenMetrics text format, or the Prometheus text format unless OpenMetrics is accepted", ResponseType: OpenMetricsContentType},
+		},
+	},
+}
@@ -0,0 +1,201 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MonthFormat is the layout used for budgeting months
+const MonthFormat = "2006-01"
+
+// MaxMonthOffsetYears bounds how far a budgeting month may be from the current
+// month, which also bounds how many months ComputeEnvelopeMonth replays
+const MaxMonthOffsetYears = 5
+
+// MonthInRange reports whether month is within MaxMonthOffsetYears of the month
+// containing now
+func MonthInRange(month, now time.Time) bool {
+	earliest, latest := monthRange(now)
+	return !month.Before(earliest) && !month.After(latest)
+}
+
+// DateInRange reports whether date is within MaxMonthOffsetYears of the day
+// containing now
+func DateInRange(date, now time.Time) bool {
+	today := truncateDay(now)
+	return !date.Before(today.AddDate(-MaxMonthOffsetYears, 0, 0)) && !date.After(today.AddDate(MaxMonthOffsetYears, 0, 0))
+}
+
+// monthRange returns the first and last budgeting months accepted at now
+func monthRange(now time.Time) (time.Time, time.Time) {
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return current.AddDate(-MaxMonthOffsetYears, 0, 0), current.AddDate(MaxMonthOffsetYears, 0, 0)
+}
+
+// EnvelopeBalance represents one envelope's position within a month
+type EnvelopeBalance struct {
+	Envelope       Envelope `json:"envelope"`
+	CarriedOver    float64  `json:"carried_over"`
+	Assigned       float64  `json:"assigned"`
+	TransferredIn  float64  `json:"transferred_in"`
+	TransferredOut float64  `json:"transferred_out"`
+	Spent          float64  `json:"spent"`
+	Available      float64  `json:"available"`
+	Overspent      bool     `json:"overspent"`
+}
+
+// EnvelopeMonth represents the zero-based budget for a single month
+type EnvelopeMonth struct {
+	Month           string            `json:"month"`
+	Income          float64           `json:"income"`
+	Assigned        float64           `json:"assigned"`
+	Spent           float64           `json:"spent"`
+	UnbudgetedSpent float64           `json:"unbudgeted_spent"`
+	ToBeAssigned    float64           `json:"to_be_assigned"`
+	Envelopes       []EnvelopeBalance `json:"envelopes"`
+}
+
+// ComputeEnvelopeMonth replays every month from the earliest activity up to
+// month and returns that month's envelope balances and "to be assigned" money.
+//
+// At the end of each month a positive balance stays in the envelope when it
+// rolls over and is otherwise released back to "to be assigned". Overspending
+// is always cleared by taking the shortfall out of next month's "to be
+// assigned", so a negative balance never carries forward.
+//
+// month must be within MaxMonthOffsetYears of the current month, and activity
+// before the earliest such month is ignored, so at most ten years are replayed.
+func ComputeEnvelopeMonth(envelopes []Envelope, assignments []EnvelopeAssignment, transfers []EnvelopeTransfer, transactions []Transaction, month string) (*EnvelopeMonth, error) {
+	target, err := time.Parse(MonthFormat, month)
+	if err != nil {
+		return nil, fmt.Errorf("month must be in YYYY-MM format")
+	}
+	now := time.Now()
+	if !MonthInRange(target, now) {
+		return nil, fmt.Errorf("month must be within %d years of the current month", MaxMonthOffsetYears)
+	}
+
+	byCategory := make(map[string]string, len(envelopes))
+	for _, e := range envelopes {
+		byCategory[strings.ToLower(e.Category)] = e.ID
+	}
+
+	earliest, _ := monthRange(now)
+	first := target
+	consider := func(m time.Time) {
+		if m.Before(first) && !m.Before(earliest) {
+			first = m
+		}
+	}
+	for _, a := range assignments {
+		if m, err := time.Parse(MonthFormat, a.Month); err == nil {
+			consider(m)
+		}
+	}
+	for _, t := range transfers {
+		if m, err := time.Parse(MonthFormat, t.Month); err == nil {
+			consider(m)
+		}
+	}
+	for _, t := range transactions {
+		consider(time.Date(t.Date.Year(), t.Date.Month(), 1, 0, 0, 0, 0, time.UTC))
+	}
+
+	carry := make(map[string]float64, len(envelopes))
+	var carryToBeAssigned float64
+	var result *EnvelopeMonth
+
+	for m := first; !m.After(target); m = m.AddDate(0, 1, 0) {
+		key := m.Format(MonthFormat)
+		view := &EnvelopeMonth{Month: key}
+
+		assigned := make(map[string]float64)
+		for _, a := range assignments {
+			if a.Month == key {
+				assigned[a.EnvelopeID] += a.Amount
+				view.Assigned += a.Amount
+			}
+		}
+		in := make(map[string]float64)
+		out := make(map[string]float64)
+		for _, t := range transfers {
+			if t.Month == key {
+				out[t.FromEnvelopeID] += t.Amount
+				in[t.ToEnvelopeID] += t.Amount
+			}
+		}
+		spent := make(map[string]float64)
+		for _, t := range transactions {
+			if t.Date.Format(MonthFormat) != key {
+				continue
+			}
+			if t.Type == "income" {
+				view.Income += t.Amount
+				continue
+			}
+			view.Spent += t.Amount
+			if id, ok := byCategory[strings.ToLower(t.Category)]; ok {
+				spent[id] += t.Amount
+			} else {
+				view.UnbudgetedSpent += t.Amount
+			}
+		}
+
+		view.ToBeAssigned = carryToBeAssigned + view.Income - view.Assigned - view.UnbudgetedSpent
+		nextToBeAssigned := view.ToBeAssigned
+
+		for _, e := range envelopes {
+			balance := EnvelopeBalance{
+				Envelope:       e,
+				CarriedOver:    roundCents(carry[e.ID]),
+				Assigned:       roundCents(assigned[e.ID]),
+				TransferredIn:  roundCents(in[e.ID]),
+				TransferredOut: roundCents(out[e.ID]),
+				Spent:          roundCents(spent[e.ID]),
+			}
+			available := carry[e.ID] + assigned[e.ID] + in[e.ID] - out[e.ID] - spent[e.ID]
+			balance.Available = roundCents(available)
+			balance.Overspent = balance.Available < 0
+			view.Envelopes = append(view.Envelopes, balance)
+
+			switch {
+			case available < 0:
+				nextToBeAssigned += available
+				carry[e.ID] = 0
+			case e.Rollover:
+				carry[e.ID] = available
+			default:
+				nextToBeAssigned += available
+				carry[e.ID] = 0
+			}
+		}
+		carryToBeAssigned = nextToBeAssigned
+
+		view.Income = roundCents(view.Income)
+		view.Assigned = roundCents(view.Assigned)
+		view.Spent = roundCents(view.Spent)
+		view.UnbudgetedSpent = roundCents(view.UnbudgetedSpent)
+		view.ToBeAssigned = roundCents(view.ToBeAssigned)
+		result = view
+	}
+
+	sort.SliceStable(result.Envelopes, func(i, j int) bool {
+		return result.Envelopes[i].Envelope.Name < result.Envelopes[j].Envelope.Name
+	})
+	if result.Envelopes == nil {
+		result.Envelopes = []EnvelopeBalance{}
+	}
+	return result, nil
+}
+
+// EnvelopeAvailable returns the available balance of one envelope in a month view
+func (m *EnvelopeMonth) EnvelopeAvailable(envelopeID string) (float64, bool) {
+	for _, b := range m.Envelopes {
+		if b.Envelope.ID == envelopeID {
+			return b.Available, true
+		}
+	}
+	return 0, false
+}
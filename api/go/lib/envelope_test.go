@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthInRange(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		month time.Time
+		want  bool
+	}{
+		{date(2024, 6, 1), true},
+		{date(2019, 6, 1), true},
+		{date(2019, 5, 1), false},
+		{date(2029, 6, 1), true},
+		{date(2029, 7, 1), false},
+		{date(9999, 12, 1), false},
+		{date(1, 1, 1), false},
+	}
+	for _, tt := range tests {
+		if got := MonthInRange(tt.month, now); got != tt.want {
+			t.Errorf("MonthInRange(%s) = %v, want %v", tt.month.Format(MonthFormat), got, tt.want)
+		}
+	}
+}
+
+func TestDateInRange(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		date time.Time
+		want bool
+	}{
+		{date(2024, 6, 15), true},
+		{date(2019, 6, 15), true},
+		{date(2019, 6, 14), false},
+		{date(2029, 6, 15), true},
+		{date(2029, 6, 16), false},
+		{date(1, 1, 1), false},
+	}
+	for _, tt := range tests {
+		if got := DateInRange(tt.date, now); got != tt.want {
+			t.Errorf("DateInRange(%s) = %v, want %v", tt.date.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+func TestValidateMonth(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		month string
+		valid bool
+	}{
+		{now.Format(MonthFormat), true},
+		{now.AddDate(-4, 0, 0).Format(MonthFormat), true},
+		{"9999-12", false},
+		{"0001-01", false},
+		{"2024-13", false},
+		{"June", false},
+	}
+	for _, tt := range tests {
+		errs := Validate(&EnvelopeMonthParams{Month: tt.month})
+		if valid := len(errs) == 0; valid != tt.valid {
+			t.Errorf("month %q: errors %v, want valid %v", tt.month, errs, tt.valid)
+		}
+	}
+}
+
+func TestComputeEnvelopeMonth(t *testing.T) {
+	now := time.Now().UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	previous := current.AddDate(0, -1, 0)
+	envelopes := []Envelope{
+		{ID: "groceries", Category: "Groceries", Rollover: true},
+		{ID: "fun", Category: "Fun"},
+	}
+	assignments := []EnvelopeAssignment{
+		{EnvelopeID: "groceries", Month: previous.Format(MonthFormat), Amount: 300},
+		{EnvelopeID: "fun", Month: previous.Format(MonthFormat), Amount: 100},
+	}
+	transactions := []Transaction{
+		{Type: "income", Amount: 1000, Date: previous.AddDate(0, 0, 1)},
+		{Type: "expense", Category: "Groceries", Amount: 250, Date: previous.AddDate(0, 0, 2)},
+		{Type: "expense", Category: "Fun", Amount: 40, Date: previous.AddDate(0, 0, 3)},
+		// Outside the replayed range, so ignored
+		{Type: "income", Amount: 5000, Date: current.AddDate(-MaxMonthOffsetYears-1, 0, 0)},
+	}
+
+	month, err := ComputeEnvelopeMonth(envelopes, assignments, nil, transactions, current.Format(MonthFormat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Groceries keeps its 50; the unspent 60 of fun returns to be assigned
+	if month.Envelopes[0].CarriedOver != 50 || month.Envelopes[1].CarriedOver != 0 {
+		t.Errorf("carried over %v and %v, want 50 and 0", month.Envelopes[0].CarriedOver, month.Envelopes[1].CarriedOver)
+	}
+	if month.ToBeAssigned != 660 {
+		t.Errorf("ToBeAssigned = %v, want 660", month.ToBeAssigned)
+	}
+
+	for _, bad := range []string{"9999-12", "0001-01", "soon"} {
+		if _, err := ComputeEnvelopeMonth(envelopes, nil, nil, nil, bad); err == nil {
+			t.Errorf("month %q: expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error codes returned in the code field of error responses. They are stable
+// and safe for clients to branch on, unlike the human-readable message.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeValidation           = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeGone                 = "gone"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeUnprocessable        = "unprocessable"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal_error"
+	CodeUpstream             = "upstream_error"
+)
+
+// ErrorCodes lists every error code, for API documentation
+var ErrorCodes = []string{
+	CodeBadRequest, CodeValidation, CodeUnauthorized, CodeForbidden, CodeNotFound,
+	CodeMethodNotAllowed, CodeConflict, CodeGone, CodePreconditionFailed, CodePreconditionRequired,
+	CodeUnsupportedMediaType, CodeUnprocessable, CodeRateLimited, CodeInternal, CodeUpstream,
+}
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// APIError is an error with an HTTP status and a stable code. Handlers return
+// one of the constructors below through WriteError; any other error is
+// reported as an internal error without exposing its message.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Details interface{} // extra context serialized with the error, such as ValidationErrors
+	Err     error       // underlying cause, logged but never sent to clients
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// NewError creates an APIError
+func NewError(status int, code, message string, details interface{}) *APIError {
+	return &APIError{Status: status, Code: code, Message: message, Details: details}
+}
+
+// BadRequestError reports a malformed request
+func BadRequestError(message string, details interface{}) *APIError {
+	return NewError(http.StatusBadRequest, CodeBadRequest, message, details)
+}
+
+// ValidationError reports request fields that failed validation
+func ValidationError(errs ValidationErrors) *APIError {
+	return NewError(http.StatusBadRequest, CodeValidation, "Validation failed", errs)
+}
+
+// UnauthorizedError reports a missing or invalid bearer token
+func UnauthorizedError() *APIError {
+	return NewError(http.StatusUnauthorized, CodeUnauthorized, "Unauthorized", nil)
+}
+
+// ForbiddenError reports an authenticated user lacking permission
+func ForbiddenError(message string, details interface{}) *APIError {
+	return NewError(http.StatusForbidden, CodeForbidden, message, details)
+}
+
+// NotFoundError reports a missing resource
+func NotFoundError(message string) *APIError {
+	return NewError(http.StatusNotFound, CodeNotFound, message, nil)
+}
+
+// MethodNotAllowedError reports an unsupported method, listing the allowed ones
+func MethodNotAllowedError(allowed []string) *APIError {
+	var details interface{}
+	if len(allowed) > 0 {
+		details = map[string]interface{}{"allowed": allowed}
+	}
+	return NewError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed", details)
+}
+
+// ConflictError reports a request conflicting with the resource's current state
+func ConflictError(message string, details interface{}) *APIError {
+	return NewError(http.StatusConflict, CodeConflict, message, details)
+}
+
+// UnprocessableError reports a well-formed request that cannot be carried out
+func UnprocessableError(message string, details interface{}) *APIError {
+	return NewError(http.StatusUnprocessableEntity, CodeUnprocessable, message, details)
+}
+
+// RateLimitedError reports an exhausted rate limit
+func RateLimitedError(limit int, retryAfter int) *APIError {
+	return NewError(http.StatusTooManyRequests, CodeRateLimited, "Too many requests", map[string]int{
+		"limit":       limit,
+		"retry_after": retryAfter,
+	})
+}
+
+// InternalError reports a failure on our side. The cause is logged, not sent.
+func InternalError(message string, err error) *APIError {
+	e := NewError(http.StatusInternalServerError, CodeInternal, message, nil)
+	e.Err = err
+	return e
+}
+
+// UpstreamError reports a failure of a service we depend on, such as the
+// database or an AI provider. The cause is logged, not sent.
+func UpstreamError(message string, err error) *APIError {
+	e := NewError(http.StatusBadGateway, CodeUpstream, message, nil)
+	e.Err = err
+	return e
+}
+
+// CodeForStatus returns the error code used for a status by ErrorResponse,
+// which clients also fall back to for error responses without a code
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGone:
+		return CodeGone
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusPreconditionRequired:
+		return CodePreconditionRequired
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return CodeUpstream
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// ErrorCode returns the code of an APIError in err's chain, or
+// CodeInternal for any other error
+func ErrorCode(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return CodeInternal
+}
+
+// Problem represents an RFC 7807 problem details response
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail"`
+	Instance  string      `json:"instance,omitempty"`
+	Code      string      `json:"code"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Timestamp string      `json:"timestamp"`
+}
+
+// WriteError sends an error response for err. APIErrors are sent as they
+// are; any other error becomes a generic 500 so internal messages never reach
+// clients. Causes of server errors are logged. The body is a Response
+// envelope, or problem details when the request accepts them (see
+// ProblemDetails).
+func WriteError(w http.ResponseWriter, err error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = InternalError("Internal server error", err)
+	}
+	requestID := w.Header().Get(RequestIDHeader)
+	for _, sw := range findWriters[*statusWriter](w) {
+		sw.code = apiErr.Code
+	}
+	if apiErr.Status >= http.StatusInternalServerError && apiErr.Err != nil {
+		Logger.Error(apiErr.Message, "request_id", requestID, "code", apiErr.Code, "error", apiErr.Err.Error())
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	if pws := findWriters[*problemWriter](w); len(pws) > 0 && pws[0].accepts {
+		w.Header().Set("Content-Type", ProblemContentType)
+		w.WriteHeader(apiErr.Status)
+		json.NewEncoder(w).Encode(Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(apiErr.Status),
+			Status:    apiErr.Status,
+			Detail:    apiErr.Message,
+			Instance:  pws[0].instance,
+			Code:      apiErr.Code,
+			Details:   apiErr.Details,
+			RequestID: requestID,
+			Timestamp: timestamp,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(Response{
+		Success:   false,
+		Error:     apiErr.Message,
+		Code:      apiErr.Code,
+		Details:   apiErr.Details,
+		RequestID: requestID,
+		Timestamp: timestamp,
+	})
+}
+
+// ProblemDetails lets WriteError answer with application/problem+json when
+// the request's Accept header asks for it
+func ProblemDetails() Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next(&problemWriter{
+				ResponseWriter: w,
+				accepts:        acceptsProblem(r),
+				instance:       r.URL.Path,
+			}, r)
+		}
+	}
+}
+
+// problemWriter carries the request's error format preference to WriteError
+type problemWriter struct {
+	http.ResponseWriter
+	accepts  bool
+	instance string
+}
+
+func (pw *problemWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+// findWriters returns the writers of type T among w and the writers it
+// wraps, innermost first
+func findWriters[T http.ResponseWriter](w http.ResponseWriter) []T {
+	var found []T
+	for w != nil {
+		if match, ok := w.(T); ok {
+			found = append(found, match)
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = unwrapper.Unwrap()
+	}
+	return found
+}
+
+// acceptsProblem reports whether the Accept header lists problem+json
+func acceptsProblem(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || mediaType != ProblemContentType {
+			continue
+		}
+		q, err := strconv.ParseFloat(params["q"], 64)
+		return err != nil || q > 0
+	}
+	return false
+}
+
+// Recover turns a panic in the rest of the chain into a 500 response and
+// logs it with a stack trace. If the response had already started it can
+// only be logged.
+func Recover() Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			tw := &trackingWriter{ResponseWriter: w}
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				RequestLogger(r).Error("panic", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+				if !tw.wroteHeader {
+					WriteError(w, InternalError("Internal server error", nil))
+				}
+			}()
+			next(tw, r)
+		}
+	}
+}
+
+// trackingWriter records whether the response has started
+type trackingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (tw *trackingWriter) WriteHeader(status int) {
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *trackingWriter) Write(b []byte) (int, error) {
+	tw.wroteHeader = true
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *trackingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
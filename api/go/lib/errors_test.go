@@ -0,0 +1,202 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteErrorContentNegotiation(t *testing.T) {
+	previousLogger := Logger
+	t.Cleanup(func() { Logger = previousLogger })
+	Logger = NewLogger(io.Discard, "error")
+
+	tests := []struct {
+		name    string
+		accept  string
+		err     error
+		problem bool
+		status  int
+		code    string
+		message string
+	}{
+		{"no accept header", "", NotFoundError("Budget not found"), false, http.StatusNotFound, CodeNotFound, "Budget not found"},
+		{"json", "application/json", NotFoundError("Budget not found"), false, http.StatusNotFound, CodeNotFound, "Budget not found"},
+		{"problem", "application/problem+json", NotFoundError("Budget not found"), true, http.StatusNotFound, CodeNotFound, "Budget not found"},
+		{"problem among others", "application/json, application/problem+json;q=0.5", ConflictError("Already exists", nil), true, http.StatusConflict, CodeConflict, "Already exists"},
+		{"problem refused", "application/json, application/problem+json;q=0", NotFoundError("Budget not found"), false, http.StatusNotFound, CodeNotFound, "Budget not found"},
+		{"other errors are hidden", "application/problem+json", errors.New("connection refused"), true, http.StatusInternalServerError, CodeInternal, "Internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Chain(func(w http.ResponseWriter, r *http.Request) {
+				WriteError(w, tt.err)
+			}, ProblemDetails())
+			req := httptest.NewRequest(http.MethodGet, "/api/go/budgets", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			wantType := "application/json"
+			if tt.problem {
+				wantType = ProblemContentType
+			}
+			if got := rec.Header().Get("Content-Type"); got != wantType {
+				t.Errorf("Content-Type = %q, want %q", got, wantType)
+			}
+			if strings.Contains(rec.Body.String(), "connection refused") {
+				t.Errorf("body leaks the cause: %s", rec.Body)
+			}
+
+			if tt.problem {
+				var problem Problem
+				if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+					t.Fatal(err)
+				}
+				if problem.Status != tt.status || problem.Code != tt.code || problem.Detail != tt.message ||
+					problem.Title != http.StatusText(tt.status) || problem.Instance != "/api/go/budgets" {
+					t.Errorf("problem = %+v", problem)
+				}
+				return
+			}
+			var resp Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Success || resp.Code != tt.code || resp.Error != tt.message {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	previousLogger := Logger
+	t.Cleanup(func() { Logger = previousLogger })
+	Logger = NewLogger(io.Discard, "error")
+
+	tests := []struct {
+		name    string
+		accept  string
+		handler http.HandlerFunc
+		status  int
+		code    string
+	}{
+		{
+			name:    "panic before the response",
+			handler: func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			status:  http.StatusInternalServerError,
+			code:    CodeInternal,
+		},
+		{
+			name:    "panic with problem details",
+			accept:  ProblemContentType,
+			handler: func(w http.ResponseWriter, r *http.Request) { panic(errors.New("boom")) },
+			status:  http.StatusInternalServerError,
+			code:    CodeInternal,
+		},
+		{
+			name: "panic after the response started",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			status: http.StatusAccepted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Chain(tt.handler, ProblemDetails(), Recover())
+			req := httptest.NewRequest(http.MethodGet, "/api/go/budgets", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.code == "" {
+				if rec.Body.Len() != 0 {
+					t.Errorf("body written after the response started: %s", rec.Body)
+				}
+				return
+			}
+			var body struct {
+				Code string `json:"code"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.code {
+				t.Errorf("code = %q, want %q", body.Code, tt.code)
+			}
+			if strings.Contains(rec.Body.String(), "boom") {
+				t.Errorf("body leaks the panic: %s", rec.Body)
+			}
+		})
+	}
+
+	t.Run("aborted handlers are not recovered", func(t *testing.T) {
+		handler := Recover()(func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) })
+		defer func() {
+			if recovered := recover(); recovered != http.ErrAbortHandler {
+				t.Errorf("recovered %v, want http.ErrAbortHandler", recovered)
+			}
+		}()
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestCodeForStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusBadRequest, CodeBadRequest},
+		{http.StatusUnauthorized, CodeUnauthorized},
+		{http.StatusForbidden, CodeForbidden},
+		{http.StatusNotFound, CodeNotFound},
+		{http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{http.StatusConflict, CodeConflict},
+		{http.StatusGone, CodeGone},
+		{http.StatusPreconditionFailed, CodePreconditionFailed},
+		{http.StatusPreconditionRequired, CodePreconditionRequired},
+		{http.StatusUnsupportedMediaType, CodeUnsupportedMediaType},
+		{http.StatusUnprocessableEntity, CodeUnprocessable},
+		{http.StatusTooManyRequests, CodeRateLimited},
+		{http.StatusInternalServerError, CodeInternal},
+		{http.StatusNotImplemented, CodeInternal},
+		{http.StatusBadGateway, CodeUpstream},
+		{http.StatusServiceUnavailable, CodeUpstream},
+		{http.StatusGatewayTimeout, CodeUpstream},
+		{http.StatusTeapot, CodeBadRequest},
+	}
+	for _, tt := range tests {
+		if got := CodeForStatus(tt.status); got != tt.want {
+			t.Errorf("CodeForStatus(%d) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+
+	// Every constructor uses the code its status maps to, so clients falling
+	// back to CodeForStatus agree with the API
+	for _, err := range []*APIError{
+		BadRequestError("bad", nil), UnauthorizedError(), ForbiddenError("no", nil), NotFoundError("missing"),
+		MethodNotAllowedError(nil), ConflictError("conflict", nil), UnprocessableError("no", nil),
+		RateLimitedError(1, 1), InternalError("failed", nil), UpstreamError("failed", nil),
+	} {
+		if got := CodeForStatus(err.Status); got != err.Code {
+			t.Errorf("CodeForStatus(%d) = %q, but %q uses %q", err.Status, got, err.Message, err.Code)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies
+const (
+	FrequencyDaily     = "daily"
+	FrequencyWeekly    = "weekly"
+	FrequencyBiweekly  = "biweekly"
+	FrequencyMonthly   = "monthly"
+	FrequencyQuarterly = "quarterly"
+	FrequencyYearly    = "yearly"
+)
+
+// MaxForecastDays caps how far ahead a cash-flow forecast may project
+const MaxForecastDays = 365
+
+// Subscription represents a recurring charge detected from transaction history
+type Subscription struct {
+	Merchant     string    `json:"merchant"`
+	Category     string    `json:"category"`
+	Amount       float64   `json:"amount"`
+	Frequency    string    `json:"frequency"`
+	IntervalDays float64   `json:"interval_days"`
+	Occurrences  int       `json:"occurrences"`
+	LastDate     time.Time `json:"last_date"`
+	NextDate     time.Time `json:"next_date"`
+}
+
+// ForecastOptions configures a cash-flow forecast
+type ForecastOptions struct {
+	Start           time.Time
+	Days            int
+	StartingBalance float64
+	Threshold       float64
+	ConfidenceLevel float64 // 0.8, 0.9 or 0.95
+}
+
+// ForecastDay represents the projected cash flow for a single day
+type ForecastDay struct {
+	Date         string  `json:"date"`
+	Income       float64 `json:"income"`
+	Expenses     float64 `json:"expenses"`
+	Net          float64 `json:"net"`
+	Balance      float64 `json:"balance"`
+	BalanceLower float64 `json:"balance_lower"`
+	BalanceUpper float64 `json:"balance_upper"`
+}
+
+// CashFlowForecast represents a projected daily balance over a horizon
+type CashFlowForecast struct {
+	StartDate          string         `json:"start_date"`
+	EndDate            string         `json:"end_date"`
+	Days               int            `json:"days"`
+	StartingBalance    float64        `json:"starting_balance"`
+	ProjectedBalance   float64        `json:"projected_balance"`
+	TotalIncome        float64        `json:"total_income"`
+	TotalExpenses      float64        `json:"total_expenses"`
+	ConfidenceLevel    float64        `json:"confidence_level"`
+	Threshold          float64        `json:"threshold"`
+	BelowThresholdDate string         `json:"below_threshold_date,omitempty"`
+	AtRiskDate         string         `json:"at_risk_date,omitempty"` // lower band first crosses the threshold
+	Subscriptions      []Subscription `json:"subscriptions"`
+	Daily              []ForecastDay  `json:"daily"`
+}
+
+// confidenceZ maps supported confidence levels to two-sided z-scores
+var confidenceZ = map[float64]float64{
+	0.8:  1.2816,
+	0.9:  1.6449,
+	0.95: 1.96,
+}
+
+// ForecastCashFlow projects daily income, expenses and balance from scheduled
+// recurring items, subscriptions detected in history, and seasonal
+// per-category averages of the remaining (variable) spending.
+func ForecastCashFlow(history []Transaction, recurring []RecurringTransaction, opts ForecastOptions) (*CashFlowForecast, error) {
+	if opts.Days <= 0 || opts.Days > MaxForecastDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", MaxForecastDays)
+	}
+	if opts.ConfidenceLevel == 0 {
+		opts.ConfidenceLevel = 0.8
+	}
+	z, ok := confidenceZ[opts.ConfidenceLevel]
+	if !ok {
+		return nil, fmt.Errorf("confidence level must be one of 0.8, 0.9 or 0.95")
+	}
+
+	start := truncateDay(opts.Start)
+	end := start.AddDate(0, 0, opts.Days)
+
+	var active []RecurringTransaction
+	for _, rt := range recurring {
+		if rt.Active {
+			active = append(active, rt)
+		}
+	}
+
+	// Subscriptions already covered by a scheduled item would be counted twice
+	var subscriptions []Subscription
+	for _, sub := range DetectSubscriptions(history, start) {
+		covered := false
+		for _, rt := range active {
+			if rt.Type == "expense" && rt.Category == sub.Category && withinTolerance(rt.Amount, sub.Amount, 0.1) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			subscriptions = append(subscriptions, sub)
+		}
+	}
+
+	// Anything explained by a scheduled item or subscription is not variable spend
+	var variable []Transaction
+	for _, t := range history {
+		if isScheduled(t, active, subscriptions) {
+			continue
+		}
+		variable = append(variable, t)
+	}
+	seasonal := buildSeasonalModel(variable, start)
+
+	income := make([]float64, opts.Days)
+	expenses := make([]float64, opts.Days)
+	variance := make([]float64, opts.Days)
+
+	for _, rt := range active {
+		for _, d := range occurrences(rt.StartDate, rt.Frequency, start, end, rt.EndDate) {
+			addToDay(income, expenses, start, d, rt.Type, rt.Amount)
+		}
+	}
+	for _, sub := range subscriptions {
+		for _, d := range occurrences(sub.NextDate, sub.Frequency, start, end, time.Time{}) {
+			addToDay(income, expenses, start, d, "expense", sub.Amount)
+		}
+	}
+	for i := 0; i < opts.Days; i++ {
+		month := start.AddDate(0, 0, i).Month()
+		for _, c := range seasonal {
+			avg := c.overall
+			if m, ok := c.monthly[month]; ok {
+				avg = m
+			}
+			if c.txType == "income" {
+				income[i] += avg
+			} else {
+				expenses[i] += avg
+			}
+			variance[i] += c.stdDev * c.stdDev
+		}
+	}
+
+	forecast := &CashFlowForecast{
+		StartDate:       start.Format("2006-01-02"),
+		EndDate:         end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Days:            opts.Days,
+		StartingBalance: opts.StartingBalance,
+		ConfidenceLevel: opts.ConfidenceLevel,
+		Threshold:       opts.Threshold,
+		Subscriptions:   subscriptions,
+		Daily:           make([]ForecastDay, 0, opts.Days),
+	}
+	if forecast.Subscriptions == nil {
+		forecast.Subscriptions = []Subscription{}
+	}
+
+	balance := opts.StartingBalance
+	var cumulativeVariance float64
+	for i := 0; i < opts.Days; i++ {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		net := income[i] - expenses[i]
+		balance += net
+		cumulativeVariance += variance[i]
+		band := z * math.Sqrt(cumulativeVariance)
+
+		day := ForecastDay{
+			Date:         date,
+			Income:       roundCents(income[i]),
+			Expenses:     roundCents(expenses[i]),
+			Net:          roundCents(net),
+			Balance:      roundCents(balance),
+			BalanceLower: roundCents(balance - band),
+			BalanceUpper: roundCents(balance + band),
+		}
+		forecast.Daily = append(forecast.Daily, day)
+		forecast.TotalIncome += income[i]
+		forecast.TotalExpenses += expenses[i]
+
+		if forecast.BelowThresholdDate == "" && day.Balance < opts.Threshold {
+			forecast.BelowThresholdDate = date
+		}
+		if forecast.AtRiskDate == "" && day.BalanceLower < opts.Threshold {
+			forecast.AtRiskDate = date
+		}
+	}
+
+	forecast.TotalIncome = roundCents(forecast.TotalIncome)
+	forecast.TotalExpenses = roundCents(forecast.TotalExpenses)
+	forecast.ProjectedBalance = roundCents(balance)
+
+	return forecast, nil
+}
+
+// DetectSubscriptions finds expenses that repeat at a regular interval with a
+// stable amount for the same merchant. Subscriptions whose last charge is more
+// than two intervals before asOf are treated as cancelled and omitted.
+func DetectSubscriptions(history []Transaction, asOf time.Time) []Subscription {
+	groups := make(map[string][]Transaction)
+	for _, t := range history {
+		if t.Type != "expense" {
+			continue
+		}
+		key := merchantKey(t)
+		if key == "" {
+			continue
+		}
+		groups[key] = append(groups[key], t)
+	}
+
+	var subscriptions []Subscription
+	for _, group := range groups {
+		if len(group) < 3 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool { return group[i].Date.Before(group[j].Date) })
+
+		amounts := make([]float64, len(group))
+		intervals := make([]float64, 0, len(group)-1)
+		for i, t := range group {
+			amounts[i] = t.Amount
+			if i > 0 {
+				intervals = append(intervals, group[i].Date.Sub(group[i-1].Date).Hours()/24)
+			}
+		}
+
+		amount := median(amounts)
+		stable := true
+		for _, a := range amounts {
+			if !withinTolerance(a, amount, 0.15) {
+				stable = false
+				break
+			}
+		}
+		if !stable {
+			continue
+		}
+
+		interval := median(intervals)
+		frequency := classifyInterval(interval)
+		if frequency == "" {
+			continue
+		}
+		regular := 0
+		for _, iv := range intervals {
+			if withinTolerance(iv, interval, 0.25) {
+				regular++
+			}
+		}
+		if float64(regular) < 0.75*float64(len(intervals)) {
+			continue
+		}
+
+		last := group[len(group)-1]
+		if asOf.Sub(last.Date).Hours()/24 > 2*interval {
+			continue
+		}
+
+		merchant := last.Merchant
+		if merchant == "" {
+			merchant = last.Description
+		}
+		subscriptions = append(subscriptions, Subscription{
+			Merchant:     merchant,
+			Category:     last.Category,
+			Amount:       roundCents(amount),
+			Frequency:    frequency,
+			IntervalDays: math.Round(interval*10) / 10,
+			Occurrences:  len(group),
+			LastDate:     last.Date,
+			NextDate:     advance(last.Date, frequency, 1),
+		})
+	}
+
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].NextDate.Before(subscriptions[j].NextDate) })
+	return subscriptions
+}
+
+// seasonalCategory holds daily spending averages for one category and type
+type seasonalCategory struct {
+	category string
+	txType   string
+	overall  float64
+	monthly  map[time.Month]float64
+	stdDev   float64
+}
+
+// buildSeasonalModel computes per-category daily averages over the history
+// window ending before asOf, with per-calendar-month averages where at least
+// two weeks of that month have been observed.
+func buildSeasonalModel(history []Transaction, asOf time.Time) []seasonalCategory {
+	if len(history) == 0 {
+		return nil
+	}
+
+	first := asOf
+	for _, t := range history {
+		if t.Date.Before(first) {
+			first = t.Date
+		}
+	}
+	first = truncateDay(first)
+	days := int(asOf.Sub(first).Hours() / 24)
+	if days <= 0 {
+		return nil
+	}
+
+	daysInMonth := make(map[time.Month]int)
+	for i := 0; i < days; i++ {
+		daysInMonth[first.AddDate(0, 0, i).Month()]++
+	}
+
+	type key struct{ category, txType string }
+	daily := make(map[key][]float64)
+	for _, t := range history {
+		idx := int(truncateDay(t.Date).Sub(first).Hours() / 24)
+		if idx < 0 || idx >= days {
+			continue
+		}
+		k := key{t.Category, t.Type}
+		if daily[k] == nil {
+			daily[k] = make([]float64, days)
+		}
+		daily[k][idx] += t.Amount
+	}
+
+	model := make([]seasonalCategory, 0, len(daily))
+	for k, totals := range daily {
+		mean, stdDev := meanStdDev(totals)
+		c := seasonalCategory{
+			category: k.category,
+			txType:   k.txType,
+			overall:  mean,
+			monthly:  make(map[time.Month]float64),
+			stdDev:   stdDev,
+		}
+		monthTotals := make(map[time.Month]float64)
+		for i, v := range totals {
+			monthTotals[first.AddDate(0, 0, i).Month()] += v
+		}
+		for m, observed := range daysInMonth {
+			if observed >= 14 {
+				c.monthly[m] = monthTotals[m] / float64(observed)
+			}
+		}
+		model = append(model, c)
+	}
+
+	sort.Slice(model, func(i, j int) bool {
+		if model[i].category != model[j].category {
+			return model[i].category < model[j].category
+		}
+		return model[i].txType < model[j].txType
+	})
+	return model
+}
+
+// isScheduled reports whether a historical transaction is explained by a
+// recurring item or a detected subscription
+func isScheduled(t Transaction, recurring []RecurringTransaction, subscriptions []Subscription) bool {
+	for _, rt := range recurring {
+		if rt.Type == t.Type && rt.Category == t.Category && withinTolerance(t.Amount, rt.Amount, 0.1) {
+			return true
+		}
+	}
+	if t.Type == "expense" {
+		key := merchantKey(t)
+		for _, sub := range subscriptions {
+			if key != "" && key == strings.ToLower(strings.TrimSpace(sub.Merchant)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// occurrences lists the dates in [from, to) on which a schedule anchored at
+// anchor falls, stopping at until when it is set
+func occurrences(anchor time.Time, frequency string, from, to, until time.Time) []time.Time {
+	anchor = truncateDay(anchor)
+	var dates []time.Time
+	for n := 0; ; n++ {
+		d := advance(anchor, frequency, n)
+		if d.IsZero() || !d.Before(to) || (!until.IsZero() && d.After(until)) {
+			break
+		}
+		if !d.Before(from) {
+			dates = append(dates, d)
+		}
+		if n > 100000 {
+			break
+		}
+	}
+	return dates
+}
+
+// advance returns anchor moved forward by n periods of frequency. Steps are
+// always computed from the anchor, and month-based steps land on the last day
+// of shorter months, so a schedule anchored on the 31st falls on Feb 29 and
+// then Mar 31 rather than skipping February.
+func advance(anchor time.Time, frequency string, n int) time.Time {
+	switch frequency {
+	case FrequencyDaily:
+		return anchor.AddDate(0, 0, n)
+	case FrequencyWeekly:
+		return anchor.AddDate(0, 0, 7*n)
+	case FrequencyBiweekly:
+		return anchor.AddDate(0, 0, 14*n)
+	case FrequencyMonthly:
+		return addMonths(anchor, n)
+	case FrequencyQuarterly:
+		return addMonths(anchor, 3*n)
+	case FrequencyYearly:
+		return addMonths(anchor, 12*n)
+	default:
+		return time.Time{}
+	}
+}
+
+// addMonths moves t forward by n calendar months, clamping the day to the
+// last day of the target month where AddDate would overflow into the next
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), last)-1)
+}
+
+// classifyInterval maps an interval in days to a recurrence frequency
+func classifyInterval(days float64) string {
+	switch {
+	case days >= 6 && days <= 8:
+		return FrequencyWeekly
+	case days >= 13 && days <= 16:
+		return FrequencyBiweekly
+	case days >= 27 && days <= 33:
+		return FrequencyMonthly
+	case days >= 85 && days <= 95:
+		return FrequencyQuarterly
+	case days >= 355 && days <= 375:
+		return FrequencyYearly
+	default:
+		return ""
+	}
+}
+
+// addToDay adds an amount to the income or expense bucket for date
+func addToDay(income, expenses []float64, start, date time.Time, txType string, amount float64) {
+	idx := int(date.Sub(start).Hours() / 24)
+	if idx < 0 || idx >= len(income) {
+		return
+	}
+	if txType == "income" {
+		income[idx] += amount
+	} else {
+		expenses[idx] += amount
+	}
+}
+
+// merchantKey returns a normalised merchant name, falling back to description
+func merchantKey(t Transaction) string {
+	key := t.Merchant
+	if key == "" {
+		key = t.Description
+	}
+	return strings.ToLower(strings.TrimSpace(key))
+}
+
+// withinTolerance reports whether a is within a relative tolerance of b
+func withinTolerance(a, b, tolerance float64) bool {
+	if b == 0 {
+		return a == 0
+	}
+	return math.Abs(a-b) <= math.Abs(b)*tolerance
+}
+
+// truncateDay returns t at midnight UTC
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,201 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestAdvance(t *testing.T) {
+	tests := []struct {
+		name      string
+		anchor    time.Time
+		frequency string
+		n         int
+		want      time.Time
+	}{
+		{"daily", date(2024, 1, 30), FrequencyDaily, 3, date(2024, 2, 2)},
+		{"weekly", date(2024, 1, 1), FrequencyWeekly, 2, date(2024, 1, 15)},
+		{"biweekly", date(2024, 1, 1), FrequencyBiweekly, 2, date(2024, 1, 29)},
+		{"monthly", date(2024, 1, 15), FrequencyMonthly, 1, date(2024, 2, 15)},
+		{"monthly from the 31st into february", date(2024, 1, 31), FrequencyMonthly, 1, date(2024, 2, 29)},
+		{"monthly from the 31st into march", date(2024, 1, 31), FrequencyMonthly, 2, date(2024, 3, 31)},
+		{"monthly from the 31st into april", date(2024, 1, 31), FrequencyMonthly, 3, date(2024, 4, 30)},
+		{"monthly from the 30th in a common year", date(2023, 1, 30), FrequencyMonthly, 1, date(2023, 2, 28)},
+		{"monthly across a year", date(2024, 11, 30), FrequencyMonthly, 3, date(2025, 2, 28)},
+		{"quarterly from the 31st", date(2024, 1, 31), FrequencyQuarterly, 1, date(2024, 4, 30)},
+		{"quarterly from the 29th", date(2023, 11, 29), FrequencyQuarterly, 1, date(2024, 2, 29)},
+		{"yearly from a leap day", date(2024, 2, 29), FrequencyYearly, 1, date(2025, 2, 28)},
+		{"yearly back onto a leap day", date(2024, 2, 29), FrequencyYearly, 4, date(2028, 2, 29)},
+		{"unknown frequency", date(2024, 1, 1), "hourly", 1, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := advance(tt.anchor, tt.frequency, tt.n); !got.Equal(tt.want) {
+				t.Errorf("advance(%s, %s, %d) = %s, want %s", tt.anchor.Format("2006-01-02"), tt.frequency, tt.n,
+					got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestAdvanceMonthEndDoesNotSkipMonths(t *testing.T) {
+	anchor := date(2024, 1, 31)
+	seen := map[time.Month]int{}
+	for n := 0; n < 12; n++ {
+		seen[advance(anchor, FrequencyMonthly, n).Month()]++
+	}
+	for month := time.January; month <= time.December; month++ {
+		if seen[month] != 1 {
+			t.Errorf("%s occurs %d times, want 1", month, seen[month])
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name      string
+		anchor    time.Time
+		frequency string
+		from, to  time.Time
+		until     time.Time
+		want      []time.Time
+	}{
+		{
+			name:      "monthly within window",
+			anchor:    date(2024, 1, 31),
+			frequency: FrequencyMonthly,
+			from:      date(2024, 2, 1),
+			to:        date(2024, 5, 1),
+			want:      []time.Time{date(2024, 2, 29), date(2024, 3, 31), date(2024, 4, 30)},
+		},
+		{
+			name:      "stops at until",
+			anchor:    date(2024, 1, 1),
+			frequency: FrequencyWeekly,
+			from:      date(2024, 1, 1),
+			to:        date(2024, 2, 1),
+			until:     date(2024, 1, 10),
+			want:      []time.Time{date(2024, 1, 1), date(2024, 1, 8)},
+		},
+		{
+			name:      "anchor after window",
+			anchor:    date(2024, 6, 1),
+			frequency: FrequencyDaily,
+			from:      date(2024, 1, 1),
+			to:        date(2024, 2, 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrences(tt.anchor, tt.frequency, tt.from, tt.to, tt.until)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d dates %v, want %v", len(got), got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("date %d = %s, want %s", i, got[i].Format("2006-01-02"), tt.want[i].Format("2006-01-02"))
+				}
+			}
+		})
+	}
+}
+
+func TestClassifyInterval(t *testing.T) {
+	tests := []struct {
+		days float64
+		want string
+	}{
+		{7, FrequencyWeekly},
+		{14, FrequencyBiweekly},
+		{30.4, FrequencyMonthly},
+		{91, FrequencyQuarterly},
+		{365, FrequencyYearly},
+		{3, ""},
+		{60, ""},
+	}
+	for _, tt := range tests {
+		if got := classifyInterval(tt.days); got != tt.want {
+			t.Errorf("classifyInterval(%v) = %q, want %q", tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestDetectSubscriptions(t *testing.T) {
+	asOf := date(2024, 5, 10)
+	var history []Transaction
+	for m := time.January; m <= time.May; m++ {
+		history = append(history, Transaction{Type: "expense", Merchant: "Streamly", Category: "Entertainment", Amount: 15.99, Date: date(2024, m, 3)})
+	}
+	// Irregular amounts are not a subscription
+	for i, amount := range []float64{12, 48, 7} {
+		history = append(history, Transaction{Type: "expense", Merchant: "Corner Shop", Category: "Groceries", Amount: amount, Date: date(2024, time.Month(i+1), 5)})
+	}
+	// Stopped more than two intervals ago
+	for m := time.January; m <= time.February; m++ {
+		history = append(history, Transaction{Type: "expense", Merchant: "OldGym", Category: "Fitness", Amount: 30, Date: date(2023, m, 1)})
+	}
+
+	subscriptions := DetectSubscriptions(history, asOf)
+	if len(subscriptions) != 1 {
+		t.Fatalf("got %d subscriptions %+v, want 1", len(subscriptions), subscriptions)
+	}
+	sub := subscriptions[0]
+	if sub.Merchant != "Streamly" || sub.Frequency != FrequencyMonthly || sub.Amount != 15.99 || sub.Occurrences != 5 {
+		t.Errorf("unexpected subscription %+v", sub)
+	}
+	if want := date(2024, 6, 3); !sub.NextDate.Equal(want) {
+		t.Errorf("NextDate = %s, want %s", sub.NextDate, want)
+	}
+}
+
+func TestForecastCashFlow(t *testing.T) {
+	start := date(2024, 1, 1)
+	recurring := []RecurringTransaction{
+		{Type: "income", Category: "Salary", Amount: 3000, Frequency: FrequencyMonthly, StartDate: date(2023, 12, 31), Active: true},
+		{Type: "expense", Category: "Rent", Amount: 1200, Frequency: FrequencyMonthly, StartDate: date(2024, 1, 2), Active: true},
+		{Type: "expense", Category: "Gym", Amount: 99, Frequency: FrequencyMonthly, StartDate: date(2024, 1, 5), Active: false},
+	}
+
+	forecast, err := ForecastCashFlow(nil, recurring, ForecastOptions{Start: start, Days: 60, StartingBalance: 500, Threshold: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forecast.StartDate != "2024-01-01" || forecast.EndDate != "2024-02-29" || len(forecast.Daily) != 60 {
+		t.Fatalf("unexpected window %s to %s with %d days", forecast.StartDate, forecast.EndDate, len(forecast.Daily))
+	}
+	// Salary lands on Jan 31 and Feb 29 (clamped), rent on Jan 2 and Feb 2
+	if forecast.TotalIncome != 6000 || forecast.TotalExpenses != 2400 {
+		t.Errorf("income %v, expenses %v; want 6000 and 2400", forecast.TotalIncome, forecast.TotalExpenses)
+	}
+	if forecast.ProjectedBalance != 4100 {
+		t.Errorf("ProjectedBalance = %v, want 4100", forecast.ProjectedBalance)
+	}
+	if forecast.BelowThresholdDate != "2024-01-02" {
+		t.Errorf("BelowThresholdDate = %q, want 2024-01-02", forecast.BelowThresholdDate)
+	}
+	if day := forecast.Daily[59]; day.Date != "2024-02-29" || day.Income != 3000 {
+		t.Errorf("last day = %+v, want salary on 2024-02-29", day)
+	}
+}
+
+func TestForecastCashFlowRejectsInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ForecastOptions
+	}{
+		{"no days", ForecastOptions{Days: 0}},
+		{"too many days", ForecastOptions{Days: MaxForecastDays + 1}},
+		{"unsupported confidence", ForecastOptions{Days: 30, ConfidenceLevel: 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ForecastCashFlow(nil, nil, tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Build information, injected at build time with
+//
+//	go build -ldflags "-X github.com/budget-buddy/api/lib.BuildVersion=1.2.0 \
+//	  -X github.com/budget-buddy/api/lib.BuildCommit=$(git rev-parse HEAD) \
+//	  -X github.com/budget-buddy/api/lib.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Builds that cannot pass linker flags, such as Vercel's, fall back to
+// VERCEL_GIT_COMMIT_SHA and the VCS details Go stamps into the binary.
+var (
+	BuildVersion = ""
+	BuildCommit  = ""
+	BuildTime    = ""
+)
+
+// CurrentBuildInfo describes the running binary
+func CurrentBuildInfo() BuildInfo {
+	info := BuildInfo{Version: BuildVersion, GitSHA: BuildCommit, BuildTime: BuildTime}
+	if info.Version == "" {
+		info.Version = APIVersion
+	}
+	if info.GitSHA == "" {
+		info.GitSHA = GetEnv("VERCEL_GIT_COMMIT_SHA", "")
+	}
+	if stamped, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range stamped.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.GitSHA == "":
+				info.GitSHA = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			case setting.Key == "vcs.modified" && setting.Value == "true":
+				info.Modified = true
+			}
+		}
+	}
+	return info
+}
+
+// Overall health statuses
+const (
+	HealthHealthy   = "healthy"   // every dependency is reachable
+	HealthDegraded  = "degraded"  // an optional dependency is failing
+	HealthUnhealthy = "unhealthy" // a critical dependency is failing; not ready for traffic
+)
+
+// Dependency check statuses
+const (
+	CheckOK      = "ok"
+	CheckFailed  = "failed"
+	CheckSkipped = "skipped" // the dependency is not configured
+)
+
+// DefaultHealthCheckTimeout bounds each dependency check, unless
+// HEALTH_CHECK_TIMEOUT_MS is set
+const DefaultHealthCheckTimeout = 2 * time.Second
+
+// ErrNotConfigured is returned by a check whose dependency is not configured.
+// The check is reported as skipped and does not affect readiness.
+var ErrNotConfigured = errors.New("not configured")
+
+// HealthCheck probes one dependency of the API
+type HealthCheck struct {
+	Name     string
+	Critical bool          // a failure makes the service unhealthy rather than degraded
+	Timeout  time.Duration // defaults to HEALTH_CHECK_TIMEOUT_MS
+	Check    func(ctx context.Context) error
+}
+
+// HealthChecks are the dependencies probed by the readiness endpoint. Append
+// to it when a function starts relying on another service.
+var HealthChecks = []HealthCheck{
+	{Name: "datastore", Critical: true, Check: checkDatastore},
+	{Name: "jwks", Critical: true, Check: checkJWKS},
+	// Workspaces, splits, the trash and the audit log fail every request using
+	// them without it; lib.Audit alone makes every mutation fail
+	{Name: "redis", Critical: true, Check: checkRedis},
+	// CheckRateLimit fails open, so an unreachable backend only disables limiting
+	{Name: "rate_limit", Check: checkRateLimits},
+	{Name: "exchange_rates", Check: checkExchangeRates},
+}
+
+// RunHealthChecks runs checks concurrently, each under its own timeout, and
+// returns the overall status with the result of every check in order
+func RunHealthChecks(ctx context.Context, checks []HealthCheck) (string, []DependencyStatus) {
+	timeout := DefaultHealthCheckTimeout
+	if ms, err := strconv.Atoi(GetEnv("HEALTH_CHECK_TIMEOUT_MS", "")); err == nil && ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+
+	results := make([]DependencyStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			if check.Timeout > 0 {
+				results[i] = runHealthCheck(ctx, check, check.Timeout)
+			} else {
+				results[i] = runHealthCheck(ctx, check, timeout)
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	status := HealthHealthy
+	for _, result := range results {
+		if result.Status != CheckFailed {
+			continue
+		}
+		if result.Critical {
+			status = HealthUnhealthy
+		} else if status == HealthHealthy {
+			status = HealthDegraded
+		}
+	}
+	return status, results
+}
+
+func runHealthCheck(ctx context.Context, check HealthCheck, timeout time.Duration) DependencyStatus {
+	ctx, span := StartSpan(ctx, "health."+check.Name, SpanKindClient)
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.Check(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Some clients ignore the context; stop waiting for them
+		err = ctx.Err()
+	}
+
+	result := DependencyStatus{
+		Name:      check.Name,
+		Status:    CheckOK,
+		Critical:  check.Critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	switch {
+	case errors.Is(err, ErrNotConfigured):
+		result.Status = CheckSkipped
+		result.LatencyMS = 0
+	case err != nil:
+		result.Status = CheckFailed
+		result.Error = describeCheckError(err)
+		span.RecordError(err)
+		Logger.Warn("health check failed", "check", check.Name, "error", err.Error())
+	}
+	span.SetAttribute("health.status", result.Status)
+	return result
+}
+
+// describeCheckError summarizes a check failure without the addresses and
+// hosts in network errors, since the health endpoint is public
+func describeCheckError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timed out"
+	case errors.As(err, &netErr):
+		return "unreachable"
+	}
+	return err.Error()
+}
+
+var healthClient = &http.Client{}
+
+// probeHTTP sends a GET to url and returns the response body, failing on
+// server errors and, unless acceptClientErrors is set, on client errors
+func probeHTTP(ctx context.Context, url string, header http.Header, acceptClientErrors bool) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		req.Header.Set(TraceparentHeader, span.Context().Traceparent())
+	}
+	resp, err := healthClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 500 || (resp.StatusCode >= 400 && !acceptClientErrors) {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// supabaseConfig returns the Supabase project URL and anon key, from
+// SUPABASE_URL and SUPABASE_ANON_KEY or the Next.js app's public variables
+func supabaseConfig() (string, string) {
+	url := GetEnv("SUPABASE_URL", GetEnv("NEXT_PUBLIC_SUPABASE_URL", ""))
+	key := GetEnv("SUPABASE_ANON_KEY", GetEnv("NEXT_PUBLIC_SUPABASE_ANON_KEY", ""))
+	return strings.TrimSuffix(url, "/"), key
+}
+
+// checkDatastore checks that Supabase's REST API answers. Client errors
+// count as reachable, since the anon key may not read the schema.
+func checkDatastore(ctx context.Context) error {
+	url, key := supabaseConfig()
+	if url == "" {
+		return ErrNotConfigured
+	}
+	header := http.Header{}
+	if key != "" {
+		header.Set("apikey", key)
+		header.Set("Authorization", "Bearer "+key)
+	}
+	_, err := probeHTTP(ctx, url+"/rest/v1/", header, true)
+	return err
+}
+
+// checkJWKS checks that the key set used to verify access tokens, JWKS_URL or
+// Supabase Auth's, can be fetched and parsed
+func checkJWKS(ctx context.Context) error {
+	jwksURL := GetEnv("JWKS_URL", "")
+	if jwksURL == "" {
+		url, _ := supabaseConfig()
+		if url == "" {
+			return ErrNotConfigured
+		}
+		jwksURL = url + "/auth/v1/.well-known/jwks.json"
+	}
+	body, err := probeHTTP(ctx, jwksURL, nil, false)
+	if err != nil {
+		return err
+	}
+	var keySet struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &keySet); err != nil || keySet.Keys == nil {
+		return errors.New("invalid key set")
+	}
+	return nil
+}
+
+// Pinger is implemented by stores backed by a server, such as
+// RedisRateLimitStore, to check the server is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// checkRateLimits pings the rate limit backend. In-memory stores always pass.
+func checkRateLimits(ctx context.Context) error {
+	if pinger, ok := RateLimits.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// checkRedis pings the Redis-protocol server at REDIS_URL through the
+// workspace store, which shares it with the other stores. An invalid
+// REDIS_URL fails it, as the stores then keep their state per instance.
+func checkRedis(ctx context.Context) error {
+	if GetEnv("REDIS_URL", "") == "" {
+		return ErrNotConfigured
+	}
+	if err := RedisConfigError(); err != nil {
+		return err
+	}
+	if pinger, ok := Workspaces.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// checkExchangeRates checks that the exchange rate provider at
+// EXCHANGE_RATES_URL answers
+func checkExchangeRates(ctx context.Context) error {
+	url := GetEnv("EXCHANGE_RATES_URL", "")
+	if url == "" {
+		return ErrNotConfigured
+	}
+	_, err := probeHTTP(ctx, url, nil, false)
+	return err
+}
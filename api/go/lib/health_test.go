@@ -0,0 +1,97 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRunHealthChecks(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("down") }
+	unconfigured := func(ctx context.Context) error { return ErrNotConfigured }
+	tests := []struct {
+		name   string
+		checks []HealthCheck
+		want   string
+	}{
+		{"all passing", []HealthCheck{{Name: "a", Critical: true, Check: ok}, {Name: "b", Check: ok}}, HealthHealthy},
+		{"skipped", []HealthCheck{{Name: "a", Critical: true, Check: unconfigured}}, HealthHealthy},
+		{"non-critical failure", []HealthCheck{{Name: "a", Critical: true, Check: ok}, {Name: "b", Check: failing}}, HealthDegraded},
+		{"critical failure", []HealthCheck{{Name: "a", Critical: true, Check: failing}, {Name: "b", Check: failing}}, HealthUnhealthy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, results := RunHealthChecks(context.Background(), tt.checks)
+			if status != tt.want {
+				t.Errorf("status %s with %+v, want %s", status, results, tt.want)
+			}
+		})
+	}
+}
+
+// failingPinger is a rate limit store whose backend is unreachable
+type failingPinger struct{ RateLimitStore }
+
+func (failingPinger) Ping(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestRateLimitBackendFailureDegrades(t *testing.T) {
+	previous := RateLimits
+	t.Cleanup(func() { RateLimits = previous })
+	RateLimits = failingPinger{NewMemoryRateLimitStore()}
+
+	var checks []HealthCheck
+	for _, check := range HealthChecks {
+		if check.Name == "rate_limit" {
+			checks = append(checks, check)
+		}
+	}
+	if len(checks) != 1 {
+		t.Fatalf("%d rate_limit checks, want 1", len(checks))
+	}
+	status, results := RunHealthChecks(context.Background(), checks)
+	if status != HealthDegraded || results[0].Status != CheckFailed {
+		t.Errorf("status %s with %+v, want degraded", status, results)
+	}
+}
+
+func TestRedisCheckIsCritical(t *testing.T) {
+	previous, previousLogger := Workspaces, Logger
+	t.Cleanup(func() { Workspaces, Logger = previous, previousLogger })
+	t.Setenv("REDIS_URL", "redis://127.0.0.1:1")
+	Logger = NewLogger(io.Discard, "error")
+
+	var checks []HealthCheck
+	for _, check := range HealthChecks {
+		if check.Name == "redis" {
+			checks = append(checks, check)
+		}
+	}
+	if len(checks) != 1 {
+		t.Fatalf("%d redis checks, want 1", len(checks))
+	}
+
+	Workspaces = InstrumentWorkspaceStore(&RedisWorkspaceStore{client: newTestRESPClient(t)})
+	if status, results := RunHealthChecks(context.Background(), checks); status != HealthHealthy {
+		t.Errorf("status %s with %+v, want healthy", status, results)
+	}
+
+	unreachable, err := NewRedisWorkspaceStore("redis://127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Workspaces = InstrumentWorkspaceStore(unreachable)
+	if status, results := RunHealthChecks(context.Background(), checks); status != HealthUnhealthy || results[0].Status != CheckFailed {
+		t.Errorf("status %s with %+v, want unhealthy", status, results)
+	}
+
+	// An invalid REDIS_URL left the stores in memory
+	previousErr := redisConfigErr
+	t.Cleanup(func() { redisConfigErr = previousErr })
+	redisConfigErr = errors.New("invalid REDIS_URL")
+	Workspaces = NewMemoryWorkspaceStore()
+	if status, results := RunHealthChecks(context.Background(), checks); status != HealthUnhealthy {
+		t.Errorf("status %s with %+v for an invalid REDIS_URL, want unhealthy", status, results)
+	}
+}
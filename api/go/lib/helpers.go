@@ -3,6 +3,7 @@ package lib
 import (
 	"context"
 	"encoding/json"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -14,7 +15,9 @@ type Response struct {
 	Success   bool        `json:"success"`
 	Data      interface{} `json:"data,omitempty"`
 	Error     string      `json:"error,omitempty"`
+	Code      string      `json:"code,omitempty"` // stable error code, see CodeNotFound etc.
 	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
 	Timestamp string      `json:"timestamp"`
 }
 
@@ -28,7 +31,12 @@ type User struct {
 type Config struct {
 	RequireAuth bool
 	AllowedMethods []string
-	EnableCORS bool
+	EnableCORS bool // shorthand for CORS: DefaultCORS(), ignored when CORS is set
+	CORS *CORSPolicy // nil disables CORS unless EnableCORS is set
+	WorkspaceScoped bool // check workspace_id membership and role, requires auth
+	Idempotent bool // replay POST responses for a repeated Idempotency-Key, requires auth
+	RateLimit *RateLimitPolicy // nil disables rate limiting
+	Middleware []Middleware // extra middleware wrapping the built-in stages, outermost first
 }
 
 // SuccessResponse sends a success response
@@ -38,38 +46,14 @@ func SuccessResponse(w http.ResponseWriter, data interface{}, status int) {
 	json.NewEncoder(w).Encode(Response{
 		Success:   true,
 		Data:      data,
+		RequestID: w.Header().Get(RequestIDHeader),
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 	})
 }
 
 // ErrorResponse sends an error response
 func ErrorResponse(w http.ResponseWriter, message string, status int, details interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(Response{
-		Success:   false,
-		Error:     message,
-		Details:   details,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-	})
-}
-
-// ApplyCORS applies CORS headers to response
-func ApplyCORS(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-}
-
-// HandleCORSPreflight handles OPTIONS preflight requests
-func HandleCORSPreflight(w http.ResponseWriter, r *http.Request) bool {
-	if r.Method == "OPTIONS" {
-		ApplyCORS(w)
-		w.WriteHeader(http.StatusOK)
-		return true
-	}
-	return false
+	WriteError(w, NewError(status, CodeForStatus(status), message, details))
 }
 
 // ValidateMethod checks if the HTTP method is allowed
@@ -79,9 +63,7 @@ func ValidateMethod(w http.ResponseWriter, r *http.Request, allowed []string) bo
 			return true
 		}
 	}
-	ErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed, map[string]interface{}{
-		"allowed": allowed,
-	})
+	WriteError(w, MethodNotAllowedError(allowed))
 	return false
 }
 
@@ -91,23 +73,90 @@ func ParseJSONBody(r *http.Request, v interface{}) error {
 	return decoder.Decode(v)
 }
 
-// GetClientIP extracts client IP from request
+// GetClientIP extracts client IP from request. X-Forwarded-For and X-Real-IP
+// are only trusted when the request comes from a proxy in TRUSTED_PROXIES, as
+// anyone can send them; otherwise the peer address is the client.
 func GetClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		ips := strings.Split(forwarded, ",")
-		return strings.TrimSpace(ips[0])
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	proxies := trustedProxies()
+	if !proxies.contains(remote) {
+		return remote
+	}
+
+	// Each proxy appends the address it received the request from, so the
+	// client is the last entry that is not itself a trusted proxy
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if i == 0 || !proxies.contains(hops[i]) {
+			return hops[i]
+		}
 	}
 
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
 		return realIP
 	}
+	return remote
+}
 
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
+// proxyList is the set of proxies whose forwarding headers are trusted
+type proxyList struct {
+	any  bool
+	nets []*net.IPNet
+}
+
+// trustedProxies parses TRUSTED_PROXIES, a comma-separated list of IPs and
+// CIDR ranges. "*" trusts every peer, for platforms such as Vercel whose edge
+// overwrites the forwarding headers before they reach the function.
+func trustedProxies() proxyList {
+	var proxies proxyList
+	for _, entry := range strings.Split(GetEnv("TRUSTED_PROXIES", ""), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "*" {
+			proxies.any = true
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip4 := ip.To4(); ip4 != nil {
+					ip, bits = ip4, 8*net.IPv4len
+				}
+				proxies.nets = append(proxies.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			}
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			proxies.nets = append(proxies.nets, network)
+		}
+	}
+	return proxies
+}
+
+// contains reports whether address is a trusted proxy
+func (p proxyList) contains(address string) bool {
+	if p.any {
+		return true
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p.nets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
 // GetQueryParam gets a query parameter with a default value
@@ -173,6 +222,7 @@ const (
 
 // SetUserContext sets user in request context
 func SetUserContext(r *http.Request, user *User) *http.Request {
+	setRequestUser(r.Context(), user.ID)
 	ctx := context.WithValue(r.Context(), UserContextKey, user)
 	return r.WithContext(ctx)
 }
@@ -183,35 +233,8 @@ func GetUserFromContext(r *http.Request) (*User, bool) {
 	return user, ok
 }
 
-// CreateHandler creates a wrapped handler with middleware
+// CreateHandler creates a wrapped handler with middleware. The Config
+// fields are shorthand for the chain returned by Config.Middlewares.
 func CreateHandler(handler http.HandlerFunc, config Config) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Apply CORS
-		if config.EnableCORS {
-			ApplyCORS(w)
-			if HandleCORSPreflight(w, r) {
-				return
-			}
-		}
-
-		// Validate method
-		if len(config.AllowedMethods) > 0 {
-			if !ValidateMethod(w, r, config.AllowedMethods) {
-				return
-			}
-		}
-
-		// Authenticate if required
-		if config.RequireAuth {
-			user, err := AuthenticateRequest(r)
-			if err != nil {
-				ErrorResponse(w, "Unauthorized", http.StatusUnauthorized, nil)
-				return
-			}
-			r = SetUserContext(r, user)
-		}
-
-		// Call handler
-		handler(w, r)
-	}
+	return Chain(handler, config.Middlewares()...)
 }
@@ -0,0 +1,352 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header carrying a client-chosen key
+// that makes retries of a POST safe
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed from an earlier request
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// DefaultIdempotencyTTLHours is how long stored responses are replayed,
+// unless IDEMPOTENCY_TTL_HOURS is set
+const DefaultIdempotencyTTLHours = 24
+
+// MaxIdempotencyKeyLength is the longest Idempotency-Key accepted
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyRecord represents a request made with an Idempotency-Key and,
+// once it has finished, the response to replay for retries of it
+type IdempotencyRecord struct {
+	Key         string      `json:"key"`          // user ID and Idempotency-Key
+	RequestHash string      `json:"request_hash"` // method, URI and body
+	Completed   bool        `json:"completed"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+}
+
+// IdempotencyTTL returns how long stored responses are kept
+func IdempotencyTTL() time.Duration {
+	hours, err := strconv.Atoi(GetEnv("IDEMPOTENCY_TTL_HOURS", ""))
+	if err != nil || hours <= 0 {
+		hours = DefaultIdempotencyTTLHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// HandleIdempotent runs handler at most once per Idempotency-Key for the
+// authenticated user. Retries with the same request replay the first
+// response; reusing a key for a different request fails with 422 and a retry
+// while the first request is still running fails with 409. Requests without
+// the header are passed straight through. Server errors are not stored, so
+// the request can be retried with the same key.
+func HandleIdempotent(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		handler(w, r)
+		return
+	}
+	if len(key) > MaxIdempotencyKeyLength {
+		WriteError(w, BadRequestError("Invalid Idempotency-Key", map[string]string{
+			"hint": "Use at most " + strconv.Itoa(MaxIdempotencyKeyLength) + " characters, such as a UUID",
+		}))
+		return
+	}
+
+	user, ok := GetUserFromContext(r)
+	if !ok {
+		WriteError(w, UnauthorizedError())
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, BadRequestError("Unable to read request body", nil))
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	now := time.Now().UTC()
+	record := IdempotencyRecord{
+		Key:         user.ID + ":" + key,
+		RequestHash: hashRequest(r, body),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(IdempotencyTTL()),
+	}
+
+	existing, err := reserveIdempotent(r.Context(), record)
+	if err != nil {
+		WriteError(w, InternalError("Failed to check Idempotency-Key", err))
+		return
+	}
+	if existing != nil {
+		replayIdempotent(w, existing, record.RequestHash)
+		return
+	}
+
+	// The reservation is released unless a response is stored, including when
+	// the handler panics; the deferred call runs while the panic unwinds to
+	// the Recover middleware, so retries are not refused with 409 until it expires
+	stored := false
+	defer func() {
+		if !stored {
+			Idempotency.Release(r.Context(), record.Key)
+		}
+	}()
+
+	recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	handler(recorder, r)
+
+	if recorder.status >= http.StatusInternalServerError {
+		return
+	}
+	stored = true
+	record.Completed = true
+	record.Status = recorder.status
+	record.Header = w.Header().Clone()
+	// CORS, rate limit and request ID headers describe the retry rather than
+	// the stored response, so they are not replayed. Neither are the encoding
+	// headers, as the stored body is the handler's uncompressed one and Compress
+	// encodes the replay afresh.
+	for name := range record.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "access-control-") || strings.HasPrefix(lower, "ratelimit-") ||
+			lower == "vary" || lower == "x-request-id" || lower == "content-encoding" || lower == "content-length" {
+			delete(record.Header, name)
+		}
+	}
+	record.Body = recorder.body.Bytes()
+	// The response has already been sent, so a failure here only loses the replay
+	Idempotency.Complete(r.Context(), record)
+}
+
+// maxIdempotencyReserveAttempts bounds how often a reservation is retried when
+// the record that blocked it expires before it can be read
+const maxIdempotencyReserveAttempts = 3
+
+// reserveIdempotent reserves the record's key, returning nil when it was
+// reserved or the existing record for the key otherwise
+func reserveIdempotent(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error) {
+	for attempt := 1; ; attempt++ {
+		reserved, err := Idempotency.Reserve(ctx, record)
+		if err != nil || reserved {
+			return nil, err
+		}
+		existing, err := Idempotency.Get(ctx, record.Key)
+		if errors.Is(err, ErrNotFound) && attempt < maxIdempotencyReserveAttempts {
+			// Expired or released between Reserve and Get
+			continue
+		}
+		return existing, err
+	}
+}
+
+// replayIdempotent writes the response stored for an earlier request with the
+// same key, or the error explaining why it cannot be replayed
+func replayIdempotent(w http.ResponseWriter, existing *IdempotencyRecord, requestHash string) {
+	switch {
+	case existing.RequestHash != requestHash:
+		WriteError(w, UnprocessableError("Idempotency-Key was already used for a different request", map[string]string{
+			"hint": "Use a new key for each distinct request",
+		}))
+	case !existing.Completed:
+		w.Header().Set("Retry-After", "1")
+		WriteError(w, ConflictError("A request with this Idempotency-Key is still in progress", nil))
+	default:
+		for name, values := range existing.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set(IdempotentReplayedHeader, "true")
+		w.WriteHeader(existing.Status)
+		w.Write(existing.Body)
+	}
+}
+
+// hashRequest identifies a request by its method, URI and body
+func hashRequest(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// IdempotencyStore persists idempotency records. Records are JSON-serializable
+// so persistent stores can keep them in any key-value or SQL backend.
+type IdempotencyStore interface {
+	// Reserve atomically stores an in-progress record unless an unexpired one
+	// exists for the same key, reporting whether it did
+	Reserve(ctx context.Context, record IdempotencyRecord) (bool, error)
+	// Get returns the unexpired record for a key
+	Get(ctx context.Context, key string) (*IdempotencyRecord, error)
+	// Complete stores the finished record, replacing the reservation
+	Complete(ctx context.Context, record IdempotencyRecord) error
+	// Release drops a reservation so the key can be used again
+	Release(ctx context.Context, key string) error
+}
+
+// Idempotency is the idempotency store used by CreateHandler. A retry usually
+// reaches another serverless instance, so it is kept in the Redis-protocol
+// server at REDIS_URL when set.
+var Idempotency IdempotencyStore = InstrumentIdempotencyStore(newIdempotencyStore())
+
+func newIdempotencyStore() IdempotencyStore {
+	if client := newRedisClient("idempotency"); client != nil {
+		return &RedisIdempotencyStore{client: client}
+	}
+	return NewMemoryIdempotencyStore()
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory idempotency store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records: make(map[string]IdempotencyRecord),
+	}
+}
+
+// Reserve stores an in-progress record unless an unexpired one exists, also
+// dropping expired records
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, existing := range s.records {
+		if now.After(existing.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+	if _, ok := s.records[record.Key]; ok {
+		return false, nil
+	}
+	s.records[record.Key] = record
+	return true, nil
+}
+
+// Get returns the unexpired record for a key
+func (s *MemoryIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok || time.Now().After(record.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+// Complete stores the finished record
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Key] = record
+	return nil
+}
+
+// Release drops a reservation
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// RedisIdempotencyStore is an IdempotencyStore in Redis, one JSON string per
+// key expiring with the record
+type RedisIdempotencyStore struct {
+	client *RESPClient
+}
+
+func idempotencyKey(key string) string {
+	return "idempotency:" + key
+}
+
+// Reserve sets the record's key unless it exists
+func (s *RedisIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord) (bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	reply, err := s.client.Do("SET", idempotencyKey(record.Key), string(data), "NX", "PX", idempotencyTTLMillis(record))
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// Get returns the record for a key; Redis drops it once expired
+func (s *RedisIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	var record IdempotencyRecord
+	if err := s.client.getJSON(idempotencyKey(key), &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Complete overwrites the reservation with the finished record
+func (s *RedisIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do("SET", idempotencyKey(record.Key), string(data), "PX", idempotencyTTLMillis(record))
+	return err
+}
+
+// Release deletes the reservation
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.client.Do("DEL", idempotencyKey(key))
+	return err
+}
+
+// idempotencyTTLMillis is the time left until the record expires, at least
+// 1ms as PX rejects anything less
+func idempotencyTTLMillis(record IdempotencyRecord) string {
+	return strconv.FormatInt(max(time.Until(record.ExpiresAt).Milliseconds(), 1), 10)
+}
@@ -0,0 +1,211 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useIdempotencyStore swaps the Idempotency store for the test
+func useIdempotencyStore(t *testing.T, store IdempotencyStore) {
+	t.Helper()
+	previous := Idempotency
+	Idempotency = store
+	t.Cleanup(func() { Idempotency = previous })
+}
+
+func idempotentRequest(key, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/go/transactions", strings.NewReader(body))
+	r.Header.Set(IdempotencyKeyHeader, key)
+	return SetUserContext(r, &User{ID: "user-1"})
+}
+
+func TestHandleIdempotentReplays(t *testing.T) {
+	useIdempotencyStore(t, NewMemoryIdempotencyStore())
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Access-Control-Allow-Origin", "https://app.example.com")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"tx-1"}`))
+	}
+
+	first := httptest.NewRecorder()
+	HandleIdempotent(first, idempotentRequest("key-1", `{"amount":1}`), handler)
+	retry := httptest.NewRecorder()
+	HandleIdempotent(retry, idempotentRequest("key-1", `{"amount":1}`), handler)
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want once", calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != `{"id":"tx-1"}` || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry %d %s %v, want the replayed 201", retry.Code, retry.Body, retry.Header())
+	}
+	if got := retry.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("replayed Access-Control-Allow-Origin %q, want none", got)
+	}
+
+	other := httptest.NewRecorder()
+	HandleIdempotent(other, idempotentRequest("key-1", `{"amount":2}`), handler)
+	if other.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key for another request: %d, want 422", other.Code)
+	}
+}
+
+func TestHandleIdempotentReleasesOnPanic(t *testing.T) {
+	useIdempotencyStore(t, NewMemoryIdempotencyStore())
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the handler's panic was swallowed")
+			}
+		}()
+		HandleIdempotent(httptest.NewRecorder(), idempotentRequest("key-1", `{}`), func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+	}()
+
+	retry := httptest.NewRecorder()
+	HandleIdempotent(retry, idempotentRequest("key-1", `{}`), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	if retry.Code != http.StatusCreated {
+		t.Errorf("retry after a panic: %d, want 201", retry.Code)
+	}
+}
+
+func TestHandleIdempotentReleasesServerErrors(t *testing.T) {
+	useIdempotencyStore(t, NewMemoryIdempotencyStore())
+	status := http.StatusServiceUnavailable
+	handler := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(status) }
+
+	HandleIdempotent(httptest.NewRecorder(), idempotentRequest("key-1", `{}`), handler)
+	status = http.StatusCreated
+	retry := httptest.NewRecorder()
+	HandleIdempotent(retry, idempotentRequest("key-1", `{}`), handler)
+	if retry.Code != http.StatusCreated || retry.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("retry after a server error: %d %v, want a fresh 201", retry.Code, retry.Header())
+	}
+}
+
+// expiringIdempotencyStore refuses the first reservation and then reports the
+// blocking record as gone, as when it expires between Reserve and Get
+type expiringIdempotencyStore struct {
+	*MemoryIdempotencyStore
+	refused int
+}
+
+func (s *expiringIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord) (bool, error) {
+	if s.refused == 0 {
+		s.refused++
+		return false, nil
+	}
+	return s.MemoryIdempotencyStore.Reserve(ctx, record)
+}
+
+func TestHandleIdempotentRetriesExpiredReservation(t *testing.T) {
+	store := &expiringIdempotencyStore{MemoryIdempotencyStore: NewMemoryIdempotencyStore()}
+	useIdempotencyStore(t, store)
+	w := httptest.NewRecorder()
+	HandleIdempotent(w, idempotentRequest("key-1", `{}`), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	if w.Code != http.StatusCreated || store.refused != 1 {
+		t.Errorf("status %d after %d refused reservations, want 201 after 1", w.Code, store.refused)
+	}
+}
+
+func TestHandleIdempotentReplaysCompressedResponses(t *testing.T) {
+	useIdempotencyStore(t, NewMemoryIdempotencyStore())
+	handler := Compress()(func(w http.ResponseWriter, r *http.Request) {
+		HandleIdempotent(w, r, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"tx-1"}`))
+		})
+	})
+
+	first := idempotentRequest("key-1", `{}`)
+	first.Header.Set("Accept-Encoding", "gzip")
+	handler(httptest.NewRecorder(), first)
+
+	// A retry that does not accept gzip gets the plain body, unlabelled
+	retry := httptest.NewRecorder()
+	handler(retry, idempotentRequest("key-1", `{}`))
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatal("the response was not replayed")
+	}
+	if got := retry.Header().Get("Content-Encoding"); got != "" || retry.Body.String() != `{"id":"tx-1"}` {
+		t.Errorf("replayed Content-Encoding %q with body %q, want the plain body", got, retry.Body)
+	}
+}
+
+func TestIdempotencyStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) IdempotencyStore{
+		"memory": func(t *testing.T) IdempotencyStore { return NewMemoryIdempotencyStore() },
+		"redis":  func(t *testing.T) IdempotencyStore { return &RedisIdempotencyStore{client: newTestRESPClient(t)} },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			now := time.Now().UTC()
+			record := IdempotencyRecord{Key: "user-1:key-1", RequestHash: "hash", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+
+			if reserved, err := store.Reserve(ctx, record); err != nil || !reserved {
+				t.Fatalf("Reserve = %v, %v, want reserved", reserved, err)
+			}
+			if reserved, err := store.Reserve(ctx, record); err != nil || reserved {
+				t.Fatalf("second Reserve = %v, %v, want refused", reserved, err)
+			}
+			if got, err := store.Get(ctx, record.Key); err != nil || got.Completed || got.RequestHash != "hash" {
+				t.Fatalf("Get = %+v, %v, want the in-progress record", got, err)
+			}
+
+			record.Completed = true
+			record.Status = http.StatusCreated
+			record.Header = http.Header{"Content-Type": {"application/json"}}
+			record.Body = []byte(`{"id":"tx-1"}`)
+			if err := store.Complete(ctx, record); err != nil {
+				t.Fatal(err)
+			}
+			got, err := store.Get(ctx, record.Key)
+			if err != nil || !got.Completed || got.Status != http.StatusCreated || string(got.Body) != `{"id":"tx-1"}` ||
+				got.Header.Get("Content-Type") != "application/json" {
+				t.Fatalf("Get after Complete = %+v, %v, want the stored response", got, err)
+			}
+
+			if err := store.Release(ctx, record.Key); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Get(ctx, record.Key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after Release: %v, want ErrNotFound", err)
+			}
+			if reserved, err := store.Reserve(ctx, record); err != nil || !reserved {
+				t.Errorf("Reserve after Release = %v, %v, want reserved", reserved, err)
+			}
+		})
+	}
+}
+
+func TestHandleIdempotentSharedAcrossInstances(t *testing.T) {
+	// Two instances share the Redis server but not memory
+	client := newTestRESPClient(t)
+	first, second := &RedisIdempotencyStore{client: client}, &RedisIdempotencyStore{client: client}
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	useIdempotencyStore(t, first)
+	HandleIdempotent(httptest.NewRecorder(), idempotentRequest("key-1", `{}`), handler)
+	Idempotency = second
+	retry := httptest.NewRecorder()
+	HandleIdempotent(retry, idempotentRequest("key-1", `{}`), handler)
+	if calls != 1 || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("handler ran %d times with the retry replayed %q, want one run and a replay", calls, retry.Header().Get(IdempotentReplayedHeader))
+	}
+}
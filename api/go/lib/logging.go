@@ -0,0 +1,271 @@
+package lib
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// RequestIDHeader carries the request ID. A valid client-supplied ID is kept so
+// logs can be correlated across services; otherwise one is generated. Either
+// way it is echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// MaxRequestIDLength is the longest client-supplied request ID accepted
+const MaxRequestIDLength = 128
+
+// Redacted replaces the value of sensitive log attributes
+const Redacted = "[REDACTED]"
+
+// Logger writes JSON logs to stderr at LOG_LEVEL (debug, info, warn or error;
+// default info). Sensitive attributes are redacted, see RedactAttr.
+var Logger = NewLogger(os.Stderr, GetEnv("LOG_LEVEL", "info"))
+
+// NewLogger creates a JSON logger writing to w at the given level
+func NewLogger(w io.Writer, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: lvl,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			return RedactAttr(a)
+		},
+	}))
+}
+
+// sensitiveKeys are attribute and header names whose values are never logged
+var sensitiveKeys = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"password":            true,
+	"token":               true,
+	"access_token":        true,
+	"refresh_token":       true,
+	"secret":              true,
+	"api_key":             true,
+}
+
+// piiKeys are attribute names holding personal data, which is masked
+var piiKeys = map[string]bool{
+	"email":     true,
+	"phone":     true,
+	"full_name": true,
+	"address":   true,
+}
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	bearerPattern = regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9\-._~+/]+=*`)
+)
+
+// RedactAttr hides credentials and masks personal data in a log attribute.
+// Attributes are matched by name; email addresses and bearer tokens are also
+// scrubbed from any string value, such as error messages.
+func RedactAttr(a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case sensitiveKeys[key]:
+		return slog.String(a.Key, Redacted)
+	case piiKeys[key] && a.Value.Kind() == slog.KindString:
+		return slog.String(a.Key, maskPII(a.Value.String()))
+	case a.Value.Kind() == slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	}
+	return a
+}
+
+// RedactString scrubs email addresses and bearer tokens from free text
+func RedactString(s string) string {
+	s = bearerPattern.ReplaceAllString(s, "Bearer "+Redacted)
+	return emailPattern.ReplaceAllStringFunc(s, maskPII)
+}
+
+// maskPII keeps only the first character of a value, and the domain of an
+// email address
+func maskPII(value string) string {
+	if value == "" {
+		return ""
+	}
+	local, domain, isEmail := strings.Cut(value, "@")
+	masked := "***"
+	if first, size := utf8.DecodeRuneInString(local); size > 0 {
+		masked = string(first) + masked
+	}
+	if isEmail {
+		return masked + "@" + domain
+	}
+	return masked
+}
+
+// RedactHeaders returns request headers safe to log
+func RedactHeaders(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		if sensitiveKeys[strings.ToLower(name)] {
+			redacted[name] = Redacted
+			continue
+		}
+		redacted[name] = RedactString(strings.Join(values, ", "))
+	}
+	return redacted
+}
+
+// requestInfo holds the per-request fields of the access log. It is shared
+// through the context so inner middleware, such as Authenticate, can fill it
+// in for the outer LogRequests.
+type requestInfo struct {
+	id      string
+	userID  string
+	traceID string // set by Trace
+}
+
+type requestInfoKey struct{}
+
+// RequestID returns the ID assigned to the request by LogRequests, or else the
+// one sent by the client or the platform
+func RequestID(r *http.Request) string {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		return info.id
+	}
+	if id := r.Header.Get(RequestIDHeader); id != "" {
+		return id
+	}
+	return r.Header.Get("X-Vercel-Id")
+}
+
+// RequestLogger returns Logger with the request's ID, method, route and, once
+// known, user ID and trace ID
+func RequestLogger(r *http.Request) *slog.Logger {
+	logger := Logger.With("request_id", RequestID(r), "method", r.Method, "route", r.URL.Path)
+	if user, ok := GetUserFromContext(r); ok {
+		logger = logger.With("user_id", user.ID)
+	}
+	if span := SpanFromContext(r.Context()); span != nil {
+		logger = logger.With("trace_id", span.TraceID())
+	}
+	return logger
+}
+
+// setRequestUser records the authenticated user for the access log
+func setRequestUser(ctx context.Context, userID string) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userID = userID
+	}
+}
+
+// newRequestID returns the client's request ID if it is safe to reuse, or a
+// new random one
+func newRequestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// validRequestID accepts short IDs of visible ASCII characters, so a client
+// cannot inject log lines or oversized values
+func validRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// LogRequests assigns each request an ID, echoes it in the X-Request-ID
+// response header and writes an access log line once the response is done:
+// request ID, method, route, status, error code, latency, client IP, user ID
+// and trace ID. Server
+// errors are logged at error level and client errors at warn. At debug level
+// the redacted request headers are included.
+func LogRequests() Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			info := &requestInfo{id: newRequestID(r)}
+			w.Header().Set(RequestIDHeader, info.id)
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+
+			next(sw, r)
+
+			level := slog.LevelInfo
+			switch {
+			case sw.status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case sw.status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			attrs := []slog.Attr{
+				slog.String("request_id", info.id),
+				slog.String("method", r.Method),
+				slog.String("route", r.URL.Path),
+				slog.Int("status", sw.status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int("bytes", sw.bytes),
+				slog.String("client_ip", GetClientIP(r)),
+				slog.String("user_agent", r.UserAgent()),
+			}
+			if sw.code != "" {
+				attrs = append(attrs, slog.String("code", sw.code))
+			}
+			if info.userID != "" {
+				attrs = append(attrs, slog.String("user_id", info.userID))
+			}
+			if info.traceID != "" {
+				attrs = append(attrs, slog.String("trace_id", info.traceID))
+			}
+			if Logger.Enabled(r.Context(), slog.LevelDebug) {
+				attrs = append(attrs, slog.Any("headers", RedactHeaders(r.Header)))
+			}
+			Logger.LogAttrs(r.Context(), level, "request", attrs...)
+		}
+	}
+}
+
+// statusWriter records the status, size and error code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	code        string // set by WriteError
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status = status
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += n
+	return n, err
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestMaskPII(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"", ""},
+		{"Alice", "A***"},
+		{"alice@example.com", "a***@example.com"},
+		{"@example.com", "***@example.com"},
+		{"@", "***@"},
+		{"élodie@example.fr", "é***@example.fr"},
+		{"渡辺", "渡***"},
+		{"\xffbad", "�***"},
+	}
+	for _, tt := range tests {
+		if got := maskPII(tt.value); got != tt.want {
+			t.Errorf("maskPII(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRedactAttr(t *testing.T) {
+	tests := []struct {
+		attr slog.Attr
+		want string
+	}{
+		{slog.String("Authorization", "Bearer abc"), Redacted},
+		{slog.String("email", "@example.com"), "***@example.com"},
+		{slog.String("error", "lookup of bob@example.com failed"), "lookup of b***@example.com failed"},
+		{slog.String("error", "token Bearer abc.def rejected"), "token Bearer " + Redacted + " rejected"},
+	}
+	for _, tt := range tests {
+		if got := RedactAttr(tt.attr).Value.String(); got != tt.want {
+			t.Errorf("RedactAttr(%v) = %q, want %q", tt.attr, got, tt.want)
+		}
+	}
+}
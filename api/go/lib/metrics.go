@@ -0,0 +1,350 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenMetricsContentType is the media type of the OpenMetrics text format
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// PrometheusContentType is the media type of the Prometheus text format,
+// served to scrapers that do not ask for OpenMetrics
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// MetricsPrefix namespaces every application metric
+const MetricsPrefix = "budgetbuddy_"
+
+// DefaultLatencyBuckets are histogram bucket upper bounds in seconds
+var DefaultLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics is the registry served by the metrics endpoint and pushed over OTLP
+var Metrics = NewRegistry()
+
+// Application metrics. Metrics live in the memory of each instance, so on
+// serverless deployments each instance reports its own counts; push them to an
+// OTLP collector (see StartOTLPPush and PushOTLPBatch) to aggregate them.
+var (
+	HTTPRequests = Metrics.NewCounter(MetricsPrefix+"http_requests",
+		"Requests handled, by route, method and status", "route", "method", "status")
+	HTTPRequestDuration = Metrics.NewHistogram(MetricsPrefix+"http_request_duration_seconds",
+		"Time to handle a request, by route and method", DefaultLatencyBuckets, "route", "method")
+	HTTPErrors = Metrics.NewCounter(MetricsPrefix+"http_errors",
+		"Error responses, by route and error code", "route", "code")
+	AuthFailures = Metrics.NewCounter(MetricsPrefix+"auth_failures",
+		"Requests rejected for a missing or invalid bearer token, by route", "route")
+	RateLimitRejections = Metrics.NewCounter(MetricsPrefix+"rate_limit_rejections",
+		"Requests refused with 429, by rate limit policy", "policy")
+	StorageDuration = Metrics.NewHistogram(MetricsPrefix+"storage_operation_duration_seconds",
+		"Time spent in storage calls, by store and operation", DefaultLatencyBuckets, "store", "operation")
+	StorageErrors = Metrics.NewCounter(MetricsPrefix+"storage_errors",
+		"Failed storage calls, by store and operation", "store", "operation")
+)
+
+func init() {
+	Metrics.NewGaugeFunc("go_goroutines", "Number of goroutines", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	Metrics.NewGaugeFunc("go_memstats_heap_alloc_bytes", "Bytes of allocated heap objects", func() float64 {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return float64(m.HeapAlloc)
+	})
+}
+
+// ObserveStorage records the latency of a storage call started at start, and
+// counts it as failed if err is neither nil nor ErrNotFound
+func ObserveStorage(store, operation string, start time.Time, err error) {
+	StorageDuration.Observe(time.Since(start).Seconds(), store, operation)
+	if err != nil && err != ErrNotFound {
+		StorageErrors.Inc(store, operation)
+	}
+}
+
+// Registry holds a set of metrics. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	start   time.Time
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{start: time.Now()}
+}
+
+// metric is a family of samples sharing a name
+type metric interface {
+	name() string
+	write(w *bufio.Writer, openMetrics bool)
+	otlp(start, now time.Time) otlpMetric
+}
+
+func (reg *Registry) register(m metric) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.metrics = append(reg.metrics, m)
+}
+
+// sorted returns the registered metrics ordered by name
+func (reg *Registry) sorted() []metric {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	metrics := append([]metric(nil), reg.metrics...)
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+	return metrics
+}
+
+// WriteText writes every metric in the OpenMetrics text format, or in the
+// Prometheus text format when openMetrics is false
+func (reg *Registry) WriteText(w io.Writer, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range reg.sorted() {
+		m.write(bw, openMetrics)
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// series is one labelled sample of a metric family
+type series struct {
+	labels []string
+	value  float64  // counter value
+	counts []uint64 // histogram bucket counts, not cumulative
+	sum    float64  // histogram sum
+	count  uint64   // histogram observations
+}
+
+// family holds the labelled series of one metric
+type family struct {
+	mu     sync.Mutex
+	metric string
+	help   string
+	labels []string
+	series map[string]*series
+}
+
+func newFamily(name, help string, labels []string) family {
+	return family{metric: name, help: help, labels: labels, series: make(map[string]*series)}
+}
+
+func (f *family) name() string {
+	return f.metric
+}
+
+// get returns the series for label values, creating it. f.mu must be held.
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		padded := make([]string, len(f.labels))
+		copy(padded, values)
+		values = padded
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...)}
+		f.series[key] = s
+	}
+	return s
+}
+
+// sortedSeries returns copies of the series ordered by label values. f.mu must be held.
+func (f *family) sortedSeries() []series {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]series, len(keys))
+	for i, key := range keys {
+		s := *f.series[key]
+		s.counts = append([]uint64(nil), s.counts...)
+		result[i] = s
+	}
+	return result
+}
+
+// labelString formats label pairs as {a="x",b="y"}, with extra pairs appended
+func (f *family) labelString(values []string, extra ...string) string {
+	if len(f.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range f.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label + `="` + escapeLabel(values[i]) + `"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i] + `="` + escapeLabel(extra[i+1]) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter is a monotonically increasing count, exposed with a _total suffix
+type Counter struct {
+	family
+}
+
+// NewCounter registers a counter. name omits the _total suffix.
+func (reg *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newFamily(name, help, labels)}
+	reg.register(c)
+	return c
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the series with the given label values
+func (c *Counter) Add(delta float64, values ...string) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(values).value += delta
+}
+
+func (c *Counter) write(w *bufio.Writer, openMetrics bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	familyName := c.metric
+	if !openMetrics {
+		familyName += "_total"
+	}
+	w.WriteString("# HELP " + familyName + " " + c.help + "\n")
+	w.WriteString("# TYPE " + familyName + " counter\n")
+	for _, s := range c.sortedSeries() {
+		w.WriteString(c.metric + "_total" + c.labelString(s.labels) + " " + formatFloat(s.value) + "\n")
+	}
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	family
+	buckets []float64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds
+func (reg *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{family: newFamily(name, help, labels), buckets: append([]float64(nil), buckets...)}
+	sort.Float64s(h.buckets)
+	reg.register(h)
+	return h
+}
+
+// Observe records a value in the series with the given label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(values)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets)+1)
+	}
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer, openMetrics bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w.WriteString("# HELP " + h.metric + " " + h.help + "\n")
+	w.WriteString("# TYPE " + h.metric + " histogram\n")
+	for _, s := range h.sortedSeries() {
+		var cumulative uint64
+		for i, count := range s.counts {
+			bound := math.Inf(1)
+			if i < len(h.buckets) {
+				bound = h.buckets[i]
+			}
+			cumulative += count
+			w.WriteString(h.metric + "_bucket" + h.labelString(s.labels, "le", formatFloat(bound)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		w.WriteString(h.metric + "_sum" + h.labelString(s.labels) + " " + formatFloat(s.sum) + "\n")
+		w.WriteString(h.metric + "_count" + h.labelString(s.labels) + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+// GaugeFunc is a gauge whose value is read when the metrics are collected
+type GaugeFunc struct {
+	family
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge reporting fn
+func (reg *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{family: newFamily(name, help, nil), fn: fn}
+	reg.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer, openMetrics bool) {
+	w.WriteString("# HELP " + g.metric + " " + g.help + "\n")
+	w.WriteString("# TYPE " + g.metric + " gauge\n")
+	w.WriteString(g.metric + " " + formatFloat(g.fn()) + "\n")
+}
+
+// Instrument counts requests, their latency and error codes in HTTPRequests,
+// HTTPRequestDuration and HTTPErrors. When a collector is configured it starts
+// the OTLP push on the first request or, on serverless instances, pushes
+// after each batch of requests with PushOTLPBatch.
+func Instrument() Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			serverless := Serverless()
+			if !serverless {
+				StartOTLPPush(context.Background())
+			}
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+			next(sw, r)
+
+			route := r.URL.Path
+			HTTPRequests.Inc(route, r.Method, strconv.Itoa(sw.status))
+			HTTPRequestDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+			if sw.code != "" {
+				HTTPErrors.Inc(route, sw.code)
+			}
+			if serverless {
+				PushOTLPBatch(context.WithoutCancel(r.Context()))
+			}
+		}
+	}
+}
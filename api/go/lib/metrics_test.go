@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounter("test_requests", "Requests handled", "route", "method")
+	latency := reg.NewHistogram("test_latency_seconds", "Request latency", []float64{1, 0.1}, "route")
+	reg.NewGaugeFunc("test_up", "Whether the service is up", func() float64 { return 1 })
+
+	requests.Inc("/a", "GET")
+	requests.Add(2, "/a\"b\\c\n", "POST")
+	requests.Add(-1, "/a", "GET") // counters never go down
+	for _, v := range []float64{0.25, 0.5, 4, 0.1} {
+		latency.Observe(v, "/a")
+	}
+
+	tests := []struct {
+		name        string
+		openMetrics bool
+		want        string
+	}{
+		{"prometheus", false, `# HELP test_latency_seconds Request latency
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/a",le="0.1"} 1
+test_latency_seconds_bucket{route="/a",le="1"} 3
+test_latency_seconds_bucket{route="/a",le="+Inf"} 4
+test_latency_seconds_sum{route="/a"} 4.85
+test_latency_seconds_count{route="/a"} 4
+# HELP test_requests_total Requests handled
+# TYPE test_requests_total counter
+test_requests_total{route="/a\"b\\c\n",method="POST"} 2
+test_requests_total{route="/a",method="GET"} 1
+# HELP test_up Whether the service is up
+# TYPE test_up gauge
+test_up 1
+`},
+		// OpenMetrics names the counter family without _total and ends with # EOF
+		{"openmetrics", true, `# HELP test_latency_seconds Request latency
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/a",le="0.1"} 1
+test_latency_seconds_bucket{route="/a",le="1"} 3
+test_latency_seconds_bucket{route="/a",le="+Inf"} 4
+test_latency_seconds_sum{route="/a"} 4.85
+test_latency_seconds_count{route="/a"} 4
+# HELP test_requests Requests handled
+# TYPE test_requests counter
+test_requests_total{route="/a\"b\\c\n",method="POST"} 2
+test_requests_total{route="/a",method="GET"} 1
+# HELP test_up Whether the service is up
+# TYPE test_up gauge
+test_up 1
+# EOF
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := reg.WriteText(&b, tt.openMetrics); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("WriteText wrote\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package lib
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Middleware wraps a handler with behaviour that runs around it. A middleware
+// may answer the request itself by not calling next.
+type Middleware func(next http.HandlerFunc) http.HandlerFunc
+
+// Chain wraps handler in middleware. The first middleware is the outermost,
+// so it sees the request first and the response last.
+func Chain(handler http.HandlerFunc, middleware ...Middleware) http.HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Middlewares returns the chain a Config is shorthand for: request logging,
+// tracing, metrics, problem details negotiation and panic recovery, its extra Middleware, then CORS, method
+// check, authentication, workspace scope, rate limiting and idempotency.
+func (c Config) Middlewares() []Middleware {
+	chain := append([]Middleware{LogRequests(), Trace(), Instrument(), ProblemDetails(), Recover()}, c.Middleware...)
+	cors := c.CORS
+	if cors == nil && c.EnableCORS {
+		cors = DefaultCORS()
+	}
+	if cors != nil {
+		chain = append(chain, ApplyCORS(cors, c.AllowedMethods))
+	}
+	if len(c.AllowedMethods) > 0 {
+		chain = append(chain, AllowMethods(c.AllowedMethods...))
+	}
+	if c.RequireAuth {
+		chain = append(chain, Authenticate())
+		if c.WorkspaceScoped {
+			chain = append(chain, ScopeWorkspace())
+		}
+	}
+	if c.RateLimit != nil {
+		chain = append(chain, LimitRate(c.RateLimit))
+	}
+	if c.Idempotent && c.RequireAuth {
+		chain = append(chain, ReplayIdempotent())
+	}
+	return chain
+}
+
+// ApplyCORS applies a CORS policy, answering preflight requests. methods are
+// advertised when the policy does not list its own.
+func ApplyCORS(policy *CORSPolicy, methods []string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if policy.Handle(w, r, methods) {
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// AllowMethods rejects requests using any other method with 405
+func AllowMethods(methods ...string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !ValidateMethod(w, r, methods) {
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// Authenticate rejects requests without a valid bearer token with 401 and
+// stores the user in the request context
+func Authenticate() Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, span := StartSpan(r.Context(), "auth", SpanKindInternal)
+			user, err := AuthenticateRequest(r)
+			if err != nil {
+				span.RecordError(err)
+				span.End()
+				AuthFailures.Inc(r.URL.Path)
+				WriteError(w, UnauthorizedError())
+				return
+			}
+			span.SetAttribute("enduser.id", user.ID)
+			span.End()
+			next(w, SetUserContext(r, user))
+		}
+	}
+}
+
+// ScopeWorkspace checks workspace_id membership and role, see
+// AuthorizeWorkspace. It must run after Authenticate.
+func ScopeWorkspace() Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			user, ok := GetUserFromContext(r)
+			if !ok {
+				WriteError(w, UnauthorizedError())
+				return
+			}
+			if r, ok = AuthorizeWorkspace(w, r, user); !ok {
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// LimitRate throttles requests per user, or per client IP for anonymous
+// requests, see CheckRateLimit
+func LimitRate(policy *RateLimitPolicy) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !CheckRateLimit(w, r, policy) {
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// ReplayIdempotent replays retried POSTs carrying an Idempotency-Key instead
+// of repeating them, see HandleIdempotent. It must run after Authenticate.
+func ReplayIdempotent() Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next(w, r)
+				return
+			}
+			HandleIdempotent(w, r, next)
+		}
+	}
+}
+
+// Timeout sets a deadline on the request context. Work started with the
+// context, such as database queries, is cancelled once it passes.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
+
+// Compress gzips response bodies for clients that accept it
+func Compress() Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) {
+				next(w, r)
+				return
+			}
+			gw := &gzipResponseWriter{ResponseWriter: w}
+			defer gw.Close()
+			next(gw, r)
+		}
+	}
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter compresses the body once it is written, leaving bodiless
+// responses and responses already encoded by the handler untouched. The status
+// is held back until the first body bytes or Close, so a response that turns
+// out to have no body is not labelled as gzip.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	status      int // pending status, 0 until WriteHeader
+	wroteHeader bool
+	passthrough bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if status < http.StatusOK {
+		g.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if g.wroteHeader || g.status != 0 {
+		return
+	}
+	g.status = status
+}
+
+// start sends the pending status, compressing the body when there is one
+func (g *gzipResponseWriter) start(hasBody bool) {
+	g.wroteHeader = true
+	status := g.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := g.Header()
+	g.passthrough = !hasBody || header.Get("Content-Encoding") != "" ||
+		status == http.StatusNoContent || status == http.StatusNotModified
+	if !g.passthrough {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		g.start(true)
+	}
+	if g.passthrough {
+		return g.ResponseWriter.Write(b)
+	}
+	if g.gz == nil {
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	return g.gz.Write(b)
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// Close flushes the compressed body, or sends a pending status that never
+// got a body
+func (g *gzipResponseWriter) Close() error {
+	if !g.wroteHeader && g.status != 0 {
+		g.start(false)
+	}
+	if g.gz == nil {
+		return nil
+	}
+	return g.gz.Close()
+}
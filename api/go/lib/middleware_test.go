@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		handler        http.HandlerFunc
+		status         int
+		encoding       string
+		body           string
+	}{
+		{
+			name:           "body is compressed",
+			acceptEncoding: "gzip, deflate",
+			handler:        func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("hello")) },
+			status:         http.StatusOK,
+			encoding:       "gzip",
+			body:           "hello",
+		},
+		{
+			name:           "status without a body",
+			acceptEncoding: "gzip",
+			handler:        func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusAccepted) },
+			status:         http.StatusAccepted,
+		},
+		{
+			name:           "empty writes",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write(nil)
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:           "no content",
+			acceptEncoding: "gzip",
+			handler:        func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			status:         http.StatusNoContent,
+		},
+		{
+			name:           "already encoded",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "br")
+				w.Write([]byte("brotli"))
+			},
+			status:   http.StatusOK,
+			encoding: "br",
+			body:     "brotli",
+		},
+		{
+			name:           "gzip refused",
+			acceptEncoding: "gzip;q=0",
+			handler:        func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("hello")) },
+			status:         http.StatusOK,
+			body:           "hello",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/go", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			Compress()(tt.handler)(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("Content-Encoding %q, want %q", got, tt.encoding)
+			}
+			body := w.Body.String()
+			if tt.encoding == "gzip" {
+				reader, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded, err := io.ReadAll(reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = string(decoded)
+			}
+			if body != tt.body {
+				t.Errorf("body %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
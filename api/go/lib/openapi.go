@@ -0,0 +1,708 @@
+package lib
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenAPIVersion is the OpenAPI specification version of the generated document
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPIDocument represents an OpenAPI 3.1 document
+type OpenAPIDocument struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       OpenAPIInfo          `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components OpenAPIComponents    `json:"components"`
+}
+
+// OpenAPIInfo represents the document's API metadata
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem represents the operations available on a path
+type PathItem struct {
+	Get    *OpenAPIOperation `json:"get,omitempty"`
+	Post   *OpenAPIOperation `json:"post,omitempty"`
+	Put    *OpenAPIOperation `json:"put,omitempty"`
+	Patch  *OpenAPIOperation `json:"patch,omitempty"`
+	Delete *OpenAPIOperation `json:"delete,omitempty"`
+}
+
+// OpenAPIOperation represents a single method on a path
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+// OpenAPIParameter represents a query or header parameter
+type OpenAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// OpenAPIRequestBody represents an operation's request body
+type OpenAPIRequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// OpenAPIResponse represents a response for a status code
+type OpenAPIResponse struct {
+	Description string                   `json:"description"`
+	Headers     map[string]OpenAPIHeader `json:"headers,omitempty"`
+	Content     map[string]MediaType     `json:"content,omitempty"`
+}
+
+// OpenAPIHeader represents a response header
+type OpenAPIHeader struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType represents the schema of a request or response body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// OpenAPIComponents represents reusable schemas and security schemes
+type OpenAPIComponents struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme represents an authentication scheme
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Schema represents a JSON Schema (2020-12) as used by OpenAPI 3.1
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument *OpenAPIDocument
+)
+
+// OpenAPISpec returns the OpenAPI document for every registered endpoint.
+// The document is generated once from the request and response DTOs.
+func OpenAPISpec() *OpenAPIDocument {
+	openAPIOnce.Do(func() {
+		openAPIDocument = BuildOpenAPI(Endpoints)
+	})
+	return openAPIDocument
+}
+
+// BuildOpenAPI generates an OpenAPI document for the given endpoints. Schemas
+// are derived from the DTO types by reflection, following their json tags.
+func BuildOpenAPI(endpoints []Endpoint) *OpenAPIDocument {
+	g := &schemaGenerator{schemas: make(map[string]*Schema)}
+	g.schemas["Error"] = errorSchema()
+	g.schemas["Problem"] = problemSchema()
+
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:   APITitle,
+			Version: APIVersion,
+			Description: "Successful responses are wrapped in a `{success, data, timestamp}` envelope " +
+				"and errors in `{success, error, code, details, timestamp}`, where `code` is stable and " +
+				"machine-readable. Send `Accept: application/problem+json` to receive errors as RFC 7807 " +
+				"problem details instead. Every response carries an `X-Request-ID` header, echoing the " +
+				"client's own when it sends one.",
+		},
+		Paths: make(map[string]*PathItem),
+		Components: OpenAPIComponents{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	for _, endpoint := range endpoints {
+		item := &PathItem{}
+		for _, method := range endpointMethods(endpoint) {
+			op := g.operation(endpoint, method)
+			switch method {
+			case http.MethodGet:
+				item.Get = op
+			case http.MethodPost:
+				item.Post = op
+			case http.MethodPut:
+				item.Put = op
+			case http.MethodPatch:
+				item.Patch = op
+			case http.MethodDelete:
+				item.Delete = op
+			}
+		}
+		doc.Paths[endpoint.Path] = item
+	}
+
+	return doc
+}
+
+// endpointMethods lists an endpoint's methods in order of first appearance
+func endpointMethods(endpoint Endpoint) []string {
+	var methods []string
+	seen := make(map[string]bool)
+	for _, op := range endpoint.Operations {
+		if !seen[op.Method] {
+			seen[op.Method] = true
+			methods = append(methods, op.Method)
+		}
+	}
+	return methods
+}
+
+// operation merges an endpoint's operations for one method. Operations
+// selected by a query parameter such as action or view become alternatives of
+// a single OpenAPI operation, with the parameter's values listed as an enum.
+func (g *schemaGenerator) operation(endpoint Endpoint, method string) *OpenAPIOperation {
+	var ops []Operation
+	for _, op := range endpoint.Operations {
+		if op.Method == method {
+			ops = append(ops, op)
+		}
+	}
+
+	result := &OpenAPIOperation{
+		OperationID: strings.ToLower(method) + strings.ToUpper(endpoint.Name[:1]) + endpoint.Name[1:],
+		Tags:        []string{endpoint.Name},
+		Responses:   make(map[string]OpenAPIResponse),
+	}
+	if len(ops) == 1 {
+		result.Summary = ops[0].Summary
+	} else {
+		result.Summary = endpoint.Description
+		var lines []string
+		for _, op := range ops {
+			selector := op.Selector
+			if selector == "" {
+				selector = "default"
+			}
+			lines = append(lines, "- `"+selector+"`: "+op.Summary)
+		}
+		result.Description = strings.Join(lines, "\n")
+	}
+
+	// A parameter is only required when every merged operation requires it
+	if selector := selectorParameter(ops); selector != nil {
+		result.Parameters = append(result.Parameters, *selector)
+	}
+	index := make(map[string]int)
+	uses := make(map[string]int)
+	for _, op := range ops {
+		for _, p := range append(op.Params, queryParams(op.Query)...) {
+			uses[p.Name]++
+			if i, ok := index[p.Name]; ok {
+				result.Parameters[i].Required = result.Parameters[i].Required && p.Required
+				continue
+			}
+			index[p.Name] = len(result.Parameters)
+			result.Parameters = append(result.Parameters, p.openAPI())
+		}
+	}
+	for name, i := range index {
+		if uses[name] < len(ops) {
+			result.Parameters[i].Required = false
+		}
+	}
+	if _, ok := index["workspace_id"]; endpoint.WorkspaceScoped && !ok {
+		result.Parameters = append(result.Parameters, workspaceParam.openAPI())
+	}
+
+	conditional := false
+	for _, op := range ops {
+		conditional = conditional || (op.ETag && method != http.MethodGet)
+	}
+	if conditional {
+		result.Parameters = append(result.Parameters, OpenAPIParameter{
+			Name:        "If-Match",
+			In:          "header",
+			Description: "Only apply the change if the resource still has this ETag, or * for any version",
+			Required:    true,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+	idempotent := endpoint.Idempotent && endpoint.Auth && method == http.MethodPost
+	if idempotent {
+		maxLength := MaxIdempotencyKeyLength
+		result.Parameters = append(result.Parameters, OpenAPIParameter{
+			Name:        "Idempotency-Key",
+			In:          "header",
+			Description: "Client-chosen key, such as a UUID; retries with the same key replay the first response",
+			Schema:      &Schema{Type: "string", MaxLength: &maxLength},
+		})
+	}
+
+	bodies := make(map[string][]*Schema)
+	var contentTypes []string
+	bodyRequired := true
+	for _, op := range ops {
+		if op.Body == nil {
+			bodyRequired = false
+			continue
+		}
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		if _, ok := bodies[contentType]; !ok {
+			contentTypes = append(contentTypes, contentType)
+		}
+		bodies[contentType] = appendSchema(bodies[contentType], g.schemaFor(reflect.TypeOf(op.Body)))
+	}
+	if len(contentTypes) > 0 {
+		result.RequestBody = &OpenAPIRequestBody{
+			Required: bodyRequired,
+			Content:  make(map[string]MediaType),
+		}
+		for _, contentType := range contentTypes {
+			result.RequestBody.Content[contentType] = MediaType{Schema: oneOf(bodies[contentType])}
+		}
+	}
+
+	byStatus := make(map[int][]*Schema)
+	etags := make(map[int]bool)
+	responseTypes := make(map[int]string)
+	var statuses []int
+	for _, op := range ops {
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if _, ok := byStatus[status]; !ok {
+			statuses = append(statuses, status)
+		}
+		etags[status] = etags[status] || op.ETag
+		var schema *Schema
+		if op.ResponseType != "" {
+			responseTypes[status] = op.ResponseType
+		}
+		if op.Response != nil {
+			schema = g.schemaFor(reflect.TypeOf(op.Response))
+		} else if op.ResponseType != "" {
+			schema = &Schema{Type: "string"}
+		} else {
+			schema = &Schema{Type: "object"}
+		}
+		byStatus[status] = appendSchema(byStatus[status], schema)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		schema := oneOf(byStatus[status])
+		if !endpoint.Raw {
+			schema = envelopeSchema(schema)
+		}
+		responseType := "application/json"
+		if endpoint.Raw && responseTypes[status] != "" {
+			responseType = responseTypes[status]
+		}
+		response := OpenAPIResponse{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{responseType: {Schema: schema}},
+		}
+		if etags[status] {
+			response.Headers = map[string]OpenAPIHeader{
+				"ETag": {Description: "Current version of the resource, for If-Match", Schema: &Schema{Type: "string"}},
+			}
+		}
+		result.Responses[strconv.Itoa(status)] = response
+	}
+
+	errorContent := map[string]MediaType{
+		"application/json": {Schema: &Schema{Ref: schemaRef("Error")}},
+		ProblemContentType: {Schema: &Schema{Ref: schemaRef("Problem")}},
+	}
+	if conditional {
+		result.Responses["412"] = OpenAPIResponse{Description: "The resource no longer matches If-Match", Content: errorContent}
+		result.Responses["428"] = OpenAPIResponse{Description: "If-Match is missing", Content: errorContent}
+	}
+	if idempotent {
+		result.Responses["409"] = OpenAPIResponse{Description: "A request with this Idempotency-Key is still in progress", Content: errorContent}
+		result.Responses["422"] = OpenAPIResponse{Description: "The Idempotency-Key was already used for a different request", Content: errorContent}
+	}
+	if endpoint.RateLimited {
+		result.Responses["429"] = OpenAPIResponse{
+			Description: "Rate limit exceeded",
+			Content:     errorContent,
+			Headers: map[string]OpenAPIHeader{
+				"Retry-After": {Description: "Seconds until the next request is allowed", Schema: &Schema{Type: "integer"}},
+			},
+		}
+	}
+	if endpoint.Auth {
+		result.Security = []map[string][]string{{"bearerAuth": {}}}
+		result.Responses["401"] = OpenAPIResponse{Description: "Unauthorized", Content: errorContent}
+	}
+	result.Responses["default"] = OpenAPIResponse{Description: "Error", Content: errorContent}
+
+	return result
+}
+
+// selectorParameter describes the query parameter that selects between
+// operations sharing a method, or nil when there is none
+func selectorParameter(ops []Operation) *OpenAPIParameter {
+	var name, def string
+	var values []string
+	required := true
+	for _, op := range ops {
+		key, value, ok := strings.Cut(op.Selector, "=")
+		if !ok {
+			// Selected by the mere presence of a parameter, or the default
+			required = false
+			continue
+		}
+		name = key
+		values = append(values, value)
+		if op.Default {
+			def = value
+			required = false
+		}
+	}
+	if name == "" {
+		return nil
+	}
+
+	schema := &Schema{Type: "string"}
+	for _, value := range values {
+		schema.Enum = append(schema.Enum, value)
+	}
+	if def != "" {
+		schema.Default = def
+	}
+	return &OpenAPIParameter{Name: name, In: "query", Required: required, Schema: schema}
+}
+
+// queryParams describes the query-tagged fields of a query DTO
+func queryParams(query interface{}) []Param {
+	if query == nil {
+		return nil
+	}
+	t := reflect.TypeOf(query)
+	var params []Param
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("query")
+		// Prefixed parameters such as buffer_* have no fixed name to document;
+		// the field they refine describes them instead
+		if name == "" || strings.HasSuffix(name, "*") {
+			continue
+		}
+		rules := field.Tag.Get("validate")
+		params = append(params, Param{
+			Name:        name,
+			Type:        (&schemaGenerator{}).schemaFor(field.Type).Type,
+			Description: field.Tag.Get("doc"),
+			Default:     field.Tag.Get("default"),
+			Required:    hasRule(rules, "required"),
+			Rules:       rules,
+		})
+	}
+	return params
+}
+
+func (p Param) openAPI() OpenAPIParameter {
+	schema := &Schema{Type: p.Type}
+	if schema.Type == "" {
+		schema.Type = "string"
+	}
+	applyRules(schema, p.Rules)
+	for _, value := range p.Enum {
+		schema.Enum = append(schema.Enum, typedValue(schema.Type, value))
+	}
+	if p.Default != "" {
+		schema.Default = typedValue(schema.Type, p.Default)
+	}
+	return OpenAPIParameter{
+		Name:        p.Name,
+		In:          "query",
+		Description: p.Description,
+		Required:    p.Required,
+		Schema:      schema,
+	}
+}
+
+// typedValue converts a query parameter value to its JSON type
+func typedValue(schemaType, value string) interface{} {
+	switch schemaType {
+	case "integer":
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// applyRules adds the constraints of validate rules to a schema
+func applyRules(schema *Schema, rules string) {
+	if rules == "" || schema.Ref != "" {
+		return
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			n := int(limit)
+			switch {
+			case schema.Type == "string" && name == "min":
+				schema.MinLength = &n
+			case schema.Type == "string":
+				schema.MaxLength = &n
+			case schema.Type == "array" && name == "min":
+				schema.MinItems = &n
+			case schema.Type == "array":
+				schema.MaxItems = &n
+			case name == "min":
+				schema.Minimum = &limit
+			default:
+				schema.Maximum = &limit
+			}
+		case "gt":
+			if limit, err := strconv.ParseFloat(arg, 64); err == nil {
+				schema.ExclusiveMinimum = &limit
+			}
+		case "oneof":
+			schema.Enum = nil
+			for _, option := range strings.Fields(arg) {
+				schema.Enum = append(schema.Enum, typedValue(schema.Type, option))
+			}
+		case "date":
+			schema.Format = "date"
+		case "datetime":
+			schema.Description = "YYYY-MM-DD date or RFC 3339 timestamp"
+		case "month":
+			schema.Pattern = `^\d{4}-(0[1-9]|1[0-2])$`
+		case "email":
+			schema.Format = "email"
+		}
+	}
+}
+
+// envelopeSchema wraps a data schema in the standard success Response
+func envelopeSchema(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"success":    {Type: "boolean"},
+			"data":       data,
+			"request_id": requestIDSchema(),
+			"timestamp":  {Type: "string", Format: "date-time"},
+		},
+		Required: []string{"success", "data", "timestamp"},
+	}
+}
+
+func errorSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"success":    {Type: "boolean"},
+			"error":      {Type: "string"},
+			"code":       errorCodeSchema(),
+			"details":    {Description: "Additional error context, such as the offending fields"},
+			"request_id": requestIDSchema(),
+			"timestamp":  {Type: "string", Format: "date-time"},
+		},
+		Required: []string{"success", "error", "code", "timestamp"},
+	}
+}
+
+// problemSchema describes RFC 7807 problem details, see Problem
+func problemSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"type":       {Type: "string", Format: "uri-reference"},
+			"title":      {Type: "string"},
+			"status":     {Type: "integer"},
+			"detail":     {Type: "string"},
+			"instance":   {Type: "string", Format: "uri-reference"},
+			"code":       errorCodeSchema(),
+			"details":    {Description: "Additional error context, such as the offending fields"},
+			"request_id": requestIDSchema(),
+			"timestamp":  {Type: "string", Format: "date-time"},
+		},
+		Required: []string{"type", "title", "status", "detail", "code", "timestamp"},
+	}
+}
+
+func requestIDSchema() *Schema {
+	return &Schema{Type: "string", Description: "Request ID, also sent in the " + RequestIDHeader + " header; quote it when reporting problems"}
+}
+
+func errorCodeSchema() *Schema {
+	schema := &Schema{Type: "string", Description: "Stable error code for clients to branch on"}
+	for _, code := range ErrorCodes {
+		schema.Enum = append(schema.Enum, code)
+	}
+	return schema
+}
+
+func oneOf(schemas []*Schema) *Schema {
+	if len(schemas) == 1 {
+		return schemas[0]
+	}
+	return &Schema{OneOf: schemas}
+}
+
+// appendSchema appends a schema unless an identical reference is already present
+func appendSchema(schemas []*Schema, schema *Schema) []*Schema {
+	if schema.Ref != "" {
+		for _, s := range schemas {
+			if s.Ref == schema.Ref {
+				return schemas
+			}
+		}
+	}
+	return append(schemas, schema)
+}
+
+func schemaRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+// schemaGenerator derives JSON Schemas from Go types, registering named
+// structs as reusable components
+type schemaGenerator struct {
+	schemas map[string]*Schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// Register before generating so self-referencing types terminate
+			g.schemas[t.Name()] = &Schema{}
+			*g.schemas[t.Name()] = *g.structSchema(t)
+		}
+		return &Schema{Ref: schemaRef(t.Name())}
+	}
+
+	// interface{} and anything else accepts any value
+	return &Schema{}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(schema, t)
+	return schema
+}
+
+// addFields adds a struct's fields following encoding/json rules: embedded
+// structs are flattened, "-" is skipped and omitempty fields are optional
+func (g *schemaGenerator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			g.addFields(schema, fieldType)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		property := g.schemaFor(field.Type)
+		rules, validated := field.Tag.Lookup("validate")
+		applyRules(property, rules)
+		schema.Properties[name] = property
+
+		// Declared rules decide whether input fields are required
+		if validated && hasRule(rules, "required") || !validated && !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIOperationsHaveSchemas(t *testing.T) {
+	for _, endpoint := range Endpoints {
+		for _, op := range endpoint.Operations {
+			if op.Response == nil && op.ResponseType == "" {
+				t.Errorf("%s %s %s declares no response", op.Method, endpoint.Path, op.Selector)
+			}
+		}
+	}
+
+	doc := BuildOpenAPI(Endpoints)
+	if len(doc.Paths) != len(Endpoints) {
+		t.Errorf("document has %d paths for %d endpoints", len(doc.Paths), len(Endpoints))
+	}
+	for _, endpoint := range Endpoints {
+		item := doc.Paths[endpoint.Path]
+		if item == nil {
+			t.Errorf("%s is missing from the document", endpoint.Path)
+			continue
+		}
+		operations := map[string]*OpenAPIOperation{
+			http.MethodGet: item.Get, http.MethodPost: item.Post, http.MethodPut: item.Put,
+			http.MethodPatch: item.Patch, http.MethodDelete: item.Delete,
+		}
+		for _, method := range endpointMethods(endpoint) {
+			op := operations[method]
+			if op == nil {
+				t.Errorf("%s %s is missing from the document", method, endpoint.Path)
+				continue
+			}
+			if op.RequestBody != nil {
+				for contentType, media := range op.RequestBody.Content {
+					checkSchema(t, doc, method+" "+endpoint.Path+" request "+contentType, media.Schema)
+				}
+			}
+			success := false
+			for status, response := range op.Responses {
+				success = success || strings.HasPrefix(status, "2")
+				if len(response.Content) == 0 {
+					t.Errorf("%s %s responds %s without content", method, endpoint.Path, status)
+				}
+				for contentType, media := range response.Content {
+					checkSchema(t, doc, method+" "+endpoint.Path+" "+status+" "+contentType, media.Schema)
+				}
+			}
+			if !success {
+				t.Errorf("%s %s has no success response", method, endpoint.Path)
+			}
+		}
+	}
+	for name, schema := range doc.Components.Schemas {
+		checkSchema(t, doc, name, schema)
+	}
+}
+
+// checkSchema fails unless schema is set and every reference within it
+// resolves to a component schema
+func checkSchema(t *testing.T, doc *OpenAPIDocument, where string, schema *Schema) {
+	t.Helper()
+	if schema == nil {
+		t.Errorf("%s has no schema", where)
+		return
+	}
+	if schema.Ref != "" {
+		if doc.Components.Schemas[strings.TrimPrefix(schema.Ref, schemaRef(""))] == nil {
+			t.Errorf("%s references undefined %s", where, schema.Ref)
+		}
+		return
+	}
+	for name, property := range schema.Properties {
+		checkSchema(t, doc, where+"."+name, property)
+	}
+	for _, alternative := range schema.OneOf {
+		checkSchema(t, doc, where, alternative)
+	}
+	if schema.Items != nil {
+		checkSchema(t, doc, where+"[]", schema.Items)
+	}
+	if schema.AdditionalProperties != nil {
+		checkSchema(t, doc, where+"{}", schema.AdditionalProperties)
+	}
+}
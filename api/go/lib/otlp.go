@@ -0,0 +1,292 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultOTLPPushInterval is how often metrics are pushed, unless
+// OTLP_METRICS_INTERVAL_SECONDS is set
+const DefaultOTLPPushInterval = 15 * time.Second
+
+// DefaultOTLPPushBatchSize is how many requests a serverless instance serves
+// between metrics pushes, unless OTLP_METRICS_BATCH_SIZE is set
+const DefaultOTLPPushBatchSize = 1
+
+// ServiceName identifies this API in OTLP resources
+const ServiceName = "budget-buddy-api-go"
+
+// serviceInstanceID tells the cumulative series of concurrent processes apart
+// at the collector
+var serviceInstanceID = NewID("instance")
+
+// OTLP/HTTP JSON payload, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding. 64-bit
+// integers are encoded as strings.
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Unit        string         `json:"unit,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE
+const otlpCumulative = 2
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberPoint `json:"dataPoints"`
+}
+
+type otlpNumberPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          float64         `json:"asDouble"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type otlpHistogramPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// attributes pairs a family's label names with a series' values
+func (f *family) attributes(values []string) []otlpAttribute {
+	attrs := make([]otlpAttribute, len(f.labels))
+	for i, label := range f.labels {
+		attrs[i] = otlpAttr(label, values[i])
+	}
+	return attrs
+}
+
+func (c *Counter) otlp(start, now time.Time) otlpMetric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sum := &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+	for _, s := range c.sortedSeries() {
+		sum.DataPoints = append(sum.DataPoints, otlpNumberPoint{
+			Attributes:        c.attributes(s.labels),
+			StartTimeUnixNano: unixNano(start),
+			TimeUnixNano:      unixNano(now),
+			AsDouble:          s.value,
+		})
+	}
+	return otlpMetric{Name: c.metric, Description: c.help, Sum: sum}
+}
+
+func (h *Histogram) otlp(start, now time.Time) otlpMetric {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	histogram := &otlpHistogram{AggregationTemporality: otlpCumulative}
+	for _, s := range h.sortedSeries() {
+		counts := make([]string, len(s.counts))
+		for i, count := range s.counts {
+			counts[i] = strconv.FormatUint(count, 10)
+		}
+		histogram.DataPoints = append(histogram.DataPoints, otlpHistogramPoint{
+			Attributes:        h.attributes(s.labels),
+			StartTimeUnixNano: unixNano(start),
+			TimeUnixNano:      unixNano(now),
+			Count:             strconv.FormatUint(s.count, 10),
+			Sum:               s.sum,
+			BucketCounts:      counts,
+			ExplicitBounds:    h.buckets,
+		})
+	}
+	unit := ""
+	if strings.HasSuffix(h.metric, "_seconds") {
+		unit = "s"
+	}
+	return otlpMetric{Name: h.metric, Description: h.help, Unit: unit, Histogram: histogram}
+}
+
+func (g *GaugeFunc) otlp(start, now time.Time) otlpMetric {
+	return otlpMetric{Name: g.metric, Description: g.help, Gauge: &otlpGauge{
+		DataPoints: []otlpNumberPoint{{TimeUnixNano: unixNano(now), AsDouble: g.fn()}},
+	}}
+}
+
+// PushOTLP sends every metric to an OTLP/HTTP collector, such as
+// http://localhost:4318/v1/metrics, as cumulative JSON
+func (reg *Registry) PushOTLP(ctx context.Context, endpoint string) error {
+	now := time.Now()
+	scope := otlpScopeMetrics{Scope: otlpTelemetryScope()}
+	for _, m := range reg.sorted() {
+		metric := m.otlp(reg.start, now)
+		// Skip metrics with nothing recorded yet
+		if (metric.Sum != nil && len(metric.Sum.DataPoints) == 0) || (metric.Histogram != nil && len(metric.Histogram.DataPoints) == 0) {
+			continue
+		}
+		scope.Metrics = append(scope.Metrics, metric)
+	}
+	payload, err := json.Marshal(otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpServiceResource(),
+		ScopeMetrics: []otlpScopeMetrics{scope},
+	}}})
+	if err != nil {
+		return err
+	}
+	return postOTLP(ctx, endpoint, payload)
+}
+
+var otlpClient = &http.Client{Timeout: 5 * time.Second}
+
+// otlpServiceResource describes this service in OTLP payloads
+func otlpServiceResource() otlpResource {
+	return otlpResource{Attributes: []otlpAttribute{
+		otlpAttr("service.name", ServiceName),
+		otlpAttr("service.version", APIVersion),
+		otlpAttr("service.instance.id", serviceInstanceID),
+	}}
+}
+
+// otlpTelemetryScope names the instrumentation in OTLP payloads
+func otlpTelemetryScope() otlpScope {
+	return otlpScope{Name: "github.com/budget-buddy/api/lib", Version: APIVersion}
+}
+
+// otlpEndpoint returns the signal-specific endpoint variable as is, or
+// OTEL_EXPORTER_OTLP_ENDPOINT with the signal's path appended
+func otlpEndpoint(signalVar, path string) string {
+	if endpoint := GetEnv(signalVar, ""); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := GetEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + path
+	}
+	return ""
+}
+
+// OTLPMetricsEndpoint returns the collector URL metrics are pushed to:
+// OTEL_EXPORTER_OTLP_METRICS_ENDPOINT as is, or OTEL_EXPORTER_OTLP_ENDPOINT
+// with /v1/metrics appended. Empty when pushing is disabled.
+func OTLPMetricsEndpoint() string {
+	return otlpEndpoint("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "/v1/metrics")
+}
+
+var otlpPushOnce sync.Once
+
+// Serverless reports whether this process is a serverless function, as on
+// Vercel, whose instances are frozen between requests so background work such
+// as the StartOTLPPush ticker does not run
+func Serverless() bool {
+	return GetEnv("VERCEL", "") != ""
+}
+
+var otlpPendingRequests atomic.Int64
+
+// PushOTLPBatch counts a finished request and, every OTLP_METRICS_BATCH_SIZE
+// requests (default 1), pushes Metrics to the collector before returning. It
+// stands in for StartOTLPPush on serverless instances: the counts are
+// cumulative, so a batch left unpushed when the instance is reclaimed only
+// loses the requests since the last push. Failed pushes are logged.
+func PushOTLPBatch(ctx context.Context) {
+	endpoint := OTLPMetricsEndpoint()
+	if endpoint == "" {
+		return
+	}
+	batch := int64(DefaultOTLPPushBatchSize)
+	if size, err := strconv.Atoi(GetEnv("OTLP_METRICS_BATCH_SIZE", "")); err == nil && size > 0 {
+		batch = int64(size)
+	}
+	if otlpPendingRequests.Add(1)%batch != 0 {
+		return
+	}
+	if err := Metrics.PushOTLP(ctx, endpoint); err != nil {
+		Logger.Warn("OTLP metrics push failed", "endpoint", endpoint, "error", err.Error())
+	}
+}
+
+// StartOTLPPush pushes Metrics to the collector every interval until ctx is
+// done, when an OTLP endpoint is configured. It only starts once per process;
+// cmd/server starts it at startup, and Instrument calls it on the first
+// request outside serverless platforms, which push with PushOTLPBatch instead.
+// Failed pushes are logged and retried on the next tick.
+func StartOTLPPush(ctx context.Context) {
+	endpoint := OTLPMetricsEndpoint()
+	if endpoint == "" {
+		return
+	}
+	otlpPushOnce.Do(func() {
+		interval := DefaultOTLPPushInterval
+		if seconds, err := strconv.Atoi(GetEnv("OTLP_METRICS_INTERVAL_SECONDS", "")); err == nil && seconds > 0 {
+			interval = time.Duration(seconds) * time.Second
+		}
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := Metrics.PushOTLP(ctx, endpoint); err != nil {
+						Logger.Warn("OTLP metrics push failed", "endpoint", endpoint, "error", err.Error())
+					}
+				}
+			}
+		}()
+	})
+}
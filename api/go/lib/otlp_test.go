@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// otlpReceiver is a collector that keeps the payloads posted to it by path
+type otlpReceiver struct {
+	URL      string
+	mu       sync.Mutex
+	payloads map[string][][]byte
+}
+
+func newOTLPReceiver(t *testing.T) *otlpReceiver {
+	t.Helper()
+	receiver := &otlpReceiver{payloads: map[string][][]byte{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		receiver.payloads[r.URL.Path] = append(receiver.payloads[r.URL.Path], body)
+		receiver.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	receiver.URL = server.URL
+	return receiver
+}
+
+// received returns the payloads posted to path so far
+func (rec *otlpReceiver) received(path string) [][]byte {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([][]byte(nil), rec.payloads[path]...)
+}
+
+func TestPushOTLPBatchOnServerless(t *testing.T) {
+	receiver := newOTLPReceiver(t)
+	t.Setenv("VERCEL", "1")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", receiver.URL)
+	t.Setenv("OTLP_METRICS_BATCH_SIZE", "2")
+	otlpPendingRequests.Store(0)
+
+	handler := Instrument()(func(w http.ResponseWriter, r *http.Request) {})
+	pushes := []int{0, 1, 1, 2}
+	for i, want := range pushes {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/go/health", nil))
+		if got := len(receiver.received("/v1/metrics")); got != want {
+			t.Fatalf("after request %d: %d pushes, want %d", i+1, got, want)
+		}
+	}
+
+	var payload otlpMetricsRequest
+	if err := json.Unmarshal(receiver.received("/v1/metrics")[0], &payload); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, attr := range payload.ResourceMetrics[0].Resource.Attributes {
+		if attr.Key == "service.instance.id" && attr.Value.StringValue != nil && *attr.Value.StringValue == serviceInstanceID {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("resource %+v has no service.instance.id", payload.ResourceMetrics[0].Resource)
+	}
+}
@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MergePatchContentType is the media type of an RFC 7396 JSON merge patch
+const MergePatchContentType = "application/merge-patch+json"
+
+// ETag returns the entity tag of a resource last modified at updatedAt
+func ETag(updatedAt time.Time) string {
+	return `"` + strconv.FormatInt(updatedAt.UnixNano(), 36) + `"`
+}
+
+// CheckIfMatch evaluates the request's If-Match precondition against the
+// resource's current ETag. When it fails a 412 response carrying the current
+// ETag is written and false is returned. If-Match is required, so a write
+// can't silently overwrite a change it never saw: without it the response is
+// 428, also carrying the current ETag. "*" matches any version.
+func CheckIfMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := strings.Join(r.Header.Values("If-Match"), ",")
+	if header == "" {
+		w.Header().Set("ETag", etag)
+		WriteError(w, NewError(http.StatusPreconditionRequired, CodePreconditionRequired, "Precondition required", map[string]string{
+			"etag": etag,
+			"hint": "Send the ETag of the version you are changing as If-Match",
+		}))
+		return false
+	}
+	if matchesETag(header, etag) {
+		return true
+	}
+
+	w.Header().Set("ETag", etag)
+	WriteError(w, NewError(http.StatusPreconditionFailed, CodePreconditionFailed, "Precondition failed", map[string]string{
+		"etag": etag,
+		"hint": "The resource was changed by another request; fetch it again and reapply your changes",
+	}))
+	return false
+}
+
+// matchesETag reports whether an If-Match header value matches etag using
+// strong comparison, so weak tags never match
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// BindMergePatch applies the request's JSON merge patch to v, which holds the
+// resource's current editable fields, then validates the result. Members set
+// to null are cleared; unknown members are rejected. On failure it writes a
+// 400 or 415 response and returns false, leaving v unusable.
+func BindMergePatch(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	_, span := StartSpan(r.Context(), "validate patch", SpanKindInternal)
+	defer span.End()
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != MergePatchContentType && mediaType != "application/json" {
+		WriteError(w, NewError(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "Unsupported media type", map[string]interface{}{
+			"accepted": []string{MergePatchContentType, "application/json"},
+		}))
+		return false
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, BadRequestError("Unable to read request body", nil))
+		return false
+	}
+
+	doc, err := json.Marshal(v)
+	if err != nil {
+		WriteError(w, InternalError("Unable to apply patch", err))
+		return false
+	}
+
+	merged, err := MergePatch(doc, patch)
+	if err == nil {
+		rv := reflect.ValueOf(v).Elem()
+		rv.Set(reflect.Zero(rv.Type()))
+		err = decodeStrict(bytes.NewReader(merged), v)
+	}
+	return checkBody(w, err, v)
+}
+
+// MergePatch applies an RFC 7396 merge patch to a JSON document. The patch
+// must be a JSON object.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := decodeNumbers(doc, &target); err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(patch)) == 0 {
+		return nil, errors.New("request body is empty")
+	}
+	var p interface{}
+	if err := decodeNumbers(patch, &p); err != nil {
+		return nil, err
+	}
+	if _, ok := p.(map[string]interface{}); !ok {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// decodeNumbers decodes JSON keeping numbers exact so they survive re-encoding
+func decodeNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("request body must contain a single JSON object")
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7396 appendix A, plus the errors
+	tests := []struct {
+		doc, patch, want string
+		wantErr          bool
+	}{
+		{doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{doc: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{doc: `{"e":null}`, patch: `{"a":1}`, want: `{"a":1,"e":null}`},
+		{doc: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+		{doc: `{"amount":100.10}`, patch: `{"note":"x"}`, want: `{"amount":100.10,"note":"x"}`},
+		{doc: `{"a":"b"}`, patch: `["c"]`, wantErr: true},
+		{doc: `{"a":"b"}`, patch: `"c"`, wantErr: true},
+		{doc: `{"a":"b"}`, patch: ` `, wantErr: true},
+		{doc: `{"a":"b"}`, patch: `{"a":1}{"b":2}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("MergePatch(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindMergePatch(t *testing.T) {
+	current := CreateTransactionInput{Amount: 10, Category: "Food", Type: "expense", Date: "2024-01-15", Merchant: "Cafe"}
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		want        CreateTransactionInput
+		status      int // 0 when the patch applies
+	}{
+		{"changes a field", MergePatchContentType, `{"amount":12.5}`, CreateTransactionInput{Amount: 12.5, Category: "Food", Type: "expense", Date: "2024-01-15", Merchant: "Cafe"}, 0},
+		{"clears an optional field", MergePatchContentType, `{"merchant":null}`, CreateTransactionInput{Amount: 10, Category: "Food", Type: "expense", Date: "2024-01-15"}, 0},
+		{"plain JSON is accepted", "application/json", `{"category":"Dining"}`, CreateTransactionInput{Amount: 10, Category: "Dining", Type: "expense", Date: "2024-01-15", Merchant: "Cafe"}, 0},
+		{"clearing a required field fails validation", MergePatchContentType, `{"category":null}`, CreateTransactionInput{}, http.StatusBadRequest},
+		{"invalid result", MergePatchContentType, `{"type":"gift"}`, CreateTransactionInput{}, http.StatusBadRequest},
+		{"unknown member", MergePatchContentType, `{"colour":"red"}`, CreateTransactionInput{}, http.StatusBadRequest},
+		{"not an object", MergePatchContentType, `[1]`, CreateTransactionInput{}, http.StatusBadRequest},
+		{"unsupported media type", "text/plain", `{}`, CreateTransactionInput{}, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/api/go/transactions?id=tx-1", strings.NewReader(tt.patch))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			input := current
+			ok := BindMergePatch(w, r, &input)
+			if tt.status != 0 {
+				if ok || w.Code != tt.status {
+					t.Errorf("ok %v with status %d, want status %d", ok, w.Code, tt.status)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("patch rejected: %s", w.Body)
+			}
+			if !reflect.DeepEqual(input, tt.want) {
+				t.Errorf("patched %+v, want %+v", input, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	etag := ETag(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name    string
+		ifMatch []string
+		status  int // 0 when the precondition holds
+	}{
+		{"matching", []string{etag}, 0},
+		{"any version", []string{"*"}, 0},
+		{"one of a list", []string{`"other", ` + etag}, 0},
+		{"one of several headers", []string{`"other"`, etag}, 0},
+		{"missing", nil, http.StatusPreconditionRequired},
+		{"stale", []string{`"other"`}, http.StatusPreconditionFailed},
+		{"weak tags never match", []string{"W/" + etag}, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/api/go/transactions?id=tx-1", nil)
+			for _, value := range tt.ifMatch {
+				r.Header.Add("If-Match", value)
+			}
+			w := httptest.NewRecorder()
+			ok := CheckIfMatch(w, r, etag)
+			if tt.status == 0 {
+				if !ok {
+					t.Errorf("precondition failed with status %d", w.Code)
+				}
+				return
+			}
+			if ok || w.Code != tt.status {
+				t.Fatalf("ok %v with status %d, want status %d", ok, w.Code, tt.status)
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag header %q, want the current %q", got, etag)
+			}
+			var body Response
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != CodeForStatus(tt.status) {
+				t.Errorf("body %s, want code %s", w.Body, CodeForStatus(tt.status))
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package lib
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rate limiting algorithms
+const (
+	// SlidingWindow allows Limit requests in any Window-long period
+	SlidingWindow = "sliding_window"
+	// TokenBucket allows bursts of up to Limit requests, refilling at Limit per Window
+	TokenBucket = "token_bucket"
+)
+
+// Rate limit keys
+const (
+	// RateLimitByUser limits each authenticated user, falling back to the client IP
+	RateLimitByUser = "user"
+	// RateLimitByIP limits each client IP
+	RateLimitByIP = "ip"
+)
+
+// DefaultRateLimitRPM is the default requests per minute, unless RATE_LIMIT_RPM is set
+const DefaultRateLimitRPM = 60
+
+// RateLimitPolicy configures the rate limit stage of CreateHandler
+type RateLimitPolicy struct {
+	Name      string        // key prefix shared by handlers drawing on the same allowance
+	Limit     int           // requests allowed per window
+	Window    time.Duration // length of the window
+	Algorithm string        // SlidingWindow (default) or TokenBucket
+	KeyBy     string        // RateLimitByUser (default) or RateLimitByIP
+}
+
+// APIRateLimit returns the general API policy: RATE_LIMIT_RPM requests per
+// minute per user, matching the Node API's "api" limit
+func APIRateLimit() *RateLimitPolicy {
+	rpm, err := strconv.Atoi(GetEnv("RATE_LIMIT_RPM", ""))
+	if err != nil || rpm <= 0 {
+		rpm = DefaultRateLimitRPM
+	}
+	return &RateLimitPolicy{Name: "api", Limit: rpm, Window: time.Minute}
+}
+
+// RateLimitResult represents the outcome of counting one request
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the allowance is fully available again
+	RetryAfter time.Duration // until the next request is allowed, when denied
+}
+
+// CheckRateLimit counts the request against policy, setting the RateLimit-*
+// headers. When the limit is exceeded a 429 response with Retry-After is
+// written and false is returned. If the store is unavailable the request is
+// allowed, so a cache outage does not take the API down with it.
+func CheckRateLimit(w http.ResponseWriter, r *http.Request, policy *RateLimitPolicy) bool {
+	result, err := RateLimits.Take(r.Context(), rateLimitKey(r, policy), *policy, time.Now())
+	if err != nil {
+		return true
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	w.Header().Set("RateLimit-Policy", strconv.Itoa(policy.Limit)+";w="+strconv.Itoa(ceilSeconds(policy.Window)))
+	if result.Allowed {
+		return true
+	}
+
+	RateLimitRejections.Inc(policy.Name)
+	retryAfter := ceilSeconds(result.RetryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	WriteError(w, RateLimitedError(result.Limit, retryAfter))
+	return false
+}
+
+// rateLimitKey identifies who a request is counted against
+func rateLimitKey(r *http.Request, policy *RateLimitPolicy) string {
+	if policy.KeyBy != RateLimitByIP {
+		if user, ok := GetUserFromContext(r); ok {
+			return "ratelimit:" + policy.Name + ":user:" + user.ID
+		}
+	}
+	return "ratelimit:" + policy.Name + ":ip:" + GetClientIP(r)
+}
+
+// ceilSeconds rounds a duration up to whole seconds, at least 1
+func ceilSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// RateLimitStore counts requests. Implementations must apply both algorithms
+// atomically so concurrent instances share one allowance.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error)
+}
+
+// RateLimits is the rate limit store used by CreateHandler. Serverless
+// instances do not share memory, so when REDIS_URL is set counts are kept in
+// that Redis-protocol server instead.
+var RateLimits RateLimitStore = InstrumentRateLimitStore(newRateLimitStore())
+
+func newRateLimitStore() RateLimitStore {
+	if client := newRedisClient("ratelimit"); client != nil {
+		return &RedisRateLimitStore{client: client}
+	}
+	return NewMemoryRateLimitStore()
+}
+
+// memoryRateLimitPruneInterval is how often MemoryRateLimitStore drops the
+// state of keys that have gone idle
+const memoryRateLimitPruneInterval = time.Minute
+
+// MemoryRateLimitStore is an in-memory RateLimitStore
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	windows  map[string][]time.Time  // sliding window request times, oldest first
+	buckets  map[string]*tokenBucket // token bucket state
+	idle     map[string]time.Time    // when each key's allowance is fully restored
+	prunedAt time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory rate limit store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		windows: make(map[string][]time.Time),
+		buckets: make(map[string]*tokenBucket),
+		idle:    make(map[string]time.Time),
+	}
+}
+
+// Take counts a request against key
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+	// Either algorithm is back to a full allowance one window after the last request
+	s.idle[key] = now.Add(policy.Window)
+	if policy.Algorithm == TokenBucket {
+		return s.takeToken(key, policy, now), nil
+	}
+	return s.takeWindow(key, policy, now), nil
+}
+
+// prune drops the state of keys whose allowance has been fully restored,
+// scanning at most once per memoryRateLimitPruneInterval
+func (s *MemoryRateLimitStore) prune(now time.Time) {
+	if now.Sub(s.prunedAt) < memoryRateLimitPruneInterval {
+		return
+	}
+	s.prunedAt = now
+	for key, idle := range s.idle {
+		if !now.Before(idle) {
+			delete(s.windows, key)
+			delete(s.buckets, key)
+			delete(s.idle, key)
+		}
+	}
+}
+
+func (s *MemoryRateLimitStore) takeWindow(key string, policy RateLimitPolicy, now time.Time) RateLimitResult {
+	requests := s.windows[key]
+	start := now.Add(-policy.Window)
+	kept := requests[:0]
+	for _, t := range requests {
+		if t.After(start) {
+			kept = append(kept, t)
+		}
+	}
+
+	result := RateLimitResult{Limit: policy.Limit, Reset: policy.Window}
+	if len(kept) > 0 {
+		result.Reset = kept[0].Add(policy.Window).Sub(now)
+	}
+	if len(kept) >= policy.Limit {
+		s.windows[key] = kept
+		result.RetryAfter = result.Reset
+		return result
+	}
+
+	s.windows[key] = append(kept, now)
+	result.Allowed = true
+	result.Remaining = policy.Limit - len(kept) - 1
+	return result
+}
+
+func (s *MemoryRateLimitStore) takeToken(key string, policy RateLimitPolicy, now time.Time) RateLimitResult {
+	rate := float64(policy.Limit) / float64(policy.Window) // tokens per nanosecond
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(policy.Limit), updated: now}
+		s.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(policy.Limit), bucket.tokens+float64(now.Sub(bucket.updated))*rate)
+	bucket.updated = now
+
+	result := RateLimitResult{Limit: policy.Limit}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - bucket.tokens) / rate))
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = time.Duration(math.Ceil((float64(policy.Limit) - bucket.tokens) / rate))
+	return result
+}
+
+// RedisRateLimitStore is a RateLimitStore backed by a Redis-protocol server.
+// Each algorithm runs as a Lua script so concurrent instances stay consistent.
+type RedisRateLimitStore struct {
+	client *RESPClient
+}
+
+// NewRedisRateLimitStore creates a rate limit store for a redis:// or rediss:// URL
+func NewRedisRateLimitStore(redisURL string) (*RedisRateLimitStore, error) {
+	client, err := NewRESPClient(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisRateLimitStore{client: client}, nil
+}
+
+// Ping checks the server answers PING
+func (s *RedisRateLimitStore) Ping(ctx context.Context) error {
+	return s.client.Ping()
+}
+
+// slidingWindowScript keeps request times in a sorted set.
+// Returns {allowed, remaining, reset ms, retry after ms}.
+const slidingWindowScript = `
+local now, window, limit = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local reset = window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then reset = tonumber(oldest[2]) + window - now end
+if count >= limit then return {0, 0, reset, reset} end
+redis.call('ZADD', KEYS[1], now, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], window)
+return {1, limit - count - 1, reset, 0}
+`
+
+// tokenBucketScript keeps the token count and refill time in a hash.
+// Returns {allowed, remaining, reset ms, retry after ms}.
+const tokenBucketScript = `
+local now, window, limit = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local rate = limit / window
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or limit
+local updated = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + (now - updated) * rate)
+local allowed, retry = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate)
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, math.floor(tokens), math.ceil((limit - tokens) / rate), retry}
+`
+
+// Take counts a request against key
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error) {
+	script := slidingWindowScript
+	if policy.Algorithm == TokenBucket {
+		script = tokenBucketScript
+	}
+
+	reply, err := s.client.Do("EVAL", script, "1", key,
+		strconv.FormatInt(now.UnixMilli(), 10),
+		strconv.FormatInt(policy.Window.Milliseconds(), 10),
+		strconv.Itoa(policy.Limit),
+		NewID(strconv.FormatInt(now.UnixNano(), 36)),
+	)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 4 {
+		return RateLimitResult{}, RESPError("unexpected rate limit script reply")
+	}
+	numbers := make([]int64, len(values))
+	for i, value := range values {
+		if numbers[i], ok = value.(int64); !ok {
+			return RateLimitResult{}, RESPError("unexpected rate limit script reply")
+		}
+	}
+
+	return RateLimitResult{
+		Allowed:    numbers[0] == 1,
+		Limit:      policy.Limit,
+		Remaining:  int(numbers[1]),
+		Reset:      time.Duration(numbers[2]) * time.Millisecond,
+		RetryAfter: time.Duration(numbers[3]) * time.Millisecond,
+	}, nil
+}
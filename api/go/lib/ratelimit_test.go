@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitSlidingWindow(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	policy := RateLimitPolicy{Name: "test", Limit: 3, Window: time.Minute}
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	take := func(at time.Duration) RateLimitResult {
+		result, err := store.Take(context.Background(), "key", policy, start.Add(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	for i, at := range []time.Duration{0, 10 * time.Second, 20 * time.Second} {
+		if result := take(at); !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("request %d: %+v, want allowed with %d remaining", i+1, result, 2-i)
+		}
+	}
+	// The oldest request leaves the window at 1m, 30s from now
+	if result := take(30 * time.Second); result.Allowed || result.RetryAfter != 30*time.Second {
+		t.Errorf("over the limit: %+v, want denied with RetryAfter 30s", result)
+	}
+	if result := take(time.Minute + time.Second); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("after the oldest expired: %+v, want allowed with 0 remaining", result)
+	}
+}
+
+func TestMemoryRateLimitTokenBucket(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	policy := RateLimitPolicy{Name: "test", Limit: 2, Window: time.Minute, Algorithm: TokenBucket}
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	take := func(at time.Duration) RateLimitResult {
+		result, err := store.Take(context.Background(), "key", policy, start.Add(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// A full bucket allows a burst of Limit requests
+	if result := take(0); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("first request: %+v, want allowed with 1 remaining", result)
+	}
+	if result := take(0); !result.Allowed || result.Remaining != 0 || result.Reset != time.Minute {
+		t.Errorf("second request: %+v, want allowed with 0 remaining and Reset 1m", result)
+	}
+	// Tokens refill at one per 30s
+	if result := take(10 * time.Second); result.Allowed || result.RetryAfter != 20*time.Second {
+		t.Errorf("empty bucket: %+v, want denied with RetryAfter 20s", result)
+	}
+	if result := take(30 * time.Second); !result.Allowed {
+		t.Errorf("after a refill: %+v, want allowed", result)
+	}
+}
+
+func TestMemoryRateLimitPrunesIdleKeys(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	window := RateLimitPolicy{Name: "test", Limit: 5, Window: time.Minute}
+	bucket := RateLimitPolicy{Name: "test", Limit: 5, Window: time.Hour, Algorithm: TokenBucket}
+	store.Take(context.Background(), "window", window, start)
+	store.Take(context.Background(), "bucket", bucket, start)
+
+	store.Take(context.Background(), "other", window, start.Add(2*time.Minute))
+	if _, ok := store.windows["window"]; ok {
+		t.Error("idle sliding window key was kept")
+	}
+	if _, ok := store.buckets["bucket"]; !ok {
+		t.Error("token bucket key was dropped before its bucket refilled")
+	}
+
+	store.Take(context.Background(), "other", window, start.Add(time.Hour))
+	if _, ok := store.buckets["bucket"]; ok {
+		t.Error("idle token bucket key was kept")
+	}
+	if len(store.idle) != 1 {
+		t.Errorf("%d keys tracked, want only the active one", len(store.idle))
+	}
+}
+
+func TestGetClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		want       string
+	}{
+		{"no proxy configured", "", "203.0.113.7:1234", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"untrusted peer", "10.0.0.0/8", "203.0.113.7:1234", "198.51.100.1", "", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.0/8", "10.0.0.5:1234", "198.51.100.1", "", "198.51.100.1"},
+		{"spoofed entries before the proxy's", "10.0.0.5", "10.0.0.5:1234", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.0/8", "10.0.0.5:1234", "1.2.3.4, 198.51.100.1, 10.0.0.9", "", "198.51.100.1"},
+		{"only proxies forwarded", "10.0.0.0/8", "10.0.0.5:1234", "10.0.0.8, 10.0.0.9", "", "10.0.0.8"},
+		{"real IP from a trusted proxy", "10.0.0.5", "10.0.0.5:1234", "", "198.51.100.2", "198.51.100.2"},
+		{"no forwarding headers", "10.0.0.5", "10.0.0.5:1234", "", "", "10.0.0.5"},
+		{"any peer", "*", "203.0.113.7:1234", "198.51.100.1", "", "198.51.100.1"},
+		{"IPv6 proxy", "2001:db8::/32", "[2001:db8::1]:1234", "198.51.100.1", "", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.trusted)
+			r := httptest.NewRequest("GET", "/api/go/transactions", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := GetClientIP(r); got != tt.want {
+				t.Errorf("GetClientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
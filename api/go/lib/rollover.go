@@ -0,0 +1,174 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Budget rollover policies
+const (
+	RolloverNone    = "none"    // every period starts from the base amount
+	RolloverSurplus = "surplus" // unspent money is added to the next period
+	RolloverDeficit = "deficit" // overspending is taken from the next period
+	RolloverBoth    = "both"    // surplus and deficit both carry forward
+)
+
+// ValidRolloverPolicy reports whether policy is a known rollover policy
+func ValidRolloverPolicy(policy string) bool {
+	switch policy {
+	case RolloverNone, RolloverSurplus, RolloverDeficit, RolloverBoth:
+		return true
+	}
+	return false
+}
+
+// BudgetPeriod represents a budget's allowance and spend for one period
+type BudgetPeriod struct {
+	StartDate   string  `json:"start_date"`
+	EndDate     string  `json:"end_date"`
+	BaseAmount  float64 `json:"base_amount"`
+	CarriedIn   float64 `json:"carried_in"`
+	Allowance   float64 `json:"allowance"`
+	Spent       float64 `json:"spent"`
+	Remaining   float64 `json:"remaining"`
+	CarriedOut  float64 `json:"carried_out"`
+	Utilization float64 `json:"utilization"` // percent of allowance spent
+	AlertActive bool    `json:"alert_active"`
+}
+
+// BudgetStatus represents a budget together with its current period
+type BudgetStatus struct {
+	Budget
+	CurrentPeriod BudgetPeriod `json:"current_period"`
+}
+
+// ComputeBudgetHistory walks a budget's periods from its start date up to
+// and including the period containing asOf, carrying surplus and/or deficit
+// between periods according to the budget's rollover policy. Periods starting
+// more than MaxMonthOffsetYears before asOf are not replayed, so the carry
+// starts from zero after them.
+func ComputeBudgetHistory(budget Budget, transactions []Transaction, asOf time.Time) ([]BudgetPeriod, error) {
+	policy := budget.RolloverPolicy
+	if policy == "" {
+		policy = RolloverNone
+	}
+	if !ValidRolloverPolicy(policy) {
+		return nil, fmt.Errorf("invalid rollover policy %q", policy)
+	}
+	if budget.Period != FrequencyWeekly && budget.Period != FrequencyMonthly && budget.Period != FrequencyYearly {
+		return nil, fmt.Errorf("invalid budget period %q", budget.Period)
+	}
+
+	start := truncateDay(budget.StartDate)
+	if start.After(asOf) {
+		return []BudgetPeriod{}, nil
+	}
+
+	var history []BudgetPeriod
+	var carry float64
+	for n := firstPeriodFrom(start, budget.Period, asOf.AddDate(-MaxMonthOffsetYears, 0, 0)); ; n++ {
+		from := advance(start, budget.Period, n)
+		to := advance(start, budget.Period, n+1)
+		if from.After(asOf) || (!budget.EndDate.IsZero() && !from.Before(budget.EndDate)) {
+			break
+		}
+
+		var spent float64
+		for _, t := range transactions {
+			if t.Type != "expense" || !strings.EqualFold(t.Category, budget.Category) {
+				continue
+			}
+			if !t.Date.Before(from) && t.Date.Before(to) {
+				spent += t.Amount
+			}
+		}
+
+		allowance := budget.Amount + carry
+		remaining := allowance - spent
+		period := BudgetPeriod{
+			StartDate:   from.Format("2006-01-02"),
+			EndDate:     to.AddDate(0, 0, -1).Format("2006-01-02"),
+			BaseAmount:  budget.Amount,
+			CarriedIn:   roundCents(carry),
+			Allowance:   roundCents(allowance),
+			Spent:       roundCents(spent),
+			Remaining:   roundCents(remaining),
+			Utilization: utilization(spent, allowance),
+		}
+		if budget.AlertThreshold > 0 {
+			period.AlertActive = period.Utilization >= float64(budget.AlertThreshold)
+		}
+
+		carry = carryForward(remaining, policy, budget.RolloverCap)
+		period.CarriedOut = roundCents(carry)
+		history = append(history, period)
+	}
+
+	return history, nil
+}
+
+// firstPeriodFrom returns the index of the first period of a budget starting
+// at start that begins on or after earliest
+func firstPeriodFrom(start time.Time, period string, earliest time.Time) int {
+	if !start.Before(earliest) {
+		return 0
+	}
+	// Estimate from below, then step to the exact period
+	var n int
+	switch period {
+	case FrequencyWeekly:
+		n = int((earliest.Unix() - start.Unix()) / (7 * 24 * 60 * 60))
+	case FrequencyMonthly:
+		n = (earliest.Year()-start.Year())*12 + int(earliest.Month()) - int(start.Month())
+	default:
+		n = earliest.Year() - start.Year()
+	}
+	n = max(n-1, 0)
+	for advance(start, period, n).Before(earliest) {
+		n++
+	}
+	return n
+}
+
+// CurrentBudgetStatus returns a budget with the period containing asOf
+func CurrentBudgetStatus(budget Budget, transactions []Transaction, asOf time.Time) (BudgetStatus, error) {
+	status := BudgetStatus{Budget: budget}
+	history, err := ComputeBudgetHistory(budget, transactions, asOf)
+	if err != nil {
+		return status, err
+	}
+	if len(history) > 0 {
+		status.CurrentPeriod = history[len(history)-1]
+	}
+	return status, nil
+}
+
+// carryForward returns the amount a period's remaining balance contributes
+// to the next period under a rollover policy, limited to cap when set
+func carryForward(remaining float64, policy string, cap float64) float64 {
+	var carry float64
+	switch {
+	case remaining > 0 && (policy == RolloverSurplus || policy == RolloverBoth):
+		carry = remaining
+	case remaining < 0 && (policy == RolloverDeficit || policy == RolloverBoth):
+		carry = remaining
+	}
+	if cap > 0 && math.Abs(carry) > cap {
+		carry = math.Copysign(cap, carry)
+	}
+	return carry
+}
+
+// utilization returns spent as a percentage of allowance. An exhausted or
+// negative allowance with any spending counts as fully utilised.
+func utilization(spent, allowance float64) float64 {
+	if allowance <= 0 {
+		if spent > 0 {
+			return 100
+		}
+		return 0
+	}
+	return math.Round(spent/allowance*1000) / 10
+}
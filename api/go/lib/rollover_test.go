@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComputeBudgetHistoryMonthEndAnchor(t *testing.T) {
+	budget := Budget{Category: "Groceries", Amount: 400, Period: FrequencyMonthly, StartDate: date(2024, 1, 31), RolloverPolicy: RolloverSurplus}
+	transactions := []Transaction{
+		{Type: "expense", Category: "Groceries", Amount: 100, Date: date(2024, 2, 15)},
+		// Falls on the clamped start of the second period rather than in a skipped March
+		{Type: "expense", Category: "Groceries", Amount: 50, Date: date(2024, 2, 29)},
+		{Type: "expense", Category: "Groceries", Amount: 450, Date: date(2024, 3, 31)},
+	}
+
+	history, err := ComputeBudgetHistory(budget, transactions, date(2024, 5, 15))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type period struct {
+		Start, End       string
+		Spent, CarriedIn float64
+	}
+	var got []period
+	for _, p := range history {
+		got = append(got, period{p.StartDate, p.EndDate, p.Spent, p.CarriedIn})
+	}
+	want := []period{
+		{"2024-01-31", "2024-02-28", 100, 0},
+		{"2024-02-29", "2024-03-30", 50, 300},
+		{"2024-03-31", "2024-04-29", 450, 650},
+		{"2024-04-30", "2024-05-30", 0, 600},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("periods\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestComputeBudgetHistoryBoundsReplay(t *testing.T) {
+	asOf := date(2024, 6, 15)
+	tests := []struct {
+		period string
+		first  string
+	}{
+		{FrequencyWeekly, "2019-06-17"},
+		{FrequencyMonthly, "2019-07-01"},
+		{FrequencyYearly, "2020-01-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			// 0001-01-01 is a Monday, so weeks start on Mondays
+			budget := Budget{Category: "Groceries", Amount: 100, Period: tt.period, StartDate: date(1, 1, 1), RolloverPolicy: RolloverSurplus}
+			history, err := ComputeBudgetHistory(budget, nil, asOf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) == 0 {
+				t.Fatal("no history")
+			}
+			if history[0].StartDate != tt.first || history[0].CarriedIn != 0 {
+				t.Errorf("history starts with %+v, want %s with nothing carried in", history[0], tt.first)
+			}
+			if last := history[len(history)-1]; last.StartDate > "2024-06-15" || last.EndDate < "2024-06-15" {
+				t.Errorf("history ends with %s to %s, want the period containing 2024-06-15", last.StartDate, last.EndDate)
+			}
+		})
+	}
+}
+
+func TestComputeBudgetHistoryPolicies(t *testing.T) {
+	// Spend 150 of 100 in January and 20 in February
+	transactions := []Transaction{
+		{Type: "expense", Category: "Fun", Amount: 150, Date: date(2024, 1, 10)},
+		{Type: "expense", Category: "fun", Amount: 20, Date: date(2024, 2, 10)},
+		{Type: "income", Category: "Fun", Amount: 1000, Date: date(2024, 2, 11)},
+	}
+	tests := []struct {
+		policy  string
+		cap     float64
+		carried []float64 // carried into February and March
+	}{
+		{RolloverNone, 0, []float64{0, 0}},
+		{RolloverSurplus, 0, []float64{0, 80}},
+		{RolloverDeficit, 0, []float64{-50, 0}},
+		{RolloverBoth, 0, []float64{-50, 30}},
+		{RolloverBoth, 25, []float64{-25, 25}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			budget := Budget{Category: "Fun", Amount: 100, Period: FrequencyMonthly, StartDate: date(2024, 1, 1), RolloverPolicy: tt.policy, RolloverCap: tt.cap}
+			history, err := ComputeBudgetHistory(budget, transactions, date(2024, 3, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != 3 {
+				t.Fatalf("got %d periods, want 3", len(history))
+			}
+			if got := []float64{history[1].CarriedIn, history[2].CarriedIn}; !reflect.DeepEqual(got, tt.carried) {
+				t.Errorf("carried in %v, want %v", got, tt.carried)
+			}
+		})
+	}
+}
+
+func TestComputeBudgetHistoryErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget Budget
+	}{
+		{"unknown policy", Budget{Period: FrequencyMonthly, RolloverPolicy: "sometimes"}},
+		{"unsupported period", Budget{Period: FrequencyQuarterly}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ComputeBudgetHistory(tt.budget, nil, date(2024, 1, 1)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
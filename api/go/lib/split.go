@@ -0,0 +1,529 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Expense split methods
+const (
+	SplitEqual   = "equal"   // divided evenly between participants
+	SplitShares  = "shares"  // divided in proportion to each participant's shares
+	SplitExact   = "exact"   // each participant owes a given amount
+	SplitPercent = "percent" // each participant owes a percentage
+)
+
+// SettlementCategory is the transaction category used for recorded settlements
+const SettlementCategory = "Settlement"
+
+// maxExactSettleUp bounds the exhaustive minimal-transfer search; larger
+// groups fall back to the greedy algorithm
+const maxExactSettleUp = 16
+
+// MemberBalance represents what a member has paid, owes and is owed overall
+type MemberBalance struct {
+	UserID string  `json:"user_id"`
+	Paid   float64 `json:"paid"`
+	Share  float64 `json:"share"`
+	Net    float64 `json:"net"` // positive when the member is owed money
+}
+
+// Transfer represents a payment that settles balances between two members
+type Transfer struct {
+	FromUserID string  `json:"from_user_id"`
+	ToUserID   string  `json:"to_user_id"`
+	Amount     float64 `json:"amount"`
+}
+
+// SplitExpense divides an amount between participants. Amounts are handled in
+// cents and leftover cents go to the participants with the largest remainders
+// so the splits always add up to the total exactly.
+func SplitExpense(amount float64, method string, participants []SplitParticipantInput) ([]ExpenseSplit, error) {
+	total := toCents(amount)
+	if total <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if len(participants) == 0 {
+		return nil, fmt.Errorf("at least one participant is required")
+	}
+
+	seen := make(map[string]bool, len(participants))
+	for _, p := range participants {
+		if p.UserID == "" {
+			return nil, fmt.Errorf("participant user_id is required")
+		}
+		if seen[p.UserID] {
+			return nil, fmt.Errorf("participant %q is listed more than once", p.UserID)
+		}
+		seen[p.UserID] = true
+	}
+
+	weights := make([]float64, len(participants))
+	switch method {
+	case SplitEqual:
+		for i := range weights {
+			weights[i] = 1
+		}
+	case SplitShares:
+		for i, p := range participants {
+			if p.Shares <= 0 {
+				return nil, fmt.Errorf("participant %q must have positive shares", p.UserID)
+			}
+			weights[i] = p.Shares
+		}
+	case SplitPercent:
+		var sum float64
+		for i, p := range participants {
+			if p.Percent <= 0 {
+				return nil, fmt.Errorf("participant %q must have a positive percent", p.UserID)
+			}
+			weights[i] = p.Percent
+			sum += p.Percent
+		}
+		if math.Abs(sum-100) > 0.01 {
+			return nil, fmt.Errorf("percentages must add up to 100, got %.2f", sum)
+		}
+	case SplitExact:
+		splits := make([]ExpenseSplit, len(participants))
+		var sum int64
+		for i, p := range participants {
+			if p.Amount < 0 {
+				return nil, fmt.Errorf("participant %q must not have a negative amount", p.UserID)
+			}
+			cents := toCents(p.Amount)
+			sum += cents
+			splits[i] = ExpenseSplit{UserID: p.UserID, Amount: fromCents(cents)}
+		}
+		if sum != total {
+			return nil, fmt.Errorf("exact amounts must add up to %.2f, got %.2f", fromCents(total), fromCents(sum))
+		}
+		return splits, nil
+	default:
+		return nil, fmt.Errorf("split method must be 'equal', 'shares', 'exact', or 'percent'")
+	}
+
+	cents := allocateCents(total, weights)
+	splits := make([]ExpenseSplit, len(participants))
+	for i, p := range participants {
+		splits[i] = ExpenseSplit{UserID: p.UserID, Amount: fromCents(cents[i])}
+	}
+	return splits, nil
+}
+
+// ComputeBalances nets every expense and settlement into per-member balances
+func ComputeBalances(expenses []SharedExpense, settlements []Settlement) []MemberBalance {
+	paid := make(map[string]int64)
+	share := make(map[string]int64)
+	net := make(map[string]int64)
+
+	for _, e := range expenses {
+		amount := toCents(e.Amount)
+		paid[e.PaidBy] += amount
+		net[e.PaidBy] += amount
+		for _, s := range e.Splits {
+			cents := toCents(s.Amount)
+			share[s.UserID] += cents
+			net[s.UserID] -= cents
+		}
+	}
+	for _, s := range settlements {
+		amount := toCents(s.Amount)
+		net[s.FromUserID] += amount
+		net[s.ToUserID] -= amount
+	}
+
+	balances := make([]MemberBalance, 0, len(net))
+	for userID, cents := range net {
+		balances = append(balances, MemberBalance{
+			UserID: userID,
+			Paid:   fromCents(paid[userID]),
+			Share:  fromCents(share[userID]),
+			Net:    fromCents(cents),
+		})
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].UserID < balances[j].UserID })
+	return balances
+}
+
+// SettleUp returns the transfers that clear all balances using as few
+// payments as possible. The minimum is n minus the largest number of groups
+// whose balances sum to zero, which is found exhaustively for small groups;
+// larger groups use a greedy largest-debtor-pays-largest-creditor pass.
+func SettleUp(balances []MemberBalance) []Transfer {
+	var ids []string
+	var amounts []int64
+	for _, b := range balances {
+		if cents := toCents(b.Net); cents != 0 {
+			ids = append(ids, b.UserID)
+			amounts = append(amounts, cents)
+		}
+	}
+
+	transfers := []Transfer{}
+	if len(ids) == 0 {
+		return transfers
+	}
+	if len(ids) > maxExactSettleUp {
+		return append(transfers, greedySettle(ids, amounts)...)
+	}
+
+	for _, group := range zeroSumGroups(amounts) {
+		groupIDs := make([]string, len(group))
+		groupAmounts := make([]int64, len(group))
+		for i, idx := range group {
+			groupIDs[i] = ids[idx]
+			groupAmounts[i] = amounts[idx]
+		}
+		transfers = append(transfers, greedySettle(groupIDs, groupAmounts)...)
+	}
+	return transfers
+}
+
+// zeroSumGroups partitions balances into the largest number of groups that
+// each sum to zero, using a DP over subsets. dp[mask] is the best number of
+// zero-sum prefixes when the members in mask are added one at a time.
+func zeroSumGroups(amounts []int64) [][]int {
+	n := len(amounts)
+	full := 1<<n - 1
+	sum := make([]int64, full+1)
+	dp := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		low := mask & -mask
+		bit := 0
+		for 1<<bit != low {
+			bit++
+		}
+		sum[mask] = sum[mask^low] + amounts[bit]
+
+		best := 0
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 && dp[mask^(1<<i)] > best {
+				best = dp[mask^(1<<i)]
+			}
+		}
+		if sum[mask] == 0 {
+			best++
+		}
+		dp[mask] = best
+	}
+
+	// Walk back from the full set, cutting a group at every zero-sum prefix
+	var groups [][]int
+	var current []int
+	for mask := full; mask != 0; {
+		gain := 0
+		if sum[mask] == 0 {
+			gain = 1
+			if len(current) > 0 {
+				groups = append(groups, current)
+				current = nil
+			}
+		}
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 && dp[mask^(1<<i)]+gain == dp[mask] {
+				current = append(current, i)
+				mask ^= 1 << i
+				break
+			}
+		}
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+// greedySettle repeatedly has the largest debtor pay the largest creditor
+func greedySettle(ids []string, amounts []int64) []Transfer {
+	type party struct {
+		id     string
+		amount int64
+	}
+	var creditors, debtors []party
+	for i, id := range ids {
+		if amounts[i] > 0 {
+			creditors = append(creditors, party{id, amounts[i]})
+		} else if amounts[i] < 0 {
+			debtors = append(debtors, party{id, -amounts[i]})
+		}
+	}
+	byAmount := func(p []party) func(i, j int) bool {
+		return func(i, j int) bool {
+			if p[i].amount != p[j].amount {
+				return p[i].amount > p[j].amount
+			}
+			return p[i].id < p[j].id
+		}
+	}
+	sort.Slice(creditors, byAmount(creditors))
+	sort.Slice(debtors, byAmount(debtors))
+
+	var transfers []Transfer
+	for c, d := 0, 0; c < len(creditors) && d < len(debtors); {
+		amount := creditors[c].amount
+		if debtors[d].amount < amount {
+			amount = debtors[d].amount
+		}
+		transfers = append(transfers, Transfer{
+			FromUserID: debtors[d].id,
+			ToUserID:   creditors[c].id,
+			Amount:     fromCents(amount),
+		})
+		creditors[c].amount -= amount
+		debtors[d].amount -= amount
+		if creditors[c].amount == 0 {
+			c++
+		}
+		if debtors[d].amount == 0 {
+			d++
+		}
+	}
+	return transfers
+}
+
+// SettlementTransactions returns the expense and income transactions that
+// record a settlement in each member's own ledger
+func SettlementTransactions(s Settlement) []Transaction {
+	return []Transaction{
+		{
+			ID:          s.ID + "-out",
+			UserID:      s.FromUserID,
+			WorkspaceID: s.WorkspaceID,
+			Amount:      s.Amount,
+			Category:    SettlementCategory,
+			Type:        "expense",
+			Description: "Settle up payment to " + s.ToUserID,
+			Date:        s.Date,
+			CreatedAt:   s.CreatedAt,
+			UpdatedAt:   s.CreatedAt,
+		},
+		{
+			ID:          s.ID + "-in",
+			UserID:      s.ToUserID,
+			WorkspaceID: s.WorkspaceID,
+			Amount:      s.Amount,
+			Category:    SettlementCategory,
+			Type:        "income",
+			Description: "Settle up payment from " + s.FromUserID,
+			Date:        s.Date,
+			CreatedAt:   s.CreatedAt,
+			UpdatedAt:   s.CreatedAt,
+		},
+	}
+}
+
+// allocateCents splits total cents by weight using the largest remainder method
+func allocateCents(total int64, weights []float64) []int64 {
+	var weightSum float64
+	for _, w := range weights {
+		weightSum += w
+	}
+
+	cents := make([]int64, len(weights))
+	remainders := make([]float64, len(weights))
+	var allocated int64
+	for i, w := range weights {
+		exact := float64(total) * w / weightSum
+		cents[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(cents[i])
+		allocated += cents[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for i := 0; allocated < total; i++ {
+		cents[order[i%len(order)]]++
+		allocated++
+	}
+	return cents
+}
+
+func toCents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+func fromCents(c int64) float64 {
+	return float64(c) / 100
+}
+
+// SplitStore persists shared expenses and settlements
+type SplitStore interface {
+	SaveExpense(ctx context.Context, expense SharedExpense) error
+	ListExpenses(ctx context.Context, workspaceID string) ([]SharedExpense, error)
+	DeleteExpense(ctx context.Context, workspaceID, id string) error
+	SaveSettlement(ctx context.Context, settlement Settlement) error
+	ListSettlements(ctx context.Context, workspaceID string) ([]Settlement, error)
+}
+
+// Splits is the shared expense store used by handlers. Like Workspaces it is
+// kept in the Redis-protocol server at REDIS_URL when set, so every function
+// instance sees the same expenses and settlements.
+var Splits SplitStore = InstrumentSplitStore(newSplitStore())
+
+func newSplitStore() SplitStore {
+	if client := newRedisClient("splits"); client != nil {
+		return &RedisSplitStore{client: client}
+	}
+	return NewMemorySplitStore()
+}
+
+// MemorySplitStore is an in-memory SplitStore
+type MemorySplitStore struct {
+	mu          sync.RWMutex
+	expenses    map[string][]SharedExpense // workspace ID -> expenses
+	settlements map[string][]Settlement    // workspace ID -> settlements
+}
+
+// NewMemorySplitStore creates an empty in-memory split store
+func NewMemorySplitStore() *MemorySplitStore {
+	return &MemorySplitStore{
+		expenses:    make(map[string][]SharedExpense),
+		settlements: make(map[string][]Settlement),
+	}
+}
+
+// SaveExpense stores a shared expense
+func (s *MemorySplitStore) SaveExpense(ctx context.Context, expense SharedExpense) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expenses[expense.WorkspaceID] = append(s.expenses[expense.WorkspaceID], expense)
+	return nil
+}
+
+// ListExpenses returns a workspace's shared expenses, newest first
+func (s *MemorySplitStore) ListExpenses(ctx context.Context, workspaceID string) ([]SharedExpense, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expenses := append([]SharedExpense{}, s.expenses[workspaceID]...)
+	sort.SliceStable(expenses, func(i, j int) bool { return expenses[i].Date.After(expenses[j].Date) })
+	return expenses, nil
+}
+
+// DeleteExpense removes a shared expense
+func (s *MemorySplitStore) DeleteExpense(ctx context.Context, workspaceID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expenses := s.expenses[workspaceID]
+	for i, e := range expenses {
+		if e.ID == id {
+			s.expenses[workspaceID] = append(expenses[:i:i], expenses[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// SaveSettlement stores a settlement
+func (s *MemorySplitStore) SaveSettlement(ctx context.Context, settlement Settlement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settlements[settlement.WorkspaceID] = append(s.settlements[settlement.WorkspaceID], settlement)
+	return nil
+}
+
+// ListSettlements returns a workspace's settlements, newest first
+func (s *MemorySplitStore) ListSettlements(ctx context.Context, workspaceID string) ([]Settlement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	settlements := append([]Settlement{}, s.settlements[workspaceID]...)
+	sort.SliceStable(settlements, func(i, j int) bool { return settlements[i].Date.After(settlements[j].Date) })
+	return settlements, nil
+}
+
+// RedisSplitStore is a SplitStore backed by a Redis-protocol server. A
+// workspace's expenses and settlements are kept as JSON in the hashes
+// splits:<workspace ID>:expenses and splits:<workspace ID>:settlements.
+type RedisSplitStore struct {
+	client *RESPClient
+}
+
+// NewRedisSplitStore creates a split store for a redis:// or rediss:// URL
+func NewRedisSplitStore(redisURL string) (*RedisSplitStore, error) {
+	client, err := NewRESPClient(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisSplitStore{client: client}, nil
+}
+
+func splitExpensesKey(workspaceID string) string    { return "splits:" + workspaceID + ":expenses" }
+func splitSettlementsKey(workspaceID string) string { return "splits:" + workspaceID + ":settlements" }
+
+// SaveExpense stores a shared expense
+func (s *RedisSplitStore) SaveExpense(ctx context.Context, expense SharedExpense) error {
+	data, err := json.Marshal(expense)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do("HSET", splitExpensesKey(expense.WorkspaceID), expense.ID, string(data))
+	return err
+}
+
+// ListExpenses returns a workspace's shared expenses, newest first
+func (s *RedisSplitStore) ListExpenses(ctx context.Context, workspaceID string) ([]SharedExpense, error) {
+	reply, err := s.client.Do("HVALS", splitExpensesKey(workspaceID))
+	if err != nil {
+		return nil, err
+	}
+	values, err := respStrings(reply)
+	if err != nil {
+		return nil, err
+	}
+	expenses := make([]SharedExpense, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal([]byte(value), &expenses[i]); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(expenses, func(i, j int) bool { return expenses[i].Date.After(expenses[j].Date) })
+	return expenses, nil
+}
+
+// DeleteExpense removes a shared expense
+func (s *RedisSplitStore) DeleteExpense(ctx context.Context, workspaceID, id string) error {
+	reply, err := s.client.Do("HDEL", splitExpensesKey(workspaceID), id)
+	if err != nil {
+		return err
+	}
+	if reply != int64(1) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SaveSettlement stores a settlement
+func (s *RedisSplitStore) SaveSettlement(ctx context.Context, settlement Settlement) error {
+	data, err := json.Marshal(settlement)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do("HSET", splitSettlementsKey(settlement.WorkspaceID), settlement.ID, string(data))
+	return err
+}
+
+// ListSettlements returns a workspace's settlements, newest first
+func (s *RedisSplitStore) ListSettlements(ctx context.Context, workspaceID string) ([]Settlement, error) {
+	reply, err := s.client.Do("HVALS", splitSettlementsKey(workspaceID))
+	if err != nil {
+		return nil, err
+	}
+	values, err := respStrings(reply)
+	if err != nil {
+		return nil, err
+	}
+	settlements := make([]Settlement, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal([]byte(value), &settlements[i]); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(settlements, func(i, j int) bool { return settlements[i].Date.After(settlements[j].Date) })
+	return settlements, nil
+}
@@ -0,0 +1,211 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSplitExpense(t *testing.T) {
+	tests := []struct {
+		name         string
+		amount       float64
+		method       string
+		participants []SplitParticipantInput
+		want         []float64
+		wantErr      bool
+	}{
+		{
+			name:         "equal with leftover cents",
+			amount:       100,
+			method:       SplitEqual,
+			participants: []SplitParticipantInput{{UserID: "a"}, {UserID: "b"}, {UserID: "c"}},
+			want:         []float64{33.34, 33.33, 33.33},
+		},
+		{
+			name:         "shares",
+			amount:       90,
+			method:       SplitShares,
+			participants: []SplitParticipantInput{{UserID: "a", Shares: 2}, {UserID: "b", Shares: 1}},
+			want:         []float64{60, 30},
+		},
+		{
+			name:         "percent",
+			amount:       80,
+			method:       SplitPercent,
+			participants: []SplitParticipantInput{{UserID: "a", Percent: 25}, {UserID: "b", Percent: 75}},
+			want:         []float64{20, 60},
+		},
+		{
+			name:         "exact",
+			amount:       50,
+			method:       SplitExact,
+			participants: []SplitParticipantInput{{UserID: "a", Amount: 12.5}, {UserID: "b", Amount: 37.5}},
+			want:         []float64{12.5, 37.5},
+		},
+		{"exact not adding up", 50, SplitExact, []SplitParticipantInput{{UserID: "a", Amount: 10}, {UserID: "b", Amount: 30}}, nil, true},
+		{"percent not adding up", 50, SplitPercent, []SplitParticipantInput{{UserID: "a", Percent: 50}, {UserID: "b", Percent: 40}}, nil, true},
+		{"shares missing", 50, SplitShares, []SplitParticipantInput{{UserID: "a", Shares: 1}, {UserID: "b"}}, nil, true},
+		{"duplicate participant", 50, SplitEqual, []SplitParticipantInput{{UserID: "a"}, {UserID: "a"}}, nil, true},
+		{"no participants", 50, SplitEqual, nil, nil, true},
+		{"non-positive amount", 0, SplitEqual, []SplitParticipantInput{{UserID: "a"}}, nil, true},
+		{"unknown method", 50, "random", []SplitParticipantInput{{UserID: "a"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splits, err := SplitExpense(tt.amount, tt.method, tt.participants)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", splits)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]float64, len(splits))
+			var total int64
+			for i, s := range splits {
+				got[i] = s.Amount
+				total += toCents(s.Amount)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splits %v, want %v", got, tt.want)
+			}
+			if total != toCents(tt.amount) {
+				t.Errorf("splits add up to %d cents, want %d", total, toCents(tt.amount))
+			}
+		})
+	}
+}
+
+func TestComputeBalances(t *testing.T) {
+	expenses := []SharedExpense{
+		{PaidBy: "a", Amount: 90, Splits: []ExpenseSplit{{UserID: "a", Amount: 30}, {UserID: "b", Amount: 30}, {UserID: "c", Amount: 30}}},
+		{PaidBy: "b", Amount: 30, Splits: []ExpenseSplit{{UserID: "b", Amount: 15}, {UserID: "c", Amount: 15}}},
+	}
+	settlements := []Settlement{{FromUserID: "c", ToUserID: "a", Amount: 20}}
+
+	got := ComputeBalances(expenses, settlements)
+	want := []MemberBalance{
+		{UserID: "a", Paid: 90, Share: 30, Net: 40},
+		{UserID: "b", Paid: 30, Share: 45, Net: -15},
+		{UserID: "c", Paid: 0, Share: 45, Net: -25},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("balances %+v, want %+v", got, want)
+	}
+}
+
+func TestSettleUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		balances  map[string]float64
+		transfers int
+	}{
+		{"settled", map[string]float64{"a": 0, "b": 0}, 0},
+		{"one debtor", map[string]float64{"a": 25, "b": -25}, 1},
+		{"one creditor", map[string]float64{"a": 40, "b": -15, "c": -25}, 2},
+		// Greedy matching needs four transfers here; splitting into the
+		// zero-sum groups {a, c, d} and {b, e} needs three
+		{"zero-sum groups", map[string]float64{"a": 4, "b": 3, "c": -2, "d": -2, "e": -3}, 3},
+		{"cents", map[string]float64{"a": 0.01, "b": 0.02, "c": -0.03}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfers := SettleUp(memberBalances(tt.balances))
+			if len(transfers) != tt.transfers {
+				t.Errorf("got %d transfers %+v, want %d", len(transfers), transfers, tt.transfers)
+			}
+			assertSettled(t, tt.balances, transfers)
+		})
+	}
+}
+
+func TestSettleUpLargeGroupFallsBackToGreedy(t *testing.T) {
+	balances := map[string]float64{}
+	for i := 0; i < maxExactSettleUp+4; i++ {
+		balances[fmt.Sprintf("creditor-%02d", i)] = float64(i + 1)
+		balances[fmt.Sprintf("debtor-%02d", i)] = -float64(i + 1)
+	}
+	transfers := SettleUp(memberBalances(balances))
+	if len(transfers) >= len(balances) {
+		t.Errorf("got %d transfers for %d members", len(transfers), len(balances))
+	}
+	assertSettled(t, balances, transfers)
+}
+
+func memberBalances(net map[string]float64) []MemberBalance {
+	balances := make([]MemberBalance, 0, len(net))
+	for _, id := range sortedKeys(net) {
+		balances = append(balances, MemberBalance{UserID: id, Net: net[id]})
+	}
+	return balances
+}
+
+// assertSettled checks applying transfers to balances leaves everyone at zero
+func assertSettled(t *testing.T, balances map[string]float64, transfers []Transfer) {
+	t.Helper()
+	remaining := map[string]int64{}
+	for id, net := range balances {
+		remaining[id] = toCents(net)
+	}
+	for _, tr := range transfers {
+		if tr.Amount <= 0 {
+			t.Errorf("transfer %+v is not positive", tr)
+		}
+		remaining[tr.FromUserID] += toCents(tr.Amount)
+		remaining[tr.ToUserID] -= toCents(tr.Amount)
+	}
+	for id, cents := range remaining {
+		if cents != 0 {
+			t.Errorf("%s is left with %d cents", id, cents)
+		}
+	}
+}
+
+func TestSplitStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) SplitStore{
+		"memory": func(t *testing.T) SplitStore { return NewMemorySplitStore() },
+		"redis":  func(t *testing.T) SplitStore { return &RedisSplitStore{client: newTestRESPClient(t)} },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			older := SharedExpense{ID: "e1", WorkspaceID: "ws-1", Amount: 10, Date: date(2024, 1, 1)}
+			newer := SharedExpense{ID: "e2", WorkspaceID: "ws-1", Amount: 20, Date: date(2024, 2, 1)}
+			other := SharedExpense{ID: "e3", WorkspaceID: "ws-2", Amount: 30, Date: date(2024, 3, 1)}
+			for _, e := range []SharedExpense{older, newer, other} {
+				if err := store.SaveExpense(ctx, e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expenses, err := store.ListExpenses(ctx, "ws-1")
+			if err != nil || len(expenses) != 2 || expenses[0].ID != "e2" || expenses[1].ID != "e1" {
+				t.Errorf("ListExpenses = %+v, %v; want e2 then e1", expenses, err)
+			}
+			if err := store.DeleteExpense(ctx, "ws-2", "e1"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("DeleteExpense from another workspace: %v, want ErrNotFound", err)
+			}
+			if err := store.DeleteExpense(ctx, "ws-1", "e1"); err != nil {
+				t.Fatal(err)
+			}
+			if expenses, _ := store.ListExpenses(ctx, "ws-1"); len(expenses) != 1 {
+				t.Errorf("ListExpenses after delete = %+v", expenses)
+			}
+
+			if err := store.SaveSettlement(ctx, Settlement{ID: "s1", WorkspaceID: "ws-1", FromUserID: "b", ToUserID: "a", Amount: 5}); err != nil {
+				t.Fatal(err)
+			}
+			if settlements, err := store.ListSettlements(ctx, "ws-1"); err != nil || len(settlements) != 1 || settlements[0].Amount != 5 {
+				t.Errorf("ListSettlements = %+v, %v", settlements, err)
+			}
+			if settlements, err := store.ListSettlements(ctx, "ws-2"); err != nil || len(settlements) != 0 {
+				t.Errorf("ListSettlements(ws-2) = %+v, %v; want none", settlements, err)
+			}
+		})
+	}
+}
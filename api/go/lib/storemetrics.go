@@ -0,0 +1,272 @@
+package lib
+
+import (
+	"context"
+	"time"
+)
+
+// The Instrument*Store functions wrap a store so every call is traced as a
+// client span (see TraceStorage) and timed in StorageDuration, with failures
+// counted in StorageErrors. The default stores are wrapped already; wrap
+// custom stores when plugging them in:
+//
+//	lib.Workspaces = lib.InstrumentWorkspaceStore(supabaseWorkspaces)
+
+// InstrumentWorkspaceStore traces and times the calls of a WorkspaceStore
+func InstrumentWorkspaceStore(store WorkspaceStore) WorkspaceStore {
+	return instrumentedWorkspaceStore{store}
+}
+
+type instrumentedWorkspaceStore struct {
+	store WorkspaceStore
+}
+
+// Ping forwards to the wrapped store when it is a Pinger
+func (s instrumentedWorkspaceStore) Ping(ctx context.Context) error {
+	if pinger, ok := s.store.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (s instrumentedWorkspaceStore) CreateWorkspace(ctx context.Context, workspace Workspace, owner WorkspaceMember) error {
+	ctx, done := TraceStorage(ctx, "workspaces", "create_workspace")
+	err := s.store.CreateWorkspace(ctx, workspace, owner)
+	done(err)
+	return err
+}
+
+func (s instrumentedWorkspaceStore) GetWorkspace(ctx context.Context, id string) (*Workspace, error) {
+	ctx, done := TraceStorage(ctx, "workspaces", "get_workspace")
+	result, err := s.store.GetWorkspace(ctx, id)
+	done(err)
+	return result, err
+}
+
+func (s instrumentedWorkspaceStore) DeleteWorkspace(ctx context.Context, id string) error {
+	ctx, done := TraceStorage(ctx, "workspaces", "delete_workspace")
+	err := s.store.DeleteWorkspace(ctx, id)
+	done(err)
+	return err
+}
+
+func (s instrumentedWorkspaceStore) ListWorkspaces(ctx context.Context, userID string) ([]Workspace, error) {
+	ctx, done := TraceStorage(ctx, "workspaces", "list_workspaces")
+	result, err := s.store.ListWorkspaces(ctx, userID)
+	done(err)
+	return result, err
+}
+
+func (s instrumentedWorkspaceStore) GetMember(ctx context.Context, workspaceID, userID string) (*WorkspaceMember, error) {
+	ctx, done := TraceStorage(ctx, "workspaces", "get_member")
+	result, err := s.store.GetMember(ctx, workspaceID, userID)
+	done(err)
+	return result, err
+}
+
+func (s instrumentedWorkspaceStore) ListMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	ctx, done := TraceStorage(ctx, "workspaces", "list_members")
+	result, err := s.store.ListMembers(ctx, workspaceID)
+	done(err)
+	return result, err
+}
+
+func (s instrumentedWorkspaceStore) SaveMember(ctx context.Context, member WorkspaceMember) error {
+	ctx, done := TraceStorage(ctx, "workspaces", "save_member")
+	err := s.store.SaveMember(ctx, member)
+	done(err)
+	return err
+}
+
+func (s instrumentedWorkspaceStore) RemoveMember(ctx context.Context, workspaceID, userID string) error {
+	ctx, done := TraceStorage(ctx, "workspaces", "remove_member")
+	err := s.store.RemoveMember(ctx, workspaceID, userID)
+	done(err)
+	return err
+}
+
+func (s instrumentedWorkspaceStore) SaveInvitation(ctx context.Context, invitation WorkspaceInvitation) error {
+	ctx, done := TraceStorage(ctx, "workspaces", "save_invitation")
+	err := s.store.SaveInvitation(ctx, invitation)
+	done(err)
+	return err
+}
+
+func (s instrumentedWorkspaceStore) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*WorkspaceInvitation, error) {
+	ctx, done := TraceStorage(ctx, "workspaces", "get_invitation")
+	result, err := s.store.GetInvitationByTokenHash(ctx, tokenHash)
+	done(err)
+	return result, err
+}
+
+// InstrumentSplitStore traces and times the calls of a SplitStore
+func InstrumentSplitStore(store SplitStore) SplitStore {
+	return instrumentedSplitStore{store}
+}
+
+type instrumentedSplitStore struct {
+	store SplitStore
+}
+
+func (s instrumentedSplitStore) SaveExpense(ctx context.Context, expense SharedExpense) error {
+	ctx, done := TraceStorage(ctx, "splits", "save_expense")
+	err := s.store.SaveExpense(ctx, expense)
+	done(err)
+	return err
+}
+
+func (s instrumentedSplitStore) ListExpenses(ctx context.Context, workspaceID string) ([]SharedExpense, error) {
+	ctx, done := TraceStorage(ctx, "splits", "list_expenses")
+	result, err := s.store.ListExpenses(ctx, workspaceID)
+	done(err)
+	return result, err
+}
+
+func (s instrumentedSplitStore) DeleteExpense(ctx context.Context, workspaceID, id string) error {
+	ctx, done := TraceStorage(ctx, "splits", "delete_expense")
+	err := s.store.DeleteExpense(ctx, workspaceID, id)
+	done(err)
+	return err
+}
+
+func (s instrumentedSplitStore) SaveSettlement(ctx context.Context, settlement Settlement) error {
+	ctx, done := TraceStorage(ctx, "splits", "save_settlement")
+	err := s.store.SaveSettlement(ctx, settlement)
+	done(err)
+	return err
+}
+
+func (s instrumentedSplitStore) ListSettlements(ctx context.Context, workspaceID string) ([]Settlement, error) {
+	ctx, done := TraceStorage(ctx, "splits", "list_settlements")
+	result, err := s.store.ListSettlements(ctx, workspaceID)
+	done(err)
+	return result, err
+}
+
+// InstrumentTrashStore traces and times the calls of a TrashStore
+func InstrumentTrashStore(store TrashStore) TrashStore {
+	return instrumentedTrashStore{store}
+}
+
+type instrumentedTrashStore struct {
+	store TrashStore
+}
+
+func (s instrumentedTrashStore) Put(ctx context.Context, item TrashedItem) error {
+	ctx, done := TraceStorage(ctx, "trash", "put")
+	err := s.store.Put(ctx, item)
+	done(err)
+	return err
+}
+
+func (s instrumentedTrashStore) Get(ctx context.Context, userID, workspaceID, itemType, id string) (*TrashedItem, error) {
+	ctx, done := TraceStorage(ctx, "trash", "get")
+	result, err := s.store.Get(ctx, userID, workspaceID, itemType, id)
+	done(err)
+	return result, err
+}
+
+func (s instrumentedTrashStore) List(ctx context.Context, userID, workspaceID string) ([]TrashedItem, error) {
+	ctx, done := TraceStorage(ctx, "trash", "list")
+	result, err := s.store.List(ctx, userID, workspaceID)
+	done(err)
+	return result, err
+}
+
+func (s instrumentedTrashStore) Remove(ctx context.Context, userID, workspaceID, itemType, id string) error {
+	ctx, done := TraceStorage(ctx, "trash", "remove")
+	err := s.store.Remove(ctx, userID, workspaceID, itemType, id)
+	done(err)
+	return err
+}
+
+func (s instrumentedTrashStore) PurgeBefore(ctx context.Context, t time.Time) ([]TrashedItem, error) {
+	ctx, done := TraceStorage(ctx, "trash", "purge_before")
+	result, err := s.store.PurgeBefore(ctx, t)
+	done(err)
+	return result, err
+}
+
+// InstrumentAuditStore traces and times the calls of a AuditStore
+func InstrumentAuditStore(store AuditStore) AuditStore {
+	return instrumentedAuditStore{store}
+}
+
+type instrumentedAuditStore struct {
+	store AuditStore
+}
+
+func (s instrumentedAuditStore) Append(ctx context.Context, records ...AuditRecord) error {
+	ctx, done := TraceStorage(ctx, "audit", "append")
+	err := s.store.Append(ctx, records...)
+	done(err)
+	return err
+}
+
+func (s instrumentedAuditStore) List(ctx context.Context, query AuditQuery) ([]AuditRecord, int, error) {
+	ctx, done := TraceStorage(ctx, "audit", "list")
+	result, total, err := s.store.List(ctx, query)
+	done(err)
+	return result, total, err
+}
+
+// InstrumentIdempotencyStore traces and times the calls of a IdempotencyStore
+func InstrumentIdempotencyStore(store IdempotencyStore) IdempotencyStore {
+	return instrumentedIdempotencyStore{store}
+}
+
+type instrumentedIdempotencyStore struct {
+	store IdempotencyStore
+}
+
+func (s instrumentedIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord) (bool, error) {
+	ctx, done := TraceStorage(ctx, "idempotency", "reserve")
+	result, err := s.store.Reserve(ctx, record)
+	done(err)
+	return result, err
+}
+
+func (s instrumentedIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	ctx, done := TraceStorage(ctx, "idempotency", "get")
+	result, err := s.store.Get(ctx, key)
+	done(err)
+	return result, err
+}
+
+func (s instrumentedIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord) error {
+	ctx, done := TraceStorage(ctx, "idempotency", "complete")
+	err := s.store.Complete(ctx, record)
+	done(err)
+	return err
+}
+
+func (s instrumentedIdempotencyStore) Release(ctx context.Context, key string) error {
+	ctx, done := TraceStorage(ctx, "idempotency", "release")
+	err := s.store.Release(ctx, key)
+	done(err)
+	return err
+}
+
+// InstrumentRateLimitStore traces and times the calls of a RateLimitStore
+func InstrumentRateLimitStore(store RateLimitStore) RateLimitStore {
+	return instrumentedRateLimitStore{store}
+}
+
+type instrumentedRateLimitStore struct {
+	store RateLimitStore
+}
+
+func (s instrumentedRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error) {
+	ctx, done := TraceStorage(ctx, "ratelimit", "take")
+	result, err := s.store.Take(ctx, key, policy, now)
+	done(err)
+	return result, err
+}
+
+// Ping forwards to the wrapped store when it is a Pinger
+func (s instrumentedRateLimitStore) Ping(ctx context.Context) error {
+	if pinger, ok := s.store.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BudgetTemplate splits monthly income across needs, wants and savings
+type BudgetTemplate struct {
+	Name    string  `json:"name"`
+	Needs   float64 `json:"needs"`   // share of income, 0-1
+	Wants   float64 `json:"wants"`   // share of income, 0-1
+	Savings float64 `json:"savings"` // share of income, 0-1
+}
+
+// BudgetTemplates lists the supported income-split templates
+var BudgetTemplates = map[string]BudgetTemplate{
+	"50/30/20": {Name: "50/30/20", Needs: 0.5, Wants: 0.3, Savings: 0.2},
+	"70/20/10": {Name: "70/20/10", Needs: 0.7, Wants: 0.2, Savings: 0.1},
+	"60/20/20": {Name: "60/20/20", Needs: 0.6, Wants: 0.2, Savings: 0.2},
+}
+
+// needsCategories are treated as essential spending; everything else is a want
+var needsCategories = map[string]bool{
+	"rent":           true,
+	"mortgage":       true,
+	"housing":        true,
+	"utilities":      true,
+	"groceries":      true,
+	"insurance":      true,
+	"healthcare":     true,
+	"medical":        true,
+	"transportation": true,
+	"transport":      true,
+	"childcare":      true,
+	"education":      true,
+	"debt":           true,
+	"loan":           true,
+}
+
+// BudgetProposalOptions configures budget auto-generation
+type BudgetProposalOptions struct {
+	AsOf            time.Time
+	Months          int                // full months of history to use, default 3
+	BufferPercent   float64            // percentage added on top of the median
+	CategoryBuffers map[string]float64 // per-category buffer overrides
+	Template        string             // optional income-split template, e.g. "50/30/20"
+	MonthlyIncome   float64            // defaults to median monthly income
+}
+
+// ProposedBudget represents a suggested monthly budget for one category
+type ProposedBudget struct {
+	Category      string  `json:"category"`
+	Group         string  `json:"group"` // needs or wants
+	Median        float64 `json:"median"`
+	BufferPercent float64 `json:"buffer_percent"`
+	Amount        float64 `json:"amount"`
+	Period        string  `json:"period"`
+}
+
+// TemplateGroup summarises a template group's target and proposed total
+type TemplateGroup struct {
+	Group    string  `json:"group"`
+	Share    float64 `json:"share"`
+	Target   float64 `json:"target"`
+	Proposed float64 `json:"proposed"`
+	Scaled   bool    `json:"scaled"` // proposals were reduced to fit the target
+}
+
+// BudgetProposal represents a full proposed budget set
+type BudgetProposal struct {
+	Months        int              `json:"months"`
+	Template      string           `json:"template,omitempty"`
+	MonthlyIncome float64          `json:"monthly_income"`
+	TotalBudgeted float64          `json:"total_budgeted"`
+	Unallocated   float64          `json:"unallocated"`
+	Budgets       []ProposedBudget `json:"budgets"`
+	Groups        []TemplateGroup  `json:"groups,omitempty"`
+}
+
+// ProposeBudgets suggests monthly budgets from the median spend per category
+// over the last full months of history plus a buffer. When a template is
+// given, needs and wants are scaled down to fit their share of income.
+func ProposeBudgets(history []Transaction, opts BudgetProposalOptions) (*BudgetProposal, error) {
+	if opts.Months == 0 {
+		opts.Months = 3
+	}
+	if opts.Months < 1 || opts.Months > 24 {
+		return nil, fmt.Errorf("months must be between 1 and 24")
+	}
+	if opts.BufferPercent < 0 {
+		return nil, fmt.Errorf("buffer percent must not be negative")
+	}
+
+	var template *BudgetTemplate
+	if opts.Template != "" {
+		t, ok := BudgetTemplates[opts.Template]
+		if !ok {
+			return nil, fmt.Errorf("unknown template %q", opts.Template)
+		}
+		template = &t
+	}
+
+	// Use whole months only, ending with the month before AsOf
+	end := time.Date(opts.AsOf.Year(), opts.AsOf.Month(), 1, 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, -opts.Months, 0)
+
+	spend := make(map[string][]float64)
+	income := make([]float64, opts.Months)
+	for _, t := range history {
+		// Months are bucketed in UTC, like start and end, whatever the date's location
+		d := t.Date.UTC()
+		if d.Before(start) || !d.Before(end) {
+			continue
+		}
+		idx := (d.Year()-start.Year())*12 + int(d.Month()) - int(start.Month())
+		if t.Type == "income" {
+			income[idx] += t.Amount
+			continue
+		}
+		if spend[t.Category] == nil {
+			spend[t.Category] = make([]float64, opts.Months)
+		}
+		spend[t.Category][idx] += t.Amount
+	}
+
+	proposal := &BudgetProposal{
+		Months:        opts.Months,
+		Template:      opts.Template,
+		MonthlyIncome: opts.MonthlyIncome,
+		Budgets:       []ProposedBudget{},
+	}
+	if proposal.MonthlyIncome == 0 {
+		proposal.MonthlyIncome = roundCents(median(income))
+	}
+
+	for category, totals := range spend {
+		m := median(totals)
+		// Categories that are usually untouched in a month don't get a budget
+		if m <= 0 {
+			continue
+		}
+		buffer := opts.BufferPercent
+		if override, ok := opts.CategoryBuffers[category]; ok {
+			buffer = override
+		}
+		group := "wants"
+		if needsCategories[strings.ToLower(category)] {
+			group = "needs"
+		}
+		// Rounded to cents first so float error can't add a whole unit
+		amount := math.Ceil(roundCents(m * (1 + buffer/100)))
+		proposal.Budgets = append(proposal.Budgets, ProposedBudget{
+			Category:      category,
+			Group:         group,
+			Median:        roundCents(m),
+			BufferPercent: buffer,
+			Amount:        amount,
+			Period:        FrequencyMonthly,
+		})
+	}
+	sort.Slice(proposal.Budgets, func(i, j int) bool {
+		return proposal.Budgets[i].Category < proposal.Budgets[j].Category
+	})
+
+	if template != nil {
+		if proposal.MonthlyIncome <= 0 {
+			return nil, fmt.Errorf("monthly income is required to apply a template")
+		}
+		proposal.Groups = []TemplateGroup{
+			fitGroup(proposal.Budgets, "needs", template.Needs, proposal.MonthlyIncome),
+			fitGroup(proposal.Budgets, "wants", template.Wants, proposal.MonthlyIncome),
+			{
+				Group:  "savings",
+				Share:  template.Savings,
+				Target: roundCents(proposal.MonthlyIncome * template.Savings),
+			},
+		}
+	}
+
+	for _, b := range proposal.Budgets {
+		proposal.TotalBudgeted += b.Amount
+	}
+	proposal.TotalBudgeted = roundCents(proposal.TotalBudgeted)
+	proposal.Unallocated = roundCents(proposal.MonthlyIncome - proposal.TotalBudgeted)
+
+	return proposal, nil
+}
+
+// fitGroup scales a group's proposed budgets down proportionally when their
+// total exceeds the group's share of income
+func fitGroup(budgets []ProposedBudget, group string, share, income float64) TemplateGroup {
+	summary := TemplateGroup{Group: group, Share: share, Target: roundCents(income * share)}
+
+	var total float64
+	for _, b := range budgets {
+		if b.Group == group {
+			total += b.Amount
+		}
+	}
+	if total > summary.Target && total > 0 {
+		scale := summary.Target / total
+		total = 0
+		for i := range budgets {
+			if budgets[i].Group == group {
+				budgets[i].Amount = math.Floor(budgets[i].Amount * scale)
+				total += budgets[i].Amount
+			}
+		}
+		summary.Scaled = true
+	}
+	summary.Proposed = roundCents(total)
+	return summary
+}
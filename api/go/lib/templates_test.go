@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProposeBudgets(t *testing.T) {
+	var history []Transaction
+	for m := time.January; m <= time.March; m++ {
+		history = append(history,
+			Transaction{Type: "income", Amount: 4000, Date: date(2024, m, 1)},
+			Transaction{Type: "expense", Category: "Rent", Amount: 1500, Date: date(2024, m, 2)},
+			Transaction{Type: "expense", Category: "Dining", Amount: float64(100 * m), Date: date(2024, m, 10)},
+		)
+	}
+	// Outside the window: the current month and before it
+	history = append(history,
+		Transaction{Type: "expense", Category: "Dining", Amount: 999, Date: date(2024, 4, 3)},
+		Transaction{Type: "expense", Category: "Travel", Amount: 999, Date: date(2023, 12, 20)},
+	)
+
+	proposal, err := ProposeBudgets(history, BudgetProposalOptions{AsOf: date(2024, 4, 15), BufferPercent: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.MonthlyIncome != 4000 || len(proposal.Budgets) != 2 {
+		t.Fatalf("income %v with budgets %+v, want 4000 and Dining and Rent", proposal.MonthlyIncome, proposal.Budgets)
+	}
+	want := []ProposedBudget{
+		{Category: "Dining", Group: "wants", Median: 200, BufferPercent: 10, Amount: 220, Period: FrequencyMonthly},
+		{Category: "Rent", Group: "needs", Median: 1500, BufferPercent: 10, Amount: 1650, Period: FrequencyMonthly},
+	}
+	for i := range want {
+		if proposal.Budgets[i] != want[i] {
+			t.Errorf("budget %d = %+v, want %+v", i, proposal.Budgets[i], want[i])
+		}
+	}
+}
+
+func TestProposeBudgetsNonUTCDates(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	tests := []struct {
+		name   string
+		date   time.Time
+		months int
+		want   float64 // proposed Dining budget, 0 for none
+	}{
+		// 2024-01-01T01:00Z is still December 31st in EST
+		{"first day of the window", time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC).In(est), 1, 0},
+		{"first day of a three month window", time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC).In(est), 3, 0},
+		{"first day of march", time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC).In(est), 1, 90},
+		// 2024-04-01T01:00Z is still March 31st in EST
+		{"after the window", time.Date(2024, 4, 1, 1, 0, 0, 0, time.UTC).In(est), 1, 0},
+		{"late on the last day of february", time.Date(2024, 2, 29, 23, 0, 0, 0, est), 1, 90},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := []Transaction{{Type: "expense", Category: "Dining", Amount: 90, Date: tt.date}}
+			proposal, err := ProposeBudgets(history, BudgetProposalOptions{AsOf: date(2024, 4, 15), Months: tt.months})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got float64
+			if len(proposal.Budgets) > 0 {
+				got = proposal.Budgets[0].Amount
+			}
+			if got != tt.want {
+				t.Errorf("Dining budget %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProposeBudgetsTemplate(t *testing.T) {
+	var history []Transaction
+	for m := time.January; m <= time.March; m++ {
+		history = append(history,
+			Transaction{Type: "expense", Category: "Rent", Amount: 1000, Date: date(2024, m, 1)},
+			Transaction{Type: "expense", Category: "Dining", Amount: 800, Date: date(2024, m, 5)},
+		)
+	}
+	proposal, err := ProposeBudgets(history, BudgetProposalOptions{AsOf: date(2024, 4, 1), Template: "50/30/20", MonthlyIncome: 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := map[string]TemplateGroup{}
+	for _, g := range proposal.Groups {
+		groups[g.Group] = g
+	}
+	if g := groups["wants"]; !g.Scaled || g.Target != 600 || g.Proposed != 600 {
+		t.Errorf("wants group %+v, want scaled to 600", g)
+	}
+	if g := groups["needs"]; g.Scaled || g.Proposed != 1000 {
+		t.Errorf("needs group %+v, want 1000 unscaled", g)
+	}
+}
+
+func TestProposeBudgetsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts BudgetProposalOptions
+	}{
+		{"too many months", BudgetProposalOptions{Months: 25}},
+		{"negative buffer", BudgetProposalOptions{BufferPercent: -1}},
+		{"unknown template", BudgetProposalOptions{Template: "90/5/5"}},
+		{"template without income", BudgetProposalOptions{Template: "50/30/20"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ProposeBudgets(nil, tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader carries the W3C trace context of the caller, see
+// https://www.w3.org/TR/trace-context/
+const TraceparentHeader = "traceparent"
+
+// Span kinds, as numbered by OTLP
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+)
+
+// OTLP span status codes
+const (
+	spanStatusUnset = 0
+	spanStatusError = 2
+)
+
+// SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid reports whether the trace and span IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats the span context as a traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header value. Unknown future versions
+// are accepted as long as they start with the version 00 fields.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// Span is a timed operation within a trace. Spans are only recorded and
+// exported when tracing is enabled (see OTLPTracesEndpoint) and the trace is
+// sampled; otherwise they just carry the trace context. All methods are safe
+// to call on a span that is not recorded.
+type Span struct {
+	mu         sync.Mutex
+	context    SpanContext
+	parentID   [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes []otlpAttribute
+	status     int
+	message    string
+	trace      *localTrace // nil when not recording
+	root       bool        // the first span of the trace in this process
+}
+
+// localTrace collects the spans of one trace started in this process, so
+// they can be exported together when its root span ends
+type localTrace struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the current span, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// StartSpan starts a span as a child of the span in ctx, or of a new trace.
+// End must be called on the returned span.
+func StartSpan(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if parent := SpanFromContext(ctx); parent != nil {
+		return startSpan(ctx, name, kind, parent.context, parent.trace)
+	}
+	return startSpan(ctx, name, kind, SpanContext{}, nil)
+}
+
+// startSpan starts a span with a parent span context, which may be remote or
+// empty for a new trace. trace is the parent's localTrace, nil if the parent
+// is remote or not recording.
+func startSpan(ctx context.Context, name string, kind int, parent SpanContext, trace *localTrace) (context.Context, *Span) {
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	rand.Read(span.context.SpanID[:])
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Sampled = parent.Sampled
+		span.parentID = parent.SpanID
+	} else {
+		rand.Read(span.context.TraceID[:])
+		span.context.Sampled = true
+	}
+
+	if trace == nil && span.context.Sampled && OTLPTracesEndpoint() != "" {
+		trace = &localTrace{}
+		span.root = true
+	}
+	if trace != nil && span.context.Sampled {
+		span.trace = trace
+		trace.mu.Lock()
+		trace.spans = append(trace.spans, span)
+		trace.mu.Unlock()
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Context returns the span's trace and span IDs
+func (s *Span) Context() SpanContext {
+	return s.context
+}
+
+// TraceID returns the span's trace ID in hex
+func (s *Span) TraceID() string {
+	return hex.EncodeToString(s.context.TraceID[:])
+}
+
+// SetAttribute records a string, bool, integer or float attribute on the span.
+// Other values are recorded as strings.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s.trace == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, otlpAttr(key, value))
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if err == nil || s.trace == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = spanStatusError
+	s.message = RedactString(err.Error())
+}
+
+// SetError marks the span as failed with a message
+func (s *Span) SetError(message string) {
+	if s.trace == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = spanStatusError
+	s.message = message
+}
+
+// End finishes the span. Ending the root span of a trace queues the spans
+// recorded for it for export, see FlushSpans.
+func (s *Span) End() {
+	if s.trace == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.root {
+		s.trace.mu.Lock()
+		spans := s.trace.spans
+		s.trace.spans = nil
+		s.trace.mu.Unlock()
+		startSpanExporter()
+		select {
+		case spanQueue <- spanBatch{endpoint: OTLPTracesEndpoint(), traceID: s.TraceID(), spans: spans}:
+		default:
+			Logger.Warn("OTLP trace export queue full, dropping trace", "trace_id", s.TraceID())
+		}
+	}
+}
+
+// SpanQueueSize bounds the traces waiting to be exported. Traces ended while
+// the queue is full are dropped rather than holding up requests.
+const SpanQueueSize = 256
+
+// spanBatch is the spans of one trace waiting for export, or a FlushSpans
+// marker when flushed is set
+type spanBatch struct {
+	endpoint string
+	traceID  string
+	spans    []*Span
+	flushed  chan struct{}
+}
+
+var (
+	spanQueue        = make(chan spanBatch, SpanQueueSize)
+	spanExporterOnce sync.Once
+)
+
+// startSpanExporter starts the goroutine exporting queued traces, once per process
+func startSpanExporter() {
+	spanExporterOnce.Do(func() {
+		go func() {
+			for batch := range spanQueue {
+				if batch.flushed != nil {
+					close(batch.flushed)
+					continue
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), otlpClient.Timeout)
+				if err := ExportSpans(ctx, batch.endpoint, batch.spans); err != nil {
+					Logger.Warn("OTLP trace export failed", "trace_id", batch.traceID, "error", err.Error())
+				}
+				cancel()
+			}
+		}()
+	})
+}
+
+// FlushSpans waits until the traces ended so far have been exported, or ctx
+// is done. cmd/server calls it on shutdown.
+func FlushSpans(ctx context.Context) error {
+	startSpanExporter()
+	flushed := make(chan struct{})
+	select {
+	case spanQueue <- spanBatch{flushed: flushed}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Trace continues the caller's trace from its traceparent header, or starts a
+// new one, with a server span covering the rest of the chain. The trace ID
+// is added to the access log. Spans are queued for export in the background
+// once the request is done; serverless functions, which are frozen between
+// requests, wait for the export before returning instead.
+func Trace() Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			parent, _ := ParseTraceparent(r.Header.Get(TraceparentHeader))
+			ctx, span := startSpan(r.Context(), r.Method+" "+r.URL.Path, SpanKindServer, parent, nil)
+			defer func() {
+				span.End()
+				if span.trace != nil && Serverless() {
+					ctx, cancel := context.WithTimeout(context.Background(), otlpClient.Timeout)
+					defer cancel()
+					FlushSpans(ctx)
+				}
+			}()
+			if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+				info.traceID = span.TraceID()
+			}
+			span.SetAttribute("http.request.method", r.Method)
+			span.SetAttribute("url.path", r.URL.Path)
+			span.SetAttribute("client.address", GetClientIP(r))
+			span.SetAttribute("user_agent.original", r.UserAgent())
+			span.SetAttribute("request.id", RequestID(r))
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next(sw, r.WithContext(ctx))
+
+			span.SetAttribute("http.response.status_code", sw.status)
+			if sw.code != "" {
+				span.SetAttribute("error.type", sw.code)
+			}
+			if sw.status >= http.StatusInternalServerError {
+				span.SetError(http.StatusText(sw.status))
+			}
+		}
+	}
+}
+
+// TraceStorage starts a client span for a storage call. The returned function
+// ends it, records err, and records the call in StorageDuration and
+// StorageErrors.
+func TraceStorage(ctx context.Context, store, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := StartSpan(ctx, store+"."+operation, SpanKindClient)
+	span.SetAttribute("db.collection.name", store)
+	span.SetAttribute("db.operation.name", operation)
+	return ctx, func(err error) {
+		ObserveStorage(store, operation, start, err)
+		if err != nil && err != ErrNotFound {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+}
+
+// OTLPTracesEndpoint returns the collector URL spans are exported to:
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT as is, or OTEL_EXPORTER_OTLP_ENDPOINT
+// with /v1/traces appended. Empty when tracing is disabled.
+func OTLPTracesEndpoint() string {
+	return otlpEndpoint("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "/v1/traces")
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// ExportSpans sends finished spans to an OTLP/HTTP collector, such as
+// http://localhost:4318/v1/traces, as JSON. Spans still running are skipped.
+func ExportSpans(ctx context.Context, endpoint string, spans []*Span) error {
+	scope := otlpScopeSpans{Scope: otlpTelemetryScope()}
+	for _, s := range spans {
+		s.mu.Lock()
+		if !s.end.IsZero() {
+			span := otlpSpan{
+				TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+				SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+				Name:              s.name,
+				Kind:              s.kind,
+				StartTimeUnixNano: unixNano(s.start),
+				EndTimeUnixNano:   unixNano(s.end),
+				Attributes:        append([]otlpAttribute(nil), s.attributes...),
+				Status:            otlpStatus{Code: s.status, Message: s.message},
+			}
+			if s.parentID != [8]byte{} {
+				span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+			}
+			scope.Spans = append(scope.Spans, span)
+		}
+		s.mu.Unlock()
+	}
+	if len(scope.Spans) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(otlpTracesRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpServiceResource(),
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}})
+	if err != nil {
+		return err
+	}
+	return postOTLP(ctx, endpoint, payload)
+}
+
+// postOTLP sends an OTLP/HTTP JSON payload to a collector
+func postOTLP(ctx context.Context, endpoint string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := otlpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("OTLP collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpAttr converts an attribute value to its OTLP representation
+func otlpAttr(key string, value interface{}) otlpAttribute {
+	var v otlpValue
+	switch value := value.(type) {
+	case string:
+		v.StringValue = &value
+	case bool:
+		v.BoolValue = &value
+	case int:
+		i := strconv.Itoa(value)
+		v.IntValue = &i
+	case int64:
+		i := strconv.FormatInt(value, 10)
+		v.IntValue = &i
+	case float64:
+		v.DoubleValue = &value
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
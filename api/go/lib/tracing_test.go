@@ -0,0 +1,153 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const traceID, spanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	tests := []struct {
+		name    string
+		value   string
+		ok      bool
+		sampled bool
+	}{
+		{"sampled", "00-" + traceID + "-" + spanID + "-01", true, true},
+		{"not sampled", "00-" + traceID + "-" + spanID + "-00", true, false},
+		{"other flags", "00-" + traceID + "-" + spanID + "-03", true, true},
+		{"surrounding space", " 00-" + traceID + "-" + spanID + "-01 ", true, true},
+		{"future version with extra fields", "cc-" + traceID + "-" + spanID + "-01-what-the-future-holds", true, true},
+		{"version 00 with extra fields", "00-" + traceID + "-" + spanID + "-01-extra", false, false},
+		{"invalid version", "ff-" + traceID + "-" + spanID + "-01", false, false},
+		{"zero trace ID", "00-00000000000000000000000000000000-" + spanID + "-01", false, false},
+		{"zero span ID", "00-" + traceID + "-0000000000000000-01", false, false},
+		{"short trace ID", "00-" + traceID[1:] + "-" + spanID + "-01", false, false},
+		{"not hex", "00-" + strings.Repeat("z", 32) + "-" + spanID + "-01", false, false},
+		{"bad flags", "00-" + traceID + "-" + spanID + "-zz", false, false},
+		{"missing fields", "00-" + traceID + "-" + spanID, false, false},
+		{"empty", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("ParseTraceparent(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if sc.Sampled != tt.sampled {
+				t.Errorf("sampled %v, want %v", sc.Sampled, tt.sampled)
+			}
+			if want := "00-" + traceID + "-" + spanID; !strings.HasPrefix(sc.Traceparent(), want) {
+				t.Errorf("Traceparent() = %s, want prefix %s", sc.Traceparent(), want)
+			}
+		})
+	}
+}
+
+func TestTraceExportsRequestSpans(t *testing.T) {
+	receiver := newOTLPReceiver(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", receiver.URL)
+	previousLogger := Logger
+	t.Cleanup(func() { Logger = previousLogger })
+	Logger = NewLogger(io.Discard, "error")
+
+	store := InstrumentTrashStore(NewMemoryTrashStore())
+	handler := CreateHandler(func(w http.ResponseWriter, r *http.Request) {
+		var input CreateBudgetInput
+		if !BindJSON(w, r, &input) {
+			return
+		}
+		if _, err := store.List(r.Context(), "user-id", ""); err != nil {
+			WriteError(w, InternalError("Failed to list trash", err))
+			return
+		}
+		SuccessResponse(w, input, http.StatusCreated)
+	}, Config{RequireAuth: true, AllowedMethods: []string{"POST"}})
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	r := httptest.NewRequest(http.MethodPost, "/api/go/budgets", strings.NewReader(`{"category":"Food","amount":100,"period":"monthly"}`))
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set(TraceparentHeader, "00-"+traceID+"-"+parentID+"-01")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := FlushSpans(ctx); err != nil {
+		t.Fatal(err)
+	}
+	payloads := receiver.received("/v1/traces")
+	if len(payloads) != 1 {
+		t.Fatalf("%d trace exports, want 1", len(payloads))
+	}
+	var payload otlpTracesRequest
+	if err := json.Unmarshal(payloads[0], &payload); err != nil {
+		t.Fatal(err)
+	}
+	spans := map[string]otlpSpan{}
+	for _, span := range payload.ResourceSpans[0].ScopeSpans[0].Spans {
+		if span.TraceID != traceID {
+			t.Errorf("span %s in trace %s, want the caller's %s", span.Name, span.TraceID, traceID)
+		}
+		spans[span.Name] = span
+	}
+
+	server, ok := spans["POST /api/go/budgets"]
+	if !ok {
+		t.Fatalf("no server span among %v", sortedKeys(spans))
+	}
+	if server.Kind != SpanKindServer || server.ParentSpanID != parentID {
+		t.Errorf("server span kind %d with parent %s, want %d with the caller's %s", server.Kind, server.ParentSpanID, SpanKindServer, parentID)
+	}
+	for _, name := range []string{"auth", "validate body", "trash.list"} {
+		child, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span among %v", name, sortedKeys(spans))
+			continue
+		}
+		if child.ParentSpanID != server.SpanID {
+			t.Errorf("%s span parent %s, want the server span %s", name, child.ParentSpanID, server.SpanID)
+		}
+	}
+	if kind := spans["trash.list"].Kind; kind != SpanKindClient {
+		t.Errorf("storage span kind %d, want %d", kind, SpanKindClient)
+	}
+}
+
+func TestTraceUnsampledCallerIsNotExported(t *testing.T) {
+	receiver := newOTLPReceiver(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", receiver.URL)
+	previousLogger := Logger
+	t.Cleanup(func() { Logger = previousLogger })
+	Logger = NewLogger(io.Discard, "error")
+
+	handler := CreateHandler(func(w http.ResponseWriter, r *http.Request) {
+		if span := SpanFromContext(r.Context()); span == nil || span.Context().Sampled {
+			t.Error("the request span should continue the caller's unsampled trace")
+		}
+	}, Config{})
+	r := httptest.NewRequest(http.MethodGet, "/api/go/health", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	handler(httptest.NewRecorder(), r)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := FlushSpans(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(receiver.received("/v1/traces")); got != 0 {
+		t.Errorf("%d trace exports for an unsampled trace, want none", got)
+	}
+}
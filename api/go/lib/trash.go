@@ -0,0 +1,404 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resource types that can be moved to the trash
+const (
+	TrashTransaction = "transaction"
+	TrashBudget      = "budget"
+)
+
+// DefaultTrashRetentionDays is how long deleted items stay restorable before
+// they are purged, unless TRASH_RETENTION_DAYS is set
+const DefaultTrashRetentionDays = 30
+
+// TrashedItem represents a soft-deleted resource that can still be restored
+type TrashedItem struct {
+	Type        string       `json:"type"` // transaction or budget
+	ID          string       `json:"id"`
+	UserID      string       `json:"user_id"`
+	WorkspaceID string       `json:"workspace_id,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Budget      *Budget      `json:"budget,omitempty"`
+	DeletedAt   time.Time    `json:"deleted_at"`
+	PurgeAt     time.Time    `json:"purge_at"`
+}
+
+// BelongsTo reports whether the item is in the given user's personal trash or,
+// when workspaceID is set, in that workspace's trash
+func (item TrashedItem) BelongsTo(userID, workspaceID string) bool {
+	if workspaceID != "" {
+		return item.WorkspaceID == workspaceID
+	}
+	return item.WorkspaceID == "" && item.UserID == userID
+}
+
+// trashOwner names the trash an item belongs to: the workspace's when
+// workspaceID is set, otherwise the user's personal trash
+func trashOwner(userID, workspaceID string) string {
+	if workspaceID != "" {
+		return "workspace:" + workspaceID
+	}
+	return "user:" + userID
+}
+
+// TrashRetentionDays returns the configured retention window in days
+func TrashRetentionDays() int {
+	days, err := strconv.Atoi(GetEnv("TRASH_RETENTION_DAYS", ""))
+	if err != nil || days <= 0 {
+		return DefaultTrashRetentionDays
+	}
+	return days
+}
+
+// TrashTransactionItem soft-deletes a transaction at now, returning its trash entry
+func TrashTransactionItem(transaction Transaction, now time.Time) TrashedItem {
+	transaction.DeletedAt = &now
+	return TrashedItem{
+		Type:        TrashTransaction,
+		ID:          transaction.ID,
+		UserID:      transaction.UserID,
+		WorkspaceID: transaction.WorkspaceID,
+		Transaction: &transaction,
+		DeletedAt:   now,
+		PurgeAt:     now.AddDate(0, 0, TrashRetentionDays()),
+	}
+}
+
+// TrashBudgetItem soft-deletes a budget at now, returning its trash entry
+func TrashBudgetItem(budget Budget, now time.Time) TrashedItem {
+	budget.DeletedAt = &now
+	return TrashedItem{
+		Type:        TrashBudget,
+		ID:          budget.ID,
+		UserID:      budget.UserID,
+		WorkspaceID: budget.WorkspaceID,
+		Budget:      &budget,
+		DeletedAt:   now,
+		PurgeAt:     now.AddDate(0, 0, TrashRetentionDays()),
+	}
+}
+
+// ExcludeTrashedTransactions drops transactions that are in the trash. With
+// includeDeleted they are kept instead, with DeletedAt set. Each owner's
+// trash is listed once, however many transactions there are.
+func ExcludeTrashedTransactions(ctx context.Context, transactions []Transaction, includeDeleted bool) ([]Transaction, error) {
+	trashed := trashIndex{}
+	kept := make([]Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		item, err := trashed.get(ctx, t.UserID, t.WorkspaceID, TrashTransaction, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			if !includeDeleted {
+				continue
+			}
+			t.DeletedAt = &item.DeletedAt
+		}
+		kept = append(kept, t)
+	}
+	return kept, nil
+}
+
+// ExcludeTrashedBudgets drops budgets that are in the trash. With
+// includeDeleted they are kept instead, with DeletedAt set. Each owner's
+// trash is listed once, however many budgets there are.
+func ExcludeTrashedBudgets(ctx context.Context, budgets []Budget, includeDeleted bool) ([]Budget, error) {
+	trashed := trashIndex{}
+	kept := make([]Budget, 0, len(budgets))
+	for _, b := range budgets {
+		item, err := trashed.get(ctx, b.UserID, b.WorkspaceID, TrashBudget, b.ID)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			if !includeDeleted {
+				continue
+			}
+			b.DeletedAt = &item.DeletedAt
+		}
+		kept = append(kept, b)
+	}
+	return kept, nil
+}
+
+// trashIndex holds the trash of each owner listed so far, by item type and ID
+type trashIndex map[string]map[string]TrashedItem
+
+// get returns the owner's trashed item, or nil when it is not in the trash,
+// listing the owner's trash on first use
+func (idx trashIndex) get(ctx context.Context, userID, workspaceID, itemType, id string) (*TrashedItem, error) {
+	owner := trashOwner(userID, workspaceID)
+	items, ok := idx[owner]
+	if !ok {
+		list, err := Trash.List(ctx, userID, workspaceID)
+		if err != nil {
+			return nil, err
+		}
+		items = make(map[string]TrashedItem, len(list))
+		for _, item := range list {
+			items[item.Type+"/"+item.ID] = item
+		}
+		idx[owner] = items
+	}
+	if item, ok := items[itemType+"/"+id]; ok {
+		return &item, nil
+	}
+	return nil, nil
+}
+
+// PurgeExpiredTrash permanently removes items whose retention window has
+// passed. Serverless functions have no scheduler, so handlers that touch the
+// trash call this opportunistically.
+// TODO: Run as a scheduled job and delete the purged rows from the database
+func PurgeExpiredTrash(ctx context.Context, now time.Time) ([]TrashedItem, error) {
+	return Trash.PurgeBefore(ctx, now)
+}
+
+// TrashStore persists soft-deleted items until they are restored or purged.
+// Items are scoped by owner, so Get and Remove only find an item in the trash
+// named by userID and workspaceID (see TrashedItem.BelongsTo).
+type TrashStore interface {
+	Put(ctx context.Context, item TrashedItem) error
+	Get(ctx context.Context, userID, workspaceID, itemType, id string) (*TrashedItem, error)
+	List(ctx context.Context, userID, workspaceID string) ([]TrashedItem, error)
+	Remove(ctx context.Context, userID, workspaceID, itemType, id string) error
+	PurgeBefore(ctx context.Context, t time.Time) ([]TrashedItem, error)
+}
+
+// Trash is the trash store used by handlers. Like Splits it is kept in the
+// Redis-protocol server at REDIS_URL when set, so an item deleted by one
+// function instance is hidden and restorable from every other.
+// TODO: Replace with deleted_at columns in Supabase
+var Trash TrashStore = InstrumentTrashStore(newTrashStore())
+
+func newTrashStore() TrashStore {
+	if client := newRedisClient("trash"); client != nil {
+		return &RedisTrashStore{client: client}
+	}
+	return NewMemoryTrashStore()
+}
+
+// MemoryTrashStore is an in-memory TrashStore
+type MemoryTrashStore struct {
+	mu    sync.RWMutex
+	items map[string]map[string]TrashedItem // owner -> type/id -> item
+}
+
+// NewMemoryTrashStore creates an empty in-memory trash store
+func NewMemoryTrashStore() *MemoryTrashStore {
+	return &MemoryTrashStore{
+		items: make(map[string]map[string]TrashedItem),
+	}
+}
+
+func trashKey(itemType, id string) string {
+	return itemType + "/" + id
+}
+
+// Put stores a trashed item, replacing any earlier entry for the same resource
+func (s *MemoryTrashStore) Put(ctx context.Context, item TrashedItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	owner := trashOwner(item.UserID, item.WorkspaceID)
+	if s.items[owner] == nil {
+		s.items[owner] = make(map[string]TrashedItem)
+	}
+	s.items[owner][trashKey(item.Type, item.ID)] = item
+	return nil
+}
+
+// Get returns a trashed item by type and ID from a user's or workspace's trash
+func (s *MemoryTrashStore) Get(ctx context.Context, userID, workspaceID, itemType, id string) (*TrashedItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[trashOwner(userID, workspaceID)][trashKey(itemType, id)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &item, nil
+}
+
+// List returns the items in a user's or workspace's trash, most recently deleted first
+func (s *MemoryTrashStore) List(ctx context.Context, userID, workspaceID string) ([]TrashedItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := []TrashedItem{}
+	for _, item := range s.items[trashOwner(userID, workspaceID)] {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+// Remove deletes an item from a user's or workspace's trash
+func (s *MemoryTrashStore) Remove(ctx context.Context, userID, workspaceID, itemType, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	owner, key := trashOwner(userID, workspaceID), trashKey(itemType, id)
+	if _, ok := s.items[owner][key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.items[owner], key)
+	if len(s.items[owner]) == 0 {
+		delete(s.items, owner)
+	}
+	return nil
+}
+
+// PurgeBefore removes and returns the items due for purging at or before t
+func (s *MemoryTrashStore) PurgeBefore(ctx context.Context, t time.Time) ([]TrashedItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var purged []TrashedItem
+	for owner, items := range s.items {
+		for key, item := range items {
+			if !item.PurgeAt.After(t) {
+				purged = append(purged, item)
+				delete(items, key)
+			}
+		}
+		if len(items) == 0 {
+			delete(s.items, owner)
+		}
+	}
+	return purged, nil
+}
+
+// RedisTrashStore is a TrashStore backed by a Redis-protocol server. Each
+// owner's trash is a hash trash:<owner> of type/id to the item as JSON, and
+// the sorted set trash:purge orders every item by its purge time.
+type RedisTrashStore struct {
+	client *RESPClient
+}
+
+// NewRedisTrashStore creates a trash store for a redis:// or rediss:// URL
+func NewRedisTrashStore(redisURL string) (*RedisTrashStore, error) {
+	client, err := NewRESPClient(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisTrashStore{client: client}, nil
+}
+
+const trashPurgeKey = "trash:purge"
+
+func trashOwnerKey(owner string) string { return "trash:" + owner }
+
+// trashPurgeMember names an item in trash:purge as <owner>|<type>/<id>
+func trashPurgeMember(owner, key string) string { return owner + "|" + key }
+
+// Put stores a trashed item, replacing any earlier entry for the same resource
+func (s *RedisTrashStore) Put(ctx context.Context, item TrashedItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	owner, key := trashOwner(item.UserID, item.WorkspaceID), trashKey(item.Type, item.ID)
+	_, err = s.client.Transaction(
+		[]string{"HSET", trashOwnerKey(owner), key, string(data)},
+		[]string{"ZADD", trashPurgeKey, strconv.FormatInt(item.PurgeAt.UnixMilli(), 10), trashPurgeMember(owner, key)},
+	)
+	return err
+}
+
+// Get returns a trashed item by type and ID from a user's or workspace's trash
+func (s *RedisTrashStore) Get(ctx context.Context, userID, workspaceID, itemType, id string) (*TrashedItem, error) {
+	reply, err := s.client.Do("HGET", trashOwnerKey(trashOwner(userID, workspaceID)), trashKey(itemType, id))
+	if err != nil {
+		return nil, err
+	}
+	var item TrashedItem
+	if err := decodeRESPJSON(reply, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// List returns the items in a user's or workspace's trash, most recently deleted first
+func (s *RedisTrashStore) List(ctx context.Context, userID, workspaceID string) ([]TrashedItem, error) {
+	reply, err := s.client.Do("HVALS", trashOwnerKey(trashOwner(userID, workspaceID)))
+	if err != nil {
+		return nil, err
+	}
+	values, err := respStrings(reply)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]TrashedItem, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal([]byte(value), &items[i]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+// Remove deletes an item from a user's or workspace's trash
+func (s *RedisTrashStore) Remove(ctx context.Context, userID, workspaceID, itemType, id string) error {
+	owner, key := trashOwner(userID, workspaceID), trashKey(itemType, id)
+	replies, err := s.client.Transaction(
+		[]string{"HDEL", trashOwnerKey(owner), key},
+		[]string{"ZREM", trashPurgeKey, trashPurgeMember(owner, key)},
+	)
+	if err != nil {
+		return err
+	}
+	if replies[0] != int64(1) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PurgeBefore removes and returns the items due for purging at or before t.
+// Each item is claimed by removing it from trash:purge first, so concurrent
+// purges from several instances return it only once.
+func (s *RedisTrashStore) PurgeBefore(ctx context.Context, t time.Time) ([]TrashedItem, error) {
+	reply, err := s.client.Do("ZRANGEBYSCORE", trashPurgeKey, "-inf", strconv.FormatInt(t.UnixMilli(), 10))
+	if err != nil {
+		return nil, err
+	}
+	members, err := respStrings(reply)
+	if err != nil {
+		return nil, err
+	}
+	var purged []TrashedItem
+	for _, member := range members {
+		owner, key, ok := strings.Cut(member, "|")
+		if !ok {
+			continue
+		}
+		claimed, err := s.client.Do("ZREM", trashPurgeKey, member)
+		if err != nil {
+			return purged, err
+		}
+		if claimed != int64(1) {
+			continue
+		}
+		replies, err := s.client.Transaction(
+			[]string{"HGET", trashOwnerKey(owner), key},
+			[]string{"HDEL", trashOwnerKey(owner), key},
+		)
+		if err != nil {
+			return purged, err
+		}
+		var item TrashedItem
+		if err := decodeRESPJSON(replies[0], &item); err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return purged, err
+		}
+		purged = append(purged, item)
+	}
+	return purged, nil
+}
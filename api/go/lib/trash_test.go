@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTrashStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) TrashStore{
+		"memory": func(t *testing.T) TrashStore { return NewMemoryTrashStore() },
+		"redis":  func(t *testing.T) TrashStore { return &RedisTrashStore{client: newTestRESPClient(t)} },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			older := TrashTransactionItem(Transaction{ID: "tx-1", UserID: "alice"}, date(2024, 1, 1))
+			newer := TrashBudgetItem(Budget{ID: "b-1", UserID: "alice"}, date(2024, 1, 2))
+			shared := TrashTransactionItem(Transaction{ID: "tx-2", UserID: "alice", WorkspaceID: "ws-1"}, date(2024, 1, 3))
+			for _, item := range []TrashedItem{older, newer, shared} {
+				if err := store.Put(ctx, item); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			items, err := store.List(ctx, "alice", "")
+			if err != nil || len(items) != 2 || items[0].ID != "b-1" || items[1].ID != "tx-1" {
+				t.Errorf("List = %+v, %v; want b-1 then tx-1", items, err)
+			}
+			if items, err := store.List(ctx, "bob", "ws-1"); err != nil || len(items) != 1 || items[0].ID != "tx-2" {
+				t.Errorf("List of the workspace = %+v, %v; want tx-2", items, err)
+			}
+
+			if item, err := store.Get(ctx, "alice", "", TrashTransaction, "tx-1"); err != nil || item.Transaction == nil || item.Transaction.DeletedAt == nil {
+				t.Errorf("Get = %+v, %v; want the trashed transaction", item, err)
+			}
+			if _, err := store.Get(ctx, "bob", "", TrashTransaction, "tx-1"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get from another user's trash: %v, want ErrNotFound", err)
+			}
+			if _, err := store.Get(ctx, "alice", "", TrashTransaction, "tx-2"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get of a workspace item from the personal trash: %v, want ErrNotFound", err)
+			}
+			if err := store.Remove(ctx, "bob", "", TrashTransaction, "tx-1"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Remove from another user's trash: %v, want ErrNotFound", err)
+			}
+			if err := store.Remove(ctx, "bob", "ws-1", TrashTransaction, "tx-2"); err != nil {
+				t.Errorf("Remove by a workspace member: %v", err)
+			}
+			if err := store.Remove(ctx, "bob", "ws-1", TrashTransaction, "tx-2"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Remove twice: %v, want ErrNotFound", err)
+			}
+
+			purged, err := store.PurgeBefore(ctx, older.PurgeAt)
+			if err != nil || len(purged) != 1 || purged[0].ID != "tx-1" {
+				t.Errorf("PurgeBefore = %+v, %v; want tx-1", purged, err)
+			}
+			if purged, err := store.PurgeBefore(ctx, older.PurgeAt); err != nil || len(purged) != 0 {
+				t.Errorf("PurgeBefore again = %+v, %v; want nothing", purged, err)
+			}
+			if items, err := store.List(ctx, "alice", ""); err != nil || len(items) != 1 || items[0].ID != "b-1" {
+				t.Errorf("List after purging = %+v, %v; want b-1", items, err)
+			}
+		})
+	}
+}
+
+// countingTrashStore counts List calls and fails them once failing is set
+type countingTrashStore struct {
+	TrashStore
+	lists   int
+	failing bool
+}
+
+func (s *countingTrashStore) List(ctx context.Context, userID, workspaceID string) ([]TrashedItem, error) {
+	s.lists++
+	if s.failing {
+		return nil, errors.New("connection refused")
+	}
+	return s.TrashStore.List(ctx, userID, workspaceID)
+}
+
+func TestExcludeTrashedTransactions(t *testing.T) {
+	store := &countingTrashStore{TrashStore: NewMemoryTrashStore()}
+	previous := Trash
+	t.Cleanup(func() { Trash = previous })
+	Trash = store
+	ctx := context.Background()
+	now := date(2024, 1, 15)
+
+	transactions := []Transaction{
+		{ID: "tx-1", UserID: "alice"},
+		{ID: "tx-2", UserID: "alice"},
+		{ID: "tx-3", UserID: "alice", WorkspaceID: "ws-1"},
+		{ID: "tx-4", UserID: "bob", WorkspaceID: "ws-1"},
+	}
+	for _, tx := range []Transaction{transactions[1], transactions[3]} {
+		if err := store.Put(ctx, TrashTransactionItem(tx, now)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kept, err := ExcludeTrashedTransactions(ctx, transactions, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 2 || kept[0].ID != "tx-1" || kept[1].ID != "tx-3" {
+		t.Errorf("kept %+v, want tx-1 and tx-3", kept)
+	}
+	// Alice's trash and the workspace's, once each
+	if store.lists != 2 {
+		t.Errorf("trash listed %d times, want once per owner", store.lists)
+	}
+
+	all, err := ExcludeTrashedTransactions(ctx, transactions, true)
+	if err != nil || len(all) != 4 || all[1].DeletedAt == nil || all[0].DeletedAt != nil {
+		t.Errorf("with includeDeleted: %+v, %v, want all four with tx-2 marked deleted", all, err)
+	}
+
+	store.failing = true
+	if _, err := ExcludeTrashedTransactions(ctx, transactions, false); err == nil {
+		t.Error("a failing trash backend was treated as an empty trash")
+	}
+}
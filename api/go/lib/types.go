@@ -4,52 +4,85 @@ import "time"
 
 // Transaction represents a financial transaction
 type Transaction struct {
-	ID            string    `json:"id"`
-	UserID        string    `json:"user_id"`
-	Amount        float64   `json:"amount"`
-	Category      string    `json:"category"`
-	Type          string    `json:"type"` // income or expense
-	Description   string    `json:"description,omitempty"`
-	Date          time.Time `json:"date"`
-	Merchant      string    `json:"merchant,omitempty"`
-	PaymentMethod string    `json:"payment_method,omitempty"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            string     `json:"id"`
+	UserID        string     `json:"user_id"`
+	WorkspaceID   string     `json:"workspace_id,omitempty"`
+	Amount        float64    `json:"amount"`
+	Category      string     `json:"category"`
+	Type          string     `json:"type"` // income or expense
+	Description   string     `json:"description,omitempty"`
+	Date          time.Time  `json:"date"`
+	Merchant      string     `json:"merchant,omitempty"`
+	PaymentMethod string     `json:"payment_method,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"` // set while in the trash
 }
 
 // CreateTransactionInput represents input for creating a transaction
 type CreateTransactionInput struct {
-	Amount        float64 `json:"amount"`
-	Category      string  `json:"category"`
-	Type          string  `json:"type"`
-	Description   string  `json:"description,omitempty"`
-	Date          string  `json:"date,omitempty"`
-	Merchant      string  `json:"merchant,omitempty"`
-	PaymentMethod string  `json:"payment_method,omitempty"`
+	Amount        float64 `json:"amount" validate:"required,gt=0"`
+	Category      string  `json:"category" validate:"required,max=100"`
+	Type          string  `json:"type" validate:"required,oneof=income expense"`
+	Description   string  `json:"description,omitempty" validate:"max=500"`
+	Date          string  `json:"date,omitempty" validate:"datetime"`
+	Merchant      string  `json:"merchant,omitempty" validate:"max=200"`
+	PaymentMethod string  `json:"payment_method,omitempty" validate:"max=100"`
+}
+
+// UpdateTransactionInput represents input for updating a transaction.
+// Only the fields that are set are changed.
+type UpdateTransactionInput struct {
+	Amount        *float64 `json:"amount,omitempty" validate:"gt=0"`
+	Category      *string  `json:"category,omitempty" validate:"min=1,max=100"`
+	Type          *string  `json:"type,omitempty" validate:"oneof=income expense"`
+	Description   *string  `json:"description,omitempty" validate:"max=500"`
+	Date          *string  `json:"date,omitempty" validate:"datetime"`
+	Merchant      *string  `json:"merchant,omitempty" validate:"max=200"`
+	PaymentMethod *string  `json:"payment_method,omitempty" validate:"max=100"`
 }
 
 // Budget represents a budget
 type Budget struct {
-	ID             string    `json:"id"`
-	UserID         string    `json:"user_id"`
-	Category       string    `json:"category"`
-	Amount         float64   `json:"amount"`
-	Period         string    `json:"period"` // weekly, monthly, yearly
-	StartDate      time.Time `json:"start_date"`
-	EndDate        time.Time `json:"end_date,omitempty"`
-	AlertThreshold int       `json:"alert_threshold"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	WorkspaceID    string     `json:"workspace_id,omitempty"`
+	Category       string     `json:"category"`
+	Amount         float64    `json:"amount"`
+	Period         string     `json:"period"` // weekly, monthly, yearly
+	StartDate      time.Time  `json:"start_date"`
+	EndDate        time.Time  `json:"end_date,omitempty"`
+	AlertThreshold int        `json:"alert_threshold"`
+	RolloverPolicy string     `json:"rollover_policy"`        // none, surplus, deficit or both
+	RolloverCap    float64    `json:"rollover_cap,omitempty"` // max carried amount, 0 for no cap
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"` // set while in the trash
 }
 
 // CreateBudgetInput represents input for creating a budget
 type CreateBudgetInput struct {
-	Category       string  `json:"category"`
-	Amount         float64 `json:"amount"`
-	Period         string  `json:"period"`
-	StartDate      string  `json:"start_date,omitempty"`
-	EndDate        string  `json:"end_date,omitempty"`
-	AlertThreshold int     `json:"alert_threshold,omitempty"`
+	Category       string  `json:"category" validate:"required,max=100"`
+	Amount         float64 `json:"amount" validate:"required,gt=0"`
+	Period         string  `json:"period" validate:"required,oneof=weekly monthly yearly"`
+	StartDate      string  `json:"start_date,omitempty" validate:"date"`
+	EndDate        string  `json:"end_date,omitempty" validate:"date"`
+	AlertThreshold int     `json:"alert_threshold,omitempty" validate:"min=0,max=100"`
+	RolloverPolicy string  `json:"rollover_policy,omitempty" validate:"oneof=none surplus deficit both"`
+	RolloverCap    float64 `json:"rollover_cap,omitempty" validate:"min=0"`
+}
+
+// UpdateBudgetInput represents input for updating a budget.
+// Only the fields that are set are changed.
+type UpdateBudgetInput struct {
+	Category       *string  `json:"category,omitempty" validate:"min=1,max=100"`
+	Amount         *float64 `json:"amount,omitempty" validate:"gt=0"`
+	Period         *string  `json:"period,omitempty" validate:"oneof=weekly monthly yearly"`
+	StartDate      *string  `json:"start_date,omitempty" validate:"date"`
+	EndDate        *string  `json:"end_date,omitempty" validate:"date"`
+	AlertThreshold *int     `json:"alert_threshold,omitempty" validate:"min=0,max=100"`
+	RolloverPolicy *string  `json:"rollover_policy,omitempty" validate:"oneof=none surplus deficit both"`
+	RolloverCap    *float64 `json:"rollover_cap,omitempty" validate:"min=0"`
 }
 
 // UserProfile represents a user profile
@@ -68,21 +101,26 @@ type UserProfile struct {
 
 // UpdateProfileInput represents input for updating profile
 type UpdateProfileInput struct {
-	FullName             string                 `json:"full_name,omitempty"`
-	PreferredCurrency    string                 `json:"preferred_currency,omitempty"`
-	Timezone             string                 `json:"timezone,omitempty"`
-	PreferredLanguage    string                 `json:"preferred_language,omitempty"`
+	FullName             string                 `json:"full_name,omitempty" validate:"max=100"`
+	PreferredCurrency    string                 `json:"preferred_currency,omitempty" validate:"min=3,max=3"`
+	Timezone             string                 `json:"timezone,omitempty" validate:"max=64"`
+	PreferredLanguage    string                 `json:"preferred_language,omitempty" validate:"max=10"`
 	NotificationSettings map[string]interface{} `json:"notification_settings,omitempty"`
-	ThemePreference      string                 `json:"theme_preference,omitempty"`
+	ThemePreference      string                 `json:"theme_preference,omitempty" validate:"max=20"`
+}
+
+// DeleteAccountInput represents input for deleting an account
+type DeleteAccountInput struct {
+	Confirm bool `json:"confirm"`
 }
 
 // AnalyticsSummary represents financial analytics summary
 type AnalyticsSummary struct {
-	TotalIncome       float64 `json:"totalIncome"`
-	TotalExpenses     float64 `json:"totalExpenses"`
-	NetSavings        float64 `json:"netSavings"`
-	SavingsRate       float64 `json:"savingsRate"`
-	TransactionCount  int     `json:"transactionCount"`
+	TotalIncome       float64 `json:"total_income"`
+	TotalExpenses     float64 `json:"total_expenses"`
+	NetSavings        float64 `json:"net_savings"`
+	SavingsRate       float64 `json:"savings_rate"`
+	TransactionCount  int     `json:"transaction_count"`
 }
 
 // CategoryAnalytics represents category breakdown
@@ -107,11 +145,199 @@ type PaginationParams struct {
 	Offset int `json:"offset"`
 }
 
-// PaginatedResponse represents a paginated response
-type PaginatedResponse struct {
-	Items   interface{} `json:"items"`
-	Total   int         `json:"total"`
-	Limit   int         `json:"limit"`
-	Offset  int         `json:"offset"`
-	HasMore bool        `json:"hasMore"`
+// Pagination represents the position of a page within a list
+type Pagination struct {
+	Total   int  `json:"total"`
+	Limit   int  `json:"limit"`
+	Offset  int  `json:"offset"`
+	HasMore bool `json:"has_more"`
+}
+
+// Liability represents a debt such as a credit card or loan
+type Liability struct {
+	ID             string    `json:"id"`
+	UserID         string    `json:"user_id"`
+	Name           string    `json:"name"`
+	Balance        float64   `json:"balance"`
+	APR            float64   `json:"apr"` // annual percentage rate, e.g. 19.99
+	MinimumPayment float64   `json:"minimum_payment"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// DebtPayoffInput represents input for simulating a debt payoff plan
+type DebtPayoffInput struct {
+	MonthlyBudget float64     `json:"monthly_budget" validate:"required,gt=0"`
+	Strategy      string      `json:"strategy,omitempty" validate:"oneof=avalanche snowball custom"` // avalanche, snowball or custom; empty compares all
+	CustomOrder   []string    `json:"custom_order,omitempty"`
+	StartDate     string      `json:"start_date,omitempty" validate:"date"`
+	Liabilities   []Liability `json:"liabilities,omitempty"`
+}
+
+// RecurringTransaction represents a scheduled repeating income or expense
+type RecurringTransaction struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Amount      float64   `json:"amount"`
+	Category    string    `json:"category"`
+	Type        string    `json:"type"` // income or expense
+	Description string    `json:"description,omitempty"`
+	Frequency   string    `json:"frequency"` // daily, weekly, biweekly, monthly, quarterly, yearly
+	StartDate   time.Time `json:"start_date"`
+	EndDate     time.Time `json:"end_date,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Envelope represents a zero-based budgeting envelope that income is assigned to
+type Envelope struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	WorkspaceID string    `json:"workspace_id,omitempty"`
+	Name        string    `json:"name"`
+	Category    string    `json:"category"`
+	Rollover    bool      `json:"rollover"` // carry unspent money into the next month
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CreateEnvelopeInput represents input for creating an envelope
+type CreateEnvelopeInput struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Category string `json:"category" validate:"required,max=100"`
+	Rollover *bool  `json:"rollover,omitempty"`
+}
+
+// EnvelopeAssignment represents income assigned to an envelope for a month
+type EnvelopeAssignment struct {
+	EnvelopeID string  `json:"envelope_id" validate:"required"`
+	Month      string  `json:"month" validate:"month"`     // YYYY-MM
+	Amount     float64 `json:"amount" validate:"required"` // negative un-assigns back to "to be assigned"
+}
+
+// EnvelopeTransfer represents money moved between envelopes within a month
+type EnvelopeTransfer struct {
+	FromEnvelopeID string  `json:"from_envelope_id" validate:"required"`
+	ToEnvelopeID   string  `json:"to_envelope_id" validate:"required"`
+	Month          string  `json:"month" validate:"month"` // YYYY-MM
+	Amount         float64 `json:"amount" validate:"required,gt=0"`
+}
+
+// CreateBudgetsInput represents input for creating a set of budgets at once
+type CreateBudgetsInput struct {
+	Budgets []CreateBudgetInput `json:"budgets" validate:"required,max=50"`
+}
+
+// Workspace represents a shared household space whose members share budgets and transactions
+type Workspace struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   string    `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WorkspaceMember represents a user's membership and role in a workspace
+type WorkspaceMember struct {
+	WorkspaceID string    `json:"workspace_id"`
+	UserID      string    `json:"user_id"`
+	Email       string    `json:"email,omitempty"`
+	Role        string    `json:"role"` // owner, editor or viewer
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+// WorkspaceInvitation represents a pending invitation to join a workspace
+type WorkspaceInvitation struct {
+	ID          string    `json:"id"`
+	WorkspaceID string    `json:"workspace_id"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	TokenHash   string    `json:"-"`
+	InvitedBy   string    `json:"invited_by"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	AcceptedAt  time.Time `json:"accepted_at,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateWorkspaceInput represents input for creating a workspace
+type CreateWorkspaceInput struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// InviteMemberInput represents input for inviting a user to a workspace
+type InviteMemberInput struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"oneof=editor viewer"`
+}
+
+// AcceptInvitationInput represents input for accepting a workspace invitation
+type AcceptInvitationInput struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// UpdateMemberInput represents input for changing a member's role
+type UpdateMemberInput struct {
+	Role string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+// SharedExpense represents an expense paid by one member and split among several
+type SharedExpense struct {
+	ID          string         `json:"id"`
+	WorkspaceID string         `json:"workspace_id"`
+	PaidBy      string         `json:"paid_by"`
+	Description string         `json:"description"`
+	Category    string         `json:"category,omitempty"`
+	Amount      float64        `json:"amount"`
+	SplitMethod string         `json:"split_method"` // equal, shares, exact or percent
+	Splits      []ExpenseSplit `json:"splits"`
+	Date        time.Time      `json:"date"`
+	CreatedBy   string         `json:"created_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// ExpenseSplit represents one participant's share of a shared expense
+type ExpenseSplit struct {
+	UserID string  `json:"user_id"`
+	Amount float64 `json:"amount"`
+}
+
+// SplitParticipantInput represents a participant when splitting an expense.
+// Shares, Amount or Percent is used depending on the split method.
+type SplitParticipantInput struct {
+	UserID  string  `json:"user_id" validate:"required"`
+	Shares  float64 `json:"shares,omitempty" validate:"min=0"`
+	Amount  float64 `json:"amount,omitempty" validate:"min=0"`
+	Percent float64 `json:"percent,omitempty" validate:"min=0,max=100"`
+}
+
+// CreateSharedExpenseInput represents input for recording a shared expense
+type CreateSharedExpenseInput struct {
+	Description  string                  `json:"description" validate:"required,max=200"`
+	Category     string                  `json:"category,omitempty" validate:"max=100"`
+	Amount       float64                 `json:"amount" validate:"required,gt=0"`
+	PaidBy       string                  `json:"paid_by,omitempty"`
+	SplitMethod  string                  `json:"split_method" validate:"oneof=equal shares exact percent"`
+	Participants []SplitParticipantInput `json:"participants" validate:"required,max=50"`
+	Date         string                  `json:"date,omitempty" validate:"date"`
+}
+
+// Settlement represents a repayment between two workspace members
+type Settlement struct {
+	ID          string    `json:"id"`
+	WorkspaceID string    `json:"workspace_id"`
+	FromUserID  string    `json:"from_user_id"`
+	ToUserID    string    `json:"to_user_id"`
+	Amount      float64   `json:"amount"`
+	Date        time.Time `json:"date"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateSettlementInput represents input for recording a settlement
+type CreateSettlementInput struct {
+	FromUserID string  `json:"from_user_id,omitempty"`
+	ToUserID   string  `json:"to_user_id" validate:"required"`
+	Amount     float64 `json:"amount" validate:"required,gt=0"`
+	Date       string  `json:"date,omitempty" validate:"date"`
 }
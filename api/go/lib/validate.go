@@ -0,0 +1,533 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validation is declared with struct tags and applied by BindJSON and
+// BindQuery. The same tags feed the generated OpenAPI schemas.
+//
+//	validate:"required,gt=0,oneof=income expense"
+//	query:"limit" default:"50" doc:"Page size"
+//
+// Rules:
+//
+//	required   must be present and non-zero (non-nil for pointers)
+//	min=N      number at least N, or string/list length at least N
+//	max=N      number at most N, or string/list length at most N
+//	gt=N       number greater than N
+//	oneof=a b  one of the space separated values
+//	date       YYYY-MM-DD
+//	datetime   YYYY-MM-DD or an RFC 3339 timestamp
+//	month      YYYY-MM, within MaxMonthOffsetYears of the current month
+//	email      email address
+//
+// Optional fields are only checked when set. Nested structs and lists of
+// structs are validated recursively.
+
+// FieldError represents a validation failure for a single field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors aggregates field errors
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// BindJSON decodes a JSON body into v, rejecting unknown fields, and
+// validates it. On failure it writes a 400 response listing the field errors
+// in Details and returns false.
+func BindJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	_, span := StartSpan(r.Context(), "validate body", SpanKindInternal)
+	defer span.End()
+	return checkBody(w, DecodeJSONStrict(r, v), v)
+}
+
+// checkBody reports a body decoding error or validates the decoded value,
+// writing a 400 response and returning false on failure
+func checkBody(w http.ResponseWriter, err error, v interface{}) bool {
+	if err != nil {
+		var fieldErrs ValidationErrors
+		if errors.As(err, &fieldErrs) {
+			WriteError(w, NewError(http.StatusBadRequest, CodeValidation, "Invalid JSON body", fieldErrs))
+			return false
+		}
+		WriteError(w, BadRequestError("Invalid JSON body", map[string]string{
+			"error": err.Error(),
+		}))
+		return false
+	}
+
+	if errs := Validate(v); len(errs) > 0 {
+		WriteError(w, ValidationError(errs))
+		return false
+	}
+	return true
+}
+
+// BindQuery fills v from the query string using its query and default tags
+// and validates it. On failure it writes a 400 response listing the field
+// errors in Details and returns false.
+func BindQuery(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	_, span := StartSpan(r.Context(), "validate query", SpanKindInternal)
+	defer span.End()
+	if errs := DecodeQuery(r, v); len(errs) > 0 {
+		WriteError(w, NewError(http.StatusBadRequest, CodeValidation, "Invalid query parameters", errs))
+		return false
+	}
+	return true
+}
+
+// DecodeJSONStrict decodes a JSON body, rejecting unknown fields and trailing
+// data. Unknown fields and type mismatches are returned as ValidationErrors.
+func DecodeJSONStrict(r *http.Request, v interface{}) error {
+	return decodeStrict(r.Body, v)
+}
+
+func decodeStrict(body io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &typeErr):
+			return ValidationErrors{{Field: typeErr.Field, Message: "must be " + jsonTypeName(typeErr.Type)}}
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+			return ValidationErrors{{Field: field, Message: "unknown field"}}
+		case errors.Is(err, io.EOF):
+			return errors.New("request body is empty")
+		}
+		return err
+	}
+
+	if decoder.More() {
+		return errors.New("request body must contain a single JSON object")
+	}
+	return nil
+}
+
+// DecodeQuery fills the query-tagged fields of the struct v points to,
+// applying default tags for missing parameters, and validates them. A
+// parameter that is given or defaulted is always checked, even when zero.
+//
+// A field tagged with a trailing "*", such as query:"buffer_*", must be a map
+// and collects every parameter with that prefix, keyed by the rest of the
+// name; its rules apply to each value. Parameters matching no field are
+// rejected, apart from workspace_id, id and the parameters selecting the
+// operation in the endpoint registry.
+func DecodeQuery(r *http.Request, v interface{}) ValidationErrors {
+	var errs ValidationErrors
+	values := r.URL.Query()
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+
+	known := queryKeys(r.URL.Path)
+	var prefixes []string
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name := field.Tag.Get("query")
+		if name == "" {
+			continue
+		}
+		rules := field.Tag.Get("validate")
+
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			prefixes = append(prefixes, prefix)
+			decodeQueryPrefix(values, prefix, rv.Field(i), rules, &errs)
+			continue
+		}
+		known[name] = true
+
+		raw := values.Get(name)
+		if raw == "" {
+			raw = field.Tag.Get("default")
+		}
+		if raw == "" {
+			if hasRule(rules, "required") {
+				errs = append(errs, FieldError{Field: name, Message: "is required"})
+			}
+			continue
+		}
+
+		if err := setQueryValue(rv.Field(i), raw); err != nil {
+			errs = append(errs, FieldError{Field: name, Message: "must be " + jsonTypeName(field.Type)})
+			continue
+		}
+		if rules != "" {
+			checkRules(rv.Field(i), name, rules, true, &errs)
+		}
+	}
+
+	unknown := []string{}
+	for key := range values {
+		if !known[key] && !hasAnyPrefix(key, prefixes) {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, FieldError{Field: key, Message: "unknown parameter"})
+	}
+	return errs
+}
+
+// decodeQueryPrefix fills the map field v from the parameters starting with prefix
+func decodeQueryPrefix(values url.Values, prefix string, v reflect.Value, rules string, errs *ValidationErrors) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name, ok := strings.CutPrefix(key, prefix)
+		raw := values[key]
+		if !ok || name == "" || len(raw) == 0 {
+			continue
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		item := reflect.New(v.Type().Elem()).Elem()
+		if err := setQueryValue(item, raw[0]); err != nil {
+			*errs = append(*errs, FieldError{Field: key, Message: "must be " + jsonTypeName(item.Type())})
+			continue
+		}
+		if rules != "" && !checkRules(item, key, rules, true, errs) {
+			continue
+		}
+		v.SetMapIndex(reflect.ValueOf(name), item)
+	}
+}
+
+// queryKeys returns the parameters every query DTO accepts: the workspace,
+// the resource ID and the parameters selecting an operation of the endpoint
+// at path
+func queryKeys(path string) map[string]bool {
+	keys := map[string]bool{"workspace_id": true, "id": true}
+	path = strings.TrimSuffix(path, "/")
+	for _, endpoint := range Endpoints {
+		if endpoint.Path != path {
+			continue
+		}
+		for _, op := range endpoint.Operations {
+			if name, _, _ := strings.Cut(op.Selector, "="); name != "" {
+				keys[name] = true
+			}
+		}
+	}
+	return keys
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func setQueryValue(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported query field type %s", v.Type())
+	}
+	return nil
+}
+
+// Validate checks v against its validate tags and returns every failure
+func Validate(v interface{}) ValidationErrors {
+	var errs ValidationErrors
+	validateValue(reflect.ValueOf(v), "", &errs)
+	return errs
+}
+
+// ValidateDateRange reports the end field when both YYYY-MM-DD dates are set
+// and end falls before start. Validate the dates themselves first.
+func ValidateDateRange(startField, start, endField, end string) ValidationErrors {
+	from, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return nil
+	}
+	to, err := time.Parse("2006-01-02", end)
+	if err != nil || !to.Before(from) {
+		return nil
+	}
+	return ValidationErrors{{Field: endField, Message: "must not be before " + startField}}
+}
+
+func validateValue(v reflect.Value, path string, errs *ValidationErrors) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := fieldName(field)
+			if name == "" {
+				continue
+			}
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			fv := v.Field(i)
+			if rules := field.Tag.Get("validate"); rules != "" {
+				if !checkRules(fv, fieldPath, rules, false, errs) {
+					continue
+				}
+			}
+			validateValue(fv, fieldPath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+// checkRules applies a field's rules, returning false when the field failed
+// or is absent so nested validation is skipped. Zero values count as absent
+// unless present is set.
+func checkRules(v reflect.Value, path, rules string, present bool, errs *ValidationErrors) bool {
+	required := hasRule(rules, "required")
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if required {
+				*errs = append(*errs, FieldError{Field: path, Message: "is required"})
+			}
+			return false
+		}
+		v = v.Elem()
+	} else if !present && isZero(v) {
+		if required {
+			*errs = append(*errs, FieldError{Field: path, Message: zeroMessage(v, rules)})
+		}
+		return false
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		if message := checkRule(v, name, arg); message != "" {
+			*errs = append(*errs, FieldError{Field: path, Message: message})
+			return false
+		}
+	}
+	return true
+}
+
+// zeroMessage explains why a required zero value is invalid. A zero number
+// can't be told apart from a missing one, so when it is also out of range the
+// range error is reported, which holds either way.
+func zeroMessage(v reflect.Value, rules string) string {
+	if _, isLength := measure(v); !isLength && v.Kind() != reflect.Bool {
+		for _, rule := range strings.Split(rules, ",") {
+			name, arg, _ := strings.Cut(rule, "=")
+			if name != "min" && name != "max" && name != "gt" {
+				continue
+			}
+			if message := checkRule(v, name, arg); message != "" {
+				return message
+			}
+		}
+	}
+	return "is required"
+}
+
+func hasRule(rules, name string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		if rule == name || strings.HasPrefix(rule, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func checkRule(v reflect.Value, rule, arg string) string {
+	switch rule {
+	case "required":
+		if v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "" {
+			return "is required"
+		}
+	case "min", "max", "gt":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return ""
+		}
+		value, isLength := measure(v)
+		switch {
+		case rule == "min" && value < limit && isLength:
+			return fmt.Sprintf("must contain at least %s", plural(arg, v))
+		case rule == "min" && value < limit:
+			return "must be at least " + arg
+		case rule == "max" && value > limit && isLength:
+			return fmt.Sprintf("must contain at most %s", plural(arg, v))
+		case rule == "max" && value > limit:
+			return "must be at most " + arg
+		case rule == "gt" && value <= limit:
+			return "must be greater than " + arg
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		value := fmt.Sprint(v.Interface())
+		for _, option := range options {
+			if value == option {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(options, ", ")
+	case "date":
+		if _, err := time.Parse("2006-01-02", v.String()); err != nil {
+			return "must be a date in YYYY-MM-DD format"
+		}
+	case "datetime":
+		if _, err := time.Parse("2006-01-02", v.String()); err != nil {
+			if _, err := time.Parse(time.RFC3339, v.String()); err != nil {
+				return "must be a YYYY-MM-DD date or RFC 3339 timestamp"
+			}
+		}
+	case "month":
+		month, err := time.Parse(MonthFormat, v.String())
+		if err != nil {
+			return "must be a month in YYYY-MM format"
+		}
+		if !MonthInRange(month, time.Now()) {
+			return fmt.Sprintf("must be within %d years of the current month", MaxMonthOffsetYears)
+		}
+	case "email":
+		if _, err := mail.ParseAddress(v.String()); err != nil {
+			return "must be a valid email address"
+		}
+	}
+	return ""
+}
+
+// measure returns a number's value or a string's or list's length
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
+
+func plural(n string, v reflect.Value) string {
+	unit := "item"
+	if v.Kind() == reflect.String {
+		unit = "character"
+	}
+	if n != "1" {
+		unit += "s"
+	}
+	return n + " " + unit
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// fieldName returns a field's JSON or query name, or "" when it is not serialized
+func fieldName(field reflect.StructField) string {
+	if name := field.Tag.Get("query"); name != "" {
+		return name
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
@@ -0,0 +1,156 @@
+package lib
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		params interface{}
+		want   interface{}
+		errs   ValidationErrors
+	}{
+		{
+			name:   "defaults",
+			target: "/api/go/transactions",
+			params: &TransactionListParams{},
+			want:   &TransactionListParams{Limit: 50},
+		},
+		{
+			name:   "workspace and id are always accepted",
+			target: "/api/go/transactions?limit=10&workspace_id=ws-1&id=tx-1",
+			params: &TransactionListParams{},
+			want:   &TransactionListParams{Limit: 10},
+		},
+		{
+			name:   "operation selectors are accepted",
+			target: "/api/go/analytics?type=forecast&days=7",
+			params: &ForecastParams{},
+			want:   &ForecastParams{Days: 7, Confidence: 0.8, StartingBalance: 2500},
+		},
+		{
+			name:   "date range",
+			target: "/api/go/analytics?type=trend&start_date=2024-01-01&end_date=2024-03",
+			params: &AnalyticsRangeParams{},
+			errs:   ValidationErrors{{Field: "end_date", Message: "must be a date in YYYY-MM-DD format"}},
+		},
+		{
+			name:   "unknown summary parameters are rejected",
+			target: "/api/go/analytics?start=2024-01-01",
+			params: &AnalyticsRangeParams{},
+			errs:   ValidationErrors{{Field: "start", Message: "unknown parameter"}},
+		},
+		{
+			name:   "unknown parameters are rejected",
+			target: "/api/go/transactions?limt=10&sort=date",
+			params: &TransactionListParams{},
+			errs:   ValidationErrors{{Field: "limt", Message: "unknown parameter"}, {Field: "sort", Message: "unknown parameter"}},
+		},
+		{
+			name:   "selectors of other endpoints are rejected",
+			target: "/api/go/transactions?view=history",
+			params: &TransactionListParams{},
+			errs:   ValidationErrors{{Field: "view", Message: "unknown parameter"}},
+		},
+		{
+			name:   "zero is range checked rather than missing",
+			target: "/api/go/transactions?limit=0",
+			params: &TransactionListParams{},
+			errs:   ValidationErrors{{Field: "limit", Message: "must be at least 1"}},
+		},
+		{
+			name:   "type mismatch",
+			target: "/api/go/transactions?limit=ten",
+			params: &TransactionListParams{},
+			errs:   ValidationErrors{{Field: "limit", Message: "must be an integer"}},
+		},
+		{
+			name:   "required",
+			target: "/api/go/budgets?view=history",
+			params: &BudgetHistoryParams{},
+			errs:   ValidationErrors{{Field: "id", Message: "is required"}},
+		},
+		{
+			name:   "prefixed parameters",
+			target: "/api/go/budgets?view=proposal&buffer=5&buffer_Dining=20&buffer_Rent=0",
+			params: &BudgetProposalParams{},
+			want:   &BudgetProposalParams{Months: 3, Buffer: 5, CategoryBuffers: map[string]float64{"Dining": 20, "Rent": 0}},
+		},
+		{
+			name:   "prefixed parameters are validated",
+			target: "/api/go/budgets?view=proposal&buffer_Dining=150&buffer_Rent=lots",
+			params: &BudgetProposalParams{},
+			errs:   ValidationErrors{{Field: "buffer_Dining", Message: "must be at most 100"}, {Field: "buffer_Rent", Message: "must be a number"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := DecodeQuery(httptest.NewRequest("GET", tt.target, nil), tt.params)
+			if !reflect.DeepEqual(errs, tt.errs) {
+				t.Errorf("errors %v, want %v", errs, tt.errs)
+			}
+			if tt.want != nil && !reflect.DeepEqual(tt.params, tt.want) {
+				t.Errorf("decoded %+v, want %+v", tt.params, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		errs  ValidationErrors
+	}{
+		{
+			name:  "valid",
+			input: &CreateBudgetInput{Category: "Food", Amount: 100, Period: "monthly"},
+		},
+		{
+			name:  "zero amount reports the range",
+			input: &CreateBudgetInput{Category: "Food", Period: "monthly"},
+			errs:  ValidationErrors{{Field: "amount", Message: "must be greater than 0"}},
+		},
+		{
+			name:  "missing string",
+			input: &CreateBudgetInput{Amount: 100, Period: "monthly"},
+			errs:  ValidationErrors{{Field: "category", Message: "is required"}},
+		},
+		{
+			name:  "nested lists",
+			input: &CreateBudgetsInput{Budgets: []CreateBudgetInput{{Category: "Food", Amount: 1, Period: "daily"}}},
+			errs:  ValidationErrors{{Field: "budgets[0].period", Message: "must be one of: weekly, monthly, yearly"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := Validate(tt.input); !reflect.DeepEqual(errs, tt.errs) {
+				t.Errorf("errors %v, want %v", errs, tt.errs)
+			}
+		})
+	}
+}
+
+func TestValidateDateRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		errs       ValidationErrors
+	}{
+		{"ordered", "2024-01-01", "2024-01-31", nil},
+		{"one day", "2024-01-01", "2024-01-01", nil},
+		{"open ended", "2024-01-01", "", nil},
+		{"inverted", "2024-02-01", "2024-01-31", ValidationErrors{{Field: "end_date", Message: "must not be before start_date"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := ValidateDateRange("start_date", tt.start, "end_date", tt.end); !reflect.DeepEqual(errs, tt.errs) {
+				t.Errorf("errors %v, want %v", errs, tt.errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,532 @@
+package lib
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Workspace member roles
+const (
+	RoleOwner  = "owner"  // full access, manages members and the workspace
+	RoleEditor = "editor" // reads and writes budgets and transactions
+	RoleViewer = "viewer" // read-only access
+)
+
+// Permission represents an action a member may perform in a workspace
+type Permission int
+
+const (
+	// PermissionRead allows reading shared data
+	PermissionRead Permission = iota + 1
+	// PermissionWrite allows creating, updating and deleting shared data
+	PermissionWrite
+	// PermissionManage allows managing members, invitations and the workspace
+	PermissionManage
+)
+
+// InvitationTTL is how long a workspace invitation token stays valid
+const InvitationTTL = 7 * 24 * time.Hour
+
+// ErrNotFound is returned by stores when a record does not exist
+var ErrNotFound = errors.New("not found")
+
+// ValidRole reports whether role is a known workspace role
+func ValidRole(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+// RoleAllows reports whether a role grants a permission
+func RoleAllows(role string, permission Permission) bool {
+	switch role {
+	case RoleOwner:
+		return true
+	case RoleEditor:
+		return permission <= PermissionWrite
+	case RoleViewer:
+		return permission <= PermissionRead
+	default:
+		return false
+	}
+}
+
+// WorkspaceStore persists workspaces, memberships and invitations
+type WorkspaceStore interface {
+	CreateWorkspace(ctx context.Context, workspace Workspace, owner WorkspaceMember) error
+	GetWorkspace(ctx context.Context, id string) (*Workspace, error)
+	DeleteWorkspace(ctx context.Context, id string) error
+	ListWorkspaces(ctx context.Context, userID string) ([]Workspace, error)
+	GetMember(ctx context.Context, workspaceID, userID string) (*WorkspaceMember, error)
+	ListMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error)
+	SaveMember(ctx context.Context, member WorkspaceMember) error
+	RemoveMember(ctx context.Context, workspaceID, userID string) error
+	SaveInvitation(ctx context.Context, invitation WorkspaceInvitation) error
+	GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*WorkspaceInvitation, error)
+}
+
+// Workspaces is the workspace store used by handlers. Every function runs in
+// its own serverless instance and memberships must be visible to all of them,
+// so when REDIS_URL is set workspaces are kept in that Redis-protocol server.
+// The in-memory store only suits a single process such as cmd/server.
+var Workspaces WorkspaceStore = InstrumentWorkspaceStore(newWorkspaceStore())
+
+func newWorkspaceStore() WorkspaceStore {
+	if client := newRedisClient("workspaces"); client != nil {
+		return &RedisWorkspaceStore{client: client}
+	}
+	return NewMemoryWorkspaceStore()
+}
+
+// MemoryWorkspaceStore is an in-memory WorkspaceStore
+type MemoryWorkspaceStore struct {
+	mu          sync.RWMutex
+	workspaces  map[string]Workspace
+	members     map[string]map[string]WorkspaceMember // workspace ID -> user ID -> member
+	invitations map[string]WorkspaceInvitation        // token hash -> invitation
+}
+
+// NewMemoryWorkspaceStore creates an empty in-memory workspace store
+func NewMemoryWorkspaceStore() *MemoryWorkspaceStore {
+	return &MemoryWorkspaceStore{
+		workspaces:  make(map[string]Workspace),
+		members:     make(map[string]map[string]WorkspaceMember),
+		invitations: make(map[string]WorkspaceInvitation),
+	}
+}
+
+// CreateWorkspace stores a workspace together with its owner membership
+func (s *MemoryWorkspaceStore) CreateWorkspace(ctx context.Context, workspace Workspace, owner WorkspaceMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workspaces[workspace.ID] = workspace
+	s.members[workspace.ID] = map[string]WorkspaceMember{owner.UserID: owner}
+	return nil
+}
+
+// GetWorkspace returns a workspace by ID
+func (s *MemoryWorkspaceStore) GetWorkspace(ctx context.Context, id string) (*Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	workspace, ok := s.workspaces[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &workspace, nil
+}
+
+// DeleteWorkspace removes a workspace, its members and its invitations
+func (s *MemoryWorkspaceStore) DeleteWorkspace(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.workspaces[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.workspaces, id)
+	delete(s.members, id)
+	for hash, invitation := range s.invitations {
+		if invitation.WorkspaceID == id {
+			delete(s.invitations, hash)
+		}
+	}
+	return nil
+}
+
+// ListWorkspaces returns the workspaces a user is a member of
+func (s *MemoryWorkspaceStore) ListWorkspaces(ctx context.Context, userID string) ([]Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	workspaces := []Workspace{}
+	for id, members := range s.members {
+		if _, ok := members[userID]; ok {
+			workspaces = append(workspaces, s.workspaces[id])
+		}
+	}
+	return workspaces, nil
+}
+
+// GetMember returns a user's membership in a workspace
+func (s *MemoryWorkspaceStore) GetMember(ctx context.Context, workspaceID, userID string) (*WorkspaceMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	member, ok := s.members[workspaceID][userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &member, nil
+}
+
+// ListMembers returns all members of a workspace
+func (s *MemoryWorkspaceStore) ListMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.workspaces[workspaceID]; !ok {
+		return nil, ErrNotFound
+	}
+	members := []WorkspaceMember{}
+	for _, member := range s.members[workspaceID] {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// SaveMember adds a member or updates an existing member's role
+func (s *MemoryWorkspaceStore) SaveMember(ctx context.Context, member WorkspaceMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	members, ok := s.members[member.WorkspaceID]
+	if !ok {
+		return ErrNotFound
+	}
+	members[member.UserID] = member
+	return nil
+}
+
+// RemoveMember removes a user from a workspace
+func (s *MemoryWorkspaceStore) RemoveMember(ctx context.Context, workspaceID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.members[workspaceID][userID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.members[workspaceID], userID)
+	return nil
+}
+
+// SaveInvitation creates or updates an invitation
+func (s *MemoryWorkspaceStore) SaveInvitation(ctx context.Context, invitation WorkspaceInvitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invitations[invitation.TokenHash] = invitation
+	return nil
+}
+
+// GetInvitationByTokenHash returns the invitation for a hashed token
+func (s *MemoryWorkspaceStore) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*WorkspaceInvitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	invitation, ok := s.invitations[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &invitation, nil
+}
+
+// RedisWorkspaceStore is a WorkspaceStore backed by a Redis-protocol server.
+// Records are stored as JSON: workspace:<id> holds the workspace,
+// workspace:<id>:members a hash of memberships by user ID and
+// workspace:<id>:invitations the token hashes of its invitations, which are
+// stored at invitation:<hash>. user:<id>:workspaces indexes memberships by user.
+type RedisWorkspaceStore struct {
+	client *RESPClient
+}
+
+// NewRedisWorkspaceStore creates a workspace store for a redis:// or rediss:// URL
+func NewRedisWorkspaceStore(redisURL string) (*RedisWorkspaceStore, error) {
+	client, err := NewRESPClient(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisWorkspaceStore{client: client}, nil
+}
+
+// storedInvitation keeps the token hash, which WorkspaceInvitation leaves out
+// of its JSON
+type storedInvitation struct {
+	WorkspaceInvitation
+	TokenHash string `json:"token_hash"`
+}
+
+func workspaceKey(id string) string        { return "workspace:" + id }
+func workspaceMembersKey(id string) string { return "workspace:" + id + ":members" }
+func workspaceInvitesKey(id string) string { return "workspace:" + id + ":invitations" }
+func userWorkspacesKey(id string) string   { return "user:" + id + ":workspaces" }
+func invitationKey(hash string) string     { return "invitation:" + hash }
+
+// Ping checks the server answers PING
+func (s *RedisWorkspaceStore) Ping(ctx context.Context) error {
+	return s.client.Ping()
+}
+
+// CreateWorkspace stores a workspace together with its owner membership
+func (s *RedisWorkspaceStore) CreateWorkspace(ctx context.Context, workspace Workspace, owner WorkspaceMember) error {
+	workspaceJSON, err := json.Marshal(workspace)
+	if err != nil {
+		return err
+	}
+	ownerJSON, err := json.Marshal(owner)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Transaction(
+		[]string{"SET", workspaceKey(workspace.ID), string(workspaceJSON)},
+		[]string{"DEL", workspaceMembersKey(workspace.ID)},
+		[]string{"HSET", workspaceMembersKey(workspace.ID), owner.UserID, string(ownerJSON)},
+		[]string{"SADD", userWorkspacesKey(owner.UserID), workspace.ID},
+	)
+	return err
+}
+
+// GetWorkspace returns a workspace by ID
+func (s *RedisWorkspaceStore) GetWorkspace(ctx context.Context, id string) (*Workspace, error) {
+	var workspace Workspace
+	if err := s.client.getJSON(workspaceKey(id), &workspace); err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+// DeleteWorkspace removes a workspace, its members and its invitations
+func (s *RedisWorkspaceStore) DeleteWorkspace(ctx context.Context, id string) error {
+	if _, err := s.GetWorkspace(ctx, id); err != nil {
+		return err
+	}
+	reply, err := s.client.Do("HKEYS", workspaceMembersKey(id))
+	if err != nil {
+		return err
+	}
+	userIDs, err := respStrings(reply)
+	if err != nil {
+		return err
+	}
+	if reply, err = s.client.Do("SMEMBERS", workspaceInvitesKey(id)); err != nil {
+		return err
+	}
+	hashes, err := respStrings(reply)
+	if err != nil {
+		return err
+	}
+
+	commands := [][]string{{"DEL", workspaceKey(id), workspaceMembersKey(id), workspaceInvitesKey(id)}}
+	for _, userID := range userIDs {
+		commands = append(commands, []string{"SREM", userWorkspacesKey(userID), id})
+	}
+	for _, hash := range hashes {
+		commands = append(commands, []string{"DEL", invitationKey(hash)})
+	}
+	_, err = s.client.Transaction(commands...)
+	return err
+}
+
+// ListWorkspaces returns the workspaces a user is a member of
+func (s *RedisWorkspaceStore) ListWorkspaces(ctx context.Context, userID string) ([]Workspace, error) {
+	reply, err := s.client.Do("SMEMBERS", userWorkspacesKey(userID))
+	if err != nil {
+		return nil, err
+	}
+	ids, err := respStrings(reply)
+	if err != nil {
+		return nil, err
+	}
+	workspaces := []Workspace{}
+	if len(ids) == 0 {
+		return workspaces, nil
+	}
+
+	keys := []string{"MGET"}
+	for _, id := range ids {
+		keys = append(keys, workspaceKey(id))
+	}
+	if reply, err = s.client.Do(keys...); err != nil {
+		return nil, err
+	}
+	values, err := respStrings(reply)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		var workspace Workspace
+		if err := json.Unmarshal([]byte(value), &workspace); err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	return workspaces, nil
+}
+
+// GetMember returns a user's membership in a workspace
+func (s *RedisWorkspaceStore) GetMember(ctx context.Context, workspaceID, userID string) (*WorkspaceMember, error) {
+	reply, err := s.client.Do("HGET", workspaceMembersKey(workspaceID), userID)
+	if err != nil {
+		return nil, err
+	}
+	var member WorkspaceMember
+	if err := decodeRESPJSON(reply, &member); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// ListMembers returns all members of a workspace
+func (s *RedisWorkspaceStore) ListMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	replies, err := s.client.Transaction(
+		[]string{"EXISTS", workspaceKey(workspaceID)},
+		[]string{"HVALS", workspaceMembersKey(workspaceID)},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if replies[0] != int64(1) {
+		return nil, ErrNotFound
+	}
+	values, err := respStrings(replies[1])
+	if err != nil {
+		return nil, err
+	}
+	members := []WorkspaceMember{}
+	for _, value := range values {
+		var member WorkspaceMember
+		if err := json.Unmarshal([]byte(value), &member); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// SaveMember adds a member or updates an existing member's role
+func (s *RedisWorkspaceStore) SaveMember(ctx context.Context, member WorkspaceMember) error {
+	if _, err := s.GetWorkspace(ctx, member.WorkspaceID); err != nil {
+		return err
+	}
+	data, err := json.Marshal(member)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Transaction(
+		[]string{"HSET", workspaceMembersKey(member.WorkspaceID), member.UserID, string(data)},
+		[]string{"SADD", userWorkspacesKey(member.UserID), member.WorkspaceID},
+	)
+	return err
+}
+
+// RemoveMember removes a user from a workspace
+func (s *RedisWorkspaceStore) RemoveMember(ctx context.Context, workspaceID, userID string) error {
+	replies, err := s.client.Transaction(
+		[]string{"HDEL", workspaceMembersKey(workspaceID), userID},
+		[]string{"SREM", userWorkspacesKey(userID), workspaceID},
+	)
+	if err != nil {
+		return err
+	}
+	if replies[0] != int64(1) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SaveInvitation creates or updates an invitation
+func (s *RedisWorkspaceStore) SaveInvitation(ctx context.Context, invitation WorkspaceInvitation) error {
+	data, err := json.Marshal(storedInvitation{invitation, invitation.TokenHash})
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Transaction(
+		[]string{"SET", invitationKey(invitation.TokenHash), string(data)},
+		[]string{"SADD", workspaceInvitesKey(invitation.WorkspaceID), invitation.TokenHash},
+	)
+	return err
+}
+
+// GetInvitationByTokenHash returns the invitation for a hashed token
+func (s *RedisWorkspaceStore) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*WorkspaceInvitation, error) {
+	var stored storedInvitation
+	if err := s.client.getJSON(invitationKey(tokenHash), &stored); err != nil {
+		return nil, err
+	}
+	stored.WorkspaceInvitation.TokenHash = stored.TokenHash
+	return &stored.WorkspaceInvitation, nil
+}
+
+// NewInvitationToken returns a random invitation token and its hash. Only
+// the hash is stored; the token itself is sent to the invitee.
+func NewInvitationToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(buf)
+	return token, HashInvitationToken(token), nil
+}
+
+// HashInvitationToken hashes an invitation token for storage and lookup
+func HashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewID returns a random identifier with the given prefix
+func NewID(prefix string) string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return prefix + "-" + hex.EncodeToString(buf)
+}
+
+// WorkspaceAccess represents the workspace scope of a request. An empty
+// WorkspaceID means the user's personal data.
+type WorkspaceAccess struct {
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	Role        string `json:"role"`
+}
+
+const (
+	// WorkspaceContextKey is the context key for workspace access
+	WorkspaceContextKey ContextKey = "workspace"
+)
+
+// GetWorkspaceFromContext retrieves workspace access from request context
+func GetWorkspaceFromContext(r *http.Request) (*WorkspaceAccess, bool) {
+	access, ok := r.Context().Value(WorkspaceContextKey).(*WorkspaceAccess)
+	return access, ok
+}
+
+// WorkspaceIDFromContext returns the request's workspace ID, or "" for personal data
+func WorkspaceIDFromContext(r *http.Request) string {
+	if access, ok := GetWorkspaceFromContext(r); ok {
+		return access.WorkspaceID
+	}
+	return ""
+}
+
+// AuthorizeWorkspace resolves the workspace named by the workspace_id query
+// parameter and checks the user's role allows the request. Reads need the
+// viewer role and anything else the editor role. It writes an error response
+// and returns false when access is denied.
+func AuthorizeWorkspace(w http.ResponseWriter, r *http.Request, user *User) (*http.Request, bool) {
+	workspaceID := strings.TrimSpace(r.URL.Query().Get("workspace_id"))
+
+	access := &WorkspaceAccess{Role: RoleOwner}
+	if workspaceID != "" {
+		member, err := Workspaces.GetMember(r.Context(), workspaceID, user.ID)
+		if errors.Is(err, ErrNotFound) {
+			// Don't reveal whether a workspace exists to non-members
+			WriteError(w, NotFoundError("Workspace not found"))
+			return r, false
+		}
+		if err != nil {
+			WriteError(w, InternalError("Failed to check workspace membership", err))
+			return r, false
+		}
+
+		permission := PermissionWrite
+		if r.Method == "GET" || r.Method == "HEAD" {
+			permission = PermissionRead
+		}
+		if !RoleAllows(member.Role, permission) {
+			WriteError(w, ForbiddenError("Forbidden", map[string]string{
+				"role": member.Role,
+			}))
+			return r, false
+		}
+		access = &WorkspaceAccess{WorkspaceID: workspaceID, Role: member.Role}
+	}
+
+	ctx := context.WithValue(r.Context(), WorkspaceContextKey, access)
+	return r.WithContext(ctx), true
+}
@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWorkspaceStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) WorkspaceStore{
+		"memory": func(t *testing.T) WorkspaceStore { return NewMemoryWorkspaceStore() },
+		"redis": func(t *testing.T) WorkspaceStore {
+			return &RedisWorkspaceStore{client: newTestRESPClient(t)}
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			testWorkspaceStore(t, newStore(t))
+		})
+	}
+}
+
+func testWorkspaceStore(t *testing.T, store WorkspaceStore) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	workspace := Workspace{ID: "ws-1", Name: "Household", OwnerID: "alice", CreatedAt: now, UpdatedAt: now}
+	owner := WorkspaceMember{WorkspaceID: "ws-1", UserID: "alice", Role: RoleOwner, JoinedAt: now}
+	if err := store.CreateWorkspace(ctx, workspace, owner); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.GetWorkspace(ctx, "ws-1")
+	if err != nil || got.Name != "Household" || !got.CreatedAt.Equal(now) {
+		t.Fatalf("GetWorkspace = %+v, %v", got, err)
+	}
+	if _, err := store.GetWorkspace(ctx, "ws-missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetWorkspace of a missing workspace: %v, want ErrNotFound", err)
+	}
+
+	bob := WorkspaceMember{WorkspaceID: "ws-1", UserID: "bob", Role: RoleViewer, JoinedAt: now}
+	if err := store.SaveMember(ctx, bob); err != nil {
+		t.Fatal(err)
+	}
+	bob.Role = RoleEditor
+	if err := store.SaveMember(ctx, bob); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveMember(ctx, WorkspaceMember{WorkspaceID: "ws-missing", UserID: "bob"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SaveMember to a missing workspace: %v, want ErrNotFound", err)
+	}
+	member, err := store.GetMember(ctx, "ws-1", "bob")
+	if err != nil || member.Role != RoleEditor {
+		t.Errorf("GetMember = %+v, %v; want editor", member, err)
+	}
+	members, err := store.ListMembers(ctx, "ws-1")
+	if err != nil || len(members) != 2 {
+		t.Errorf("ListMembers = %+v, %v; want 2 members", members, err)
+	}
+	if _, err := store.ListMembers(ctx, "ws-missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ListMembers of a missing workspace: %v, want ErrNotFound", err)
+	}
+	if workspaces, err := store.ListWorkspaces(ctx, "bob"); err != nil || len(workspaces) != 1 || workspaces[0].ID != "ws-1" {
+		t.Errorf("ListWorkspaces(bob) = %+v, %v", workspaces, err)
+	}
+
+	invitation := WorkspaceInvitation{ID: "inv-1", WorkspaceID: "ws-1", Email: "carol@example.com", Role: RoleViewer, TokenHash: "hash-1", ExpiresAt: now.Add(InvitationTTL)}
+	if err := store.SaveInvitation(ctx, invitation); err != nil {
+		t.Fatal(err)
+	}
+	found, err := store.GetInvitationByTokenHash(ctx, "hash-1")
+	if err != nil || found.ID != "inv-1" || found.TokenHash != "hash-1" {
+		t.Errorf("GetInvitationByTokenHash = %+v, %v", found, err)
+	}
+
+	if err := store.RemoveMember(ctx, "ws-1", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RemoveMember(ctx, "ws-1", "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RemoveMember twice: %v, want ErrNotFound", err)
+	}
+	if workspaces, err := store.ListWorkspaces(ctx, "bob"); err != nil || len(workspaces) != 0 {
+		t.Errorf("ListWorkspaces(bob) after removal = %+v, %v", workspaces, err)
+	}
+
+	if err := store.DeleteWorkspace(ctx, "ws-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetMember(ctx, "ws-1", "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetMember after delete: %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetInvitationByTokenHash(ctx, "hash-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetInvitationByTokenHash after delete: %v, want ErrNotFound", err)
+	}
+	if workspaces, err := store.ListWorkspaces(ctx, "alice"); err != nil || len(workspaces) != 0 {
+		t.Errorf("ListWorkspaces(alice) after delete = %+v, %v", workspaces, err)
+	}
+	if err := store.DeleteWorkspace(ctx, "ws-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteWorkspace twice: %v, want ErrNotFound", err)
+	}
+}
+
+func TestAuthorizeWorkspace(t *testing.T) {
+	previous := Workspaces
+	t.Cleanup(func() { Workspaces = previous })
+	Workspaces = NewMemoryWorkspaceStore()
+	ctx := context.Background()
+	Workspaces.CreateWorkspace(ctx, Workspace{ID: "ws-1", OwnerID: "alice"}, WorkspaceMember{WorkspaceID: "ws-1", UserID: "alice", Role: RoleOwner})
+	Workspaces.SaveMember(ctx, WorkspaceMember{WorkspaceID: "ws-1", UserID: "vic", Role: RoleViewer})
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		query  string
+		status int // 0 when access is granted
+		role   string
+	}{
+		{"personal data", "mallory", http.MethodPost, "", 0, RoleOwner},
+		{"owner writes", "alice", http.MethodPost, "?workspace_id=ws-1", 0, RoleOwner},
+		{"viewer reads", "vic", http.MethodGet, "?workspace_id=ws-1", 0, RoleViewer},
+		{"viewer cannot write", "vic", http.MethodPut, "?workspace_id=ws-1", http.StatusForbidden, ""},
+		{"non-member", "mallory", http.MethodGet, "?workspace_id=ws-1", http.StatusNotFound, ""},
+		{"unknown workspace", "alice", http.MethodGet, "?workspace_id=ws-2", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, "/api/go/transactions"+tt.query, nil)
+			r, ok := AuthorizeWorkspace(w, r, &User{ID: tt.user})
+			if tt.status != 0 {
+				if ok || w.Code != tt.status {
+					t.Errorf("granted %v with status %d, want status %d", ok, w.Code, tt.status)
+				}
+				return
+			}
+			access, _ := GetWorkspaceFromContext(r)
+			if !ok || access == nil || access.Role != tt.role {
+				t.Errorf("granted %v with access %+v, want role %s", ok, access, tt.role)
+			}
+		})
+	}
+}
+
+func TestAuthorizeWorkspaceStoreFailure(t *testing.T) {
+	previous, previousLogger := Workspaces, Logger
+	t.Cleanup(func() { Workspaces, Logger = previous, previousLogger })
+	Logger = NewLogger(io.Discard, "error")
+	unreachable, err := NewRedisWorkspaceStore("redis://127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Workspaces = unreachable
+
+	// An unreachable store is an outage, not a sign the user isn't a member
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/go/transactions?workspace_id=ws-1", nil)
+	if _, ok := AuthorizeWorkspace(w, r, &User{ID: "alice"}); ok || w.Code != http.StatusInternalServerError {
+		t.Errorf("granted %v with status %d, want status 500", ok, w.Code)
+	}
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// Metrics serves request and storage metrics to Prometheus-compatible scrapers
+func Metrics(w http.ResponseWriter, r *http.Request) {
+	config := lib.Config{
+		AllowedMethods: []string{"GET"},
+	}
+
+	handler := lib.CreateHandler(metricsHandler, config)
+	handler(w, r)
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	// Scrapers authenticate with a shared token rather than a user session
+	token := lib.GetEnv("METRICS_TOKEN", "")
+	if token == "" {
+		lib.WriteError(w, lib.NotFoundError("Metrics are disabled"))
+		return
+	}
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		lib.WriteError(w, lib.UnauthorizedError())
+		return
+	}
+
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", lib.OpenMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", lib.PrometheusContentType)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	lib.Metrics.WriteText(w, openMetrics)
+}
@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// Splits handles shared expense splitting and settle-up between workspace members
+func Splits(w http.ResponseWriter, r *http.Request) {
+	config := lib.Config{
+		RequireAuth:     true,
+		AllowedMethods:  []string{"GET", "POST", "DELETE"},
+		CORS:            lib.DefaultCORS().AllowHeaders("Idempotency-Key").ExposeHeaders("Idempotent-Replayed"),
+		WorkspaceScoped: true,
+		Idempotent:      true,
+		RateLimit:       lib.APIRateLimit(),
+	}
+
+	handler := lib.CreateHandler(splitHandler, config)
+	handler(w, r)
+}
+
+func splitHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := lib.GetUserFromContext(r)
+	if !ok {
+		lib.WriteError(w, lib.UnauthorizedError())
+		return
+	}
+
+	// Splitting only makes sense between the members of a shared workspace
+	workspaceID := lib.WorkspaceIDFromContext(r)
+	if workspaceID == "" {
+		lib.WriteError(w, lib.BadRequestError("Workspace ID required", map[string]string{
+			"hint": "Pass workspace_id as a query parameter",
+		}))
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		if lib.GetQueryParam(r, "view", "") == "balances" {
+			handleGetBalances(w, r, workspaceID)
+			return
+		}
+		handleGetSharedExpenses(w, r, workspaceID)
+	case "POST":
+		if lib.GetQueryParam(r, "action", "") == "settle" {
+			handleCreateSettlement(w, r, user, workspaceID)
+			return
+		}
+		handleCreateSharedExpense(w, r, user, workspaceID)
+	case "DELETE":
+		handleDeleteSharedExpense(w, r, user, workspaceID)
+	default:
+		lib.WriteError(w, lib.MethodNotAllowedError(nil))
+	}
+}
+
+func handleGetSharedExpenses(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	expenses, err := lib.Splits.ListExpenses(r.Context(), workspaceID)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to list expenses", err))
+		return
+	}
+
+	settlements, err := lib.Splits.ListSettlements(r.Context(), workspaceID)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to list settlements", err))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.SharedExpenseListResponse{
+		Expenses:    expenses,
+		Settlements: settlements,
+	}, http.StatusOK)
+}
+
+func handleGetBalances(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	expenses, err := lib.Splits.ListExpenses(r.Context(), workspaceID)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to list expenses", err))
+		return
+	}
+
+	settlements, err := lib.Splits.ListSettlements(r.Context(), workspaceID)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to list settlements", err))
+		return
+	}
+
+	balances := lib.ComputeBalances(expenses, settlements)
+
+	lib.SuccessResponse(w, lib.SplitBalancesResponse{
+		Balances:  balances,
+		Transfers: lib.SettleUp(balances),
+	}, http.StatusOK)
+}
+
+func handleCreateSharedExpense(w http.ResponseWriter, r *http.Request, user *lib.User, workspaceID string) {
+	var input lib.CreateSharedExpenseInput
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	if input.PaidBy == "" {
+		input.PaidBy = user.ID
+	}
+
+	if input.SplitMethod == "" {
+		input.SplitMethod = lib.SplitEqual
+	}
+
+	date := parseSplitDate(input.Date)
+
+	splits, err := lib.SplitExpense(input.Amount, input.SplitMethod, input.Participants)
+	if err != nil {
+		lib.WriteError(w, lib.BadRequestError("Invalid split", map[string]string{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	userIDs := []string{input.PaidBy}
+	for _, s := range splits {
+		userIDs = append(userIDs, s.UserID)
+	}
+	if !requireMembers(w, r, workspaceID, userIDs) {
+		return
+	}
+
+	expense := lib.SharedExpense{
+		ID:          lib.NewID("expense"),
+		WorkspaceID: workspaceID,
+		PaidBy:      input.PaidBy,
+		Description: input.Description,
+		Category:    input.Category,
+		Amount:      input.Amount,
+		SplitMethod: input.SplitMethod,
+		Splits:      splits,
+		Date:        date,
+		CreatedBy:   user.ID,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditCreate, Resource: "shared_expense", ResourceID: expense.ID, After: expense}) {
+		return
+	}
+
+	if err := lib.Splits.SaveExpense(r.Context(), expense); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to save expense", err))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.SharedExpenseResponse{
+		Expense: expense,
+	}, http.StatusCreated)
+}
+
+func handleCreateSettlement(w http.ResponseWriter, r *http.Request, user *lib.User, workspaceID string) {
+	var input lib.CreateSettlementInput
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	if input.FromUserID == "" {
+		input.FromUserID = user.ID
+	}
+
+	// Members record repayments they made or received; only the owner may
+	// record one between two other members
+	if user.ID != input.FromUserID && user.ID != input.ToUserID && !isWorkspaceOwner(r) {
+		lib.WriteError(w, lib.ForbiddenError("Only the payer, the recipient or the workspace owner can record a settlement", nil))
+		return
+	}
+
+	if input.ToUserID == input.FromUserID {
+		lib.WriteError(w, lib.ValidationError(lib.ValidationErrors{
+			{Field: "to_user_id", Message: "must differ from from_user_id"},
+		}))
+		return
+	}
+
+	date := parseSplitDate(input.Date)
+
+	if !requireMembers(w, r, workspaceID, []string{input.FromUserID, input.ToUserID}) {
+		return
+	}
+
+	settlement := lib.Settlement{
+		ID:          lib.NewID("settlement"),
+		WorkspaceID: workspaceID,
+		FromUserID:  input.FromUserID,
+		ToUserID:    input.ToUserID,
+		Amount:      input.Amount,
+		Date:        date,
+		CreatedBy:   user.ID,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditCreate, Resource: "settlement", ResourceID: settlement.ID, After: settlement}) {
+		return
+	}
+
+	if err := lib.Splits.SaveSettlement(r.Context(), settlement); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to save settlement", err))
+		return
+	}
+
+	// TODO: Insert the settlement transactions into database
+	lib.SuccessResponse(w, lib.SettlementResponse{
+		Settlement:   settlement,
+		Transactions: lib.SettlementTransactions(settlement),
+	}, http.StatusCreated)
+}
+
+func handleDeleteSharedExpense(w http.ResponseWriter, r *http.Request, user *lib.User, workspaceID string) {
+	id := lib.GetQueryParam(r, "id", "")
+	if id == "" {
+		lib.WriteError(w, lib.BadRequestError("Expense ID required", nil))
+		return
+	}
+
+	// The expense is looked up first to check who may delete it and so the
+	// audit record keeps what was deleted
+	expenses, err := lib.Splits.ListExpenses(r.Context(), workspaceID)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to load expenses", err))
+		return
+	}
+	var expense *lib.SharedExpense
+	for i := range expenses {
+		if expenses[i].ID == id {
+			expense = &expenses[i]
+		}
+	}
+	if expense == nil {
+		lib.WriteError(w, lib.NotFoundError("Expense not found"))
+		return
+	}
+	if expense.CreatedBy != user.ID && !isWorkspaceOwner(r) {
+		lib.WriteError(w, lib.ForbiddenError("Only the member who added an expense or the workspace owner can delete it", nil))
+		return
+	}
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditDelete, Resource: "shared_expense", ResourceID: id, Before: *expense}) {
+		return
+	}
+
+	if err := lib.Splits.DeleteExpense(r.Context(), workspaceID, id); err != nil {
+		lib.WriteError(w, lib.NotFoundError("Expense not found"))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.DeleteResponse{
+		Message: "Expense deleted successfully",
+		ID:      id,
+	}, http.StatusOK)
+}
+
+// requireMembers checks every user belongs to the workspace, writing an error
+// response listing the non-members when not
+func requireMembers(w http.ResponseWriter, r *http.Request, workspaceID string, userIDs []string) bool {
+	var missing []string
+	for _, id := range userIDs {
+		if _, err := lib.Workspaces.GetMember(r.Context(), workspaceID, id); err != nil {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		lib.WriteError(w, lib.BadRequestError("All participants must be workspace members", map[string]interface{}{
+			"not_members": missing,
+		}))
+		return false
+	}
+	return true
+}
+
+// isWorkspaceOwner reports whether the caller owns the request's workspace
+func isWorkspaceOwner(r *http.Request) bool {
+	access, ok := lib.GetWorkspaceFromContext(r)
+	return ok && access.Role == lib.RoleOwner
+}
+
+// parseSplitDate parses an already validated date, defaulting to today
+func parseSplitDate(value string) time.Time {
+	if value == "" {
+		return time.Now().UTC()
+	}
+	date, _ := time.Parse("2006-01-02", value)
+	return date
+}
@@ -2,17 +2,20 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
+	"time"
 	
 	"github.com/budget-buddy/api/lib"
 )
 
-// Handler handles transaction CRUD operations
-func Handler(w http.ResponseWriter, r *http.Request) {
+// Transactions handles transaction CRUD operations
+func Transactions(w http.ResponseWriter, r *http.Request) {
 	config := lib.Config{
-		RequireAuth:    true,
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
-		EnableCORS:     true,
+		RequireAuth:     true,
+		AllowedMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		CORS:            lib.DefaultCORS().AllowHeaders("If-Match", "Idempotency-Key").ExposeHeaders("ETag", "Idempotent-Replayed"),
+		WorkspaceScoped: true,
+		Idempotent:      true,
+		RateLimit:       lib.APIRateLimit(),
 	}
 
 	handler := lib.CreateHandler(transactionHandler, config)
@@ -22,162 +25,315 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 func transactionHandler(w http.ResponseWriter, r *http.Request) {
 	user, ok := lib.GetUserFromContext(r)
 	if !ok {
-		lib.ErrorResponse(w, "Unauthorized", http.StatusUnauthorized, nil)
+		lib.WriteError(w, lib.UnauthorizedError())
 		return
 	}
 
 	switch r.Method {
 	case "GET":
+		if lib.GetQueryParam(r, "id", "") != "" {
+			handleGetTransaction(w, r, user)
+			return
+		}
 		handleGetTransactions(w, r, user)
 	case "POST":
 		handleCreateTransaction(w, r, user)
 	case "PUT":
 		handleUpdateTransaction(w, r, user)
+	case "PATCH":
+		handlePatchTransaction(w, r, user)
 	case "DELETE":
 		handleDeleteTransaction(w, r, user)
 	default:
-		lib.ErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed, nil)
+		lib.WriteError(w, lib.MethodNotAllowedError(nil))
 	}
 }
 
 func handleGetTransactions(w http.ResponseWriter, r *http.Request, user *lib.User) {
-	// Parse query parameters
-	limitStr := lib.GetQueryParam(r, "limit", "50")
-	offsetStr := lib.GetQueryParam(r, "offset", "0")
-	transactionType := lib.GetQueryParam(r, "type", "")
-	category := lib.GetQueryParam(r, "category", "")
-
-	limit, _ := strconv.Atoi(limitStr)
-	offset, _ := strconv.Atoi(offsetStr)
+	var params lib.TransactionListParams
+	if !lib.BindQuery(w, r, &params) {
+		return
+	}
 
-	// TODO: Query database
+	// TODO: Query database, scoped to lib.WorkspaceIDFromContext(r) when set
 	// For now, return mock data
-	transactions := []map[string]interface{}{
+	transactions := []lib.Transaction{
 		{
-			"id":          "trans-1",
-			"user_id":     user.ID,
-			"amount":      100.50,
-			"category":    "Groceries",
-			"type":        "expense",
-			"description": "Weekly shopping",
-			"date":        "2024-01-15T10:00:00Z",
+			ID:          "trans-1",
+			UserID:      user.ID,
+			Amount:      100.50,
+			Category:    "Groceries",
+			Type:        "expense",
+			Description: "Weekly shopping",
+			Date:        time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+			CreatedAt:   time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+			UpdatedAt:   time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
 		},
 	}
+	transactions, err := lib.ExcludeTrashedTransactions(r.Context(), transactions, params.IncludeDeleted)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to load trash", err))
+		return
+	}
 
-	// Apply filters
-	var filtered []map[string]interface{}
+	// Apply filters and calculate summary; trashed transactions are listed but not totalled
+	filtered := []lib.Transaction{}
+	var summary lib.TransactionSummary
 	for _, t := range transactions {
-		if transactionType != "" && t["type"] != transactionType {
+		if params.Type != "" && t.Type != params.Type {
 			continue
 		}
-		if category != "" && t["category"] != category {
+		if params.Category != "" && t.Category != params.Category {
 			continue
 		}
 		filtered = append(filtered, t)
+		if t.DeletedAt != nil {
+			continue
+		}
+		if t.Type == "income" {
+			summary.TotalIncome += t.Amount
+		} else {
+			summary.TotalExpenses += t.Amount
+		}
 	}
+	summary.Count = len(filtered)
 
-	// Calculate summary
-	summary := map[string]interface{}{
-		"totalIncome":   0.0,
-		"totalExpenses": 100.50,
-		"count":         len(filtered),
-	}
-
-	response := map[string]interface{}{
-		"transactions": filtered,
-		"summary":      summary,
-		"pagination": map[string]interface{}{
-			"total":   len(filtered),
-			"limit":   limit,
-			"offset":  offset,
-			"hasMore": false,
+	lib.SuccessResponse(w, lib.TransactionListResponse{
+		Transactions: filtered,
+		Summary:      summary,
+		Pagination: lib.Pagination{
+			Total:   len(filtered),
+			Limit:   params.Limit,
+			Offset:  params.Offset,
+			HasMore: false,
 		},
+	}, http.StatusOK)
+}
+
+func handleGetTransaction(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	transaction := getTransaction(r, user, lib.GetQueryParam(r, "id", ""))
+	if transaction == nil {
+		lib.WriteError(w, lib.NotFoundError("Transaction not found"))
+		return
 	}
 
-	lib.SuccessResponse(w, response, http.StatusOK)
+	w.Header().Set("ETag", lib.ETag(transaction.UpdatedAt))
+	lib.SuccessResponse(w, lib.TransactionResponse{
+		Transaction: *transaction,
+	}, http.StatusOK)
 }
 
 func handleCreateTransaction(w http.ResponseWriter, r *http.Request, user *lib.User) {
 	var input lib.CreateTransactionInput
-	if err := lib.ParseJSONBody(r, &input); err != nil {
-		lib.ErrorResponse(w, "Invalid JSON body", http.StatusBadRequest, map[string]string{
-			"error": err.Error(),
-		})
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	date := parseTransactionDate(input.Date)
+
+	// TODO: Insert into database
+	now := time.Now().UTC()
+	transaction := lib.Transaction{
+		ID:            "trans-new",
+		UserID:        user.ID,
+		WorkspaceID:   lib.WorkspaceIDFromContext(r),
+		Amount:        input.Amount,
+		Category:      input.Category,
+		Type:          input.Type,
+		Description:   input.Description,
+		Date:          date,
+		Merchant:      input.Merchant,
+		PaymentMethod: input.PaymentMethod,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditCreate, Resource: "transaction", ResourceID: transaction.ID, After: transaction}) {
 		return
 	}
 
-	// Validate input
-	if input.Amount <= 0 {
-		lib.ErrorResponse(w, "Amount must be positive", http.StatusBadRequest, nil)
+	w.Header().Set("ETag", lib.ETag(transaction.UpdatedAt))
+	lib.SuccessResponse(w, lib.TransactionResponse{
+		Transaction: transaction,
+	}, http.StatusCreated)
+}
+
+func handleUpdateTransaction(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	id := lib.GetQueryParam(r, "id", "")
+	if id == "" {
+		lib.WriteError(w, lib.BadRequestError("Transaction ID required", nil))
 		return
 	}
 
-	if input.Category == "" {
-		lib.ErrorResponse(w, "Category is required", http.StatusBadRequest, nil)
+	transaction := getTransaction(r, user, id)
+	if transaction == nil {
+		lib.WriteError(w, lib.NotFoundError("Transaction not found"))
 		return
 	}
 
-	if input.Type != "income" && input.Type != "expense" {
-		lib.ErrorResponse(w, "Type must be 'income' or 'expense'", http.StatusBadRequest, nil)
+	if !lib.CheckIfMatch(w, r, lib.ETag(transaction.UpdatedAt)) {
 		return
 	}
 
-	// TODO: Insert into database
-	transaction := map[string]interface{}{
-		"id":             "trans-new",
-		"user_id":        user.ID,
-		"amount":         input.Amount,
-		"category":       input.Category,
-		"type":           input.Type,
-		"description":    input.Description,
-		"date":           input.Date,
-		"merchant":       input.Merchant,
-		"payment_method": input.PaymentMethod,
-		"created_at":     "2024-01-15T10:00:00Z",
-	}
-
-	lib.SuccessResponse(w, map[string]interface{}{
-		"transaction": transaction,
-	}, http.StatusCreated)
+	var input lib.UpdateTransactionInput
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	before := *transaction
+	if input.Amount != nil {
+		transaction.Amount = *input.Amount
+	}
+	if input.Category != nil {
+		transaction.Category = *input.Category
+	}
+	if input.Type != nil {
+		transaction.Type = *input.Type
+	}
+	if input.Date != nil {
+		transaction.Date = parseTransactionDate(*input.Date)
+	}
+	if input.Description != nil {
+		transaction.Description = *input.Description
+	}
+	if input.Merchant != nil {
+		transaction.Merchant = *input.Merchant
+	}
+	if input.PaymentMethod != nil {
+		transaction.PaymentMethod = *input.PaymentMethod
+	}
+	transaction.UpdatedAt = time.Now().UTC()
+
+	// TODO: Update in database, conditional on the UpdatedAt that was checked
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditUpdate, Resource: "transaction", ResourceID: id, Before: before, After: *transaction}) {
+		return
+	}
+
+	w.Header().Set("ETag", lib.ETag(transaction.UpdatedAt))
+	lib.SuccessResponse(w, lib.TransactionResponse{
+		Transaction: *transaction,
+	}, http.StatusOK)
 }
 
-func handleUpdateTransaction(w http.ResponseWriter, r *http.Request, user *lib.User) {
+// handlePatchTransaction applies a JSON merge patch (RFC 7396) to a transaction
+func handlePatchTransaction(w http.ResponseWriter, r *http.Request, user *lib.User) {
 	id := lib.GetQueryParam(r, "id", "")
 	if id == "" {
-		lib.ErrorResponse(w, "Transaction ID required", http.StatusBadRequest, nil)
+		lib.WriteError(w, lib.BadRequestError("Transaction ID required", nil))
 		return
 	}
 
-	var input map[string]interface{}
-	if err := lib.ParseJSONBody(r, &input); err != nil {
-		lib.ErrorResponse(w, "Invalid JSON body", http.StatusBadRequest, nil)
+	transaction := getTransaction(r, user, id)
+	if transaction == nil {
+		lib.WriteError(w, lib.NotFoundError("Transaction not found"))
+		return
+	}
+
+	if !lib.CheckIfMatch(w, r, lib.ETag(transaction.UpdatedAt)) {
+		return
+	}
+
+	// The patch is applied to the editable fields and validated like a create
+	input := lib.CreateTransactionInput{
+		Amount:        transaction.Amount,
+		Category:      transaction.Category,
+		Type:          transaction.Type,
+		Description:   transaction.Description,
+		Date:          transaction.Date.Format(time.RFC3339),
+		Merchant:      transaction.Merchant,
+		PaymentMethod: transaction.PaymentMethod,
+	}
+	if !lib.BindMergePatch(w, r, &input) {
+		return
+	}
+	// A transaction always has a date; an empty one would become now on create
+	if input.Date == "" {
+		lib.WriteError(w, lib.ValidationError(lib.ValidationErrors{{Field: "date", Message: "cannot be cleared"}}))
 		return
 	}
 
-	// TODO: Update in database
-	transaction := map[string]interface{}{
-		"id":      id,
-		"user_id": user.ID,
-		"updated": true,
+	before := *transaction
+	transaction.Amount = input.Amount
+	transaction.Category = input.Category
+	transaction.Type = input.Type
+	transaction.Description = input.Description
+	transaction.Date = parseTransactionDate(input.Date)
+	transaction.Merchant = input.Merchant
+	transaction.PaymentMethod = input.PaymentMethod
+	transaction.UpdatedAt = time.Now().UTC()
+
+	// TODO: Update in database, conditional on the UpdatedAt that was checked
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditUpdate, Resource: "transaction", ResourceID: id, Before: before, After: *transaction}) {
+		return
 	}
 
-	lib.SuccessResponse(w, map[string]interface{}{
-		"transaction": transaction,
+	w.Header().Set("ETag", lib.ETag(transaction.UpdatedAt))
+	lib.SuccessResponse(w, lib.TransactionResponse{
+		Transaction: *transaction,
 	}, http.StatusOK)
 }
 
 func handleDeleteTransaction(w http.ResponseWriter, r *http.Request, user *lib.User) {
 	id := lib.GetQueryParam(r, "id", "")
 	if id == "" {
-		lib.ErrorResponse(w, "Transaction ID required", http.StatusBadRequest, nil)
+		lib.WriteError(w, lib.BadRequestError("Transaction ID required", nil))
+		return
+	}
+
+	transaction := getTransaction(r, user, id)
+	if transaction == nil {
+		lib.WriteError(w, lib.NotFoundError("Transaction not found"))
 		return
 	}
 
-	// TODO: Delete from database
+	// Deleted transactions go to the trash and can be restored until purged
+	item := lib.TrashTransactionItem(*transaction, time.Now().UTC())
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditDelete, Resource: "transaction", ResourceID: id, Before: *transaction, After: *item.Transaction}) {
+		return
+	}
+	if err := lib.Trash.Put(r.Context(), item); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to delete transaction", err))
+		return
+	}
+	// TODO: Set deleted_at in database
+	lib.PurgeExpiredTrash(r.Context(), item.DeletedAt)
 
-	lib.SuccessResponse(w, map[string]interface{}{
-		"message": "Transaction deleted successfully",
-		"id":      id,
+	lib.SuccessResponse(w, lib.TrashedResponse{
+		Message: "Transaction moved to trash",
+		Item:    item,
 	}, http.StatusOK)
 }
 
+// getTransaction returns a transaction, or nil when it does not exist or is in the trash
+func getTransaction(r *http.Request, user *lib.User, id string) *lib.Transaction {
+	if _, err := lib.Trash.Get(r.Context(), user.ID, lib.WorkspaceIDFromContext(r), lib.TrashTransaction, id); err == nil {
+		return nil
+	}
+
+	// TODO: Query database
+	return &lib.Transaction{
+		ID:          id,
+		UserID:      user.ID,
+		WorkspaceID: lib.WorkspaceIDFromContext(r),
+		Amount:      100.50,
+		Category:    "Groceries",
+		Type:        "expense",
+		Date:        time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+		CreatedAt:   time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+		UpdatedAt:   time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+// parseTransactionDate parses an already validated YYYY-MM-DD date or RFC 3339
+// timestamp, defaulting to now when empty
+func parseTransactionDate(value string) time.Time {
+	if value == "" {
+		return time.Now().UTC()
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date
+	}
+	date, _ := time.Parse(time.RFC3339, value)
+	return date
+}
+
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// Trash handles listing, restoring and purging deleted transactions and budgets
+func Trash(w http.ResponseWriter, r *http.Request) {
+	config := lib.Config{
+		RequireAuth:     true,
+		AllowedMethods:  []string{"GET", "POST", "DELETE"},
+		CORS:            lib.DefaultCORS().AllowHeaders("Idempotency-Key").ExposeHeaders("Idempotent-Replayed"),
+		WorkspaceScoped: true,
+		Idempotent:      true,
+		RateLimit:       lib.APIRateLimit(),
+	}
+
+	handler := lib.CreateHandler(trashHandler, config)
+	handler(w, r)
+}
+
+func trashHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := lib.GetUserFromContext(r)
+	if !ok {
+		lib.WriteError(w, lib.UnauthorizedError())
+		return
+	}
+
+	// Items past the retention window are purged before anything is read
+	if _, err := lib.PurgeExpiredTrash(r.Context(), time.Now().UTC()); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to purge expired items", err))
+		return
+	}
+
+	workspaceID := lib.WorkspaceIDFromContext(r)
+
+	switch r.Method {
+	case "GET":
+		handleListTrash(w, r, user, workspaceID)
+	case "POST":
+		if action := lib.GetQueryParam(r, "action", ""); action != "restore" {
+			lib.WriteError(w, lib.BadRequestError("Invalid action", map[string]interface{}{
+				"allowed": []string{"restore"},
+			}))
+			return
+		}
+		handleRestoreItem(w, r, user, workspaceID)
+	case "DELETE":
+		handlePurgeItem(w, r, user, workspaceID)
+	default:
+		lib.WriteError(w, lib.MethodNotAllowedError(nil))
+	}
+}
+
+func handleListTrash(w http.ResponseWriter, r *http.Request, user *lib.User, workspaceID string) {
+	var params lib.TrashListParams
+	if !lib.BindQuery(w, r, &params) {
+		return
+	}
+
+	items, err := lib.Trash.List(r.Context(), user.ID, workspaceID)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to list trash", err))
+		return
+	}
+
+	filtered := []lib.TrashedItem{}
+	for _, item := range items {
+		if params.Type == "" || item.Type == params.Type {
+			filtered = append(filtered, item)
+		}
+	}
+
+	lib.SuccessResponse(w, lib.TrashListResponse{
+		Items:         filtered,
+		RetentionDays: lib.TrashRetentionDays(),
+	}, http.StatusOK)
+}
+
+func handleRestoreItem(w http.ResponseWriter, r *http.Request, user *lib.User, workspaceID string) {
+	item, ok := findTrashedItem(w, r, user, workspaceID)
+	if !ok {
+		return
+	}
+
+	response := lib.RestoreResponse{
+		Message: "Item restored",
+		Type:    item.Type,
+	}
+	event := lib.AuditEvent{Action: lib.AuditRestore, Resource: item.Type, ResourceID: item.ID}
+	switch item.Type {
+	case lib.TrashTransaction:
+		event.Before = *item.Transaction
+		restored := *item.Transaction
+		restored.DeletedAt = nil
+		response.Transaction = &restored
+		event.After = restored
+	case lib.TrashBudget:
+		event.Before = *item.Budget
+		restored := *item.Budget
+		restored.DeletedAt = nil
+		response.Budget = &restored
+		event.After = restored
+	}
+
+	if !lib.Audit(w, r, event) {
+		return
+	}
+
+	if err := lib.Trash.Remove(r.Context(), user.ID, workspaceID, item.Type, item.ID); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to restore item", err))
+		return
+	}
+	// TODO: Clear deleted_at in database
+
+	lib.SuccessResponse(w, response, http.StatusOK)
+}
+
+func handlePurgeItem(w http.ResponseWriter, r *http.Request, user *lib.User, workspaceID string) {
+	item, ok := findTrashedItem(w, r, user, workspaceID)
+	if !ok {
+		return
+	}
+
+	event := lib.AuditEvent{Action: lib.AuditDelete, Resource: item.Type, ResourceID: item.ID}
+	switch item.Type {
+	case lib.TrashTransaction:
+		event.Before = *item.Transaction
+	case lib.TrashBudget:
+		event.Before = *item.Budget
+	}
+	if !lib.Audit(w, r, event) {
+		return
+	}
+
+	if err := lib.Trash.Remove(r.Context(), user.ID, workspaceID, item.Type, item.ID); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to delete item", err))
+		return
+	}
+	// TODO: Delete the row from database
+
+	lib.SuccessResponse(w, lib.DeleteResponse{
+		Message: "Item permanently deleted",
+		ID:      item.ID,
+	}, http.StatusOK)
+}
+
+// findTrashedItem looks up the item named by the type and id query
+// parameters in the caller's trash, writing an error response when it is
+// missing
+func findTrashedItem(w http.ResponseWriter, r *http.Request, user *lib.User, workspaceID string) (*lib.TrashedItem, bool) {
+	var params lib.TrashItemParams
+	if !lib.BindQuery(w, r, &params) {
+		return nil, false
+	}
+
+	item, err := lib.Trash.Get(r.Context(), user.ID, workspaceID, params.Type, params.ID)
+	if err != nil {
+		lib.WriteError(w, lib.NotFoundError("Item not found in trash"))
+		return nil, false
+	}
+	return item, true
+}
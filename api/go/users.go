@@ -2,16 +2,18 @@ package handler
 
 import (
 	"net/http"
+	"time"
 	
 	"github.com/budget-buddy/api/lib"
 )
 
-// Handler handles user profile operations
-func Handler(w http.ResponseWriter, r *http.Request) {
+// Users handles user profile operations
+func Users(w http.ResponseWriter, r *http.Request) {
 	config := lib.Config{
 		RequireAuth:    true,
 		AllowedMethods: []string{"GET", "PUT", "DELETE"},
-		EnableCORS:     true,
+		CORS:           lib.DefaultCORS(),
+		RateLimit:      lib.APIRateLimit(),
 	}
 
 	handler := lib.CreateHandler(userHandler, config)
@@ -21,7 +23,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 func userHandler(w http.ResponseWriter, r *http.Request) {
 	user, ok := lib.GetUserFromContext(r)
 	if !ok {
-		lib.ErrorResponse(w, "Unauthorized", http.StatusUnauthorized, nil)
+		lib.WriteError(w, lib.UnauthorizedError())
 		return
 	}
 
@@ -33,67 +35,90 @@ func userHandler(w http.ResponseWriter, r *http.Request) {
 	case "DELETE":
 		handleDeleteAccount(w, r, user)
 	default:
-		lib.ErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed, nil)
+		lib.WriteError(w, lib.MethodNotAllowedError(nil))
 	}
 }
 
 func handleGetProfile(w http.ResponseWriter, user *lib.User) {
-	// TODO: Query database
-	profile := map[string]interface{}{
-		"id":                  user.ID,
-		"email":               user.Email,
-		"full_name":           "John Doe",
-		"preferred_currency":  "USD",
-		"timezone":            "America/New_York",
-		"preferred_language":  "en",
-		"theme_preference":    "dark",
-		"created_at":          "2024-01-01T00:00:00Z",
-	}
-
-	lib.SuccessResponse(w, map[string]interface{}{
-		"profile": profile,
+	lib.SuccessResponse(w, lib.ProfileResponse{
+		Profile: getProfile(user),
 	}, http.StatusOK)
 }
 
 func handleUpdateProfile(w http.ResponseWriter, r *http.Request, user *lib.User) {
 	var input lib.UpdateProfileInput
-	if err := lib.ParseJSONBody(r, &input); err != nil {
-		lib.ErrorResponse(w, "Invalid JSON body", http.StatusBadRequest, nil)
+	if !lib.BindJSON(w, r, &input) {
 		return
 	}
 
+	profile := getProfile(user)
+	before := profile
+	if input.FullName != "" {
+		profile.FullName = input.FullName
+	}
+	if input.PreferredCurrency != "" {
+		profile.PreferredCurrency = input.PreferredCurrency
+	}
+	if input.Timezone != "" {
+		profile.Timezone = input.Timezone
+	}
+	if input.PreferredLanguage != "" {
+		profile.PreferredLanguage = input.PreferredLanguage
+	}
+	if input.NotificationSettings != nil {
+		profile.NotificationSettings = input.NotificationSettings
+	}
+	if input.ThemePreference != "" {
+		profile.ThemePreference = input.ThemePreference
+	}
+	profile.UpdatedAt = time.Now().UTC()
+
 	// TODO: Update in database
-	profile := map[string]interface{}{
-		"id":      user.ID,
-		"updated": true,
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditUpdate, Resource: "profile", ResourceID: user.ID, Before: before, After: profile}) {
+		return
 	}
 
-	lib.SuccessResponse(w, map[string]interface{}{
-		"profile": profile,
-		"message": "Profile updated successfully",
+	lib.SuccessResponse(w, lib.ProfileResponse{
+		Profile: profile,
+		Message: "Profile updated successfully",
 	}, http.StatusOK)
 }
 
 func handleDeleteAccount(w http.ResponseWriter, r *http.Request, user *lib.User) {
-	var input map[string]interface{}
-	if err := lib.ParseJSONBody(r, &input); err != nil {
-		lib.ErrorResponse(w, "Invalid JSON body", http.StatusBadRequest, nil)
+	var input lib.DeleteAccountInput
+	if !lib.BindJSON(w, r, &input) {
 		return
 	}
 
 	// Check confirmation
-	confirm, ok := input["confirm"].(bool)
-	if !ok || !confirm {
-		lib.ErrorResponse(w, "Account deletion requires confirmation", http.StatusBadRequest, map[string]interface{}{
+	if !input.Confirm {
+		lib.WriteError(w, lib.BadRequestError("Account deletion requires confirmation", map[string]interface{}{
 			"hint": "Set 'confirm': true in request body",
-		})
+		}))
 		return
 	}
 
 	// TODO: Delete user data and account
+	// The audit log outlives the account so the deletion itself stays traceable
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditDelete, Resource: "account", ResourceID: user.ID, Before: getProfile(user)}) {
+		return
+	}
 
-	lib.SuccessResponse(w, map[string]interface{}{
-		"message": "Account deleted successfully",
+	lib.SuccessResponse(w, lib.MessageResponse{
+		Message: "Account deleted successfully",
 	}, http.StatusOK)
 }
 
+func getProfile(user *lib.User) lib.UserProfile {
+	// TODO: Query database
+	return lib.UserProfile{
+		ID:                user.ID,
+		Email:             user.Email,
+		FullName:          "John Doe",
+		PreferredCurrency: "USD",
+		Timezone:          "America/New_York",
+		PreferredLanguage: "en",
+		ThemePreference:   "dark",
+		CreatedAt:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
@@ -0,0 +1,443 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/budget-buddy/api/lib"
+)
+
+// Workspaces handles shared household workspaces, members and invitations
+func Workspaces(w http.ResponseWriter, r *http.Request) {
+	config := lib.Config{
+		RequireAuth:    true,
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+		CORS:           lib.DefaultCORS().AllowHeaders("Idempotency-Key").ExposeHeaders("Idempotent-Replayed"),
+		Idempotent:     true,
+		RateLimit:      lib.APIRateLimit(),
+	}
+
+	handler := lib.CreateHandler(workspaceHandler, config)
+	handler(w, r)
+}
+
+func workspaceHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := lib.GetUserFromContext(r)
+	if !ok {
+		lib.WriteError(w, lib.UnauthorizedError())
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		handleGetWorkspaces(w, r, user)
+	case "POST":
+		switch lib.GetQueryParam(r, "action", "create") {
+		case "create":
+			handleCreateWorkspace(w, r, user)
+		case "invite":
+			handleInviteMember(w, r, user)
+		case "accept":
+			handleAcceptInvitation(w, r, user)
+		default:
+			lib.WriteError(w, lib.BadRequestError("Invalid action", map[string]interface{}{
+				"allowed": []string{"create", "invite", "accept"},
+			}))
+		}
+	case "PUT":
+		handleUpdateMember(w, r, user)
+	case "DELETE":
+		if lib.GetQueryParam(r, "user_id", "") != "" {
+			handleRemoveMember(w, r, user)
+			return
+		}
+		handleDeleteWorkspace(w, r, user)
+	default:
+		lib.WriteError(w, lib.MethodNotAllowedError(nil))
+	}
+}
+
+func handleGetWorkspaces(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	id := lib.GetQueryParam(r, "id", "")
+	if id == "" {
+		workspaces, err := lib.Workspaces.ListWorkspaces(r.Context(), user.ID)
+		if err != nil {
+			lib.WriteError(w, lib.InternalError("Failed to list workspaces", err))
+			return
+		}
+		lib.SuccessResponse(w, lib.WorkspaceListResponse{
+			Workspaces: workspaces,
+		}, http.StatusOK)
+		return
+	}
+
+	member, ok := requireWorkspaceRole(w, r, id, user, lib.PermissionRead)
+	if !ok {
+		return
+	}
+
+	workspace, err := lib.Workspaces.GetWorkspace(r.Context(), id)
+	if err != nil {
+		lib.WriteError(w, storeError(err, "Workspace not found"))
+		return
+	}
+
+	members, err := lib.Workspaces.ListMembers(r.Context(), id)
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to list members", err))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.WorkspaceDetailResponse{
+		Workspace: workspace,
+		Members:   members,
+		Role:      member.Role,
+	}, http.StatusOK)
+}
+
+func handleCreateWorkspace(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var input lib.CreateWorkspaceInput
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+
+	now := time.Now().UTC()
+	workspace := lib.Workspace{
+		ID:        lib.NewID("workspace"),
+		Name:      input.Name,
+		OwnerID:   user.ID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	owner := lib.WorkspaceMember{
+		WorkspaceID: workspace.ID,
+		UserID:      user.ID,
+		Email:       user.Email,
+		Role:        lib.RoleOwner,
+		JoinedAt:    now,
+	}
+
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditCreate, Resource: "workspace", ResourceID: workspace.ID, After: workspace}) {
+		return
+	}
+
+	if err := lib.Workspaces.CreateWorkspace(r.Context(), workspace, owner); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to create workspace", err))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.WorkspaceResponse{
+		Workspace: workspace,
+	}, http.StatusCreated)
+}
+
+func handleInviteMember(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	id := lib.GetQueryParam(r, "id", "")
+	if id == "" {
+		lib.WriteError(w, lib.BadRequestError("Workspace ID required", nil))
+		return
+	}
+
+	if _, ok := requireWorkspaceRole(w, r, id, user, lib.PermissionManage); !ok {
+		return
+	}
+
+	var input lib.InviteMemberInput
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+	if input.Role == "" {
+		input.Role = lib.RoleEditor
+	}
+
+	token, tokenHash, err := lib.NewInvitationToken()
+	if err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to create invitation", err))
+		return
+	}
+
+	now := time.Now().UTC()
+	invitation := lib.WorkspaceInvitation{
+		ID:          lib.NewID("invitation"),
+		WorkspaceID: id,
+		Email:       input.Email,
+		Role:        input.Role,
+		TokenHash:   tokenHash,
+		InvitedBy:   user.ID,
+		ExpiresAt:   now.Add(lib.InvitationTTL),
+		CreatedAt:   now,
+	}
+	// The invitation token is returned once and never written to the audit log
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditCreate, Resource: "invitation", ResourceID: invitation.ID, After: invitation}) {
+		return
+	}
+
+	if err := lib.Workspaces.SaveInvitation(r.Context(), invitation); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to create invitation", err))
+		return
+	}
+
+	// TODO: Email the invitation link instead of returning the token to the inviter
+	lib.SuccessResponse(w, lib.InvitationResponse{
+		Invitation: invitation,
+		Token:      token,
+		AcceptURL:  lib.GetEnv("APP_URL", "") + "/workspaces/accept?token=" + token,
+	}, http.StatusCreated)
+}
+
+func handleAcceptInvitation(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	var input lib.AcceptInvitationInput
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	invitation, err := lib.Workspaces.GetInvitationByTokenHash(r.Context(), lib.HashInvitationToken(input.Token))
+	if err != nil {
+		lib.WriteError(w, storeError(err, "Invitation not found"))
+		return
+	}
+
+	now := time.Now().UTC()
+	if !invitation.AcceptedAt.IsZero() {
+		lib.WriteError(w, lib.ConflictError("Invitation has already been accepted", nil))
+		return
+	}
+
+	if now.After(invitation.ExpiresAt) {
+		lib.WriteError(w, lib.NewError(http.StatusGone, lib.CodeGone, "Invitation has expired", nil))
+		return
+	}
+
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		lib.WriteError(w, lib.ForbiddenError("Invitation was sent to a different email address", nil))
+		return
+	}
+
+	// Accepting never downgrades an existing member's role
+	member := lib.WorkspaceMember{
+		WorkspaceID: invitation.WorkspaceID,
+		UserID:      user.ID,
+		Email:       user.Email,
+		Role:        invitation.Role,
+		JoinedAt:    now,
+	}
+	existing, err := lib.Workspaces.GetMember(r.Context(), invitation.WorkspaceID, user.ID)
+	switch {
+	case err == nil:
+		member = *existing
+	case !errors.Is(err, lib.ErrNotFound):
+		// Not knowing whether the user is a member must not re-add them
+		lib.WriteError(w, lib.InternalError("Failed to accept invitation", err))
+		return
+	}
+	joined := existing == nil
+
+	before := *invitation
+	invitation.AcceptedAt = now
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditUpdate, Resource: "invitation", ResourceID: invitation.ID, Before: before, After: *invitation}) {
+		return
+	}
+	if joined && !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditCreate, Resource: "member", ResourceID: member.UserID, After: member}) {
+		return
+	}
+
+	if joined {
+		if err := lib.Workspaces.SaveMember(r.Context(), member); err != nil {
+			lib.WriteError(w, storeError(err, "Workspace not found"))
+			return
+		}
+	}
+	if err := lib.Workspaces.SaveInvitation(r.Context(), *invitation); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to accept invitation", err))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.MemberResponse{
+		Member: member,
+	}, http.StatusOK)
+}
+
+func handleUpdateMember(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	id := lib.GetQueryParam(r, "id", "")
+	memberID := lib.GetQueryParam(r, "user_id", "")
+	if id == "" || memberID == "" {
+		lib.WriteError(w, lib.BadRequestError("Workspace ID and user ID required", nil))
+		return
+	}
+
+	if _, ok := requireWorkspaceRole(w, r, id, user, lib.PermissionManage); !ok {
+		return
+	}
+
+	var input lib.UpdateMemberInput
+	if !lib.BindJSON(w, r, &input) {
+		return
+	}
+
+	member, err := lib.Workspaces.GetMember(r.Context(), id, memberID)
+	if err != nil {
+		lib.WriteError(w, storeError(err, "Member not found"))
+		return
+	}
+
+	if member.Role == lib.RoleOwner && input.Role != lib.RoleOwner {
+		last, err := isLastOwner(r.Context(), id, memberID)
+		if err != nil {
+			lib.WriteError(w, lib.InternalError("Failed to update member", err))
+			return
+		}
+		if last {
+			lib.WriteError(w, lib.ConflictError("A workspace must keep at least one owner", nil))
+			return
+		}
+	}
+
+	before := *member
+	member.Role = input.Role
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditUpdate, Resource: "member", ResourceID: memberID, Before: before, After: *member}) {
+		return
+	}
+
+	if err := lib.Workspaces.SaveMember(r.Context(), *member); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to update member", err))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.MemberResponse{
+		Member: *member,
+	}, http.StatusOK)
+}
+
+func handleRemoveMember(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	id := lib.GetQueryParam(r, "id", "")
+	memberID := lib.GetQueryParam(r, "user_id", "")
+	if id == "" {
+		lib.WriteError(w, lib.BadRequestError("Workspace ID required", nil))
+		return
+	}
+
+	// Members may always leave; removing someone else needs the owner role
+	permission := lib.PermissionManage
+	if memberID == user.ID {
+		permission = lib.PermissionRead
+	}
+	if _, ok := requireWorkspaceRole(w, r, id, user, permission); !ok {
+		return
+	}
+
+	member, err := lib.Workspaces.GetMember(r.Context(), id, memberID)
+	if err != nil {
+		lib.WriteError(w, storeError(err, "Member not found"))
+		return
+	}
+
+	if member.Role == lib.RoleOwner {
+		last, err := isLastOwner(r.Context(), id, memberID)
+		if err != nil {
+			lib.WriteError(w, lib.InternalError("Failed to remove member", err))
+			return
+		}
+		if last {
+			lib.WriteError(w, lib.ConflictError("A workspace must keep at least one owner", map[string]string{
+				"hint": "Transfer ownership or delete the workspace instead",
+			}))
+			return
+		}
+	}
+
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditDelete, Resource: "member", ResourceID: memberID, Before: *member}) {
+		return
+	}
+
+	if err := lib.Workspaces.RemoveMember(r.Context(), id, memberID); err != nil {
+		lib.WriteError(w, lib.InternalError("Failed to remove member", err))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.RemoveMemberResponse{
+		Message: "Member removed successfully",
+		UserID:  memberID,
+	}, http.StatusOK)
+}
+
+func handleDeleteWorkspace(w http.ResponseWriter, r *http.Request, user *lib.User) {
+	id := lib.GetQueryParam(r, "id", "")
+	if id == "" {
+		lib.WriteError(w, lib.BadRequestError("Workspace ID required", nil))
+		return
+	}
+
+	if _, ok := requireWorkspaceRole(w, r, id, user, lib.PermissionManage); !ok {
+		return
+	}
+
+	workspace, err := lib.Workspaces.GetWorkspace(r.Context(), id)
+	if err != nil {
+		lib.WriteError(w, storeError(err, "Workspace not found"))
+		return
+	}
+
+	// TODO: Detach or delete the workspace's shared budgets and transactions
+	if !lib.Audit(w, r, lib.AuditEvent{Action: lib.AuditDelete, Resource: "workspace", ResourceID: id, Before: *workspace}) {
+		return
+	}
+
+	if err := lib.Workspaces.DeleteWorkspace(r.Context(), id); err != nil {
+		lib.WriteError(w, storeError(err, "Workspace not found"))
+		return
+	}
+
+	lib.SuccessResponse(w, lib.DeleteResponse{
+		Message: "Workspace deleted successfully",
+		ID:      id,
+	}, http.StatusOK)
+}
+
+// requireWorkspaceRole checks the user is a member with the given permission,
+// writing an error response when not
+func requireWorkspaceRole(w http.ResponseWriter, r *http.Request, workspaceID string, user *lib.User, permission lib.Permission) (*lib.WorkspaceMember, bool) {
+	member, err := lib.Workspaces.GetMember(r.Context(), workspaceID, user.ID)
+	if err != nil {
+		lib.WriteError(w, storeError(err, "Workspace not found"))
+		return nil, false
+	}
+
+	if !lib.RoleAllows(member.Role, permission) {
+		lib.WriteError(w, lib.ForbiddenError("Forbidden", map[string]string{
+			"role": member.Role,
+		}))
+		return nil, false
+	}
+
+	return member, true
+}
+
+func isLastOwner(ctx context.Context, workspaceID, userID string) (bool, error) {
+	members, err := lib.Workspaces.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range members {
+		if m.Role == lib.RoleOwner && m.UserID != userID {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// storeError is the response to a failed workspace store call: 404 with
+// message when the record does not exist, 500 for any other error, such as an
+// unreachable backend
+func storeError(err error, message string) *lib.APIError {
+	if errors.Is(err, lib.ErrNotFound) {
+		return lib.NotFoundError(message)
+	}
+	return lib.InternalError("Workspace store unavailable", err)
+}
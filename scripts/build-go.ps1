@@ -46,16 +46,31 @@ $functions = @(
     "transactions",
     "budgets",
     "analytics",
-    "users"
+    "users",
+    "debts",
+    "envelopes",
+    "workspaces",
+    "splits",
+    "trash",
+    "audit",
+    "metrics"
 )
 
 $buildDir = "../../.vercel/output/functions"
 New-Item -ItemType Directory -Path $buildDir -Force | Out-Null
 
+# Build info reported by the health endpoint
+$gitSha = (git rev-parse HEAD 2>$null)
+$buildTime = (Get-Date).ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ")
+$ldflags = "-X github.com/budget-buddy/api/lib.BuildCommit=$gitSha -X github.com/budget-buddy/api/lib.BuildTime=$buildTime"
+if ($env:BUILD_VERSION) {
+    $ldflags = "$ldflags -X github.com/budget-buddy/api/lib.BuildVersion=$($env:BUILD_VERSION)"
+}
+
 foreach ($func in $functions) {
     Write-Host "  Building $func.go..." -ForegroundColor Yellow
     
-    go build -o "$buildDir/$func" "$func.go"
+    go build -ldflags $ldflags -o "$buildDir/$func" "$func.go"
     
     if ($LASTEXITCODE -eq 0) {
         Write-Host "  ✅ $func built successfully" -ForegroundColor Green
@@ -47,14 +47,29 @@ FUNCTIONS=(
     "budgets"
     "analytics"
     "users"
+    "debts"
+    "envelopes"
+    "workspaces"
+    "splits"
+    "trash"
+    "audit"
+    "metrics"
 )
 
 BUILD_DIR="../../.vercel/output/functions"
 mkdir -p "$BUILD_DIR"
 
+# Build info reported by the health endpoint
+GIT_SHA=$(git rev-parse HEAD 2>/dev/null || echo "")
+BUILD_TIME=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+LDFLAGS="-X github.com/budget-buddy/api/lib.BuildCommit=$GIT_SHA -X github.com/budget-buddy/api/lib.BuildTime=$BUILD_TIME"
+if [ -n "$BUILD_VERSION" ]; then
+    LDFLAGS="$LDFLAGS -X github.com/budget-buddy/api/lib.BuildVersion=$BUILD_VERSION"
+fi
+
 for func in "${FUNCTIONS[@]}"; do
     echo "  Building $func.go..."
-    go build -o "$BUILD_DIR/$func" "$func.go"
+    go build -ldflags "$LDFLAGS" -o "$BUILD_DIR/$func" "$func.go"
     if [ $? -eq 0 ]; then
         echo "  ✅ $func built successfully"
     else